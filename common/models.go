@@ -6,8 +6,15 @@ type ErrorResponse struct {
 	Message string `json:"message"`
 }
 
+// Роли пользователей
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	Username string `json:"username"`
 	ID       int    `json:"id"`
 	Password string `json:"password"`
+	Role     string `json:"role"`
 }
@@ -1,13 +1,17 @@
 CREATE TABLE IF NOT EXISTS users (
     id SERIAL PRIMARY KEY,
     username VARCHAR(50) NOT NULL UNIQUE,
-    password VARCHAR(150) NOT NULL
+    password VARCHAR(150) NOT NULL,
+    role VARCHAR(20) NOT NULL DEFAULT 'user' -- "user" или "admin", администратора назначают вручную через UPDATE
 );
 
+-- Для баз, созданных до появления ролей
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'user';
+
 
 CREATE TABLE IF NOT EXISTS scan_results (
     id SERIAL PRIMARY KEY,
-    input_type VARCHAR(10) NOT NULL, -- "ip", "domain", "url"
+    input_type VARCHAR(10) NOT NULL, -- "ip", "domain", "url", "hash"
     request TEXT NOT NULL,
     response JSONB NOT NULL,
     access_count INT DEFAULT 0,
@@ -19,13 +23,185 @@ CREATE INDEX IF NOT EXISTS idx_scan_results_request ON scan_results (input_type,
 CREATE INDEX IF NOT EXISTS idx_scan_results_created_at ON scan_results (created_at);
 CREATE INDEX IF NOT EXISTS idx_scan_results_access_count ON scan_results (access_count);
 
-CREATE TABLE IF NOT EXISTS user_scan_stats (
-    id SERIAL PRIMARY KEY,
-    user_id INT REFERENCES users(id),
+-- История вердиктов: scan_results хранит только последний ответ, здесь остаётся каждая смена
+-- зоны или категорий индикатора. Записи только добавляются
+CREATE TABLE IF NOT EXISTS verdict_history (
+    id BIGSERIAL PRIMARY KEY,
     input_type VARCHAR(10) NOT NULL,
     request TEXT NOT NULL,
-    zone VARCHAR(10) NOT NULL, -- "Red", "Green", etc.
-    access_count INT DEFAULT 0,
-    last_accessed TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
+    zone VARCHAR(10) NOT NULL,
+    categories TEXT[] NOT NULL DEFAULT '{}', -- отсортированы по алфавиту
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_verdict_history_request ON verdict_history (input_type, request, id DESC);
+
+-- Журнал проверок: каждая проверка отдельным событием, из него строятся история пользователя и статистика.
+-- Таблица разбита на секции по дням (UTC). Секции заранее создаёт и по истечении срока удаляет шлюз,
+-- секция по умолчанию принимает события, для которых секция ещё не создана.
+-- Заменяет агрегированную таблицу user_scan_stats, которая хранила только счётчик и время последнего обращения
+CREATE TABLE IF NOT EXISTS scan_events (
+    id BIGSERIAL,
+    user_id INT REFERENCES users(id) ON DELETE SET NULL, -- NULL для неавторизованного пользователя
+    input_type VARCHAR(10) NOT NULL,
+    request TEXT NOT NULL,
+    zone VARCHAR(10) NOT NULL,
+    source VARCHAR(10) NOT NULL, -- "uri", "file", "screen", "batch", "watchlist"
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (id, created_at)
+) PARTITION BY RANGE (created_at);
+
+CREATE TABLE IF NOT EXISTS scan_events_default PARTITION OF scan_events DEFAULT;
+
+CREATE INDEX IF NOT EXISTS idx_scan_events_user_id ON scan_events (user_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_scan_events_zone_created_at ON scan_events (zone, created_at);
+
+-- Поиск подстроки в истории через ILIKE
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS idx_scan_events_request_trgm ON scan_events USING GIN (request gin_trgm_ops);
+
+-- Дневные итоги по журналу проверок, хранятся дольше самих событий
+CREATE TABLE IF NOT EXISTS scan_event_rollups (
+    day DATE NOT NULL,
+    user_id INT NOT NULL DEFAULT 0, -- 0 для неавторизованного пользователя
+    input_type VARCHAR(10) NOT NULL,
+    request TEXT NOT NULL,
+    zone VARCHAR(10) NOT NULL,
+    scan_count BIGINT NOT NULL,
+    backfilled BOOLEAN NOT NULL DEFAULT FALSE, -- перенесено из user_scan_stats, по событиям не пересчитывается
+    PRIMARY KEY (day, user_id, input_type, request, zone)
+);
+
+CREATE INDEX IF NOT EXISTS idx_scan_event_rollups_zone ON scan_event_rollups (zone, day);
+
+-- Перенос user_scan_stats в дневные итоги. Старая таблица хранила только счётчик и время последнего
+-- обращения, поэтому все обращения записываются на день последнего обращения. Этот день сдвигается
+-- раньше первого события журнала (и раньше сегодняшнего дня по UTC), чтобы перенесённые итоги
+-- не пересекались с итогами, которые шлюз пересчитывает по событиям
+DO $$
+BEGIN
+    IF to_regclass('user_scan_stats') IS NOT NULL THEN
+        INSERT INTO scan_event_rollups (day, user_id, input_type, request, zone, scan_count, backfilled)
+        SELECT LEAST(
+                   COALESCE(last_accessed, NOW())::date,
+                   (SELECT COALESCE(MIN(created_at), NOW()) AT TIME ZONE 'UTC' FROM scan_events)::date - 1
+               ),
+               COALESCE(user_id, 0), input_type, request, zone, SUM(access_count), TRUE
+        FROM user_scan_stats
+        WHERE access_count > 0
+        GROUP BY 1, 2, 3, 4, 5
+        ON CONFLICT (day, user_id, input_type, request, zone)
+            DO UPDATE SET scan_count = scan_event_rollups.scan_count + EXCLUDED.scan_count;
+
+        DROP TABLE user_scan_stats;
+    END IF;
+END $$;
+
+-- Карантинное хранилище: метаданные образцов, само содержимое лежит зашифрованным в каталоге или S3
+CREATE TABLE IF NOT EXISTS quarantine_samples (
+    sha256 VARCHAR(64) PRIMARY KEY,
+    filename TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    zone VARCHAR(10) NOT NULL,
+    file_type VARCHAR(20) NOT NULL DEFAULT '',
+    user_id INT REFERENCES users(id) ON DELETE SET NULL, -- NULL для неавторизованного пользователя
+    submissions INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    last_seen TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_quarantine_samples_expires_at ON quarantine_samples (expires_at);
+CREATE INDEX IF NOT EXISTS idx_quarantine_samples_last_seen ON quarantine_samples (last_seen);
+
+-- Журнал доступа к образцам, записи остаются и после удаления образца
+CREATE TABLE IF NOT EXISTS quarantine_audit (
+    id BIGSERIAL PRIMARY KEY,
+    sha256 VARCHAR(64) NOT NULL,
+    user_id INT REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(20) NOT NULL, -- "store", "download", "delete", "expire", "list", "audit"
+    remote_addr TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_quarantine_audit_sha256 ON quarantine_audit (sha256, id);
+
+-- Списки отслеживания: индикаторы, которые шлюз перепроверяет по расписанию в обход кэша
+CREATE TABLE IF NOT EXISTS watchlist (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    input_type VARCHAR(10) NOT NULL,
+    request TEXT NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    interval_minutes INT NOT NULL,
+    zone VARCHAR(10), -- NULL до первой проверки
+    categories TEXT[] NOT NULL DEFAULT '{}',
+    last_checked_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_check_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
     UNIQUE (user_id, input_type, request)
-);
\ No newline at end of file
+);
+
+CREATE INDEX IF NOT EXISTS idx_watchlist_next_check_at ON watchlist (next_check_at);
+CREATE INDEX IF NOT EXISTS idx_watchlist_request ON watchlist (input_type, request);
+
+-- Оповещения о смене зоны или категорий отслеживаемого индикатора
+CREATE TABLE IF NOT EXISTS watch_alerts (
+    id BIGSERIAL PRIMARY KEY,
+    watch_id BIGINT REFERENCES watchlist(id) ON DELETE SET NULL,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    input_type VARCHAR(10) NOT NULL,
+    request TEXT NOT NULL,
+    previous_zone VARCHAR(10) NOT NULL,
+    zone VARCHAR(10) NOT NULL,
+    previous_categories TEXT[] NOT NULL DEFAULT '{}',
+    categories TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_watch_alerts_user_id ON watch_alerts (user_id, id DESC);
+
+-- Подписки на события: user_id NULL - общая подписка администратора на события всех пользователей
+CREATE TABLE IF NOT EXISTS webhooks (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT REFERENCES users(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT[] NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_user_id ON webhooks (user_id);
+
+-- Очередь доставки: строка на каждую пару событие-подписка, удаляется после успешной доставки
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id BIGINT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_id VARCHAR(32) NOT NULL,
+    event VARCHAR(50) NOT NULL,
+    payload TEXT NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_error TEXT NOT NULL DEFAULT '',
+    last_status INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_next_attempt_at ON webhook_deliveries (next_attempt_at);
+
+-- События, которые не удалось доставить за все попытки
+CREATE TABLE IF NOT EXISTS webhook_dead_letters (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id BIGINT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_id VARCHAR(32) NOT NULL,
+    event VARCHAR(50) NOT NULL,
+    payload TEXT NOT NULL,
+    attempts INT NOT NULL,
+    last_error TEXT NOT NULL,
+    last_status INT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    failed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_dead_letters_webhook_id ON webhook_dead_letters (webhook_id, id DESC);
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"log"
 	"os"
+	"path/filepath"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -15,16 +16,26 @@ type Config struct {
 	Redis    RedisConfig    `yaml:"redis"`
 }
 type GatewayConfig struct {
-	Address           string        `yaml:"address"`
-	Timeout           time.Duration `yaml:"timeout"`
-	IdleTimeout       time.Duration `yaml:"idle_timeout"`
-	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
-	KasperskyAPIKey   string        `yaml:"kaspersky_api_key"`
-	IamToken          string        `yaml:"iam_token"`
-	FolderID          string        `yaml:"folder_id"`
-	LogFormat         string        `yaml:"log_format"`
-	LogFile           string        `yaml:"log_file"`
-	SessionConfig     SessionConfig `yaml:"session"`
+	Address           string             `yaml:"address"`
+	Timeout           time.Duration      `yaml:"timeout"`
+	IdleTimeout       time.Duration      `yaml:"idle_timeout"`
+	ReadHeaderTimeout time.Duration      `yaml:"read_header_timeout"`
+	KasperskyAPIKey   string             `yaml:"kaspersky_api_key"`
+	IamToken          string             `yaml:"iam_token"`
+	SAKeyFile         string             `yaml:"service_account_key_file"`
+	FolderID          string             `yaml:"folder_id"`
+	LogFormat         string             `yaml:"log_format"`
+	LogFile           string             `yaml:"log_file"`
+	SessionConfig     SessionConfig      `yaml:"session"`
+	OCR               OCRConfig          `yaml:"ocr"`
+	UploadPolicy      UploadPolicyConfig `yaml:"upload_policy"`
+	Rules             RulesConfig        `yaml:"rules"`
+	Uploads           UploadsConfig      `yaml:"uploads"`
+	Quarantine        QuarantineConfig   `yaml:"quarantine"`
+	ScanLog           ScanLogConfig      `yaml:"scan_log"`
+	Watchlist         WatchlistConfig    `yaml:"watchlist"`
+	Webhooks          WebhooksConfig     `yaml:"webhooks"`
+	ScanRefresh       ScanRefreshConfig  `yaml:"scan_refresh"`
 }
 type PostgresConfig struct {
 	Host     string `yaml:"host"`
@@ -41,6 +52,82 @@ type RedisConfig struct {
 	DB       int    `yaml:"db"`
 }
 
+type OCRConfig struct {
+	Engine        string `yaml:"engine"`         // yandex, tesseract (без PDF) или fixture
+	TesseractPath string `yaml:"tesseract_path"` // путь к исполняемому файлу tesseract
+	Languages     string `yaml:"languages"`      // языки tesseract, например eng+rus
+	FixtureDir    string `yaml:"fixture_dir"`    // каталог с сохранёнными ответами OCR для движка fixture
+}
+
+type UploadPolicyConfig struct {
+	Allowed   []string         `yaml:"allowed"`     // разрешённые типы файлов, пустой список разрешает все
+	Denied    []string         `yaml:"denied"`      // запрещённые типы файлов, имеют приоритет над разрешёнными
+	MaxSizeMB map[string]int64 `yaml:"max_size_mb"` // ограничение размера в мегабайтах по типу файла
+}
+
+type RulesConfig struct {
+	Dir            string        `yaml:"dir"`             // каталог с файлами правил *.yaml, пустой отключает правила
+	ReloadInterval time.Duration `yaml:"reload_interval"` // как часто проверять изменения в каталоге
+}
+
+type UploadsConfig struct {
+	Dir            string        `yaml:"dir"`               // каталог для частей файлов, загружаемых по протоколу tus
+	Expiry         time.Duration `yaml:"expiry"`            // через сколько после последней активности загрузка удаляется
+	MaxTotalSizeMB int           `yaml:"max_total_size_mb"` // сколько места могут занимать все незавершённые загрузки, 0 - без лимита
+}
+
+type QuarantineConfig struct {
+	Enabled         bool          `yaml:"enabled"`            // сохранять опасные образцы для аналитиков
+	Storage         string        `yaml:"storage"`            // local или s3
+	Dir             string        `yaml:"dir"`                // каталог для хранилища local
+	Key             string        `yaml:"key"`                // ключ шифрования AES-256 в base64 (32 байта)
+	Zones           []string      `yaml:"zones"`              // образцы с этими зонами сохраняются
+	Retention       time.Duration `yaml:"retention"`          // сколько хранится образец после последней загрузки
+	MaxSampleSizeMB int64         `yaml:"max_sample_size_mb"` // образцы больше этого размера не сохраняются
+	MaxTotalSizeMB  int64         `yaml:"max_total_size_mb"`  // общий лимит, сверх него удаляются давние образцы; 0 - без лимита
+	ZipPassword     string        `yaml:"zip_password"`       // пароль ZIP-архива при скачивании
+	S3              S3Config      `yaml:"s3"`
+}
+
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint"` // например http://minio:9000
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	Prefix    string `yaml:"prefix"` // префикс ключей внутри бакета
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+}
+
+type ScanLogConfig struct {
+	Retention           time.Duration `yaml:"retention"`            // сколько хранятся события проверок, не меньше 32 дней
+	RollupRetention     time.Duration `yaml:"rollup_retention"`     // сколько хранятся дневные итоги; 0 - бессрочно
+	MaintenanceInterval time.Duration `yaml:"maintenance_interval"` // как часто создаются секции, подводятся итоги и удаляются старые события
+}
+
+type WatchlistConfig struct {
+	DefaultInterval time.Duration `yaml:"default_interval"` // интервал проверки, если пользователь его не указал
+	MinInterval     time.Duration `yaml:"min_interval"`     // самый частый допустимый интервал проверки
+	MaxInterval     time.Duration `yaml:"max_interval"`     // самый редкий допустимый интервал проверки
+	MaxWatches      int           `yaml:"max_watches"`      // сколько индикаторов может отслеживать один пользователь
+	ChecksPerHour   int           `yaml:"checks_per_hour"`  // доля квоты Kaspersky API, которую тратит планировщик
+	QuotaBackoff    time.Duration `yaml:"quota_backoff"`    // пауза после ответа об исчерпанной квоте
+}
+
+type WebhooksConfig struct {
+	Timeout             time.Duration `yaml:"timeout"`               // таймаут одной попытки доставки
+	MaxAttempts         int           `yaml:"max_attempts"`          // после стольких неудачных попыток событие уходит в недоставленные
+	BaseBackoff         time.Duration `yaml:"base_backoff"`          // пауза перед второй попыткой, дальше удваивается
+	MaxBackoff          time.Duration `yaml:"max_backoff"`           // наибольшая пауза между попытками
+	Workers             int           `yaml:"workers"`               // сколько доставок выполняется одновременно
+	MaxPerUser          int           `yaml:"max_per_user"`          // сколько подписок может создать один пользователь
+	AllowPrivateTargets bool          `yaml:"allow_private_targets"` // разрешить общим подпискам адреса во внутренней сети
+}
+
+type ScanRefreshConfig struct {
+	Role    string `yaml:"role"`     // кому разрешена проверка в обход кэша: any, user или admin
+	PerHour int    `yaml:"per_hour"` // сколько таких проверок разрешено пользователю или IP-адресу за час
+}
+
 type SessionConfig struct {
 	CookieSecure       bool          `yaml:"cookie_secure"`
 	CSRFKey            string        `yaml:"csrf_key"`
@@ -59,6 +146,51 @@ func LoadConfig(filename string) (*Config, error) {
 			LogFormat:         "json",
 			FolderID:          "ajel4b7rb4q4525ph1am",
 			LogFile:           "", // По умолчанию пустой, значит логи будут только в консоль
+			OCR: OCRConfig{
+				Engine:        "yandex",
+				TesseractPath: "tesseract",
+				Languages:     "eng+rus",
+			},
+			Rules: RulesConfig{
+				ReloadInterval: 30 * time.Second,
+			},
+			Uploads: UploadsConfig{
+				Dir:            filepath.Join(os.TempDir(), "minions-uploads"),
+				Expiry:         24 * time.Hour,
+				MaxTotalSizeMB: 4096,
+			},
+			Quarantine: QuarantineConfig{
+				Storage:         "local",
+				Dir:             filepath.Join(os.TempDir(), "minions-quarantine"),
+				Zones:           []string{"Red", "Yellow"},
+				Retention:       30 * 24 * time.Hour,
+				MaxSampleSizeMB: 256,
+				ZipPassword:     "infected",
+			},
+			ScanLog: ScanLogConfig{
+				Retention:           90 * 24 * time.Hour,
+				MaintenanceInterval: time.Hour,
+			},
+			Watchlist: WatchlistConfig{
+				DefaultInterval: 24 * time.Hour,
+				MinInterval:     time.Hour,
+				MaxInterval:     30 * 24 * time.Hour,
+				MaxWatches:      100,
+				ChecksPerHour:   60,
+				QuotaBackoff:    time.Hour,
+			},
+			Webhooks: WebhooksConfig{
+				Timeout:     10 * time.Second,
+				MaxAttempts: 8,
+				BaseBackoff: 30 * time.Second,
+				MaxBackoff:  time.Hour,
+				Workers:     4,
+				MaxPerUser:  10,
+			},
+			ScanRefresh: ScanRefreshConfig{
+				Role:    "user",
+				PerHour: 20,
+			},
 		},
 		Postgres: PostgresConfig{
 			Host:     "localhost",
@@ -119,8 +251,143 @@ func LoadConfig(filename string) (*Config, error) {
 		}
 	}
 
-	// IAM_TOKEN API Key
-	if cfg.Gateway.IamToken == "" {
+	// Параметры OCR
+	if cfg.Gateway.OCR.Engine == "" {
+		cfg.Gateway.OCR.Engine = "yandex"
+	}
+	if cfg.Gateway.OCR.TesseractPath == "" {
+		cfg.Gateway.OCR.TesseractPath = "tesseract"
+	}
+	if cfg.Gateway.OCR.Languages == "" {
+		cfg.Gateway.OCR.Languages = "eng+rus"
+	}
+
+	// Параметры локальных правил
+	if cfg.Gateway.Rules.ReloadInterval == 0 {
+		cfg.Gateway.Rules.ReloadInterval = 30 * time.Second
+	}
+
+	// Параметры загрузки по частям
+	if cfg.Gateway.Uploads.Dir == "" {
+		cfg.Gateway.Uploads.Dir = filepath.Join(os.TempDir(), "minions-uploads")
+	}
+	if cfg.Gateway.Uploads.Expiry == 0 {
+		cfg.Gateway.Uploads.Expiry = 24 * time.Hour
+	}
+
+	// Параметры карантинного хранилища. Ключи лучше передавать через переменные окружения
+	if cfg.Gateway.Quarantine.Key == "" {
+		cfg.Gateway.Quarantine.Key = os.Getenv("QUARANTINE_KEY")
+	}
+	if cfg.Gateway.Quarantine.S3.AccessKey == "" {
+		cfg.Gateway.Quarantine.S3.AccessKey = os.Getenv("QUARANTINE_S3_ACCESS_KEY")
+	}
+	if cfg.Gateway.Quarantine.S3.SecretKey == "" {
+		cfg.Gateway.Quarantine.S3.SecretKey = os.Getenv("QUARANTINE_S3_SECRET_KEY")
+	}
+	if cfg.Gateway.Quarantine.Storage == "" {
+		cfg.Gateway.Quarantine.Storage = "local"
+	}
+	if cfg.Gateway.Quarantine.Dir == "" {
+		cfg.Gateway.Quarantine.Dir = filepath.Join(os.TempDir(), "minions-quarantine")
+	}
+	if len(cfg.Gateway.Quarantine.Zones) == 0 {
+		cfg.Gateway.Quarantine.Zones = []string{"Red", "Yellow"}
+	}
+	if cfg.Gateway.Quarantine.Retention == 0 {
+		cfg.Gateway.Quarantine.Retention = 30 * 24 * time.Hour
+	}
+	if cfg.Gateway.Quarantine.MaxSampleSizeMB == 0 {
+		cfg.Gateway.Quarantine.MaxSampleSizeMB = 256
+	}
+	if cfg.Gateway.Quarantine.ZipPassword == "" {
+		cfg.Gateway.Quarantine.ZipPassword = "infected"
+	}
+	if cfg.Gateway.Quarantine.Enabled && cfg.Gateway.Quarantine.Key == "" {
+		return nil, errors.New("quarantine key is not provided in config file or environment variable")
+	}
+
+	// Параметры журнала проверок. События нужны за весь самый длинный период статистики (месяц)
+	if cfg.Gateway.ScanLog.Retention == 0 {
+		cfg.Gateway.ScanLog.Retention = 90 * 24 * time.Hour
+	}
+	if cfg.Gateway.ScanLog.MaintenanceInterval == 0 {
+		cfg.Gateway.ScanLog.MaintenanceInterval = time.Hour
+	}
+	if cfg.Gateway.ScanLog.Retention < 32*24*time.Hour {
+		return nil, errors.New("scan log retention must be at least 32 days")
+	}
+	if cfg.Gateway.ScanLog.RollupRetention != 0 && cfg.Gateway.ScanLog.RollupRetention < cfg.Gateway.ScanLog.Retention {
+		return nil, errors.New("scan log rollup retention must not be shorter than event retention")
+	}
+
+	// Параметры списков отслеживания
+	if cfg.Gateway.Watchlist.DefaultInterval == 0 {
+		cfg.Gateway.Watchlist.DefaultInterval = 24 * time.Hour
+	}
+	if cfg.Gateway.Watchlist.MinInterval == 0 {
+		cfg.Gateway.Watchlist.MinInterval = time.Hour
+	}
+	if cfg.Gateway.Watchlist.MaxInterval == 0 {
+		cfg.Gateway.Watchlist.MaxInterval = 30 * 24 * time.Hour
+	}
+	if cfg.Gateway.Watchlist.MaxWatches == 0 {
+		cfg.Gateway.Watchlist.MaxWatches = 100
+	}
+	if cfg.Gateway.Watchlist.ChecksPerHour == 0 {
+		cfg.Gateway.Watchlist.ChecksPerHour = 60
+	}
+	if cfg.Gateway.Watchlist.QuotaBackoff == 0 {
+		cfg.Gateway.Watchlist.QuotaBackoff = time.Hour
+	}
+	if cfg.Gateway.Watchlist.MinInterval < time.Minute ||
+		cfg.Gateway.Watchlist.DefaultInterval < cfg.Gateway.Watchlist.MinInterval ||
+		cfg.Gateway.Watchlist.DefaultInterval > cfg.Gateway.Watchlist.MaxInterval {
+		return nil, errors.New("watchlist default interval must be between min and max interval, min interval at least 1m")
+	}
+
+	if cfg.Gateway.Webhooks.Timeout == 0 {
+		cfg.Gateway.Webhooks.Timeout = 10 * time.Second
+	}
+	if cfg.Gateway.Webhooks.MaxAttempts == 0 {
+		cfg.Gateway.Webhooks.MaxAttempts = 8
+	}
+	if cfg.Gateway.Webhooks.BaseBackoff == 0 {
+		cfg.Gateway.Webhooks.BaseBackoff = 30 * time.Second
+	}
+	if cfg.Gateway.Webhooks.MaxBackoff == 0 {
+		cfg.Gateway.Webhooks.MaxBackoff = time.Hour
+	}
+	if cfg.Gateway.Webhooks.Workers == 0 {
+		cfg.Gateway.Webhooks.Workers = 4
+	}
+	if cfg.Gateway.Webhooks.MaxPerUser == 0 {
+		cfg.Gateway.Webhooks.MaxPerUser = 10
+	}
+	if cfg.Gateway.Webhooks.MaxAttempts < 1 || cfg.Gateway.Webhooks.Workers < 1 ||
+		cfg.Gateway.Webhooks.BaseBackoff > cfg.Gateway.Webhooks.MaxBackoff {
+		return nil, errors.New("webhooks max attempts and workers must be positive, base backoff must not exceed max backoff")
+	}
+
+	if cfg.Gateway.ScanRefresh.Role == "" {
+		cfg.Gateway.ScanRefresh.Role = "user"
+	}
+	if cfg.Gateway.ScanRefresh.PerHour == 0 {
+		cfg.Gateway.ScanRefresh.PerHour = 20
+	}
+	switch cfg.Gateway.ScanRefresh.Role {
+	case "any", "user", "admin":
+	default:
+		return nil, errors.New("scan refresh role must be any, user or admin")
+	}
+
+	// Ключ сервисного аккаунта Yandex, по которому IAM-токен обновляется автоматически
+	if cfg.Gateway.SAKeyFile == "" {
+		cfg.Gateway.SAKeyFile = os.Getenv("YANDEX_SA_KEY_FILE")
+	}
+
+	// IAM_TOKEN API Key нужен только для Yandex OCR без ключа сервисного аккаунта
+	if cfg.Gateway.OCR.Engine == "yandex" && cfg.Gateway.SAKeyFile == "" && cfg.Gateway.IamToken == "" {
 		// Если API-ключ не задан в конфигурации, пытаемся получить его из переменной окружения
 		cfg.Gateway.IamToken = os.Getenv("IAM_TOKEN")
 		if cfg.Gateway.IamToken == "" {
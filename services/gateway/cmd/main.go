@@ -23,19 +23,44 @@ import (
 	"github.com/CodeMaster482/minions-server/common"
 	_ "github.com/CodeMaster482/minions-server/docs"
 
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan"
 	scanHandlers "github.com/CodeMaster482/minions-server/services/gateway/internal/scan/delivery/http"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/ocr/fixture"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/ocr/tesseract"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/ocr/yandex"
 	scanPostgresRepo "github.com/CodeMaster482/minions-server/services/gateway/internal/scan/repo/postgres"
 	scanRedisRepo "github.com/CodeMaster482/minions-server/services/gateway/internal/scan/repo/redis"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/rules"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/upload"
 	scanUsecase "github.com/CodeMaster482/minions-server/services/gateway/internal/scan/usecase"
 
 	authHandlers "github.com/CodeMaster482/minions-server/services/gateway/internal/auth/delivery/http"
 	authRepo "github.com/CodeMaster482/minions-server/services/gateway/internal/auth/repo"
 	authUsecase "github.com/CodeMaster482/minions-server/services/gateway/internal/auth/usecase"
 
+	historyHandlers "github.com/CodeMaster482/minions-server/services/gateway/internal/history/delivery/http"
+	historyRepo "github.com/CodeMaster482/minions-server/services/gateway/internal/history/repo"
+	historyUsecase "github.com/CodeMaster482/minions-server/services/gateway/internal/history/usecase"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/quarantine"
+	quarantineHandlers "github.com/CodeMaster482/minions-server/services/gateway/internal/quarantine/delivery/http"
+	quarantineRepo "github.com/CodeMaster482/minions-server/services/gateway/internal/quarantine/repo"
+	quarantineStorage "github.com/CodeMaster482/minions-server/services/gateway/internal/quarantine/storage"
+	quarantineUsecase "github.com/CodeMaster482/minions-server/services/gateway/internal/quarantine/usecase"
+
+	watchlistHandlers "github.com/CodeMaster482/minions-server/services/gateway/internal/watchlist/delivery/http"
+	watchlistRepo "github.com/CodeMaster482/minions-server/services/gateway/internal/watchlist/repo"
+	watchlistUsecase "github.com/CodeMaster482/minions-server/services/gateway/internal/watchlist/usecase"
+	webhookHandlers "github.com/CodeMaster482/minions-server/services/gateway/internal/webhook/delivery/http"
+	webhookRepo "github.com/CodeMaster482/minions-server/services/gateway/internal/webhook/repo"
+	webhookUsecase "github.com/CodeMaster482/minions-server/services/gateway/internal/webhook/usecase"
+
 	statisticsHandlers "github.com/CodeMaster482/minions-server/services/gateway/internal/statistics/delivery/http"
 	statisticsRepo "github.com/CodeMaster482/minions-server/services/gateway/internal/statistics/repo"
 	statisticsUsecase "github.com/CodeMaster482/minions-server/services/gateway/internal/statistics/usecase"
 
+	"github.com/CodeMaster482/minions-server/services/gateway/pkg/health"
 	"github.com/CodeMaster482/minions-server/services/gateway/pkg/middleware"
 	"github.com/alexedwards/scs/redisstore"
 	"github.com/gomodule/redigo/redis"
@@ -120,6 +145,26 @@ func run() error {
 
 	//=================================================================//
 
+	// Фоновые задачи останавливаются вместе с сервером
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	defer bgCancel()
+
+	healthCheckers := []health.Checker{
+		health.CheckerFunc("postgres", postgresClient.PingContext),
+		health.CheckerFunc("redis", func(ctx context.Context) error {
+			conn, err := redisPool.GetContext(ctx)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			_, err = conn.Do("PING")
+			return err
+		}),
+	}
+
+	//=================================================================//
+
 	authRepo := authRepo.New(postgresClient, logger)
 	authUsecase := authUsecase.New(authRepo, logger)
 	auth := authHandlers.New(authUsecase, sessionManager, logger)
@@ -127,15 +172,113 @@ func run() error {
 	//=================================================================//
 
 	statisticsRepo := statisticsRepo.New(postgresClient, logger)
+
+	// Секции на ближайшие дни нужны до первых проверок, поэтому первое обслуживание выполняется сразу.
+	// Ошибка не мешает запуску: события попадут в секцию по умолчанию и будут перенесены позже
+	scanLog := statisticsUsecase.NewScanLog(statisticsRepo, cfg.Gateway.ScanLog.Retention, cfg.Gateway.ScanLog.RollupRetention, cfg.Gateway.ScanLog.MaintenanceInterval, logger)
+	scanLog.Maintain(bgCtx)
+	go scanLog.Run(bgCtx)
+	healthCheckers = append(healthCheckers, scanLog)
+
 	statisticsUsecase := statisticsUsecase.New(statisticsRepo, logger)
 	stat := statisticsHandlers.New(statisticsUsecase, logger, sessionManager)
 
 	//=================================================================//
 
+	historyRepo := historyRepo.New(postgresClient, logger)
+	historyUsecase := historyUsecase.New(historyRepo, logger)
+	scanHistory := historyHandlers.New(historyUsecase, sessionManager, logger)
+
+	//=================================================================//
+
+	// Вебхуки получают события проверок и списков отслеживания, поэтому создаются раньше них
+	webhookRepo := webhookRepo.New(postgresClient, logger)
+	webhookUsecase := webhookUsecase.New(webhookRepo, webhookUsecase.Config{
+		Timeout:             cfg.Gateway.Webhooks.Timeout,
+		MaxAttempts:         cfg.Gateway.Webhooks.MaxAttempts,
+		BaseBackoff:         cfg.Gateway.Webhooks.BaseBackoff,
+		MaxBackoff:          cfg.Gateway.Webhooks.MaxBackoff,
+		Workers:             cfg.Gateway.Webhooks.Workers,
+		MaxPerUser:          cfg.Gateway.Webhooks.MaxPerUser,
+		AllowPrivateTargets: cfg.Gateway.Webhooks.AllowPrivateTargets,
+	}, logger)
+	go webhookUsecase.Run(bgCtx)
+	healthCheckers = append(healthCheckers, webhookUsecase)
+	hooks := webhookHandlers.New(webhookUsecase, sessionManager, logger)
+
+	//=================================================================//
+
 	scanPostgresRepo := scanPostgresRepo.New(postgresClient, logger)
 	scanRedisRepo := scanRedisRepo.New(redisPool, logger)
-	scanUsecase := scanUsecase.New(scanPostgresRepo, scanRedisRepo, logger)
-	scan := scanHandlers.New(cfg.Gateway.KasperskyAPIKey, cfg.Gateway.IamToken, cfg.Gateway.FolderID, scanUsecase, sessionManager, logger)
+	scanUsecase := scanUsecase.New(scanPostgresRepo, scanRedisRepo, webhookUsecase, logger)
+
+	ocrEngine, iamRefresher, err := initOCREngine(cfg.Gateway, logger)
+	if err != nil {
+		logger.Error("init OCR engine failed", slog.Any("error", err))
+
+		return err
+	}
+	if iamRefresher != nil {
+		go iamRefresher.Run(bgCtx)
+		healthCheckers = append(healthCheckers, iamRefresher)
+	}
+
+	ruleEngine := rules.New(cfg.Gateway.Rules.Dir, cfg.Gateway.Rules.ReloadInterval, logger)
+	if err := ruleEngine.Load(); err != nil {
+		logger.Error("load rules failed", slog.Any("error", err))
+
+		return err
+	}
+	if cfg.Gateway.Rules.Dir != "" {
+		go ruleEngine.Run(bgCtx)
+		// Ошибка перезагрузки оставляет прежние правила, снимать экземпляр с балансировки из-за неё не нужно
+		healthCheckers = append(healthCheckers, health.NonCritical(ruleEngine))
+	}
+
+	uploadStore, err := upload.New(cfg.Gateway.Uploads.Dir, cfg.Gateway.Uploads.Expiry, int64(cfg.Gateway.Uploads.MaxTotalSizeMB)<<20, logger)
+	if err != nil {
+		logger.Error("init upload store failed", slog.Any("error", err))
+
+		return err
+	}
+	go uploadStore.Run(bgCtx)
+
+	// Карантин необязателен: без него образцы не сохраняются, а маршруты /quarantine не регистрируются
+	var vault scan.Quarantine
+	var quarantineUC *quarantineUsecase.Usecase
+	if cfg.Gateway.Quarantine.Enabled {
+		quarantineUC, err = initQuarantine(cfg.Gateway.Quarantine, postgresClient, logger)
+		if err != nil {
+			logger.Error("init quarantine failed", slog.Any("error", err))
+
+			return err
+		}
+		go quarantineUC.Run(bgCtx)
+		healthCheckers = append(healthCheckers, quarantineUC)
+		vault = quarantineUC
+	}
+
+	scan := scanHandlers.New(cfg.Gateway.KasperskyAPIKey, uploadPolicy(cfg.Gateway.UploadPolicy), refreshPolicy(cfg.Gateway.ScanRefresh), ocrEngine, ruleEngine, uploadStore, vault, scanUsecase, sessionManager, logger)
+
+	//=================================================================//
+
+	watchlistRepo := watchlistRepo.New(postgresClient, logger)
+	watchlistUsecase := watchlistUsecase.New(watchlistRepo, scanUsecase, webhookUsecase, watchlistUsecase.Config{
+		APIKey:          cfg.Gateway.KasperskyAPIKey,
+		DefaultInterval: cfg.Gateway.Watchlist.DefaultInterval,
+		MinInterval:     cfg.Gateway.Watchlist.MinInterval,
+		MaxInterval:     cfg.Gateway.Watchlist.MaxInterval,
+		MaxWatches:      cfg.Gateway.Watchlist.MaxWatches,
+		ChecksPerHour:   cfg.Gateway.Watchlist.ChecksPerHour,
+		QuotaBackoff:    cfg.Gateway.Watchlist.QuotaBackoff,
+	}, logger)
+	go watchlistUsecase.Run(bgCtx)
+	healthCheckers = append(healthCheckers, watchlistUsecase)
+	watch := watchlistHandlers.New(watchlistUsecase, sessionManager, logger)
+
+	//=================================================================//
+
+	healthHandler := health.New(logger, healthCheckers...)
 
 	//=================================================================//
 
@@ -190,6 +333,27 @@ func run() error {
 		rV2.HandleFunc("/stat/top-green-links-all-time", stat.TopGreenLinksAllTime).Methods(http.MethodGet, http.MethodOptions)
 	}
 
+	{
+		authRouter.HandleFunc("/history", scanHistory.List).Methods(http.MethodGet, http.MethodOptions)
+	}
+
+	{
+		authRouter.HandleFunc("/watchlist", watch.List).Methods(http.MethodGet, http.MethodOptions)
+		authRouter.HandleFunc("/watchlist", watch.Create).Methods(http.MethodPost)
+		authRouter.HandleFunc("/watchlist/alerts", watch.Alerts).Methods(http.MethodGet, http.MethodOptions)
+		authRouter.HandleFunc("/watchlist/{id:[0-9]+}", watch.Get).Methods(http.MethodGet, http.MethodOptions)
+		authRouter.HandleFunc("/watchlist/{id:[0-9]+}", watch.Update).Methods(http.MethodPatch)
+		authRouter.HandleFunc("/watchlist/{id:[0-9]+}", watch.Delete).Methods(http.MethodDelete)
+	}
+
+	{
+		authRouter.HandleFunc("/webhooks", hooks.List).Methods(http.MethodGet, http.MethodOptions)
+		authRouter.HandleFunc("/webhooks", hooks.Create).Methods(http.MethodPost)
+		authRouter.HandleFunc("/webhooks/{id:[0-9]+}", hooks.Delete).Methods(http.MethodDelete, http.MethodOptions)
+		authRouter.HandleFunc("/webhooks/dead-letters", hooks.DeadLetters).Methods(http.MethodGet, http.MethodOptions)
+		authRouter.HandleFunc("/webhooks/dead-letters/{id:[0-9]+}/redeliver", hooks.Redeliver).Methods(http.MethodPost, http.MethodOptions)
+	}
+
 	{
 		r.HandleFunc("/auth/login", auth.Login).Methods(http.MethodPost, http.MethodOptions)
 		r.HandleFunc("/auth/register", auth.Register).Methods(http.MethodPost, http.MethodOptions)
@@ -198,10 +362,37 @@ func run() error {
 
 	{
 		r.HandleFunc("/scan/uri", scan.DomainIPUrl).Methods(http.MethodGet, http.MethodOptions)
+		r.HandleFunc("/scan/history", scan.VerdictHistory).Methods(http.MethodGet, http.MethodOptions)
 		r.HandleFunc("/scan/file", scan.ScanFile).Methods(http.MethodPost, http.MethodOptions)
+		r.HandleFunc("/scan/file-url", scan.ScanFileURL).Methods(http.MethodPost, http.MethodOptions)
 		r.HandleFunc("/scan/screen", scan.ScanScreen).Methods(http.MethodPost, http.MethodOptions)
+		r.HandleFunc("/scan/text", scan.ScanText).Methods(http.MethodPost, http.MethodOptions)
+		r.HandleFunc("/scan/email", scan.ScanEmail).Methods(http.MethodPost, http.MethodOptions)
 	}
 
+	{
+		// Загрузки по частям занимают место на диске, поэтому доступны только авторизованным пользователям
+		authRouter.HandleFunc("/scan/uploads", scan.CreateUpload).Methods(http.MethodPost, http.MethodOptions)
+		authRouter.HandleFunc("/scan/uploads/{id}", scan.UploadOffset).Methods(http.MethodHead)
+		authRouter.HandleFunc("/scan/uploads/{id}", scan.UploadChunk).Methods(http.MethodPatch, http.MethodOptions)
+		authRouter.HandleFunc("/scan/uploads/{id}", scan.UploadStatus).Methods(http.MethodGet)
+		authRouter.HandleFunc("/scan/uploads/{id}", scan.DeleteUpload).Methods(http.MethodDelete)
+	}
+
+	if quarantineUC != nil {
+		vaultHandler := quarantineHandlers.New(quarantineUC, sessionManager, logger)
+
+		adminRouter := r.PathPrefix("/").Subrouter()
+		adminRouter.Use(mw.RequireRole(common.RoleAdmin))
+
+		adminRouter.HandleFunc("/quarantine", vaultHandler.List).Methods(http.MethodGet, http.MethodOptions)
+		adminRouter.HandleFunc("/quarantine/audit", vaultHandler.Audit).Methods(http.MethodGet, http.MethodOptions)
+		adminRouter.HandleFunc("/quarantine/{sha256:[0-9a-fA-F]{64}}", vaultHandler.Download).Methods(http.MethodGet, http.MethodOptions)
+		adminRouter.HandleFunc("/quarantine/{sha256:[0-9a-fA-F]{64}}", vaultHandler.Delete).Methods(http.MethodDelete)
+	}
+
+	r.HandleFunc("/health", healthHandler.Health).Methods(http.MethodGet, http.MethodOptions)
+
 	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		common.RespondWithError(w, http.StatusNotFound, "Not Found")
 		logger.Warn("Not Found", slog.String("url", r.URL.String()))
@@ -291,6 +482,102 @@ func initRedisPool(cfg RedisConfig) *redis.Pool {
 	}
 }
 
+// initOCREngine создаёт движок OCR из конфигурации. Для Yandex с ключом сервисного аккаунта
+// дополнительно возвращается IAMTokenRefresher, который нужно запустить в фоне
+func initOCREngine(cfg GatewayConfig, logger *slog.Logger) (scan.OCREngine, *yandex.IAMTokenRefresher, error) {
+	switch cfg.OCR.Engine {
+	case "yandex":
+		if cfg.SAKeyFile == "" {
+			return yandex.New(yandex.StaticToken(cfg.IamToken), cfg.FolderID, logger), nil, nil
+		}
+
+		refresher, err := yandex.NewIAMTokenRefresher(cfg.SAKeyFile, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		return yandex.New(refresher, cfg.FolderID, logger), refresher, nil
+	case "tesseract":
+		return tesseract.New(cfg.OCR.TesseractPath, cfg.OCR.Languages, logger), nil, nil
+	case "fixture":
+		if cfg.OCR.FixtureDir == "" {
+			return nil, nil, errors.New("ocr fixture_dir is required for fixture engine")
+		}
+		return fixture.New(cfg.OCR.FixtureDir, logger), nil, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown OCR engine: %s", cfg.OCR.Engine)
+	}
+}
+
+// initQuarantine создаёт карантинное хранилище с локальным каталогом или S3-совместимым бакетом
+func initQuarantine(cfg QuarantineConfig, db *sql.DB, logger *slog.Logger) (*quarantineUsecase.Usecase, error) {
+	for _, zone := range cfg.Zones {
+		if !models.IsZone(zone) {
+			return nil, fmt.Errorf("unknown quarantine zone: %s", zone)
+		}
+	}
+
+	var storage quarantine.Storage
+	switch cfg.Storage {
+	case "local":
+		local, err := quarantineStorage.NewLocal(cfg.Dir)
+		if err != nil {
+			return nil, err
+		}
+		storage = local
+	case "s3":
+		s3, err := quarantineStorage.NewS3(quarantineStorage.S3Config{
+			Endpoint:  cfg.S3.Endpoint,
+			Region:    cfg.S3.Region,
+			Bucket:    cfg.S3.Bucket,
+			Prefix:    cfg.S3.Prefix,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+		storage = s3
+	default:
+		return nil, fmt.Errorf("unknown quarantine storage: %s", cfg.Storage)
+	}
+
+	return quarantineUsecase.New(quarantineRepo.New(db, logger), storage, quarantineUsecase.Config{
+		Key:           cfg.Key,
+		Zones:         cfg.Zones,
+		Retention:     cfg.Retention,
+		MaxSampleSize: cfg.MaxSampleSizeMB << 20,
+		MaxTotalSize:  cfg.MaxTotalSizeMB << 20,
+		ZipPassword:   cfg.ZipPassword,
+	}, logger)
+}
+
+// uploadPolicy переводит политику загрузки из конфигурации в модель, размеры задаются в мегабайтах
+func uploadPolicy(cfg UploadPolicyConfig) models.UploadPolicy {
+	policy := models.UploadPolicy{
+		Allowed: cfg.Allowed,
+		Denied:  cfg.Denied,
+		MaxSize: make(map[string]int64, len(cfg.MaxSizeMB)),
+	}
+	for fileType, size := range cfg.MaxSizeMB {
+		policy.MaxSize[fileType] = size << 20
+	}
+
+	return policy
+}
+
+func refreshPolicy(cfg ScanRefreshConfig) models.RefreshPolicy {
+	policy := models.RefreshPolicy{
+		Role:    cfg.Role,
+		PerHour: cfg.PerHour,
+	}
+	// Пустая роль в политике разрешает обход кэша всем
+	if policy.Role == "any" {
+		policy.Role = ""
+	}
+
+	return policy
+}
+
 func initSessionManager(cfgSession SessionConfig, redisClient *redis.Pool) (*scs.SessionManager, error) {
 	sessionManager := scs.New()
 	sessionManager.Store = redisstore.New(redisClient)
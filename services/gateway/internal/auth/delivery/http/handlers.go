@@ -81,6 +81,7 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 
 	h.sessionManager.Put(r.Context(), "user_id", createdUser.ID)
 	h.sessionManager.Put(r.Context(), "username", createdUser.Username)
+	h.sessionManager.Put(r.Context(), "role", createdUser.Role)
 
 	common.RespondWithJSON(w, http.StatusCreated, map[string]string{"message": "User registered"})
 }
@@ -135,6 +136,7 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 
 	h.sessionManager.Put(r.Context(), "user_id", user.ID)
 	h.sessionManager.Put(r.Context(), "username", user.Username)
+	h.sessionManager.Put(r.Context(), "role", user.Role)
 
 	// Return CSRF token
 	//csrfToken := csrf.Token(r)
@@ -12,10 +12,10 @@ var (
 	CreateUser = `
         INSERT INTO users (username, password)
         VALUES ($1, $2)
-        RETURNING id;
+        RETURNING id, role;
     `
 	GetUserByName = `
-        SELECT id, username, password
+        SELECT id, username, password, role
         FROM users
         WHERE username = $1;
     `
@@ -34,7 +34,7 @@ func New(db *sql.DB, logger *slog.Logger) *Repo {
 }
 
 func (r *Repo) CreateUser(ctx context.Context, u common.User) (*common.User, error) {
-	err := r.db.QueryRowContext(ctx, CreateUser, u.Username, u.Password).Scan(&u.ID)
+	err := r.db.QueryRowContext(ctx, CreateUser, u.Username, u.Password).Scan(&u.ID, &u.Role)
 	if err != nil {
 		r.logger.Error("Failed to create user", slog.Any("error", err))
 		return nil, err
@@ -45,7 +45,7 @@ func (r *Repo) CreateUser(ctx context.Context, u common.User) (*common.User, err
 
 func (r *Repo) GetUserByUsername(ctx context.Context, username string) (*common.User, error) {
 	u := &common.User{}
-	err := r.db.QueryRowContext(ctx, GetUserByName, username).Scan(&u.ID, &u.Username, &u.Password)
+	err := r.db.QueryRowContext(ctx, GetUserByName, username).Scan(&u.ID, &u.Username, &u.Password, &u.Role)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			r.logger.Error("User not found", slog.Any("error", err))
@@ -0,0 +1,180 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+
+	"github.com/CodeMaster482/minions-server/common"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/history"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/history/models"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/history/usecase"
+)
+
+const (
+	DefaultLimit   = 50  // Размер страницы по умолчанию
+	MaxLimit       = 200 // Максимальный размер страницы
+	MaxQueryLength = 256 // Максимальная длина строки поиска
+
+	dateLayout = "2006-01-02"
+
+	BadRequestMsg          = "Bad Request: Incorrect query."
+	InvalidCursorMsg       = "Bad Request: Invalid cursor."
+	UnauthorizedMsg        = "Unauthorized"
+	InternalServerErrorMsg = "Internal Server Error"
+)
+
+type Handler struct {
+	usecase        history.Usecase
+	sessionManager *scs.SessionManager
+	logger         *slog.Logger
+}
+
+func New(uc history.Usecase, sessionManager *scs.SessionManager, logger *slog.Logger) *Handler {
+	return &Handler{
+		usecase:        uc,
+		sessionManager: sessionManager,
+		logger:         logger,
+	}
+}
+
+// List
+// @Summary История проверок пользователя
+// @Description Возвращает проверки текущего пользователя, новые первыми. Фильтры zone, type и source принимают
+// @Description несколько значений через запятую. Даты from и to принимаются в формате RFC3339 или YYYY-MM-DD (UTC),
+// @Description дата в to включается целиком. Поиск q ищет подстроку в индикаторе без учёта регистра.
+// @Description Для следующей страницы передайте cursor из NextCursor, остальные параметры должны остаться теми же.
+// @ID history-list
+// @Tags History
+// @Produce json
+// @Param zone query string false "Зоны через запятую" example(Red,Yellow)
+// @Param type query string false "Типы индикаторов через запятую: ip, domain, url, hash" example(url,domain)
+// @Param source query string false "Источники через запятую: uri, file, screen, batch, watchlist" example(uri)
+// @Param from query string false "Начало периода" example(2024-01-01)
+// @Param to query string false "Конец периода" example(2024-01-31T18:00:00Z)
+// @Param q query string false "Подстрока индикатора" example(login)
+// @Param limit query int false "Размер страницы (по умолчанию 50, не больше 200)"
+// @Param cursor query string false "Курсор следующей страницы"
+// @Success 200 {object} models.Page "Страница истории"
+// @Failure 400 {object} common.ErrorResponse "Bad Request: Incorrect query."
+// @Failure 401 {object} common.ErrorResponse "Unauthorized"
+// @Failure 500 {object} common.ErrorResponse "Internal Server Error"
+// @Router /api/history [get]
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	logger := h.logger.With(
+		slog.String("method", r.Method),
+		slog.String("url", r.URL.String()),
+		slog.String("remote_addr", r.RemoteAddr),
+	)
+
+	userID := h.sessionManager.GetInt(r.Context(), "user_id")
+	if userID == 0 {
+		common.RespondWithError(w, http.StatusUnauthorized, UnauthorizedMsg)
+		return
+	}
+
+	filter, limit, err := parseFilter(r)
+	if err != nil {
+		common.RespondWithError(w, http.StatusBadRequest, BadRequestMsg)
+		logger.Warn(BadRequestMsg, slog.Any("error", err))
+		return
+	}
+
+	page, err := h.usecase.List(r.Context(), userID, filter, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidFilter):
+			common.RespondWithError(w, http.StatusBadRequest, BadRequestMsg)
+			logger.Warn(BadRequestMsg, slog.Any("error", err))
+		case errors.Is(err, usecase.ErrInvalidCursor):
+			common.RespondWithError(w, http.StatusBadRequest, InvalidCursorMsg)
+			logger.Warn(InvalidCursorMsg, slog.Any("error", err))
+		default:
+			common.RespondWithError(w, http.StatusInternalServerError, InternalServerErrorMsg)
+			logger.Error(InternalServerErrorMsg, slog.Any("error", err))
+		}
+		return
+	}
+
+	common.RespondWithJSON(w, http.StatusOK, page)
+}
+
+// parseFilter разбирает параметры фильтрации и размер страницы
+func parseFilter(r *http.Request) (models.Filter, int, error) {
+	query := r.URL.Query()
+
+	filter := models.Filter{
+		Zones:   listParam(query["zone"], normalizeZone),
+		Types:   listParam(query["type"], strings.ToLower),
+		Sources: listParam(query["source"], strings.ToLower),
+		Query:   strings.TrimSpace(query.Get("q")),
+	}
+	if len(filter.Query) > MaxQueryLength {
+		return filter, 0, fmt.Errorf("q is longer than %d bytes", MaxQueryLength)
+	}
+
+	var err error
+	if filter.From, err = parseTime(query.Get("from"), false); err != nil {
+		return filter, 0, fmt.Errorf("invalid from: %w", err)
+	}
+	if filter.To, err = parseTime(query.Get("to"), true); err != nil {
+		return filter, 0, fmt.Errorf("invalid to: %w", err)
+	}
+
+	limit := DefaultLimit
+	if raw := query.Get("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return filter, 0, fmt.Errorf("invalid limit: %q", raw)
+		}
+		limit = min(limit, MaxLimit)
+	}
+
+	return filter, limit, nil
+}
+
+// listParam собирает значения из повторяющихся параметров и списков через запятую
+func listParam(values []string, normalize func(string) string) []string {
+	var result []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, normalize(item))
+			}
+		}
+	}
+
+	return result
+}
+
+// normalizeZone приводит зону к виду, в котором она хранится: red -> Red
+func normalizeZone(zone string) string {
+	return strings.ToUpper(zone[:1]) + strings.ToLower(zone[1:])
+}
+
+// parseTime разбирает время в RFC3339 или дату. Дата конца периода включается целиком
+func parseTime(raw string, endOfPeriod bool) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(dateLayout, raw)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfPeriod {
+		t = t.AddDate(0, 0, 1)
+	}
+
+	return t, nil
+}
@@ -0,0 +1,15 @@
+package history
+
+import (
+	"context"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/history/models"
+)
+
+type Usecase interface {
+	List(ctx context.Context, userID int, filter models.Filter, cursor string, limit int) (*models.Page, error)
+}
+
+type Repo interface {
+	ListEntries(ctx context.Context, userID int, filter models.Filter, beforeID int64, limit int) ([]models.Entry, error)
+}
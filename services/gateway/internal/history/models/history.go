@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// InputTypes типы проверяемых индикаторов, по которым можно фильтровать историю
+var InputTypes = []string{"ip", "domain", "url", "hash"}
+
+// Entry представляет одну проверку из истории пользователя
+type Entry struct {
+	// Идентификатор записи
+	ID int64 `json:"ID" example:"1024"`
+
+	// Тип индикатора: ip, domain, url, hash
+	InputType string `json:"InputType" example:"url"`
+
+	// Проверенный индикатор в том виде, в котором он искался
+	Request string `json:"Request" example:"https://example.com/login"`
+
+	// Зона на момент проверки
+	Zone string `json:"Zone" example:"Red"`
+
+	// Откуда пришла проверка: uri, file, screen, batch
+	Source string `json:"Source" example:"uri"`
+
+	// Время проверки
+	CreatedAt time.Time `json:"CreatedAt" example:"2024-01-02T15:04:05Z"`
+}
+
+// Filter условия выборки истории. Пустые поля не ограничивают выборку
+type Filter struct {
+	Zones   []string
+	Types   []string
+	Sources []string
+	From    time.Time // включительно
+	To      time.Time // не включительно
+	Query   string    // подстрока индикатора без учёта регистра
+}
+
+// Page представляет страницу истории проверок
+type Page struct {
+	// Проверки, новые первыми
+	Items []Entry `json:"Items"`
+
+	// Курсор следующей страницы, пустой на последней странице
+	NextCursor string `json:"NextCursor,omitempty" example:"MTAyMw"`
+}
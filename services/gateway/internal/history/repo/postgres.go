@@ -0,0 +1,103 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/history/models"
+)
+
+var (
+	// Пустые массивы и NULL не ограничивают выборку. Записи идут по убыванию id,
+	// поэтому курсор - id последней записи на предыдущей странице
+	ListEntries = `
+        SELECT id, input_type, request, zone, source, created_at
+        FROM scan_events
+        WHERE user_id = $1
+          AND (cardinality($2::text[]) = 0 OR zone = ANY($2))
+          AND (cardinality($3::text[]) = 0 OR input_type = ANY($3))
+          AND (cardinality($4::text[]) = 0 OR source = ANY($4))
+          AND ($5::timestamptz IS NULL OR created_at >= $5)
+          AND ($6::timestamptz IS NULL OR created_at < $6)
+          AND ($7::text = '' OR request ILIKE '%' || $7 || '%')
+          AND ($8::bigint = 0 OR id < $8)
+        ORDER BY id DESC
+        LIMIT $9
+    `
+)
+
+type Postgres struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func New(db *sql.DB, logger *slog.Logger) *Postgres {
+	return &Postgres{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (p *Postgres) ListEntries(ctx context.Context, userID int, filter models.Filter, beforeID int64, limit int) ([]models.Entry, error) {
+	rows, err := p.db.QueryContext(ctx, ListEntries,
+		userID,
+		pq.Array(nonNil(filter.Zones)),
+		pq.Array(nonNil(filter.Types)),
+		pq.Array(nonNil(filter.Sources)),
+		nullTime(filter.From),
+		nullTime(filter.To),
+		escapeLike(filter.Query),
+		beforeID,
+		limit,
+	)
+	if err != nil {
+		p.logger.Error("Error executing history query", slog.Any("error", err))
+		return nil, fmt.Errorf("error executing history query: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.Entry{}
+	for rows.Next() {
+		var e models.Entry
+		if err := rows.Scan(&e.ID, &e.InputType, &e.Request, &e.Zone, &e.Source, &e.CreatedAt); err != nil {
+			p.logger.Error("Error scanning row", slog.Any("error", err))
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		p.logger.Error("Rows iteration error", slog.Any("error", err))
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return entries, nil
+}
+
+// nonNil заменяет nil на пустой срез: pq.Array передаёт nil как NULL, а cardinality(NULL) - тоже NULL
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+
+	return values
+}
+
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
+// escapeLike экранирует спецсимволы LIKE, чтобы строка поиска совпадала буквально
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
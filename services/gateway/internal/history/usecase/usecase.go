@@ -0,0 +1,92 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/history"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/history/models"
+	scanModels "github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+	"github.com/CodeMaster482/minions-server/services/gateway/pkg/cursor"
+)
+
+var (
+	ErrInvalidFilter = errors.New("invalid history filter")
+	ErrInvalidCursor = errors.New("invalid history cursor")
+)
+
+// sources источники проверок, которые пишутся в историю. Повторные проверки списка наблюдения
+// в журнал больше не пишутся, но записанные раньше события по ним можно отфильтровать
+var sources = []string{
+	scanModels.ScanSourceURI,
+	scanModels.ScanSourceFile,
+	scanModels.ScanSourceScreen,
+	scanModels.ScanSourceBatch,
+	scanModels.ScanSourceWatchlist,
+}
+
+type Usecase struct {
+	repo   history.Repo
+	logger *slog.Logger
+}
+
+func New(repo history.Repo, logger *slog.Logger) *Usecase {
+	return &Usecase{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// List возвращает страницу истории проверок пользователя. Курсор непрозрачен для клиента:
+// его нужно передавать в том виде, в котором он пришёл в NextCursor
+func (uc *Usecase) List(ctx context.Context, userID int, filter models.Filter, pageCursor string, limit int) (*models.Page, error) {
+	if err := validateFilter(filter); err != nil {
+		return nil, err
+	}
+
+	beforeID, err := cursor.Decode(pageCursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	// Одна лишняя запись показывает, есть ли следующая страница
+	entries, err := uc.repo.ListEntries(ctx, userID, filter, beforeID, limit+1)
+	if err != nil {
+		uc.logger.Error("Error getting history", slog.Any("error", err))
+		return nil, fmt.Errorf("error getting history: %w", err)
+	}
+
+	page := &models.Page{Items: entries}
+	if len(entries) > limit {
+		page.Items = entries[:limit]
+		page.NextCursor = cursor.Encode(page.Items[limit-1].ID)
+	}
+
+	return page, nil
+}
+
+func validateFilter(filter models.Filter) error {
+	for _, zone := range filter.Zones {
+		if !scanModels.IsZone(zone) {
+			return fmt.Errorf("%w: unknown zone %q", ErrInvalidFilter, zone)
+		}
+	}
+	for _, inputType := range filter.Types {
+		if !slices.Contains(models.InputTypes, inputType) {
+			return fmt.Errorf("%w: unknown type %q", ErrInvalidFilter, inputType)
+		}
+	}
+	for _, source := range filter.Sources {
+		if !slices.Contains(sources, source) {
+			return fmt.Errorf("%w: unknown source %q", ErrInvalidFilter, source)
+		}
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return fmt.Errorf("%w: from must be before to", ErrInvalidFilter)
+	}
+
+	return nil
+}
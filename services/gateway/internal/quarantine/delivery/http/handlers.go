@@ -0,0 +1,224 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/gorilla/mux"
+
+	"github.com/CodeMaster482/minions-server/common"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/quarantine"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/quarantine/usecase"
+)
+
+const (
+	DefaultLimit = 50  // Размер страницы по умолчанию
+	MaxLimit     = 500 // Максимальный размер страницы
+
+	BadRequestMsg          = "Bad Request: Incorrect query."
+	InvalidHashMsg         = "Bad Request: sha256 must be 64 hex characters."
+	NotFoundMsg            = "Not Found: Sample is not in quarantine."
+	InternalServerErrorMsg = "Internal Server Error"
+)
+
+type Handler struct {
+	usecase        quarantine.Usecase
+	sessionManager *scs.SessionManager
+	logger         *slog.Logger
+}
+
+func New(uc quarantine.Usecase, sessionManager *scs.SessionManager, logger *slog.Logger) *Handler {
+	return &Handler{
+		usecase:        uc,
+		sessionManager: sessionManager,
+		logger:         logger,
+	}
+}
+
+// List
+// @Summary Список образцов в карантине
+// @Description Возвращает метаданные сохранённых образцов, недавно присланные первыми. Просмотр записывается в журнал доступа.
+// @Description Только для администраторов.
+// @ID quarantine-list
+// @Tags Quarantine
+// @Produce json
+// @Param limit query int false "Размер страницы (по умолчанию 50, не больше 500)"
+// @Param offset query int false "Смещение от начала списка"
+// @Success 200 {object} models.SampleList "Страница образцов"
+// @Failure 400 {object} common.ErrorResponse "Bad Request: Incorrect query."
+// @Failure 401 {object} common.ErrorResponse "Unauthorized"
+// @Failure 403 {object} common.ErrorResponse "Forbidden"
+// @Failure 500 {object} common.ErrorResponse "Internal Server Error"
+// @Router /api/quarantine [get]
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	logger := h.requestLogger(r)
+
+	limit, err := queryInt(r, "limit", DefaultLimit, MaxLimit)
+	if err != nil {
+		common.RespondWithError(w, http.StatusBadRequest, BadRequestMsg)
+		logger.Warn(BadRequestMsg, slog.Any("error", err))
+		return
+	}
+	offset, err := queryInt(r, "offset", 0, -1)
+	if err != nil {
+		common.RespondWithError(w, http.StatusBadRequest, BadRequestMsg)
+		logger.Warn(BadRequestMsg, slog.Any("error", err))
+		return
+	}
+
+	list, err := h.usecase.List(r.Context(), limit, offset, h.userID(r), r.RemoteAddr)
+	if err != nil {
+		common.RespondWithError(w, http.StatusInternalServerError, InternalServerErrorMsg)
+		logger.Error(InternalServerErrorMsg, slog.Any("error", err))
+		return
+	}
+
+	common.RespondWithJSON(w, http.StatusOK, list)
+}
+
+// Download
+// @Summary Скачивание образца из карантина
+// @Description Возвращает образец в ZIP-архиве, зашифрованном паролем из конфигурации (по умолчанию "infected").
+// @Description Имя файла в архиве - SHA256 образца без расширения, чтобы его нельзя было запустить случайно.
+// @Description Каждое скачивание записывается в журнал доступа. Только для администраторов.
+// @ID quarantine-download
+// @Tags Quarantine
+// @Produce application/zip
+// @Param sha256 path string true "SHA256 образца"
+// @Success 200 {file} file "ZIP-архив с образцом"
+// @Failure 400 {object} common.ErrorResponse "Bad Request: sha256 must be 64 hex characters."
+// @Failure 401 {object} common.ErrorResponse "Unauthorized"
+// @Failure 403 {object} common.ErrorResponse "Forbidden"
+// @Failure 404 {object} common.ErrorResponse "Not Found: Sample is not in quarantine."
+// @Failure 500 {object} common.ErrorResponse "Internal Server Error"
+// @Router /api/quarantine/{sha256} [get]
+func (h *Handler) Download(w http.ResponseWriter, r *http.Request) {
+	logger := h.requestLogger(r)
+	sha256 := strings.ToLower(mux.Vars(r)["sha256"])
+
+	sample, archive, err := h.usecase.Download(r.Context(), sha256, h.userID(r), r.RemoteAddr)
+	if err != nil {
+		h.respondError(w, logger, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, sample.Sha256))
+	w.Header().Set("Content-Length", strconv.Itoa(len(archive)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(archive)
+
+	logger.Info("Quarantine sample downloaded", slog.String("sha256", sample.Sha256), slog.Int("user_id", h.userID(r)))
+}
+
+// Delete
+// @Summary Удаление образца из карантина
+// @Description Удаляет образец из хранилища до истечения срока хранения. Записывается в журнал доступа. Только для администраторов.
+// @ID quarantine-delete
+// @Tags Quarantine
+// @Produce json
+// @Param sha256 path string true "SHA256 образца"
+// @Success 204 "Образец удалён"
+// @Failure 400 {object} common.ErrorResponse "Bad Request: sha256 must be 64 hex characters."
+// @Failure 401 {object} common.ErrorResponse "Unauthorized"
+// @Failure 403 {object} common.ErrorResponse "Forbidden"
+// @Failure 404 {object} common.ErrorResponse "Not Found: Sample is not in quarantine."
+// @Failure 500 {object} common.ErrorResponse "Internal Server Error"
+// @Router /api/quarantine/{sha256} [delete]
+func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
+	logger := h.requestLogger(r)
+	sha256 := strings.ToLower(mux.Vars(r)["sha256"])
+
+	if err := h.usecase.Delete(r.Context(), sha256, h.userID(r), r.RemoteAddr); err != nil {
+		h.respondError(w, logger, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Audit
+// @Summary Журнал доступа к карантину
+// @Description Возвращает последние действия с образцами: сохранение, скачивание, удаление, удаление по сроку,
+// @Description а также просмотры списка и журнала. Просмотр журнала тоже записывается. Только для администраторов.
+// @ID quarantine-audit
+// @Tags Quarantine
+// @Produce json
+// @Param sha256 query string false "SHA256 образца, без него возвращаются записи по всем образцам"
+// @Param limit query int false "Количество записей (по умолчанию 50, не больше 500)"
+// @Success 200 {array} models.AuditEntry "Записи журнала, новые первыми"
+// @Failure 400 {object} common.ErrorResponse "Bad Request: Incorrect query."
+// @Failure 401 {object} common.ErrorResponse "Unauthorized"
+// @Failure 403 {object} common.ErrorResponse "Forbidden"
+// @Failure 500 {object} common.ErrorResponse "Internal Server Error"
+// @Router /api/quarantine/audit [get]
+func (h *Handler) Audit(w http.ResponseWriter, r *http.Request) {
+	logger := h.requestLogger(r)
+
+	limit, err := queryInt(r, "limit", DefaultLimit, MaxLimit)
+	if err != nil {
+		common.RespondWithError(w, http.StatusBadRequest, BadRequestMsg)
+		logger.Warn(BadRequestMsg, slog.Any("error", err))
+		return
+	}
+
+	entries, err := h.usecase.Audit(r.Context(), strings.ToLower(r.URL.Query().Get("sha256")), limit, h.userID(r), r.RemoteAddr)
+	if err != nil {
+		h.respondError(w, logger, err)
+		return
+	}
+
+	common.RespondWithJSON(w, http.StatusOK, entries)
+}
+
+// respondError отвечает клиенту кодом, соответствующим ошибке usecase
+func (h *Handler) respondError(w http.ResponseWriter, logger *slog.Logger, err error) {
+	switch {
+	case errors.Is(err, usecase.ErrInvalidHash):
+		common.RespondWithError(w, http.StatusBadRequest, InvalidHashMsg)
+		logger.Warn(InvalidHashMsg)
+	case errors.Is(err, usecase.ErrNotFound):
+		common.RespondWithError(w, http.StatusNotFound, NotFoundMsg)
+		logger.Warn(NotFoundMsg)
+	default:
+		common.RespondWithError(w, http.StatusInternalServerError, InternalServerErrorMsg)
+		logger.Error(InternalServerErrorMsg, slog.Any("error", err))
+	}
+}
+
+func (h *Handler) requestLogger(r *http.Request) *slog.Logger {
+	return h.logger.With(
+		slog.String("method", r.Method),
+		slog.String("url", r.URL.String()),
+		slog.String("remote_addr", r.RemoteAddr),
+	)
+}
+
+// userID возвращает идентификатор пользователя из сессии
+func (h *Handler) userID(r *http.Request) int {
+	return h.sessionManager.GetInt(r.Context(), "user_id")
+}
+
+// queryInt читает неотрицательное число из параметра запроса. max < 0 означает отсутствие ограничения сверху
+func queryInt(r *http.Request, name string, def, max int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s: %q", name, raw)
+	}
+	if max >= 0 && n > max {
+		n = max
+	}
+
+	return n, nil
+}
@@ -0,0 +1,36 @@
+package quarantine
+
+import (
+	"context"
+	"time"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/quarantine/models"
+)
+
+type Usecase interface {
+	Store(ctx context.Context, sha256, filename, fileType, zone string, content []byte, userID int) error
+	List(ctx context.Context, limit, offset, userID int, remoteAddr string) (*models.SampleList, error)
+	Download(ctx context.Context, sha256 string, userID int, remoteAddr string) (*models.Sample, []byte, error)
+	Delete(ctx context.Context, sha256 string, userID int, remoteAddr string) error
+	Audit(ctx context.Context, sha256 string, limit, userID int, remoteAddr string) ([]models.AuditEntry, error)
+}
+
+type Repo interface {
+	GetSample(ctx context.Context, sha256 string) (*models.Sample, error) // nil, если образца нет
+	SaveSample(ctx context.Context, sample models.Sample) error
+	DeleteSample(ctx context.Context, sha256 string) error
+	ListSamples(ctx context.Context, limit, offset int) ([]models.Sample, error)
+	SamplesTotal(ctx context.Context) (int, int64, error)
+	ExpiredSamples(ctx context.Context, now time.Time, limit int) ([]models.Sample, error)
+	OldestSamples(ctx context.Context, limit int) ([]models.Sample, error)
+	SaveAudit(ctx context.Context, entry models.AuditEntry) error
+	AuditEntries(ctx context.Context, sha256 string, limit int) ([]models.AuditEntry, error)
+}
+
+// Storage хранит зашифрованные образцы по ключу. Реализации: локальный каталог и S3-совместимое хранилище
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+	Check(ctx context.Context) error
+}
@@ -0,0 +1,79 @@
+package models
+
+import "time"
+
+// Действия с образцами, которые записываются в журнал доступа
+const (
+	ActionStore    = "store"    // Образец помещён в хранилище или прислан повторно
+	ActionDownload = "download" // Администратор скачал образец
+	ActionDelete   = "delete"   // Администратор удалил образец
+	ActionExpire   = "expire"   // Образец удалён по сроку хранения или из-за лимита объёма
+	ActionList     = "list"     // Администратор просмотрел список образцов
+	ActionAudit    = "audit"    // Администратор просмотрел журнал доступа
+)
+
+// Sample представляет образец в карантинном хранилище. Содержимое хранится зашифрованным, ключ - SHA256
+type Sample struct {
+	// SHA256 исходного файла
+	Sha256 string `json:"Sha256" example:"ghi789..."`
+
+	// Имя файла при последней загрузке
+	Filename string `json:"Filename" example:"invoice.pdf.exe"`
+
+	// Размер исходного файла в байтах
+	Size int64 `json:"Size" example:"48128"`
+
+	// Зона, с которой образец попал в хранилище
+	Zone string `json:"Zone" example:"Red"`
+
+	// Тип файла по содержимому
+	FileType string `json:"FileType,omitempty" example:"pe"`
+
+	// Пользователь, загрузивший файл (0 для неавторизованного)
+	UserID int `json:"UserID" example:"42"`
+
+	// Сколько раз файл присылали на проверку
+	Submissions int `json:"Submissions" example:"3"`
+
+	// Время первой загрузки
+	CreatedAt time.Time `json:"CreatedAt" example:"2024-01-01T00:00:00Z"`
+
+	// Время последней загрузки
+	LastSeen time.Time `json:"LastSeen" example:"2024-01-05T00:00:00Z"`
+
+	// После этого времени образец удаляется
+	ExpiresAt time.Time `json:"ExpiresAt" example:"2024-02-04T00:00:00Z"`
+}
+
+// AuditEntry представляет запись журнала доступа к карантинному хранилищу
+type AuditEntry struct {
+	// Идентификатор записи
+	ID int64 `json:"ID" example:"1"`
+
+	// SHA256 образца, пусто для просмотра списка и журнала по всем образцам
+	Sha256 string `json:"Sha256" example:"ghi789..."`
+
+	// Пользователь, выполнивший действие (0 для неавторизованного и для фоновой очистки)
+	UserID int `json:"UserID" example:"1"`
+
+	// Действие: store, download, delete, expire, list, audit
+	Action string `json:"Action" example:"download"`
+
+	// Адрес клиента
+	RemoteAddr string `json:"RemoteAddr,omitempty" example:"10.0.0.5:51234"`
+
+	// Время действия
+	CreatedAt time.Time `json:"CreatedAt" example:"2024-01-05T00:00:00Z"`
+}
+
+// SampleList представляет страницу списка образцов
+type SampleList struct {
+	// Образцы, новые первыми
+	Samples []Sample `json:"Samples"`
+
+	// Всего образцов в хранилище
+	Total int `json:"Total" example:"120"`
+
+	// Суммарный размер образцов в байтах
+	TotalSize int64 `json:"TotalSize" example:"73400320"`
+}
@@ -0,0 +1,215 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/quarantine/models"
+)
+
+var (
+	sampleColumns = `sha256, filename, size, zone, file_type, COALESCE(user_id, 0), submissions, created_at, last_seen, expires_at`
+
+	GetSample = `
+        SELECT ` + sampleColumns + `
+        FROM quarantine_samples
+        WHERE sha256 = $1;
+    `
+	// Повторная загрузка продлевает срок хранения и обновляет зону последним вердиктом
+	SaveSample = `
+        INSERT INTO quarantine_samples (sha256, filename, size, zone, file_type, user_id, expires_at)
+        VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), $7)
+        ON CONFLICT (sha256) DO UPDATE
+        SET filename = EXCLUDED.filename,
+            zone = EXCLUDED.zone,
+            file_type = EXCLUDED.file_type,
+            submissions = quarantine_samples.submissions + 1,
+            last_seen = NOW(),
+            expires_at = EXCLUDED.expires_at;
+    `
+	DeleteSample = `
+        DELETE FROM quarantine_samples
+        WHERE sha256 = $1;
+    `
+	ListSamples = `
+        SELECT ` + sampleColumns + `
+        FROM quarantine_samples
+        ORDER BY last_seen DESC, sha256
+        LIMIT $1 OFFSET $2;
+    `
+	SamplesTotal = `
+        SELECT COUNT(*), COALESCE(SUM(size), 0)
+        FROM quarantine_samples;
+    `
+	ExpiredSamples = `
+        SELECT ` + sampleColumns + `
+        FROM quarantine_samples
+        WHERE expires_at <= $1
+        ORDER BY expires_at
+        LIMIT $2;
+    `
+	OldestSamples = `
+        SELECT ` + sampleColumns + `
+        FROM quarantine_samples
+        ORDER BY last_seen
+        LIMIT $1;
+    `
+	SaveAudit = `
+        INSERT INTO quarantine_audit (sha256, user_id, action, remote_addr)
+        VALUES ($1, NULLIF($2, 0), $3, $4);
+    `
+	AuditEntries = `
+        SELECT id, sha256, COALESCE(user_id, 0), action, remote_addr, created_at
+        FROM quarantine_audit
+        WHERE $1::text = '' OR sha256 = $1::text
+        ORDER BY id DESC
+        LIMIT $2;
+    `
+)
+
+type Postgres struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func New(db *sql.DB, logger *slog.Logger) *Postgres {
+	return &Postgres{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (p *Postgres) GetSample(ctx context.Context, sha256 string) (*models.Sample, error) {
+	sample, err := scanSample(p.db.QueryRowContext(ctx, GetSample, sha256))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		p.logger.Error("Error getting quarantine sample", slog.Any("error", err))
+		return nil, fmt.Errorf("error getting quarantine sample: %w", err)
+	}
+
+	return sample, nil
+}
+
+func (p *Postgres) SaveSample(ctx context.Context, s models.Sample) error {
+	_, err := p.db.ExecContext(ctx, SaveSample, s.Sha256, s.Filename, s.Size, s.Zone, s.FileType, s.UserID, s.ExpiresAt)
+	if err != nil {
+		p.logger.Error("Error saving quarantine sample", slog.Any("error", err))
+		return fmt.Errorf("error saving quarantine sample: %w", err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) DeleteSample(ctx context.Context, sha256 string) error {
+	_, err := p.db.ExecContext(ctx, DeleteSample, sha256)
+	if err != nil {
+		p.logger.Error("Error deleting quarantine sample", slog.Any("error", err))
+		return fmt.Errorf("error deleting quarantine sample: %w", err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) ListSamples(ctx context.Context, limit, offset int) ([]models.Sample, error) {
+	return p.querySamples(ctx, ListSamples, limit, offset)
+}
+
+func (p *Postgres) SamplesTotal(ctx context.Context) (int, int64, error) {
+	var (
+		count int
+		size  int64
+	)
+	if err := p.db.QueryRowContext(ctx, SamplesTotal).Scan(&count, &size); err != nil {
+		p.logger.Error("Error counting quarantine samples", slog.Any("error", err))
+		return 0, 0, fmt.Errorf("error counting quarantine samples: %w", err)
+	}
+
+	return count, size, nil
+}
+
+func (p *Postgres) ExpiredSamples(ctx context.Context, now time.Time, limit int) ([]models.Sample, error) {
+	return p.querySamples(ctx, ExpiredSamples, now, limit)
+}
+
+func (p *Postgres) OldestSamples(ctx context.Context, limit int) ([]models.Sample, error) {
+	return p.querySamples(ctx, OldestSamples, limit)
+}
+
+func (p *Postgres) SaveAudit(ctx context.Context, e models.AuditEntry) error {
+	_, err := p.db.ExecContext(ctx, SaveAudit, e.Sha256, e.UserID, e.Action, e.RemoteAddr)
+	if err != nil {
+		p.logger.Error("Error saving quarantine audit entry", slog.Any("error", err))
+		return fmt.Errorf("error saving quarantine audit entry: %w", err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) AuditEntries(ctx context.Context, sha256 string, limit int) ([]models.AuditEntry, error) {
+	rows, err := p.db.QueryContext(ctx, AuditEntries, sha256, limit)
+	if err != nil {
+		p.logger.Error("Error executing quarantine audit query", slog.Any("error", err))
+		return nil, fmt.Errorf("error executing quarantine audit query: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var e models.AuditEntry
+		if err := rows.Scan(&e.ID, &e.Sha256, &e.UserID, &e.Action, &e.RemoteAddr, &e.CreatedAt); err != nil {
+			p.logger.Error("Error scanning row", slog.Any("error", err))
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		p.logger.Error("Rows iteration error", slog.Any("error", err))
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return entries, nil
+}
+
+func (p *Postgres) querySamples(ctx context.Context, query string, args ...any) ([]models.Sample, error) {
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		p.logger.Error("Error executing quarantine samples query", slog.Any("error", err))
+		return nil, fmt.Errorf("error executing quarantine samples query: %w", err)
+	}
+	defer rows.Close()
+
+	samples := []models.Sample{}
+	for rows.Next() {
+		sample, err := scanSample(rows)
+		if err != nil {
+			p.logger.Error("Error scanning row", slog.Any("error", err))
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		samples = append(samples, *sample)
+	}
+
+	if err := rows.Err(); err != nil {
+		p.logger.Error("Rows iteration error", slog.Any("error", err))
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return samples, nil
+}
+
+// scanSample читает строку с колонками sampleColumns
+func scanSample(row interface{ Scan(...any) error }) (*models.Sample, error) {
+	var s models.Sample
+	err := row.Scan(&s.Sha256, &s.Filename, &s.Size, &s.Zone, &s.FileType, &s.UserID, &s.Submissions, &s.CreatedAt, &s.LastSeen, &s.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
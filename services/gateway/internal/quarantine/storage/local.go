@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var (
+	ErrNotFound   = errors.New("sample not found in storage")
+	ErrInvalidKey = errors.New("invalid storage key")
+)
+
+// keyRegexp ключ образца - SHA256 в нижнем регистре, поэтому выйти за пределы каталога или бакета нельзя
+var keyRegexp = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Local хранит образцы в локальном каталоге. Файлы раскладываются по подкаталогам
+// по первым двум символам ключа, чтобы в одном каталоге не скапливались десятки тысяч файлов
+type Local struct {
+	dir string
+}
+
+// NewLocal создаёт каталог хранилища, доступ к нему есть только у процесса шлюза
+func NewLocal(dir string) (*Local, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+
+	return &Local{dir: dir}, nil
+}
+
+func (l *Local) Put(_ context.Context, key string, data []byte) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	// Пишем во временный файл и переименовываем, чтобы при сбое не остался обрезанный образец
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (l *Local) Get(_ context.Context, key string) ([]byte, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	return data, err
+}
+
+func (l *Local) Delete(_ context.Context, key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// Check проверяет, что каталог хранилища доступен для записи
+func (l *Local) Check(_ context.Context) error {
+	tmp, err := os.CreateTemp(l.dir, ".check.*")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+
+	return os.Remove(tmp.Name())
+}
+
+func (l *Local) path(key string) (string, error) {
+	if !keyRegexp.MatchString(key) {
+		return "", ErrInvalidKey
+	}
+
+	return filepath.Join(l.dir, key[:2], key), nil
+}
@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	s3Timeout     = 5 * time.Minute // Максимальное время одного запроса к хранилищу
+	s3ErrorBody   = 1 << 10         // Сколько байт ответа с ошибкой попадает в текст ошибки
+	amzDateFormat = "20060102T150405Z"
+)
+
+// S3Config параметры S3-совместимого хранилища (AWS S3, MinIO и т.п.)
+type S3Config struct {
+	Endpoint  string // адрес хранилища, например http://minio:9000
+	Region    string // регион для подписи запросов, для MinIO обычно us-east-1
+	Bucket    string // бакет должен существовать заранее
+	Prefix    string // префикс ключей внутри бакета
+	AccessKey string
+	SecretKey string
+}
+
+// S3 хранит образцы в S3-совместимом хранилище. Используется адресация бакета в пути (path-style),
+// которую поддерживают и MinIO, и AWS. Запросы подписываются по AWS Signature Version 4
+type S3 struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+func NewS3(cfg S3Config) (*S3, error) {
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint: %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" || cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, errors.New("s3 bucket, access key and secret key are required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	return &S3{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: s3Timeout},
+	}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, data []byte) error {
+	if !keyRegexp.MatchString(key) {
+		return ErrInvalidKey
+	}
+
+	resp, err := s.do(ctx, http.MethodPut, s.cfg.Prefix+key, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) ([]byte, error) {
+	if !keyRegexp.MatchString(key) {
+		return nil, ErrInvalidKey
+	}
+
+	resp, err := s.do(ctx, http.MethodGet, s.cfg.Prefix+key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(resp.Body)
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, s3Error(resp)
+	}
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if !keyRegexp.MatchString(key) {
+		return ErrInvalidKey
+	}
+
+	resp, err := s.do(ctx, http.MethodDelete, s.cfg.Prefix+key, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// S3 отвечает 204 и для отсутствующего объекта
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s3Error(resp)
+	}
+
+	return nil
+}
+
+// Check проверяет, что бакет существует и ключи доступа подходят
+func (s *S3) Check(ctx context.Context) error {
+	resp, err := s.do(ctx, http.MethodHead, "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("s3 bucket %q: %s", s.cfg.Bucket, resp.Status)
+	}
+
+	return nil
+}
+
+// do отправляет подписанный запрос к объекту бакета, пустой ключ означает сам бакет
+func (s *S3) do(ctx context.Context, method, key string, body []byte) (*http.Response, error) {
+	target := *s.endpoint
+	target.Path = s.endpoint.Path + "/" + s.cfg.Bucket
+	if key != "" {
+		target.Path += "/" + key
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+
+	s.sign(req, body, time.Now().UTC())
+
+	return s.client.Do(req)
+}
+
+// sign подписывает запрос по AWS Signature Version 4 с заголовком Authorization
+func (s *S3) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format(amzDateFormat)
+	date := amzDate[:8]
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + payloadHash + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), date)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+// s3Error возвращает ошибку с кодом ответа и началом XML-описания ошибки от хранилища
+func s3Error(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, s3ErrorBody))
+	return fmt.Errorf("s3 %s %s: %s: %s", resp.Request.Method, resp.Request.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,64 @@
+package usecase
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// sampleMagic начало зашифрованного образца, цифра - версия формата
+var sampleMagic = []byte("MQV1")
+
+var errCorruptSample = errors.New("quarantine sample is corrupt or encrypted with another key")
+
+// newAEAD создаёт шифр AES-256-GCM из ключа в base64
+func newAEAD(key string) (cipher.AEAD, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("quarantine key must be base64: %w", err)
+	}
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("quarantine key must be 32 bytes, got %d", len(raw))
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// seal шифрует образец. SHA256 передаётся как дополнительные данные, поэтому подменить
+// один зашифрованный образец другим в хранилище незаметно не получится
+func seal(aead cipher.AEAD, sha256 string, content []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(sampleMagic)+len(nonce)+len(content)+aead.Overhead())
+	out = append(out, sampleMagic...)
+	out = append(out, nonce...)
+
+	return aead.Seal(out, nonce, content, []byte(sha256)), nil
+}
+
+// open расшифровывает образец, записанный seal
+func open(aead cipher.AEAD, sha256 string, data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, sampleMagic) || len(data) < len(sampleMagic)+aead.NonceSize() {
+		return nil, errCorruptSample
+	}
+	data = data[len(sampleMagic):]
+
+	content, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(sha256))
+	if err != nil {
+		return nil, errCorruptSample
+	}
+
+	return content, nil
+}
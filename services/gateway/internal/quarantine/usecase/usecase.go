@@ -0,0 +1,309 @@
+package usecase
+
+import (
+	"context"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/quarantine"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/quarantine/models"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/quarantine/storage"
+	"github.com/CodeMaster482/minions-server/services/gateway/pkg/zipcrypto"
+)
+
+var (
+	ErrNotFound       = errors.New("sample not found in quarantine")
+	ErrInvalidHash    = errors.New("sha256 must be 64 hex characters")
+	ErrSampleTooLarge = errors.New("sample exceeds quarantine size limit")
+)
+
+const (
+	cleanupInterval  = 10 * time.Minute // Как часто удаляются просроченные образцы
+	cleanupBatchSize = 100              // Сколько образцов удаляется за один запрос к БД
+)
+
+var sha256Regexp = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Config параметры карантинного хранилища
+type Config struct {
+	Key           string        // ключ AES-256 в base64
+	Zones         []string      // образцы с этими зонами сохраняются
+	Retention     time.Duration // сколько хранится образец после последней загрузки
+	MaxSampleSize int64         // образцы больше этого размера не сохраняются
+	MaxTotalSize  int64         // при превышении удаляются давно не присылавшиеся образцы, 0 - без ограничения
+	ZipPassword   string        // пароль ZIP-архива при скачивании
+}
+
+type Usecase struct {
+	repo    quarantine.Repo
+	storage quarantine.Storage
+	aead    cipher.AEAD
+	cfg     Config
+	logger  *slog.Logger
+}
+
+func New(repo quarantine.Repo, storage quarantine.Storage, cfg Config, logger *slog.Logger) (*Usecase, error) {
+	aead, err := newAEAD(cfg.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Usecase{
+		repo:    repo,
+		storage: storage,
+		aead:    aead,
+		cfg:     cfg,
+		logger:  logger,
+	}, nil
+}
+
+// Store сохраняет образец, если его зона входит в список сохраняемых. Содержимое уже сохранённого
+// образца не перезаписывается, продлевается только срок хранения
+func (uc *Usecase) Store(ctx context.Context, sha256, filename, fileType, zone string, content []byte, userID int) error {
+	if !slices.Contains(uc.cfg.Zones, zone) {
+		return nil
+	}
+	if !sha256Regexp.MatchString(sha256) {
+		return ErrInvalidHash
+	}
+	if int64(len(content)) > uc.cfg.MaxSampleSize {
+		return fmt.Errorf("%w: %d bytes", ErrSampleTooLarge, len(content))
+	}
+
+	existing, err := uc.repo.GetSample(ctx, sha256)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		// Сначала содержимое, потом запись в БД: запись без содержимого в хранилище не появится
+		sealed, err := seal(uc.aead, sha256, content)
+		if err != nil {
+			return fmt.Errorf("error encrypting sample: %w", err)
+		}
+		if err := uc.storage.Put(ctx, sha256, sealed); err != nil {
+			uc.logger.Error("Error writing sample to quarantine storage", slog.Any("error", err))
+			return fmt.Errorf("error writing sample to quarantine storage: %w", err)
+		}
+	}
+
+	err = uc.repo.SaveSample(ctx, models.Sample{
+		Sha256:    sha256,
+		Filename:  filename,
+		Size:      int64(len(content)),
+		Zone:      zone,
+		FileType:  fileType,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(uc.cfg.Retention),
+	})
+	if err != nil {
+		return err
+	}
+
+	uc.audit(ctx, sha256, userID, models.ActionStore, "")
+	uc.logger.Info("Sample stored in quarantine", slog.String("sha256", sha256), slog.String("zone", zone))
+
+	return nil
+}
+
+// List возвращает страницу образцов, недавно присланные первыми. Просмотр записывается в журнал
+func (uc *Usecase) List(ctx context.Context, limit, offset, userID int, remoteAddr string) (*models.SampleList, error) {
+	samples, err := uc.repo.ListSamples(ctx, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	total, size, err := uc.repo.SamplesTotal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	uc.audit(ctx, "", userID, models.ActionList, remoteAddr)
+
+	return &models.SampleList{Samples: samples, Total: total, TotalSize: size}, nil
+}
+
+// Download расшифровывает образец и возвращает его в ZIP-архиве с паролем. Каждое скачивание
+// записывается в журнал до выдачи архива: если журнал недоступен, образец не выдаётся
+func (uc *Usecase) Download(ctx context.Context, sha256 string, userID int, remoteAddr string) (*models.Sample, []byte, error) {
+	sample, err := uc.sample(ctx, sha256)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sealed, err := uc.storage.Get(ctx, sha256)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			uc.logger.Error("Quarantine sample is missing from storage", slog.String("sha256", sha256))
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, fmt.Errorf("error reading sample from quarantine storage: %w", err)
+	}
+
+	content, err := open(uc.aead, sha256, sealed)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Пароль нужен не для секретности, а чтобы антивирус и почтовые фильтры не удалили образец
+	// по дороге и его нельзя было запустить случайно
+	archive, err := zipcrypto.Encrypt(sha256, sample.CreatedAt, content, uc.cfg.ZipPassword)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error packing sample: %w", err)
+	}
+
+	if err := uc.repo.SaveAudit(ctx, models.AuditEntry{Sha256: sha256, UserID: userID, Action: models.ActionDownload, RemoteAddr: remoteAddr}); err != nil {
+		return nil, nil, err
+	}
+
+	return sample, archive, nil
+}
+
+// Delete удаляет образец из хранилища и БД
+func (uc *Usecase) Delete(ctx context.Context, sha256 string, userID int, remoteAddr string) error {
+	if _, err := uc.sample(ctx, sha256); err != nil {
+		return err
+	}
+
+	if err := uc.remove(ctx, sha256); err != nil {
+		return err
+	}
+
+	uc.audit(ctx, sha256, userID, models.ActionDelete, remoteAddr)
+	uc.logger.Info("Sample deleted from quarantine", slog.String("sha256", sha256), slog.Int("user_id", userID))
+
+	return nil
+}
+
+// Audit возвращает последние записи журнала доступа, по всем образцам или по одному.
+// Сам просмотр журнала тоже записывается, после выборки, чтобы не попасть в свой же ответ
+func (uc *Usecase) Audit(ctx context.Context, sha256 string, limit, userID int, remoteAddr string) ([]models.AuditEntry, error) {
+	if sha256 != "" && !sha256Regexp.MatchString(sha256) {
+		return nil, ErrInvalidHash
+	}
+
+	entries, err := uc.repo.AuditEntries(ctx, sha256, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	uc.audit(ctx, sha256, userID, models.ActionAudit, remoteAddr)
+
+	return entries, nil
+}
+
+// Name имя проверки для /api/health
+func (uc *Usecase) Name() string {
+	return "quarantine"
+}
+
+// Check проверяет доступность хранилища образцов
+func (uc *Usecase) Check(ctx context.Context) error {
+	return uc.storage.Check(ctx)
+}
+
+// Run периодически удаляет образцы с истёкшим сроком хранения и сверх лимита объёма
+func (uc *Usecase) Run(ctx context.Context) {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			uc.cleanup(ctx)
+		}
+	}
+}
+
+func (uc *Usecase) cleanup(ctx context.Context) {
+	for {
+		samples, err := uc.repo.ExpiredSamples(ctx, time.Now(), cleanupBatchSize)
+		if err != nil || len(samples) == 0 || !uc.expire(ctx, samples) {
+			break
+		}
+	}
+
+	if uc.cfg.MaxTotalSize <= 0 {
+		return
+	}
+	for {
+		_, size, err := uc.repo.SamplesTotal(ctx)
+		if err != nil || size <= uc.cfg.MaxTotalSize {
+			return
+		}
+
+		samples, err := uc.repo.OldestSamples(ctx, cleanupBatchSize)
+		if err != nil || len(samples) == 0 {
+			return
+		}
+
+		// Удаляем ровно столько давних образцов, сколько нужно, чтобы уложиться в лимит
+		for i, sample := range samples {
+			size -= sample.Size
+			if size <= uc.cfg.MaxTotalSize {
+				samples = samples[:i+1]
+				break
+			}
+		}
+		if !uc.expire(ctx, samples) {
+			return
+		}
+	}
+}
+
+// expire удаляет образцы и возвращает false, если хотя бы один удалить не удалось
+func (uc *Usecase) expire(ctx context.Context, samples []models.Sample) bool {
+	for _, sample := range samples {
+		if err := uc.remove(ctx, sample.Sha256); err != nil {
+			uc.logger.Warn("Failed to remove expired quarantine sample", slog.String("sha256", sample.Sha256), slog.Any("error", err))
+			return false
+		}
+		uc.audit(ctx, sample.Sha256, 0, models.ActionExpire, "")
+	}
+
+	uc.logger.Info("Expired quarantine samples removed", slog.Int("count", len(samples)))
+	return true
+}
+
+// remove удаляет содержимое, затем запись в БД, чтобы содержимое не осталось без записи
+func (uc *Usecase) remove(ctx context.Context, sha256 string) error {
+	if err := uc.storage.Delete(ctx, sha256); err != nil {
+		return fmt.Errorf("error deleting sample from quarantine storage: %w", err)
+	}
+
+	return uc.repo.DeleteSample(ctx, sha256)
+}
+
+func (uc *Usecase) sample(ctx context.Context, sha256 string) (*models.Sample, error) {
+	if !sha256Regexp.MatchString(sha256) {
+		return nil, ErrInvalidHash
+	}
+
+	sample, err := uc.repo.GetSample(ctx, sha256)
+	if err != nil {
+		return nil, err
+	}
+	if sample == nil {
+		return nil, ErrNotFound
+	}
+
+	return sample, nil
+}
+
+// audit записывает действие в журнал. Ошибка журнала не отменяет уже выполненное действие
+func (uc *Usecase) audit(ctx context.Context, sha256 string, userID int, action, remoteAddr string) {
+	err := uc.repo.SaveAudit(ctx, models.AuditEntry{Sha256: sha256, UserID: userID, Action: action, RemoteAddr: remoteAddr})
+	if err != nil {
+		uc.logger.Error("Failed to write quarantine audit entry",
+			slog.String("sha256", sha256),
+			slog.String("action", action),
+			slog.Any("error", err),
+		)
+	}
+}
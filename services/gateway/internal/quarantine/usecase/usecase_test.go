@@ -0,0 +1,161 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/quarantine/models"
+	scanUsecase "github.com/CodeMaster482/minions-server/services/gateway/internal/scan/usecase"
+)
+
+// memoryRepo хранит записи карантина в памяти
+type memoryRepo struct {
+	samples map[string]models.Sample
+	audit   []models.AuditEntry
+}
+
+func (r *memoryRepo) GetSample(_ context.Context, sha256 string) (*models.Sample, error) {
+	sample, ok := r.samples[sha256]
+	if !ok {
+		return nil, nil
+	}
+	return &sample, nil
+}
+
+func (r *memoryRepo) SaveSample(_ context.Context, sample models.Sample) error {
+	sample.CreatedAt = time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	r.samples[sample.Sha256] = sample
+	return nil
+}
+
+func (r *memoryRepo) DeleteSample(_ context.Context, sha256 string) error {
+	delete(r.samples, sha256)
+	return nil
+}
+
+func (r *memoryRepo) ListSamples(context.Context, int, int) ([]models.Sample, error) { return nil, nil }
+func (r *memoryRepo) SamplesTotal(context.Context) (int, int64, error)               { return 0, 0, nil }
+func (r *memoryRepo) ExpiredSamples(context.Context, time.Time, int) ([]models.Sample, error) {
+	return nil, nil
+}
+func (r *memoryRepo) OldestSamples(context.Context, int) ([]models.Sample, error) { return nil, nil }
+func (r *memoryRepo) SaveAudit(_ context.Context, entry models.AuditEntry) error {
+	r.audit = append(r.audit, entry)
+	return nil
+}
+func (r *memoryRepo) AuditEntries(context.Context, string, int) ([]models.AuditEntry, error) {
+	return slices.Clone(r.audit), nil
+}
+
+// memoryStorage хранит зашифрованные образцы в памяти
+type memoryStorage map[string][]byte
+
+func (s memoryStorage) Put(_ context.Context, key string, data []byte) error {
+	s[key] = data
+	return nil
+}
+
+func (s memoryStorage) Get(_ context.Context, key string) ([]byte, error) { return s[key], nil }
+
+func (s memoryStorage) Delete(_ context.Context, key string) error {
+	delete(s, key)
+	return nil
+}
+
+func (s memoryStorage) Check(context.Context) error { return nil }
+
+func TestDownloadUnpacksWithPassword(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+
+	uc, err := New(&memoryRepo{samples: make(map[string]models.Sample)}, memoryStorage{}, Config{
+		Key:           base64.StdEncoding.EncodeToString(make([]byte, 32)),
+		Zones:         []string{"Red"},
+		Retention:     time.Hour,
+		MaxSampleSize: 1 << 20,
+		ZipPassword:   "quarantine-secret",
+	}, logger)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	content := []byte("MZ\x90\x00 not really an executable, but good enough for quarantine")
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	if err := uc.Store(ctx, hash, "invoice.exe", "pe", "Red", content, 1); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+
+	_, archive, err := uc.Download(ctx, hash, 1, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+
+	scan := scanUsecase.New(nil, nil, nil, logger)
+
+	report, err := scan.UnpackArchive(archive, []string{"quarantine-secret"})
+	if err != nil {
+		t.Fatalf("UnpackArchive() error = %v", err)
+	}
+	if len(report.Entries) != 1 {
+		t.Fatalf("UnpackArchive() entries = %d, want 1", len(report.Entries))
+	}
+
+	entry := report.Entries[0]
+	if entry.Path != hash || entry.Sha256 != hash || entry.Size != int64(len(content)) || !entry.Encrypted || entry.Error != "" {
+		t.Errorf("UnpackArchive() entry = %+v, want decrypted sample %s", entry, hash)
+	}
+
+	// Общепринятый пароль "infected" UnpackArchive пробует всегда, архив карантина им не открывается
+	report, err = scan.UnpackArchive(archive, []string{"wrong"})
+	if err != nil {
+		t.Fatalf("UnpackArchive() with wrong password error = %v", err)
+	}
+	if entry := report.Entries[0]; entry.Sha256 != "" || entry.Error == "" {
+		t.Errorf("UnpackArchive() with wrong password entry = %+v, want error", entry)
+	}
+}
+
+func TestViewsAreAudited(t *testing.T) {
+	ctx := context.Background()
+	repo := &memoryRepo{samples: make(map[string]models.Sample)}
+
+	uc, err := New(repo, memoryStorage{}, Config{
+		Key:           base64.StdEncoding.EncodeToString(make([]byte, 32)),
+		Zones:         []string{"Red"},
+		Retention:     time.Hour,
+		MaxSampleSize: 1 << 20,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if _, err := uc.List(ctx, 50, 0, 7, "10.0.0.5:51234"); err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	entries, err := uc.Audit(ctx, "", 50, 7, "10.0.0.5:51234")
+	if err != nil {
+		t.Fatalf("Audit() error = %v", err)
+	}
+	// Просмотр журнала записывается после выборки и в свой ответ не попадает
+	if len(entries) != 1 || entries[0].Action != models.ActionList {
+		t.Errorf("Audit() entries = %+v, want one list entry", entries)
+	}
+
+	want := []models.AuditEntry{
+		{UserID: 7, Action: models.ActionList, RemoteAddr: "10.0.0.5:51234"},
+		{UserID: 7, Action: models.ActionAudit, RemoteAddr: "10.0.0.5:51234"},
+	}
+	if !slices.Equal(repo.audit, want) {
+		t.Errorf("audit = %+v, want %+v", repo.audit, want)
+	}
+}
@@ -1,8 +1,9 @@
 package http
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/usecase"
@@ -10,7 +11,7 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
-	"net/url"
+	"time"
 
 	"github.com/CodeMaster482/minions-server/common"
 	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan"
@@ -39,6 +40,9 @@ const (
 	ScanFileBadRequestMsg          = "Bad Request: Failed to process the uploaded file."
 	ScanFilePayloadTooLargeMsg     = "Payload Too Large: File size exceeds the 256 MB limit."
 	ScanFileInternalServerErrorMsg = "Internal Server Error: Unable to process the file."
+	ScanFileBadArchiveMsg          = "Bad Request: Archive is damaged and cannot be unpacked."
+	ScanFileTypeDeniedMsg          = "Unsupported Media Type: File type is not allowed."
+	ScanFileTypeTooLargeMsg        = "Payload Too Large: File size exceeds the limit for this file type."
 )
 
 // Size constants
@@ -47,20 +51,30 @@ const (
 	MaxUploadSize = 256 * MB // Максимальный размер файла 256MB
 )
 
+const QuarantineTimeout = 5 * time.Minute // Максимальное время сохранения образца в карантин
+
 type Handler struct {
-	iamToken       string
 	apiKey         string
-	folderID       string
+	uploadPolicy   models.UploadPolicy
+	refreshPolicy  models.RefreshPolicy
+	ocr            scan.OCREngine
+	rules          scan.RuleEngine
+	uploads        scan.UploadStore
+	quarantine     scan.Quarantine
 	usecase        scan.Usecase
 	sessionManager *scs.SessionManager
 	logger         *slog.Logger
 }
 
-func New(apiKey string, iamToken string, folderID string, uc scan.Usecase, sessionManager *scs.SessionManager, logger *slog.Logger) *Handler {
+func New(apiKey string, uploadPolicy models.UploadPolicy, refreshPolicy models.RefreshPolicy, ocr scan.OCREngine, rules scan.RuleEngine, uploads scan.UploadStore, quarantine scan.Quarantine, uc scan.Usecase, sessionManager *scs.SessionManager, logger *slog.Logger) *Handler {
 	return &Handler{
-		iamToken:       iamToken,
-		folderID:       folderID,
 		apiKey:         apiKey,
+		uploadPolicy:   uploadPolicy,
+		refreshPolicy:  refreshPolicy,
+		ocr:            ocr,
+		rules:          rules,
+		uploads:        uploads,
+		quarantine:     quarantine,
 		usecase:        uc,
 		sessionManager: sessionManager,
 		logger:         logger,
@@ -71,15 +85,28 @@ func New(apiKey string, iamToken string, folderID string, uc scan.Usecase, sessi
 // @Summary Проверка веб-адреса, IP или домена через Kaspersky API
 // @Description Эндпоинт для проверки веб-адреса, IP или домена и получения объединенного ответа с информацией из Kaspersky API.
 // В зависимости от типа входных данных (IPv4, URL или домен), возвращаются соответствующие поля в ответе.
+// Если Kaspersky API недоступен, исчерпана квота или вердикт серый, в поле LocalHeuristic возвращается локальная оценка риска.
+// Ответ, целиком построенный эвристикой, помечается поставщиком local_heuristic в Provenance.
+// Параметр refresh=true пропускает кэш Redis и PostgreSQL и перезаписывает сохранённый ответ свежим ответом Kaspersky API,
+// он ограничен по роли и числу запросов в час. cache=only никогда не обращается к Kaspersky API.
+// В поле Provenance и заголовках X-Cache и Age возвращается, откуда взят ответ: слой (redis, postgres, upstream, local),
+// поставщик вердикта, время его получения, возраст, оставшийся срок в кэше и индикатор в том виде, в котором его искал шлюз.
 // @ID domain-check
 // @Tags Scan
 // @Accept json
 // @Produce json
 // @Param request query string true "Веб-адрес, IP или домен для проверки" example(www.example.com)
+// @Param refresh query bool false "Проверить в Kaspersky API в обход кэша"
+// @Param cache query string false "only - ответить только из кэша или БД" Enums(only)
 // @Success 200 {object} models.ResponseFromAPI "Успешная проверка. Возвращается объединенный ответ с информацией."
+// @Header 200 {string} X-Cache "HIT from redis, HIT from postgres или MISS"
+// @Header 200 {integer} Age "Возраст вердикта в секундах"
 // @Failure 400 {object} common.ErrorResponse "Bad Request: Incorrect query."
-// @Failure 404 {object} common.ErrorResponse "Not Found: Lookup results not found."
+// @Failure 403 {object} common.ErrorResponse "Forbidden: Refresh is not allowed for this role."
+// @Failure 404 {object} common.ErrorResponse "Not Found: No cached verdict for this request."
+// @Failure 429 {object} common.ErrorResponse "Too Many Requests: Refresh limit exceeded, try again later."
 // @Failure 500 {object} common.ErrorResponse "Internal Server Error"
+// @Failure 503 {object} common.ErrorResponse "Service Unavailable: Refresh limit cannot be checked, try again later."
 //
 //	@Example 200 Success {
 //	  "Zone": "Green",
@@ -161,7 +188,7 @@ func (h *Handler) DomainIPUrl(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	logger.Info("Request from user", requestParam)
+	logger.Info("Request from user", slog.String("request", requestParam))
 
 	inputType, requestParam, err := h.usecase.DetermineInputType(requestParam)
 	if err != nil {
@@ -170,216 +197,122 @@ func (h *Handler) DomainIPUrl(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Получаем userID из сессии, если пользователь авторизован
-	userID, ok := h.sessionManager.Get(ctx, "user_id").(int)
-	if !ok {
-		userID = 0
-	}
-
+	userID := h.userID(ctx)
 	logger.Info("User ID (unregistered is 0)", slog.Any("userID", userID))
 
-	// Проверяем наличие в Redis
-	var response models.ResponseFromAPI
-	cachedResponse, err := h.usecase.CachedResponse(ctx, inputType, requestParam)
-	if err == nil {
-		// Если найдено в Redis, возвращаем кэшированный ответ
-		if err := json.Unmarshal([]byte(cachedResponse), &response); err == nil {
-			w.WriteHeader(http.StatusOK)
-			w.Header().Set("Content-Type", "application/json")
-			err = json.NewEncoder(w).Encode(response)
-			if err != nil {
-				common.RespondWithError(w, http.StatusInternalServerError, FailedToEncodeResponse)
-				logger.Error(FailedToEncodeResponse, slog.Any("error", err))
-
-				return
-			}
-
-			// Обновляем счётчики
-			_, err = h.usecase.SavedResponse(ctx, inputType, requestParam)
-			if err != nil {
-				logger.Warn("Can't update count in PostgreSQL", slog.Any("error", err))
-			}
-
-			// Обновляем пользовательский счётчик, если пользователь авторизован
-			if userID != 0 {
-				err = h.usecase.SaveUserStats(ctx, response.Zone, inputType, requestParam, userID)
-				if err != nil {
-					logger.Warn("Can't update user stats in PostgreSQL", slog.Any("error", err))
-				}
-			}
-
-			logger.Info("Returning cached response from Redis", slog.String("request", requestParam))
-			return
-		}
-		// Если произошла ошибка при разборе кэша, продолжаем обработку
-	}
-
-	// Ищем в PostgreSQL
-	savedResponse, err := h.usecase.SavedResponse(ctx, inputType, requestParam)
-	if err == nil {
-		if len(savedResponse) != 0 {
-			// Если найдено в PostgreSQL, обновляем Redis и возвращаем ответ
-			if err := json.Unmarshal([]byte(savedResponse), &response); err == nil {
-				err := h.usecase.SetCachedResponse(ctx, savedResponse, inputType, requestParam)
-				if err != nil {
-					logger.Warn("Cache is not updated in Redis", slog.Any("error", err))
-				}
-
-				w.WriteHeader(http.StatusOK)
-				w.Header().Set("Content-Type", "application/json")
-				err = json.NewEncoder(w).Encode(response)
-				if err != nil {
-					common.RespondWithError(w, http.StatusInternalServerError, FailedToEncodeResponse)
-					logger.Error(FailedToEncodeResponse, slog.Any("error", err))
-
-					return
-				}
-
-				// Обновляем счётчики
-				_, err = h.usecase.SavedResponse(ctx, inputType, requestParam)
-				if err != nil {
-					logger.Warn("Can't update count in PostgreSQL", slog.Any("error", err))
-				}
-
-				// Обновляем пользовательский счётчик, если пользователь авторизован
-				if userID != 0 {
-					err = h.usecase.SaveUserStats(ctx, response.Zone, inputType, requestParam, userID)
-					if err != nil {
-						logger.Warn("Can't update user stats in PostgreSQL", slog.Any("error", err))
-					}
-				}
-
-				logger.Info("Response from DB was successfully found", slog.String("request", requestParam))
-				return
-			}
-		} else {
-			logger.Warn("Got empty saved response")
-		}
-	} else if !errors.Is(err, usecase.ErrRowNotFound) {
-		common.RespondWithError(w, http.StatusInternalServerError, InternalServerErrorMsg)
-		logger.Error("No records found in PostgreSQL", slog.Any("error", err))
-
-		return
-	}
-
-	var apiPath string
-	switch inputType {
-	case "ip":
-		apiPath = "/api/v1/search/ip"
-	case "url":
-		apiPath = "/api/v1/search/url"
-	case "domain":
-		apiPath = "/api/v1/search/domain"
-	default:
-		common.RespondWithError(w, http.StatusBadRequest, UnsupportedInputType)
-		logger.Error(UnsupportedInputType, slog.String("inputType", inputType))
-
-		return
-	}
-
-	apiURL := fmt.Sprintf("https://opentip.kaspersky.com%s?request=%s", apiPath, url.QueryEscape(requestParam))
-
-	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
-	if err != nil {
-		common.RespondWithError(w, http.StatusInternalServerError, InternalServerErrorMsg)
-		logger.Error(FailedToCreateRequest, slog.Any("error", err))
-
+	mode, ok := h.lookupMode(w, r, logger, userID)
+	if !ok {
 		return
 	}
 
-	req.Header.Set("x-api-key", h.apiKey)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	// Кэш Redis -> PostgreSQL -> Kaspersky API, refresh пропускает кэш, cache=only - Kaspersky API
+	response, err := h.usecase.LookupWithMode(ctx, inputType, requestParam, mode, userID, h.apiKey)
 	if err != nil {
-		common.RespondWithError(w, http.StatusInternalServerError, InternalServerErrorMsg)
-		logger.Error(FailedToSendRequest, slog.Any("error", err))
-
-		return
-	}
-	defer resp.Body.Close()
-
-	switch resp.StatusCode {
-	case http.StatusOK:
-		// Всё прошло хорошо, парсим ответ
-	case http.StatusBadRequest:
-		common.RespondWithError(w, http.StatusBadRequest, BadRequestMsg)
-		logger.Error(BadRequestMsg)
-		return
-	case http.StatusUnauthorized:
-		common.RespondWithError(w, http.StatusUnauthorized, UnauthorizedMsg)
-		logger.Error(UnauthorizedMsg)
-		return
-	case http.StatusForbidden:
-		common.RespondWithError(w, http.StatusForbidden, ForbiddenMsg)
-		logger.Error(ForbiddenMsg)
-		return
-	case http.StatusNotFound:
-		common.RespondWithError(w, http.StatusNotFound, NotFoundMsg)
-		logger.Error(NotFoundMsg)
-		return
-	default:
-		common.RespondWithError(w, http.StatusInternalServerError, KasperskyUnexpectedError)
-		logger.Error(KasperskyUnexpectedError, slog.Int("status_code", resp.StatusCode))
+		switch {
+		case errors.Is(err, usecase.ErrNotCached):
+			common.RespondWithError(w, http.StatusNotFound, NotCachedMsg)
+			logger.Info(NotCachedMsg)
+		case errors.Is(err, usecase.ErrKasperskyBadRequest):
+			common.RespondWithError(w, http.StatusBadRequest, BadRequestMsg)
+			logger.Error(BadRequestMsg)
+		case errors.Is(err, usecase.ErrKasperskyUnauthorized):
+			common.RespondWithError(w, http.StatusUnauthorized, UnauthorizedMsg)
+			logger.Error(UnauthorizedMsg)
+		case errors.Is(err, usecase.ErrUnsupportedFlow):
+			common.RespondWithError(w, http.StatusBadRequest, UnsupportedInputType)
+			logger.Error(UnsupportedInputType, slog.String("inputType", inputType))
+		case usecase.HeuristicFallback(err):
+			// Kaspersky API не знает индикатор, квота исчерпана или API не ответил - отдаём хотя бы локальную оценку
+			response := h.usecase.HeuristicResponse(inputType, requestParam, mode)
+			setProvenanceHeaders(w, response.Provenance)
+			RespondWithJSON(w, http.StatusOK, response)
+			logger.Warn("Responding with local heuristic", slog.String("reason", lookupErrorMsg(err)), slog.Any("error", err))
+		default:
+			common.RespondWithError(w, http.StatusInternalServerError, InternalServerErrorMsg)
+			logger.Error(InternalServerErrorMsg, slog.Any("error", err))
+		}
 		return
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		common.RespondWithError(w, http.StatusInternalServerError, InternalServerErrorMsg)
-		logger.Error(FailedToReadResponse, slog.Any("error", err))
-
-		return
-	}
+	// Эвристика не сохраняется в БД и кэш, она пересчитывается при каждой выдаче
+	h.attachHeuristic(inputType, requestParam, response)
+	setProvenanceHeaders(w, response.Provenance)
+	RespondWithJSON(w, http.StatusOK, response)
 
-	var apiResponse models.ResponseFromAPI
-	if err := json.Unmarshal(body, &apiResponse); err != nil {
-		common.RespondWithError(w, http.StatusInternalServerError, InternalServerErrorMsg)
-		logger.Error(FailedToParseResponse, slog.Any("error", err))
+	logger.Info("Successfully processed request", slog.String("request_param", requestParam), slog.String("zone", response.Zone))
+}
 
-		return
+// userID возвращает идентификатор пользователя из сессии, 0 для неавторизованного
+func (h *Handler) userID(ctx context.Context) int {
+	userID, ok := h.sessionManager.Get(ctx, "user_id").(int)
+	if !ok {
+		return 0
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-
-	respJson, err := json.Marshal(apiResponse)
-	if err != nil {
-		common.RespondWithError(w, http.StatusInternalServerError, FailedToEncodeResponse)
-		logger.Error(FailedToEncodeResponse, slog.Any("error", err))
+	return userID
+}
 
-		return
+// lookupErrorMsg возвращает сообщение для ошибки проверки индикатора
+func lookupErrorMsg(err error) string {
+	switch {
+	case errors.Is(err, usecase.ErrKasperskyBadRequest):
+		return BadRequestMsg
+	case errors.Is(err, usecase.ErrKasperskyUnauthorized):
+		return UnauthorizedMsg
+	case errors.Is(err, usecase.ErrKasperskyForbidden):
+		return ForbiddenMsg
+	case errors.Is(err, usecase.ErrKasperskyNotFound):
+		return NotFoundMsg
+	case errors.Is(err, usecase.ErrKasperskyUnavailable):
+		return FailedToSendRequest
+	case errors.Is(err, usecase.ErrKasperskyPayloadTooLarge):
+		return ScanFilePayloadTooLargeMsg
+	case errors.Is(err, usecase.ErrUnsupportedFlow):
+		return UnsupportedInputType
+	case errors.Is(err, usecase.ErrKasperskyUnexpected):
+		return KasperskyUnexpectedError
+	default:
+		return InternalServerErrorMsg
 	}
+}
 
-	w.Write(respJson)
-
-	err = h.usecase.SaveResponse(ctx, string(respJson), apiResponse.Zone, inputType, requestParam, userID)
-	if err != nil {
-		logger.Warn("Error saving response", slog.Any("error", err))
-
+// attachHeuristic добавляет локальную оценку риска, если Kaspersky API не дал определённого вердикта
+func (h *Handler) attachHeuristic(inputType, requestParam string, response *models.ResponseFromAPI) {
+	if response.Zone != "" && response.Zone != "Grey" {
 		return
 	}
 
-	err = h.usecase.SetCachedResponse(ctx, string(respJson), inputType, requestParam)
-	if err != nil {
-		logger.Warn("Cache is not updated in Redis", slog.Any("error", err))
-	}
-
-	logger.Info("Successfully processed request", slog.String("request_param", requestParam), slog.String("zone", apiResponse.Zone))
+	response.LocalHeuristic = h.usecase.EstimateRisk(inputType, requestParam, response)
 }
 
 // ScanFile
 // @Summary Сканирует файл с использованием API Kaspersky
 // @Description Эндпоинт для сканирования файла и получения базового отчета от API Kaspersky.
+// @Description С параметром unpack=true архив распаковывается в памяти, хеш каждого файла проверяется отдельно,
+// @Description в поле Archive возвращается дерево файлов с вердиктами и итоговая зона.
+// @Description Для исполняемых файлов PE, ELF и Mach-O в поле LocalAnalysis возвращается локальный разбор:
+// @Description архитектура, время компиляции, импорты, imphash, секции с энтропией и наличие подписи.
+// @Description Для документов Office и PDF в поле DocumentAnalysis возвращаются макросы, автозапуск, внешние шаблоны и OLE-связи,
+// @Description JavaScript и автоматические действия PDF, а также проверка найденных в документе ссылок.
+// @Description Тип файла определяется по сигнатуре, а не по имени, и возвращается в поле FileType вместе с признаками
+// @Description несовпадения расширения с содержимым и двойного расширения (invoice.pdf.exe).
+// @Description Запрещённые политикой загрузки типы отклоняются до отправки в Kaspersky API.
+// @Description Файл проверяется по локальным правилам (шаблоны байтов, регулярные выражения, тип, размер, энтропия),
+// @Description сработавшие правила возвращаются в поле RuleMatches и могут повысить итоговую зону.
 // @ID file-scan
 // @Tags Scan
 // @Accept multipart/form-data
 // @Produce json
 // @Param file formData file true "File to scan"
+// @Param unpack query bool false "Распаковать архив (ZIP, TAR, GZ, BZ2) и проверить хеш каждого файла"
+// @Param password formData string false "Пароль к зашифрованному ZIP, пароль infected пробуется всегда"
 // @Success 200 {object} models.FileScanResponse "Successful scan. Returns basic information about the analyzed file."
+// @Header 200 {string} X-Cache "MISS: файл всегда проверяется в Kaspersky API"
+// @Header 200 {integer} Age "Возраст вердикта в секундах"
 // @Failure 400 {object} common.ErrorResponse "Bad Request: Failed to process the uploaded file."
 // @Failure 401 {object} common.ErrorResponse "Unauthorized: Authentication failed."
 // @Failure 413 {object} common.ErrorResponse "Payload Too Large: File size exceeds the 256 Mb limit."
+// @Failure 415 {object} common.ErrorResponse "Unsupported Media Type: File type is not allowed."
 // @Failure 500 {object} common.ErrorResponse "Internal Server Error: Unable to process the file."
 //
 //	@Example 200 Success {
@@ -474,70 +407,179 @@ func (h *Handler) ScanFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	apiURL := fmt.Sprintf("https://opentip.kaspersky.com/api/v1/scan/file?filename=%s", url.QueryEscape(filename))
-
-	apiReq, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewReader(fileContent))
+	response, err := h.scanFileContent(ctx, logger, filename, fileContent, fileScanOptions{
+		unpack:   r.URL.Query().Get("unpack") == "true",
+		password: r.FormValue("password"),
+	})
 	if err != nil {
-		common.RespondWithError(w, http.StatusInternalServerError, ScanFileInternalServerErrorMsg)
-		logger.Error(ScanFileInternalServerErrorMsg, slog.Any("error", err))
+		h.respondFileScanError(w, logger, err)
 		return
 	}
 
-	apiReq.Header.Set("x-api-key", h.apiKey)
-	apiReq.Header.Set("Content-Type", "application/octet-stream")
+	setProvenanceHeaders(w, response.Provenance)
+	RespondWithJSON(w, http.StatusOK, response)
+	logger.Info("Successfully processed file scan", slog.String("filename", filename))
+}
+
+// errBadArchive архив не удалось распаковать
+var errBadArchive = errors.New("bad archive")
 
-	client := &http.Client{}
-	apiResp, err := client.Do(apiReq)
-	if err != nil {
-		common.RespondWithError(w, http.StatusInternalServerError, ScanFileInternalServerErrorMsg)
-		logger.Error(ScanFileInternalServerErrorMsg, slog.Any("error", err))
-		return
+// fileScanOptions параметры проверки файла
+type fileScanOptions struct {
+	unpack   bool   // распаковать архив и проверить каждый файл
+	password string // пароль к зашифрованному ZIP
+}
+
+// scanFileContent проверяет содержимое файла: политика загрузки, Kaspersky API, локальный разбор,
+// распаковка архива и локальные правила. Используется всеми эндпоинтами, которые получают файл целиком
+func (h *Handler) scanFileContent(ctx context.Context, logger *slog.Logger, filename string, fileContent []byte, opts fileScanOptions) (*models.FileScanResponse, error) {
+	// Индикаторы из документа и архива попадают в историю вместе с самим файлом
+	ctx = usecase.WithSource(ctx, models.ScanSourceFile)
+
+	// Имени файла не доверяем: тип определяется по содержимому, политика применяется до отправки в Kaspersky API
+	fileType := h.usecase.DetectFileType(filename, fileContent)
+	if err := h.usecase.CheckUploadPolicy(h.uploadPolicy, fileType, int64(len(fileContent))); err != nil {
+		return nil, err
+	}
+	if fileType.ExtensionMismatch || fileType.DoubleExtension {
+		logger.Warn("File extension does not match its content",
+			slog.String("filename", filename),
+			slog.String("file_type", fileType.Type),
+		)
+	}
+
+	// Распаковываем до отправки файла, чтобы не тратить квоту на битый архив
+	var archive *models.ArchiveReport
+	if opts.unpack {
+		var passwords []string
+		if opts.password != "" {
+			passwords = append(passwords, opts.password)
+		}
+
+		var err error
+		archive, err = h.usecase.UnpackArchive(fileContent, passwords)
+		if err != nil && !errors.Is(err, usecase.ErrNotArchive) {
+			return nil, fmt.Errorf("%w: %w", errBadArchive, err)
+		}
 	}
-	defer apiResp.Body.Close()
 
-	body, err := io.ReadAll(apiResp.Body)
+	apiResponse, err := h.usecase.RequestKasperskyFile(ctx, filename, fileContent, h.apiKey)
 	if err != nil {
-		common.RespondWithError(w, http.StatusInternalServerError, ScanFileInternalServerErrorMsg)
-		logger.Error(ScanFileInternalServerErrorMsg, slog.Any("error", err))
-		return
+		return nil, err
 	}
 
-	switch apiResp.StatusCode {
-	case http.StatusOK:
-		var apiResponse models.FileScanResponse
-		if err := json.Unmarshal(body, &apiResponse); err != nil {
-			common.RespondWithError(w, http.StatusInternalServerError, ScanFileInternalServerErrorMsg)
-			logger.Error(ScanFileInternalServerErrorMsg, slog.Any("error", err))
-			return
+	// Локальный разбор нужен аналитикам и при сером вердикте, поэтому сохраняется вместе с ответом
+	analysis, err := h.usecase.AnalyzeBinary(fileContent)
+	if err == nil {
+		apiResponse.LocalAnalysis = analysis
+	} else if !errors.Is(err, usecase.ErrNotExecutable) {
+		logger.Warn("Failed to analyze binary", slog.Any("error", err))
+	}
+
+	document, documentIOCs, err := h.usecase.AnalyzeDocument(fileContent)
+	if err == nil {
+		if len(documentIOCs) > MaxTextIOCs {
+			logger.Warn("Too many indicators in document, extra ones are skipped",
+				slog.Int("found", len(documentIOCs)),
+				slog.Int("limit", MaxTextIOCs),
+			)
+			documentIOCs = documentIOCs[:MaxTextIOCs]
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		if err := json.NewEncoder(w).Encode(apiResponse); err != nil {
-			common.RespondWithError(w, http.StatusInternalServerError, ScanFileInternalServerErrorMsg)
-			logger.Error(ScanFileInternalServerErrorMsg, slog.Any("error", err))
-			return
+		document.Indicators = h.lookupIOCs(ctx, logger, documentIOCs)
+		zones := []string{document.LocalZone()}
+		for _, indicator := range document.Indicators {
+			zones = append(zones, indicator.Zone())
 		}
-		logger.Info("Successfully processed file scan", slog.String("filename", filename))
+		document.Zone = models.WorstZone(zones...)
+		apiResponse.DocumentAnalysis = document
+	} else if !errors.Is(err, usecase.ErrNotDocument) {
+		logger.Warn("Failed to analyze document", slog.Any("error", err))
+	}
 
-	case http.StatusBadRequest:
-		common.RespondWithError(w, http.StatusBadRequest, BadRequestMsg)
-		logger.Error(BadRequestMsg)
-		return
+	sum := sha256.Sum256(fileContent)
+	fileHash := hex.EncodeToString(sum[:])
+	if err := h.usecase.SaveFileResult(ctx, fileHash, apiResponse, h.userID(ctx)); err != nil {
+		logger.Warn("Error saving file scan result", slog.Any("error", err))
+	}
 
-	case http.StatusUnauthorized:
-		common.RespondWithError(w, http.StatusUnauthorized, UnauthorizedMsg)
-		logger.Error(UnauthorizedMsg)
-		return
+	// Тип файла зависит от присланного имени, поэтому в сохранённый по хешу результат не попадает
+	apiResponse.FileType = fileType
 
-	case http.StatusRequestEntityTooLarge:
-		common.RespondWithError(w, http.StatusRequestEntityTooLarge, ScanFilePayloadTooLargeMsg)
-		logger.Error(ScanFilePayloadTooLargeMsg)
+	// Свои правила срабатывают и на образцы, о которых Kaspersky ещё не знает. Правила меняются,
+	// поэтому их результат тоже не сохраняется, а зона повышается только в ответе
+	apiResponse.RuleMatches = h.rules.Match(fileContent, fileType)
+	if len(apiResponse.RuleMatches) > 0 {
+		zones := []string{apiResponse.Zone}
+		for _, match := range apiResponse.RuleMatches {
+			zones = append(zones, match.Zone)
+		}
+		apiResponse.Zone = models.WorstZone(zones...)
+		logger.Info("Local rules matched", slog.Int("rules", len(apiResponse.RuleMatches)), slog.String("zone", apiResponse.Zone))
+	}
+
+	if archive != nil {
+		h.checkArchive(ctx, logger, archive)
+		archive.Zone = models.WorstZone(apiResponse.Zone, archive.Zone)
+		apiResponse.Archive = archive
+	}
+
+	zone := apiResponse.Zone
+	if archive != nil {
+		zone = archive.Zone
+	}
+	if h.quarantine != nil {
+		h.quarantineSample(ctx, logger, fileHash, filename, fileType.Type, zone, fileContent)
+	}
+	h.usecase.NotifyFileScanned(ctx, fileHash, filename, fileType.Type, zone, apiResponse, h.userID(ctx))
+
+	return apiResponse, nil
+}
+
+// quarantineSample сохраняет образец в карантин в фоне, чтобы запись в хранилище не задерживала ответ.
+// Ошибка сохранения на результат проверки не влияет
+func (h *Handler) quarantineSample(ctx context.Context, logger *slog.Logger, fileHash, filename, fileType, zone string, content []byte) {
+	userID := h.userID(ctx)
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), QuarantineTimeout)
+
+	go func() {
+		defer cancel()
+
+		if err := h.quarantine.Store(ctx, fileHash, filename, fileType, zone, content, userID); err != nil {
+			logger.Error("Failed to store sample in quarantine", slog.String("sha256", fileHash), slog.Any("error", err))
+		}
+	}()
+}
+
+// respondFileScanError отвечает клиенту кодом, соответствующим ошибке проверки файла
+func (h *Handler) respondFileScanError(w http.ResponseWriter, logger *slog.Logger, err error) {
+	status, msg := fileScanError(err)
+	common.RespondWithError(w, status, msg)
+	if status >= http.StatusInternalServerError {
+		logger.Error(msg, slog.Any("error", err))
 		return
+	}
+	logger.Warn(msg, slog.Any("error", err))
+}
 
+// fileScanError возвращает код ответа и сообщение для ошибки проверки файла
+func fileScanError(err error) (int, string) {
+	switch {
+	case errors.Is(err, usecase.ErrFileTypeDenied):
+		return http.StatusUnsupportedMediaType, ScanFileTypeDeniedMsg
+	case errors.Is(err, usecase.ErrFileTooLarge):
+		return http.StatusRequestEntityTooLarge, ScanFileTypeTooLargeMsg
+	case errors.Is(err, errBadArchive):
+		return http.StatusBadRequest, ScanFileBadArchiveMsg
+	case errors.Is(err, usecase.ErrKasperskyBadRequest):
+		return http.StatusBadRequest, BadRequestMsg
+	case errors.Is(err, usecase.ErrKasperskyUnauthorized):
+		return http.StatusUnauthorized, UnauthorizedMsg
+	case errors.Is(err, usecase.ErrKasperskyPayloadTooLarge):
+		return http.StatusRequestEntityTooLarge, ScanFilePayloadTooLargeMsg
+	case errors.Is(err, usecase.ErrKasperskyUnexpected):
+		return http.StatusInternalServerError, KasperskyUnexpectedError
 	default:
-		common.RespondWithError(w, http.StatusInternalServerError, KasperskyUnexpectedError)
-		logger.Error(KasperskyUnexpectedError, slog.Int("status_code", apiResp.StatusCode))
-		return
+		return http.StatusInternalServerError, ScanFileInternalServerErrorMsg
 	}
 }
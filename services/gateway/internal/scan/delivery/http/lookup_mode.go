@@ -0,0 +1,100 @@
+package http
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/CodeMaster482/minions-server/common"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+const (
+	LookupModeConflictMsg  = "Bad Request: refresh and cache=only cannot be combined."
+	LookupModeInvalidMsg   = "Bad Request: refresh must be a boolean and cache must be 'only'."
+	RefreshForbiddenMsg    = "Forbidden: Refresh is not allowed for this role."
+	RefreshLimitMsg        = "Too Many Requests: Refresh limit exceeded, try again later."
+	NotCachedMsg           = "Not Found: No cached verdict for this request."
+	RefreshUnavailableMsg  = "Service Unavailable: Refresh limit cannot be checked, try again later."
+	refreshLimitRetryAfter = "3600" // Окно ограничения проверок в обход кэша, в секундах
+)
+
+// lookupMode разбирает параметры refresh и cache и проверяет, разрешён ли пользователю обход кэша.
+// При ошибке отвечает клиенту сам и возвращает false
+func (h *Handler) lookupMode(w http.ResponseWriter, r *http.Request, logger *slog.Logger, userID int) (string, bool) {
+	query := r.URL.Query()
+
+	refresh := false
+	if raw := query.Get("refresh"); raw != "" {
+		var err error
+		if refresh, err = strconv.ParseBool(raw); err != nil {
+			common.RespondWithError(w, http.StatusBadRequest, LookupModeInvalidMsg)
+			logger.Warn(LookupModeInvalidMsg, slog.String("refresh", raw))
+			return "", false
+		}
+	}
+
+	cache := query.Get("cache")
+	if cache != "" && cache != "only" {
+		common.RespondWithError(w, http.StatusBadRequest, LookupModeInvalidMsg)
+		logger.Warn(LookupModeInvalidMsg, slog.String("cache", cache))
+		return "", false
+	}
+
+	switch {
+	case refresh && cache != "":
+		common.RespondWithError(w, http.StatusBadRequest, LookupModeConflictMsg)
+		logger.Warn(LookupModeConflictMsg)
+		return "", false
+	case cache != "":
+		return models.LookupModeCacheOnly, true
+	case !refresh:
+		return models.LookupModeDefault, true
+	}
+
+	if !h.refreshAllowed(r, userID) {
+		common.RespondWithError(w, http.StatusForbidden, RefreshForbiddenMsg)
+		logger.Warn(RefreshForbiddenMsg, slog.Int("user_id", userID))
+		return "", false
+	}
+
+	// Квота Kaspersky API общая на всех, поэтому обход кэша ограничивается для каждого пользователя,
+	// а для неавторизованных - для каждого IP-адреса
+	subject := "user:" + strconv.Itoa(userID)
+	if userID == 0 {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		subject = "ip:" + host
+	}
+
+	allowed, err := h.usecase.AllowRefresh(r.Context(), subject, h.refreshPolicy.PerHour)
+	if err != nil {
+		// Без Redis ограничение не посчитать, а без ограничения обход кэша быстро исчерпает квоту Kaspersky API
+		common.RespondWithError(w, http.StatusServiceUnavailable, RefreshUnavailableMsg)
+		logger.Error(RefreshUnavailableMsg, slog.Any("error", err))
+		return "", false
+	}
+	if !allowed {
+		w.Header().Set("Retry-After", refreshLimitRetryAfter)
+		common.RespondWithError(w, http.StatusTooManyRequests, RefreshLimitMsg)
+		logger.Warn(RefreshLimitMsg, slog.String("subject", subject))
+		return "", false
+	}
+
+	return models.LookupModeRefresh, true
+}
+
+// refreshAllowed проверяет роль пользователя по RefreshPolicy
+func (h *Handler) refreshAllowed(r *http.Request, userID int) bool {
+	switch h.refreshPolicy.Role {
+	case "":
+		return true
+	case common.RoleAdmin:
+		return h.sessionManager.GetString(r.Context(), "role") == common.RoleAdmin
+	default:
+		return userID != 0
+	}
+}
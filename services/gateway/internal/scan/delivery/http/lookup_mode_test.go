@@ -0,0 +1,72 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+// refreshCounter подменяет ограничение проверок в обход кэша, остальные методы Usecase не вызываются
+type refreshCounter struct {
+	scan.Usecase
+	allowed bool
+	err     error
+}
+
+func (c *refreshCounter) AllowRefresh(context.Context, string, int) (bool, error) {
+	return c.allowed, c.err
+}
+
+func TestLookupMode(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		counter    refreshCounter
+		wantMode   string
+		wantStatus int
+	}{
+		{name: "default", query: "", wantMode: models.LookupModeDefault},
+		{name: "refresh false", query: "refresh=false", wantMode: models.LookupModeDefault},
+		{name: "cache only", query: "cache=only", wantMode: models.LookupModeCacheOnly},
+		{name: "refresh", query: "refresh=1", counter: refreshCounter{allowed: true}, wantMode: models.LookupModeRefresh},
+		{name: "refresh is not boolean", query: "refresh=yes", wantStatus: http.StatusBadRequest},
+		{name: "unknown cache value", query: "cache=never", wantStatus: http.StatusBadRequest},
+		{name: "refresh with cache only", query: "refresh=true&cache=only", wantStatus: http.StatusBadRequest},
+		{name: "refresh limit exceeded", query: "refresh=true", wantStatus: http.StatusTooManyRequests},
+		{
+			name:       "refresh limit unavailable",
+			query:      "refresh=true",
+			counter:    refreshCounter{allowed: true, err: errors.New("redis: connection refused")},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{
+				usecase: &tt.counter,
+				logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/api/scan/uri?"+tt.query, nil)
+
+			mode, ok := h.lookupMode(w, r, h.logger, 0)
+			if tt.wantStatus != 0 {
+				if ok || w.Code != tt.wantStatus {
+					t.Errorf("ok = %v, status = %d, want status %d", ok, w.Code, tt.wantStatus)
+				}
+				return
+			}
+			if !ok || mode != tt.wantMode {
+				t.Errorf("mode = %q, ok = %v, want %q", mode, ok, tt.wantMode)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+// setProvenanceHeaders дублирует сведения об источнике ответа в заголовках X-Cache и Age,
+// чтобы их видели прокси и клиенты, которые не разбирают тело
+func setProvenanceHeaders(w http.ResponseWriter, provenance *models.Provenance) {
+	if provenance == nil {
+		return
+	}
+
+	switch provenance.Layer {
+	case models.LayerRedis, models.LayerPostgres:
+		w.Header().Set("X-Cache", "HIT from "+provenance.Layer)
+	default:
+		w.Header().Set("X-Cache", "MISS")
+	}
+	w.Header().Set("Age", strconv.FormatInt(provenance.Age, 10))
+}
+
+// setProvenanceSummaryHeaders заполняет X-Cache и Age для ответа из нескольких проверок:
+// HIT - все проверки взяты из кэша или БД, Age - возраст самого старого вердикта
+func setProvenanceSummaryHeaders(w http.ResponseWriter, summary *models.ProvenanceSummary) {
+	if summary == nil {
+		return
+	}
+
+	if summary.Cached() {
+		w.Header().Set("X-Cache", "HIT")
+	} else {
+		w.Header().Set("X-Cache", "MISS")
+	}
+	w.Header().Set("Age", strconv.FormatInt(summary.Age, 10))
+}
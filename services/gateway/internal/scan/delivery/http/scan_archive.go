@@ -0,0 +1,73 @@
+package http
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+const ArchiveLookupLimitMsg = "Not checked: too many files in archive."
+
+// checkArchive проверяет хеши всех файлов архива и считает зоны: у вложенного архива
+// и у архива целиком - самая опасная из зон содержимого. Одинаковые файлы проверяются один раз
+func (h *Handler) checkArchive(ctx context.Context, logger *slog.Logger, archive *models.ArchiveReport) {
+	var iocs []models.IOC
+	seen := make(map[string]struct{})
+
+	var collect func(entries []models.ArchiveEntry)
+	collect = func(entries []models.ArchiveEntry) {
+		for _, entry := range entries {
+			if entry.Sha256 != "" {
+				if _, ok := seen[entry.Sha256]; !ok && len(iocs) < MaxTextIOCs {
+					seen[entry.Sha256] = struct{}{}
+					iocs = append(iocs, models.IOC{Type: models.IOCTypeSHA256, Value: entry.Sha256})
+				}
+			}
+			collect(entry.Entries)
+		}
+	}
+	collect(archive.Entries)
+
+	results := make(map[string]models.IOCLookup, len(iocs))
+	for _, lookup := range h.lookupIOCs(ctx, logger, iocs) {
+		results[lookup.Value] = lookup
+	}
+
+	var apply func(entries []models.ArchiveEntry) []string
+	apply = func(entries []models.ArchiveEntry) []string {
+		var zones []string
+		for i := range entries {
+			entry := &entries[i]
+
+			var entryZones []string
+			if entry.Sha256 != "" {
+				lookup, ok := results[entry.Sha256]
+				switch {
+				case !ok:
+					entry.Error = ArchiveLookupLimitMsg
+				case lookup.FileResult != nil:
+					entry.Result = lookup.FileResult
+					entryZones = append(entryZones, lookup.FileResult.Zone)
+				case entry.Error == "":
+					entry.Error = lookup.Error
+				}
+			}
+
+			entryZones = append(entryZones, apply(entry.Entries)...)
+			if len(entryZones) > 0 {
+				entry.Zone = models.WorstZone(entryZones...)
+				zones = append(zones, entry.Zone)
+			}
+		}
+
+		return zones
+	}
+	archive.Zone = models.WorstZone(apply(archive.Entries)...)
+
+	logger.Info("Archive entries checked",
+		slog.String("format", archive.Format),
+		slog.Int("hashes", len(iocs)),
+		slog.String("zone", archive.Zone),
+	)
+}
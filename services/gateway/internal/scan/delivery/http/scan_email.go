@@ -0,0 +1,222 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/CodeMaster482/minions-server/common"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/usecase"
+)
+
+const (
+	MaxEmailAttachments = 10 // Максимальное количество проверяемых вложений одного письма
+
+	ScanEmailInvalidMsg = "Bad Request: Uploaded file is not a valid .eml or .msg message."
+)
+
+// ScanEmail
+// @Summary Проверка письма (.eml, .msg) через Kaspersky API
+// @Description Эндпоинт принимает письмо в формате RFC 822 (.eml) или Outlook (.msg) и возвращает сводный отчёт.
+// @Description Проверяются домен отправителя, внешние IP из цепочки Received, все ссылки и индикаторы из текстовой и HTML-частей и вложения.
+// @Description Ссылка, видимый текст которой указывает на другой адрес, помечается Mismatch и повышает итоговую зону минимум до Yellow.
+// @Description Вложения проверяются так же, как загруженные файлы: вложение, запрещённое политикой загрузки, в Kaspersky API не отправляется и помечается Denied.
+// @Description Итоговая зона письма - самая опасная из зон индикаторов и вложений.
+// @ID email-check
+// @Tags Scan
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Письмо в формате .eml или .msg"
+// @Success 200 {object} models.EmailScanResponse "Сводный отчёт о проверке письма"
+// @Header 200 {string} X-Cache "HIT - все проверки взяты из кэша или БД, иначе MISS"
+// @Header 200 {integer} Age "Возраст самого старого вердикта в секундах"
+// @Failure 400 {object} common.ErrorResponse "Bad Request: Uploaded file is not a valid .eml or .msg message."
+// @Failure 413 {object} common.ErrorResponse "Payload Too Large: File size exceeds the limit."
+// @Failure 500 {object} common.ErrorResponse "Internal Server Error"
+// @Router /api/scan/email [post]
+func (h *Handler) ScanEmail(w http.ResponseWriter, r *http.Request) {
+	ctx := usecase.WithSource(r.Context(), models.ScanSourceBatch)
+	logger := h.logger.With(
+		slog.String("method", r.Method),
+		slog.String("url", r.URL.String()),
+		slog.String("remote_addr", r.RemoteAddr),
+	)
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxUploadSize)
+
+	if err := r.ParseMultipartForm(MaxUploadSize); err != nil {
+		if err.Error() == "http: request body too large" {
+			common.RespondWithError(w, http.StatusRequestEntityTooLarge, ScanFilePayloadTooLargeMsg)
+			logger.Error(ScanFilePayloadTooLargeMsg, slog.Any("error", err))
+			return
+		}
+		common.RespondWithError(w, http.StatusBadRequest, ScanFileBadRequestMsg)
+		logger.Error(ScanFileBadRequestMsg, slog.Any("error", err))
+		return
+	}
+
+	_, header, err := r.FormFile("file")
+	if err != nil {
+		common.RespondWithError(w, http.StatusBadRequest, ScanFileBadRequestMsg)
+		logger.Error(ScanFileBadRequestMsg, slog.Any("error", err))
+		return
+	}
+
+	logger.Info("Received email for scanning", slog.String("filename", header.Filename))
+
+	content, err := readFormFile(header)
+	if err != nil {
+		common.RespondWithError(w, http.StatusInternalServerError, ScanFileInternalServerErrorMsg)
+		logger.Error(ScanFileInternalServerErrorMsg, slog.Any("error", err))
+		return
+	}
+
+	email, err := h.usecase.ParseEmail(content)
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidEmail) {
+			common.RespondWithError(w, http.StatusBadRequest, ScanEmailInvalidMsg)
+			logger.Error(ScanEmailInvalidMsg, slog.Any("error", err))
+			return
+		}
+		common.RespondWithError(w, http.StatusInternalServerError, ScanFileInternalServerErrorMsg)
+		logger.Error(ScanFileInternalServerErrorMsg, slog.Any("error", err))
+		return
+	}
+
+	candidates := h.emailIndicators(email)
+	if len(candidates) > MaxTextIOCs {
+		logger.Warn("Too many indicators in email, extra ones are skipped",
+			slog.Int("found", len(candidates)),
+			slog.Int("limit", MaxTextIOCs),
+		)
+		candidates = candidates[:MaxTextIOCs]
+	}
+
+	iocs := make([]models.IOC, 0, len(candidates))
+	for _, candidate := range candidates {
+		iocs = append(iocs, candidate.IOC)
+	}
+
+	response := models.EmailScanResponse{
+		Subject:      email.Subject,
+		From:         email.From,
+		SenderDomain: email.SenderDomain,
+		ReceivedIPs:  email.ReceivedIPs,
+		Links:        email.Links,
+		Indicators:   h.lookupIOCs(ctx, logger, iocs),
+		Attachments:  h.scanAttachments(ctx, logger, email.Attachments),
+	}
+	for i, candidate := range candidates {
+		response.Indicators[i].Sources = candidate.Sources
+	}
+
+	var zones []string
+	for _, indicator := range response.Indicators {
+		zones = append(zones, indicator.Zone())
+	}
+	provenances := make([]*models.Provenance, 0, len(response.Indicators)+len(response.Attachments))
+	for _, indicator := range response.Indicators {
+		provenances = append(provenances, indicator.Provenance())
+	}
+	for _, attachment := range response.Attachments {
+		if attachment.Result != nil {
+			zones = append(zones, attachment.Result.Zone)
+			provenances = append(provenances, attachment.Result.Provenance)
+		}
+	}
+	for _, link := range response.Links {
+		if link.Mismatch {
+			// Подмена адреса в тексте ссылки - типичный признак фишинга
+			zones = append(zones, "Yellow")
+		}
+	}
+	response.Zone = models.WorstZone(zones...)
+	response.Provenance = models.SummarizeProvenance(provenances...)
+
+	setProvenanceSummaryHeaders(w, response.Provenance)
+	RespondWithJSON(w, http.StatusOK, response)
+	logger.Info("Successfully processed email scan",
+		slog.String("zone", response.Zone),
+		slog.Int("indicators", len(response.Indicators)),
+		slog.Int("attachments", len(response.Attachments)),
+	)
+}
+
+// emailIndicators собирает индикаторы письма: домен отправителя, IP из Received, ссылки и индикаторы из текста.
+// Индикатор, найденный в нескольких местах, проверяется один раз и помечается всеми источниками
+func (h *Handler) emailIndicators(email *models.ParsedEmail) []models.IOCLookup {
+	var candidates []models.IOCLookup
+	index := make(map[models.IOC]int)
+
+	add := func(source string, iocs ...models.IOC) {
+		for _, ioc := range iocs {
+			i, ok := index[ioc]
+			if !ok {
+				i = len(candidates)
+				index[ioc] = i
+				candidates = append(candidates, models.IOCLookup{IOC: ioc})
+			}
+
+			if !slices.Contains(candidates[i].Sources, source) {
+				candidates[i].Sources = append(candidates[i].Sources, source)
+			}
+		}
+	}
+
+	if email.SenderDomain != "" {
+		add(models.IOCSourceSender, h.usecase.ExtractIOCs(email.SenderDomain)...)
+	}
+
+	for _, ip := range email.ReceivedIPs {
+		add(models.IOCSourceReceived, models.IOC{Type: models.IOCTypeIP, Value: ip})
+	}
+
+	for _, link := range email.Links {
+		add(models.IOCSourceHref, h.usecase.ExtractIOCs(link.URL)...)
+	}
+
+	add(models.IOCSourceBody, h.usecase.ExtractIOCs(email.Text)...)
+
+	return candidates
+}
+
+// scanAttachments проверяет вложения так же, как загруженные файлы: политика загрузки, Kaspersky API,
+// локальный разбор и правила. Ошибка или запрет политикой одного вложения не прерывает проверку остальных
+func (h *Handler) scanAttachments(ctx context.Context, logger *slog.Logger, attachments []models.EmailAttachmentContent) []models.EmailAttachment {
+	if len(attachments) > MaxEmailAttachments {
+		logger.Warn("Too many attachments in email, extra ones are skipped",
+			slog.Int("found", len(attachments)),
+			slog.Int("limit", MaxEmailAttachments),
+		)
+		attachments = attachments[:MaxEmailAttachments]
+	}
+
+	results := make([]models.EmailAttachment, 0, len(attachments))
+	for _, attachment := range attachments {
+		sum := sha256.Sum256(attachment.Content)
+		result := models.EmailAttachment{
+			Filename:    attachment.Filename,
+			ContentType: attachment.ContentType,
+			Size:        len(attachment.Content),
+			Sha256:      hex.EncodeToString(sum[:]),
+		}
+
+		res, err := h.scanFileContent(ctx, logger, attachment.Filename, attachment.Content, fileScanOptions{})
+		if err != nil {
+			logger.Warn("Failed to scan attachment", slog.String("filename", attachment.Filename), slog.Any("error", err))
+			_, result.Error = fileScanError(err)
+			result.Denied = errors.Is(err, usecase.ErrFileTypeDenied) || errors.Is(err, usecase.ErrFileTooLarge)
+		} else {
+			result.Result = res
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
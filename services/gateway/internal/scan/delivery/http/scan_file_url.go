@@ -0,0 +1,126 @@
+package http
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/CodeMaster482/minions-server/common"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/usecase"
+)
+
+const (
+	MaxRemoteFileSize  = MaxUploadSize   // Максимальный размер файла, скачиваемого по ссылке
+	RemoteFetchTimeout = 2 * time.Minute // Максимальное время скачивания файла по ссылке
+
+	// Время на ответ: скачивание и проверка скачанного файла. Больше WriteTimeout сервера,
+	// иначе ответ о таймауте скачивания не дойдёт до клиента
+	ScanFileURLWriteTimeout = RemoteFetchTimeout + 2*time.Minute
+
+	ScanFileURLInvalidMsg     = "Bad Request: URL must be an absolute http or https URL."
+	ScanFileURLForbiddenMsg   = "Bad Request: URL points to a private, loopback or reserved address."
+	ScanFileURLTooLargeMsg    = "Payload Too Large: Remote file exceeds the 256 MB limit."
+	ScanFileURLTimeoutMsg     = "Gateway Timeout: Remote file download timed out."
+	ScanFileURLFetchFailedMsg = "Bad Gateway: Failed to download remote file."
+)
+
+// ScanFileURL
+// @Summary Проверка файла по ссылке
+// @Description Шлюз скачивает файл по ссылке и проверяет его так же, как загруженный через /api/scan/file.
+// @Description Сама ссылка проверяется через Kaspersky API отдельно, итоговая зона - самая опасная из зон ссылки и файла.
+// @Description Скачивание ограничено по размеру и времени, соединения с частными, локальными и служебными адресами запрещены
+// @Description (в том числе после DNS и перенаправлений). Content-Type сервера возвращается как есть, тип по содержимому - в File.FileType.
+// @ID file-url-scan
+// @Tags Scan
+// @Accept json
+// @Produce json
+// @Param request body models.FileURLScanRequest true "Ссылка на файл"
+// @Success 200 {object} models.FileURLScanResponse "Вердикты по ссылке и по скачанному файлу"
+// @Header 200 {string} X-Cache "HIT - все проверки взяты из кэша или БД, иначе MISS"
+// @Header 200 {integer} Age "Возраст самого старого вердикта в секундах"
+// @Failure 400 {object} common.ErrorResponse "Bad Request: URL points to a private, loopback or reserved address."
+// @Failure 413 {object} common.ErrorResponse "Payload Too Large: Remote file exceeds the 256 MB limit."
+// @Failure 415 {object} common.ErrorResponse "Unsupported Media Type: File type is not allowed."
+// @Failure 500 {object} common.ErrorResponse "Internal Server Error: Unable to process the file."
+// @Failure 502 {object} common.ErrorResponse "Bad Gateway: Failed to download remote file."
+// @Failure 504 {object} common.ErrorResponse "Gateway Timeout: Remote file download timed out."
+// @Router /api/scan/file-url [post]
+func (h *Handler) ScanFileURL(w http.ResponseWriter, r *http.Request) {
+	ctx := usecase.WithSource(r.Context(), models.ScanSourceFile)
+	logger := h.logger.With(
+		slog.String("method", r.Method),
+		slog.String("url", r.URL.String()),
+		slog.String("remote_addr", r.RemoteAddr),
+	)
+
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(ScanFileURLWriteTimeout)); err != nil {
+		logger.Warn("Can't extend write deadline", slog.Any("error", err))
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MB)
+
+	var req models.FileURLScanRequest
+	if err := common.DecodeJSONBody(w, r, &req); err != nil {
+		common.RespondWithError(w, http.StatusBadRequest, err.Error())
+		logger.Error(BadRequestMsg, slog.Any("error", err))
+		return
+	}
+
+	logger.Info("Received file URL for scanning", slog.String("file_url", req.URL))
+
+	remote, err := h.usecase.FetchRemoteFile(ctx, req.URL, MaxRemoteFileSize, RemoteFetchTimeout)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidRemoteURL):
+			common.RespondWithError(w, http.StatusBadRequest, ScanFileURLInvalidMsg)
+			logger.Warn(ScanFileURLInvalidMsg, slog.Any("error", err))
+		case errors.Is(err, usecase.ErrForbiddenAddress):
+			common.RespondWithError(w, http.StatusBadRequest, ScanFileURLForbiddenMsg)
+			logger.Warn(ScanFileURLForbiddenMsg, slog.Any("error", err))
+		case errors.Is(err, usecase.ErrRemoteTooLarge):
+			common.RespondWithError(w, http.StatusRequestEntityTooLarge, ScanFileURLTooLargeMsg)
+			logger.Warn(ScanFileURLTooLargeMsg, slog.Any("error", err))
+		case errors.Is(err, usecase.ErrRemoteTimeout):
+			common.RespondWithError(w, http.StatusGatewayTimeout, ScanFileURLTimeoutMsg)
+			logger.Warn(ScanFileURLTimeoutMsg, slog.Any("error", err))
+		default:
+			common.RespondWithError(w, http.StatusBadGateway, ScanFileURLFetchFailedMsg)
+			logger.Warn(ScanFileURLFetchFailedMsg, slog.Any("error", err))
+		}
+		return
+	}
+
+	logger.Info("Downloaded remote file",
+		slog.String("final_url", remote.FinalURL),
+		slog.String("filename", remote.Filename),
+		slog.String("content_type", remote.ContentType),
+		slog.Int("size", len(remote.Content)),
+	)
+
+	file, err := h.scanFileContent(ctx, logger, remote.Filename, remote.Content, fileScanOptions{
+		unpack:   req.Unpack,
+		password: req.Password,
+	})
+	if err != nil {
+		h.respondFileScanError(w, logger, err)
+		return
+	}
+
+	response := models.FileURLScanResponse{
+		URL:         remote.URL,
+		FinalURL:    remote.FinalURL,
+		Filename:    remote.Filename,
+		ContentType: remote.ContentType,
+		Size:        int64(len(remote.Content)),
+		URLLookup:   h.lookupIOC(ctx, logger, models.IOC{Type: models.IOCTypeURL, Value: remote.URL}, h.userID(ctx)),
+		File:        file,
+	}
+	response.Zone = models.WorstZone(response.URLLookup.Zone(), file.Zone)
+	response.Provenance = models.SummarizeProvenance(response.URLLookup.Provenance(), file.Provenance)
+
+	setProvenanceSummaryHeaders(w, response.Provenance)
+	RespondWithJSON(w, http.StatusOK, response)
+	logger.Info("Successfully processed file URL scan", slog.String("zone", response.Zone))
+}
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/usecase"
+)
+
+// slowFetcher отвечает таймаутом скачивания спустя delay, остальные методы Usecase не вызываются
+type slowFetcher struct {
+	scan.Usecase
+	delay time.Duration
+}
+
+func (f *slowFetcher) FetchRemoteFile(context.Context, string, int64, time.Duration) (*models.RemoteFile, error) {
+	time.Sleep(f.delay)
+	return nil, usecase.ErrRemoteTimeout
+}
+
+// Скачивание идёт дольше WriteTimeout сервера, ответ о таймауте всё равно должен дойти до клиента
+func TestScanFileURLOutlivesWriteTimeout(t *testing.T) {
+	h := &Handler{
+		usecase: &slowFetcher{delay: 300 * time.Millisecond},
+		logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(h.ScanFileURL))
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(`{"url":"https://example.com/file.exe"}`))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusGatewayTimeout)
+	}
+}
@@ -1,30 +1,67 @@
 package http
 
 import (
-	"bytes"
-	"encoding/base64"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
 	"log/slog"
+	"mime/multipart"
 	"net/http"
+	"slices"
 
 	"github.com/CodeMaster482/minions-server/common"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan"
 	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/usecase"
 )
 
+const (
+	MaxScreenFiles = 10 // Максимальное количество файлов в одном запросе
+	MaxScreenPages = 20 // Максимальное количество распознаваемых страниц и изображений в одном запросе
+
+	ScanScreenUnsupportedImageMsg  = "Unsupported Media Type: Image format cannot be annotated."
+	ScanScreenUnsupportedFormatMsg = "Unsupported Media Type: Only PNG, JPEG, WebP and PDF files are supported."
+	ScanScreenTooManyFilesMsg      = "Bad Request: Too many files in one request."
+	ScanScreenInvalidPDFMsg        = "Bad Request: PDF document cannot be read."
+	ScanScreenAnnotateMultipleMsg  = "Bad Request: Annotation is available only for a single image."
+	ScanScreenOCRUnsupportedMsg    = "Unsupported Media Type: The configured OCR engine does not support PDF documents."
+	ScanScreenRecognitionFailedMsg = "Failed to recognize text"
+)
+
+// screenPage изображение или страница PDF, подготовленная к распознаванию
+type screenPage struct {
+	models.ScreenPage
+	content []byte
+}
+
+// screenResult результат распознавания одной страницы
+type screenResult struct {
+	page    models.ScreenPage
+	ocr     models.ApiResponse
+	iocs    []models.IOC
+	qrCodes []models.QRCode
+}
+
 // ScanScreen
-// @Summary Проверка веб-адреса, IP или домена из изображения через Kaspersky API
-// @Description Эндпоинт для загрузки изображения, извлечения текста, поиска веб-адресов, IP и доменов, и получения ответа с информацией из Kaspersky API.
-// В зависимости от типа входных данных (IPv4, URL или домен), возвращаются соответствующие поля в ответе.
+// @Summary Проверка веб-адреса, IP или домена из изображений и PDF через Kaspersky API
+// @Description Эндпоинт для загрузки изображений и PDF, извлечения текста, поиска веб-адресов, IP, доменов, email и хешей, и получения ответа с информацией из Kaspersky API.
+// Можно загрузить несколько файлов в поле file, PDF разбивается на страницы. Тип файла определяется по содержимому: PNG, JPEG, WebP или PDF.
+// Текст разбирается построчно, голые домены проверяются по списку публичных суффиксов, типичные ошибки OCR (0/O, 1/l) исправляются.
+// QR-коды на изображениях декодируются локально, индикаторы из них помечаются источником "qr" в поле Sources.
+// На страницах PDF QR-коды не ищутся, из них извлекается только распознанный текст.
+// Для каждого индикатора в поле Pages указаны файлы и страницы, на которых он найден.
 // @ID screen-check
 // @Tags Scan
 // @Accept multipart/form-data
-// @Produce json
-// @Param file formData file true "Изображение, содержащее веб-адрес, IP или домен для проверки"
-// @Success 200 {object} map[string]models.ResponseFromAPI "Успешная проверка. Возвращается объединенный ответ с информацией."
+// @Produce json,png
+// @Param file formData file true "Изображения или PDF, содержащие веб-адрес, IP или домен для проверки (поле можно повторять)"
+// @Param annotate query bool false "Вернуть PNG с рамками вокруг индикаторов: красная - опасный, жёлтая - подозрительный, зелёная - безопасный. Только для одного изображения"
+// @Success 200 {object} models.ScreenScanResponse "Успешная проверка. Возвращаются найденные индикаторы и результаты их проверки."
+// @Header 200 {string} X-Cache "HIT - все проверки взяты из кэша или БД, иначе MISS"
+// @Header 200 {integer} Age "Возраст самого старого вердикта в секундах"
 // @Failure 400 {object} common.ErrorResponse "Bad Request: Incorrect file upload or processing error."
 // @Failure 413 {object} common.ErrorResponse "Payload Too Large: File size exceeds the limit."
+// @Failure 415 {object} common.ErrorResponse "Unsupported Media Type: Only PNG, JPEG, WebP and PDF files are supported."
 // @Failure 404 {object} common.ErrorResponse "Not Found: Lookup results not found."
 // @Failure 500 {object} common.ErrorResponse "Internal Server Error"
 //
@@ -48,7 +85,7 @@ import (
 //
 // @Router /api/scan/screen [post]
 func (h *Handler) ScanScreen(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := usecase.WithSource(r.Context(), models.ScanSourceScreen)
 	logger := h.logger.With(
 		slog.String("method", r.Method),
 		slog.String("url", r.URL.String()),
@@ -68,111 +105,288 @@ func (h *Handler) ScanScreen(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	file, header, err := r.FormFile("file")
-	if err != nil {
+	headers := r.MultipartForm.File["file"]
+	if len(headers) == 0 {
 		common.RespondWithError(w, http.StatusBadRequest, ScanFileBadRequestMsg)
-		logger.Error(ScanFileBadRequestMsg, slog.Any("error", err))
+		logger.Error(ScanFileBadRequestMsg, slog.String("error", "no files uploaded"))
 		return
 	}
-	defer file.Close()
 
-	filename := header.Filename
-	logger.Info("Received file for scanning", slog.String("filename", filename))
-
-	if header.Size > MaxUploadSize {
-		common.RespondWithError(w, http.StatusRequestEntityTooLarge, ScanFilePayloadTooLargeMsg)
-		logger.Error(ScanFilePayloadTooLargeMsg, slog.Int64("file_size", header.Size))
+	if len(headers) > MaxScreenFiles {
+		common.RespondWithError(w, http.StatusBadRequest, ScanScreenTooManyFilesMsg)
+		logger.Error(ScanScreenTooManyFilesMsg, slog.Int("count", len(headers)), slog.Int("limit", MaxScreenFiles))
 		return
 	}
 
-	fileContent, err := io.ReadAll(file)
-	if err != nil {
-		common.RespondWithError(w, http.StatusInternalServerError, ScanFileInternalServerErrorMsg)
-		logger.Error(ScanFileInternalServerErrorMsg, slog.Any("error", err))
+	annotate := r.URL.Query().Get("annotate") == "true"
+	if annotate && len(headers) > 1 {
+		common.RespondWithError(w, http.StatusBadRequest, ScanScreenAnnotateMultipleMsg)
+		logger.Error(ScanScreenAnnotateMultipleMsg, slog.Int("count", len(headers)))
 		return
 	}
 
-	// Закодировать содержимое файла в base64
-	base64Content := base64.StdEncoding.EncodeToString(fileContent)
+	// Сначала проверяем все файлы, чтобы не тратить OCR на запрос, который всё равно будет отклонён
+	var pages []screenPage
+	for i, header := range headers {
+		logger.Info("Received file for scanning", slog.String("filename", header.Filename))
+
+		if header.Size > MaxUploadSize {
+			common.RespondWithError(w, http.StatusRequestEntityTooLarge, ScanFilePayloadTooLargeMsg)
+			logger.Error(ScanFilePayloadTooLargeMsg, slog.Int64("file_size", header.Size))
+			return
+		}
+
+		fileContent, err := readFormFile(header)
+		if err != nil {
+			common.RespondWithError(w, http.StatusInternalServerError, ScanFileInternalServerErrorMsg)
+			logger.Error(ScanFileInternalServerErrorMsg, slog.Any("error", err))
+			return
+		}
+
+		mimeType, err := h.usecase.DetectScreenMIME(fileContent)
+		if err != nil {
+			common.RespondWithError(w, http.StatusUnsupportedMediaType, ScanScreenUnsupportedFormatMsg)
+			logger.Error(ScanScreenUnsupportedFormatMsg, slog.String("filename", header.Filename))
+			return
+		}
 
-	// Подготовка данных для отправки в Yandex OCR API
-	data := map[string]interface{}{
-		"mimeType":      "application/octet-stream", // mime-type файла
-		"languageCodes": []string{"*"},
-		"content":       base64Content,
+		if annotate && mimeType == models.MimeTypePDF {
+			common.RespondWithError(w, http.StatusUnsupportedMediaType, ScanScreenUnsupportedImageMsg)
+			logger.Error(ScanScreenUnsupportedImageMsg, slog.String("mime_type", mimeType))
+			return
+		}
+
+		filePages, err := h.screenPages(i, header.Filename, mimeType, fileContent, MaxScreenPages-len(pages))
+		if err != nil {
+			if errors.Is(err, usecase.ErrInvalidPDF) {
+				common.RespondWithError(w, http.StatusBadRequest, ScanScreenInvalidPDFMsg)
+				logger.Error(ScanScreenInvalidPDFMsg, slog.Any("error", err))
+				return
+			}
+			if errors.Is(err, usecase.ErrUnsupportedImage) {
+				common.RespondWithError(w, http.StatusUnsupportedMediaType, ScanScreenUnsupportedFormatMsg)
+				logger.Error(ScanScreenUnsupportedFormatMsg, slog.Any("error", err))
+				return
+			}
+			common.RespondWithError(w, http.StatusInternalServerError, ScanFileInternalServerErrorMsg)
+			logger.Error(ScanFileInternalServerErrorMsg, slog.Any("error", err))
+			return
+		}
+		pages = append(pages, filePages...)
+
+		if len(pages) >= MaxScreenPages {
+			logger.Warn("Too many pages in request, extra ones are skipped", slog.Int("limit", MaxScreenPages))
+			break
+		}
 	}
-	payload, err := json.Marshal(data)
-	if err != nil {
-		common.RespondWithError(w, http.StatusInternalServerError, "Error preparing OCR request data")
-		logger.Error("Error preparing OCR request data", slog.Any("error", err))
-		return
+
+	response := models.ScreenScanResponse{
+		Pages: make([]models.ScreenPage, 0, len(pages)),
 	}
 
-	apiURL := "https://ocr.api.cloud.yandex.net/ocr/v1/recognizeText"
-	apiReq, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewReader(payload))
-	if err != nil {
+	var results []screenResult
+	for _, page := range pages {
+		pageLogger := logger.With(slog.Int("image", page.Image), slog.Int("page", page.Page))
+
+		ocrResponse, err := h.ocr.Recognize(ctx, page.content, page.MimeType)
+		if errors.Is(err, scan.ErrOCRUnsupportedFormat) {
+			common.RespondWithError(w, http.StatusUnsupportedMediaType, ScanScreenOCRUnsupportedMsg)
+			pageLogger.Error(ScanScreenOCRUnsupportedMsg, slog.String("mime_type", page.MimeType))
+			return
+		}
+		if err != nil {
+			// Ошибка одной страницы не прерывает обработку остальных
+			page.Error = ScanScreenRecognitionFailedMsg
+			response.Pages = append(response.Pages, page.ScreenPage)
+			pageLogger.Error("OCR failed", slog.Any("error", err))
+			continue
+		}
+		response.Pages = append(response.Pages, page.ScreenPage)
+
+		ocrIOCs, err := h.usecase.GetTextOCRResponse(*ocrResponse)
+		if err != nil {
+			pageLogger.Info("No IOCs found in OCR text", slog.Any("error", err))
+		}
+
+		// QR-коды декодируются локально, OCR их не читает. Страницу PDF без растеризации декодер не прочитает
+		var qrCodes []models.QRCode
+		if page.MimeType != models.MimeTypePDF {
+			qrCodes, err = h.usecase.DecodeQRCodes(page.content)
+			if err != nil {
+				pageLogger.Warn("Failed to decode QR codes", slog.Any("error", err))
+			}
+		}
+
+		results = append(results, screenResult{
+			page:    page.ScreenPage,
+			ocr:     *ocrResponse,
+			iocs:    ocrIOCs,
+			qrCodes: qrCodes,
+		})
+	}
+
+	if len(results) == 0 {
 		common.RespondWithError(w, http.StatusInternalServerError, ScanFileInternalServerErrorMsg)
-		logger.Error(ScanFileInternalServerErrorMsg, slog.Any("error", err))
+		logger.Error("OCR failed for all pages")
 		return
 	}
 
-	apiReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", h.iamToken)) // IAM токен
-	apiReq.Header.Set("Content-Type", "application/json")
-	apiReq.Header.Set("x-folder-id", h.folderID)
-	apiReq.Header.Set("x-data-logging-enabled", "true")
+	candidates := h.screenIndicators(results)
+	for _, result := range results {
+		response.QRCodes = append(response.QRCodes, result.qrCodes...)
+	}
 
-	client := &http.Client{}
-	apiResp, err := client.Do(apiReq)
-	if err != nil {
-		common.RespondWithError(w, http.StatusInternalServerError, ScanFileInternalServerErrorMsg)
-		logger.Error(ScanFileInternalServerErrorMsg, slog.Any("error", err))
+	if len(candidates) == 0 && len(response.QRCodes) == 0 {
+		common.RespondWithError(w, http.StatusNotFound, models.ScanScreenNotFoundIOC)
+		logger.Warn("No IOCs found on screen")
 		return
 	}
-	defer apiResp.Body.Close()
 
-	body, err := io.ReadAll(apiResp.Body)
-	if err != nil {
-		common.RespondWithError(w, http.StatusInternalServerError, ScanFileInternalServerErrorMsg)
-		logger.Error(ScanFileInternalServerErrorMsg, slog.Any("error", err))
-		return
+	if len(candidates) > MaxTextIOCs {
+		logger.Warn("Too many indicators on screen, extra ones are skipped",
+			slog.Int("found", len(candidates)),
+			slog.Int("limit", MaxTextIOCs),
+		)
+		candidates = candidates[:MaxTextIOCs]
 	}
 
-	var ocrResponse models.ApiResponse
-	err = json.Unmarshal([]byte(body), &ocrResponse)
-	logger.Debug("Yandex response", slog.Any("res", ocrResponse))
+	iocs := make([]models.IOC, 0, len(candidates))
+	for _, candidate := range candidates {
+		iocs = append(iocs, candidate.IOC)
+	}
 
-	if err != nil {
-		common.RespondWithError(w, http.StatusInternalServerError, ScanFileInternalServerErrorMsg)
-		logger.Error(ScanFileInternalServerErrorMsg, slog.Any("error", err))
+	response.Indicators = h.lookupIOCs(ctx, logger, iocs)
+	for i, candidate := range candidates {
+		response.Indicators[i].Sources = candidate.Sources
+		response.Indicators[i].Regions = candidate.Regions
+		response.Indicators[i].Pages = candidate.Pages
+	}
+	response.Provenance = models.LookupsProvenance(response.Indicators)
+
+	if annotate {
+		annotated, err := h.usecase.AnnotateImage(pages[0].content, response.Indicators)
+		if err != nil {
+			if errors.Is(err, usecase.ErrUnsupportedImage) {
+				common.RespondWithError(w, http.StatusUnsupportedMediaType, ScanScreenUnsupportedImageMsg)
+				logger.Error(ScanScreenUnsupportedImageMsg, slog.Any("error", err))
+				return
+			}
+			common.RespondWithError(w, http.StatusInternalServerError, ScanFileInternalServerErrorMsg)
+			logger.Error("Failed to annotate image", slog.Any("error", err))
+			return
+		}
+
+		setProvenanceSummaryHeaders(w, response.Provenance)
+		w.Header().Set("Content-Type", "image/png")
+		w.WriteHeader(http.StatusOK)
+		w.Write(annotated)
+		logger.Info("Successfully processed IOCs with annotated image", slog.Int("count", len(response.Indicators)))
 		return
 	}
 
-	iocs, err := h.usecase.GetTextOCRResponse(ocrResponse)
+	setProvenanceSummaryHeaders(w, response.Provenance)
+	RespondWithJSON(w, http.StatusOK, response)
+	logger.Info("Successfully processed IOCs",
+		slog.Int("count", len(response.Indicators)),
+		slog.Int("pages", len(response.Pages)),
+	)
+}
+
+// readFormFile читает загруженный файл целиком
+func readFormFile(header *multipart.FileHeader) ([]byte, error) {
+	file, err := header.Open()
 	if err != nil {
-		common.RespondWithError(w, http.StatusNotFound, models.ScanScreenNotFoundIOC)
-		logger.Error(ScanFileInternalServerErrorMsg, slog.Any("error", err))
-		return
+		return nil, err
 	}
+	defer file.Close()
 
-	infoIocs := make(map[string]*models.ResponseFromAPI)
+	return io.ReadAll(file)
+}
 
-	for _, ioc := range iocs {
-		res, err := h.usecase.RequestKasperskyAPI(ctx, ioc, h.apiKey)
+// screenPages готовит файл к распознаванию: PDF разбивается на страницы, WebP перекодируется в PNG
+func (h *Handler) screenPages(image int, filename, mimeType string, content []byte, maxPages int) ([]screenPage, error) {
+	if mimeType != models.MimeTypePDF {
+		normalized, ocrMimeType, err := h.usecase.NormalizeImage(content, mimeType)
 		if err != nil {
-			logger.Warn("Failed to process IOC", slog.Any("ioc", ioc), slog.Any("error", err))
-			continue
+			return nil, err
+		}
+
+		return []screenPage{{
+			ScreenPage: models.ScreenPage{Image: image, Filename: filename, MimeType: ocrMimeType},
+			content:    normalized,
+		}}, nil
+	}
+
+	documents, err := h.usecase.SplitPDF(content, maxPages)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make([]screenPage, 0, len(documents))
+	for i, document := range documents {
+		pages = append(pages, screenPage{
+			ScreenPage: models.ScreenPage{Image: image, Filename: filename, Page: i + 1, MimeType: mimeType},
+			content:    document,
+		})
+	}
+
+	return pages, nil
+}
+
+// screenIndicators объединяет индикаторы из распознанного текста и QR-кодов всех страниц.
+// Индикатор, найденный в нескольких источниках или на нескольких страницах, проверяется один раз
+// и помечается всеми источниками и страницами.
+func (h *Handler) screenIndicators(results []screenResult) []models.IOCLookup {
+	var candidates []models.IOCLookup
+	index := make(map[models.IOC]int)
+
+	add := func(ioc models.IOC, source string, page models.ScreenPage, regions ...models.Region) {
+		i, ok := index[ioc]
+		if !ok {
+			i = len(candidates)
+			index[ioc] = i
+			candidates = append(candidates, models.IOCLookup{IOC: ioc})
+		}
+
+		if !slices.Contains(candidates[i].Sources, source) {
+			candidates[i].Sources = append(candidates[i].Sources, source)
+		}
+
+		if !slices.ContainsFunc(candidates[i].Pages, func(p models.ScreenPage) bool {
+			return p.Image == page.Image && p.Page == page.Page
+		}) {
+			candidates[i].Pages = append(candidates[i].Pages, page)
+		}
+
+		for _, region := range regions {
+			region.Image, region.Page = page.Image, page.Page
+			candidates[i].Regions = append(candidates[i].Regions, region)
 		}
-		infoIocs[ioc] = res
 	}
 
-	if len(infoIocs) > 0 {
-		RespondWithJSON(w, http.StatusOK, infoIocs)
-		logger.Info("Successfully processed IOCs", slog.Int("count", len(infoIocs)))
-	} else {
-		common.RespondWithError(w, http.StatusNotFound, "No IOCs found")
-		logger.Warn("No IOCs were processed successfully")
+	for _, result := range results {
+		for _, ioc := range result.iocs {
+			add(ioc, models.IOCSourceOCR, result.page, h.usecase.LocateIOC(result.ocr, ioc)...)
+		}
+
+		for j := range result.qrCodes {
+			code := &result.qrCodes[j]
+			code.Image = result.page.Image
+			if code.Region != nil {
+				code.Region.Image = result.page.Image
+			}
+
+			for _, ioc := range h.usecase.ExtractIOCs(code.Payload) {
+				if code.Region != nil {
+					add(ioc, models.IOCSourceQR, result.page, *code.Region)
+				} else {
+					add(ioc, models.IOCSourceQR, result.page)
+				}
+			}
+		}
 	}
 
+	return candidates
 }
 
 // RespondWithJSON отправляет ответ с данными в формате JSON
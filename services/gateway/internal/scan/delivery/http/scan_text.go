@@ -0,0 +1,166 @@
+package http
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/CodeMaster482/minions-server/common"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/usecase"
+)
+
+const (
+	MaxTextSize = 1 * MB // Максимальный размер текста для поиска индикаторов
+	MaxTextIOCs = 100    // Максимальное количество проверяемых индикаторов за один запрос
+
+	MaxParallelLookups = 5 // Максимальное количество одновременных проверок индикаторов
+
+	ScanTextEmptyMsg    = "Bad Request: Text must not be empty."
+	ScanTextNotFoundMsg = "Not Found: No indicators found in text."
+)
+
+// ScanText
+// @Summary Поиск и проверка индикаторов компрометации в произвольном тексте
+// @Description Эндпоинт принимает произвольный текст (отчёт, сообщение из чата), восстанавливает обезвреженные индикаторы
+// @Description (hxxp://evil[.]com, 1.2.3[.]4), извлекает веб-адреса, домены, IPv4/IPv6, email и хеши MD5/SHA1/SHA256
+// @Description и проверяет каждый из них через Kaspersky API. Email проверяется по домену отправителя.
+// @ID text-check
+// @Tags Scan
+// @Accept json
+// @Produce json
+// @Param request body models.TextScanRequest true "Текст для поиска индикаторов"
+// @Success 200 {object} models.TextScanResponse "Найденные индикаторы и результаты их проверки"
+// @Header 200 {string} X-Cache "HIT - все проверки взяты из кэша или БД, иначе MISS"
+// @Header 200 {integer} Age "Возраст самого старого вердикта в секундах"
+// @Failure 400 {object} common.ErrorResponse "Bad Request: Text must not be empty."
+// @Failure 404 {object} common.ErrorResponse "Not Found: No indicators found in text."
+// @Failure 413 {object} common.ErrorResponse "Payload Too Large"
+// @Router /api/scan/text [post]
+func (h *Handler) ScanText(w http.ResponseWriter, r *http.Request) {
+	ctx := usecase.WithSource(r.Context(), models.ScanSourceBatch)
+	logger := h.logger.With(
+		slog.String("method", r.Method),
+		slog.String("url", r.URL.String()),
+		slog.String("remote_addr", r.RemoteAddr),
+	)
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxTextSize)
+
+	var req models.TextScanRequest
+	if err := common.DecodeJSONBody(w, r, &req); err != nil {
+		if err.Error() == "http: request body too large" {
+			common.RespondWithError(w, http.StatusRequestEntityTooLarge, "Payload Too Large")
+			logger.Error("Text is too large", slog.Any("error", err))
+			return
+		}
+		common.RespondWithError(w, http.StatusBadRequest, err.Error())
+		logger.Error(BadRequestMsg, slog.Any("error", err))
+		return
+	}
+
+	if strings.TrimSpace(req.Text) == "" {
+		common.RespondWithError(w, http.StatusBadRequest, ScanTextEmptyMsg)
+		logger.Error(ScanTextEmptyMsg)
+		return
+	}
+
+	iocs := h.usecase.ExtractIOCs(req.Text)
+	if len(iocs) == 0 {
+		common.RespondWithError(w, http.StatusNotFound, ScanTextNotFoundMsg)
+		logger.Warn(ScanTextNotFoundMsg)
+		return
+	}
+
+	if len(iocs) > MaxTextIOCs {
+		logger.Warn("Too many indicators in text, extra ones are skipped",
+			slog.Int("found", len(iocs)),
+			slog.Int("limit", MaxTextIOCs),
+		)
+		iocs = iocs[:MaxTextIOCs]
+	}
+
+	response := models.TextScanResponse{
+		Indicators: h.lookupIOCs(ctx, logger, iocs),
+	}
+	response.Provenance = models.LookupsProvenance(response.Indicators)
+
+	setProvenanceSummaryHeaders(w, response.Provenance)
+	RespondWithJSON(w, http.StatusOK, response)
+	logger.Info("Successfully processed text scan", slog.Int("count", len(response.Indicators)))
+}
+
+// lookupIOCs проверяет индикаторы параллельно, не более MaxParallelLookups одновременно.
+// Порядок результатов совпадает с порядком индикаторов
+func (h *Handler) lookupIOCs(ctx context.Context, logger *slog.Logger, iocs []models.IOC) []models.IOCLookup {
+	userID := h.userID(ctx)
+	lookups := make([]models.IOCLookup, len(iocs))
+
+	sem := make(chan struct{}, MaxParallelLookups)
+	var wg sync.WaitGroup
+	for i, ioc := range iocs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			lookups[i] = h.lookupIOC(ctx, logger, ioc, userID)
+		}()
+	}
+	wg.Wait()
+
+	return lookups
+}
+
+// lookupIOC проверяет один индикатор по цепочке кэш -> БД -> Kaspersky API. Ошибка проверки
+// не прерывает обработку остальных индикаторов и возвращается в поле Error, если Kaspersky API не дал вердикта,
+// в поле Result возвращается локальная оценка риска
+func (h *Handler) lookupIOC(ctx context.Context, logger *slog.Logger, ioc models.IOC, userID int) models.IOCLookup {
+	lookup := models.IOCLookup{
+		IOC:     ioc,
+		Request: ioc.Value,
+	}
+
+	switch ioc.Type {
+	case models.IOCTypeMD5, models.IOCTypeSHA1, models.IOCTypeSHA256:
+		res, err := h.usecase.LookupHash(ctx, ioc.Value, userID, h.apiKey)
+		if err != nil {
+			logger.Warn("Failed to process IOC", slog.Any("ioc", ioc), slog.Any("error", err))
+			lookup.Error = lookupErrorMsg(err)
+			return lookup
+		}
+		lookup.FileResult = res
+
+		return lookup
+
+	case models.IOCTypeEmail:
+		lookup.Request = ioc.Value[strings.LastIndex(ioc.Value, "@")+1:]
+	}
+
+	inputType, requestParam, err := h.usecase.DetermineInputType(lookup.Request)
+	if err != nil {
+		logger.Warn("Failed to determine IOC type", slog.Any("ioc", ioc), slog.Any("error", err))
+		lookup.Error = InvalidInput
+		return lookup
+	}
+	lookup.Request = requestParam
+
+	res, err := h.usecase.Lookup(ctx, inputType, requestParam, userID, h.apiKey)
+	if err != nil {
+		logger.Warn("Failed to process IOC", slog.Any("ioc", ioc), slog.Any("error", err))
+		lookup.Error = lookupErrorMsg(err)
+		if usecase.HeuristicFallback(err) {
+			// Вердикта нет - отдаём хотя бы локальную оценку
+			lookup.Result = h.usecase.HeuristicResponse(inputType, requestParam, models.LookupModeDefault)
+		}
+		return lookup
+	}
+
+	h.attachHeuristic(inputType, requestParam, res)
+	lookup.Result = res
+
+	return lookup
+}
@@ -0,0 +1,355 @@
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/CodeMaster482/minions-server/common"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/upload"
+)
+
+const (
+	TusVersion         = "1.0.0"                                    // Версия протокола tus
+	TusExtensions      = "creation,checksum,expiration,termination" // Поддерживаемые расширения tus
+	TusOffsetOctetMIME = "application/offset+octet-stream"          // Content-Type частей файла
+
+	UploadScanTimeout = 10 * time.Minute // Максимальное время проверки файла после завершения загрузки
+
+	// StatusChecksumMismatch код ответа tus при несовпадении контрольной суммы части
+	StatusChecksumMismatch = 460
+
+	UploadLengthRequiredMsg = "Bad Request: Upload-Length header must be a positive integer."
+	UploadOffsetRequiredMsg = "Bad Request: Upload-Offset header must be a non-negative integer."
+	UploadContentTypeMsg    = "Unsupported Media Type: Content-Type must be application/offset+octet-stream."
+	UploadTooLargeMsg       = "Payload Too Large: Upload exceeds the 256 MB limit."
+	UploadChunkTooLargeMsg  = "Payload Too Large: Chunk exceeds the declared upload length."
+	UploadNotFoundMsg       = "Not Found: Upload does not exist or has expired."
+	UploadOffsetMismatchMsg = "Conflict: Upload-Offset does not match the current offset."
+	UploadCompletedMsg      = "Conflict: Upload is already completed."
+	UploadBusyMsg           = "Locked: Upload is being written by another request."
+	UploadChecksumMsg       = "Checksum Mismatch: Chunk checksum does not match."
+	UploadBadChecksumMsg    = "Bad Request: Unsupported Upload-Checksum algorithm or encoding."
+	UploadInternalErrorMsg  = "Internal Server Error: Unable to store the upload."
+	UploadQuotaMsg          = "Insufficient Storage: Upload staging area is full, try again later."
+)
+
+// CreateUpload
+// @Summary Создание загрузки файла по частям (tus)
+// @Description Начинает загрузку большого файла по протоколу tus 1.0.0 (расширения creation, checksum, expiration, termination).
+// @Description Размер файла передаётся в Upload-Length, имя и параметры - в Upload-Metadata (filename, unpack, password в base64).
+// @Description Части отправляются запросами PATCH на адрес из заголовка Location. После получения последней части файл
+// @Description проверяется так же, как в /api/scan/file, состояние и результат возвращает GET по тому же адресу.
+// @Description Загрузки доступны только авторизованным пользователям, к загрузке обращается только её владелец.
+// @ID upload-create
+// @Tags Scan
+// @Produce json
+// @Param Upload-Length header int true "Полный размер файла в байтах"
+// @Param Upload-Metadata header string false "Метаданные tus: filename <base64>,unpack <base64>,password <base64>"
+// @Success 201 {object} models.Upload "Загрузка создана, адрес в заголовке Location"
+// @Failure 400 {object} common.ErrorResponse "Bad Request: Upload-Length header must be a positive integer."
+// @Failure 401 {object} common.ErrorResponse "Unauthorized"
+// @Failure 413 {object} common.ErrorResponse "Payload Too Large: Upload exceeds the 256 MB limit."
+// @Failure 500 {object} common.ErrorResponse "Internal Server Error: Unable to store the upload."
+// @Failure 507 {object} common.ErrorResponse "Insufficient Storage: Upload staging area is full, try again later."
+// @Router /api/scan/uploads [post]
+func (h *Handler) CreateUpload(w http.ResponseWriter, r *http.Request) {
+	logger := h.uploadLogger(r)
+	tusHeaders(w)
+
+	size, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || size <= 0 {
+		common.RespondWithError(w, http.StatusBadRequest, UploadLengthRequiredMsg)
+		logger.Warn(UploadLengthRequiredMsg, slog.String("upload_length", r.Header.Get("Upload-Length")))
+		return
+	}
+	if size > MaxUploadSize {
+		common.RespondWithError(w, http.StatusRequestEntityTooLarge, UploadTooLargeMsg)
+		logger.Warn(UploadTooLargeMsg, slog.Int64("upload_length", size))
+		return
+	}
+
+	metadata := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+	filename := metadata["filename"]
+	if filename == "" {
+		filename = "upload"
+	}
+
+	up, err := h.uploads.Create(h.userID(r.Context()), size, filename, metadata["unpack"] == "true", metadata["password"])
+	if err != nil {
+		status, msg := uploadError(err)
+		common.RespondWithError(w, status, msg)
+		if status >= http.StatusInternalServerError && status != http.StatusInsufficientStorage {
+			logger.Error(msg, slog.Any("error", err))
+		} else {
+			logger.Warn(msg, slog.Any("error", err))
+		}
+		return
+	}
+
+	w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+up.ID)
+	w.Header().Set("Upload-Expires", up.ExpiresAt.Format(http.TimeFormat))
+	RespondWithJSON(w, http.StatusCreated, up)
+	logger.Info("Upload created",
+		slog.String("upload_id", up.ID),
+		slog.String("filename", filename),
+		slog.Int64("size", size),
+	)
+}
+
+// UploadOffset
+// @Summary Текущее смещение загрузки (tus)
+// @Description Возвращает в заголовке Upload-Offset, сколько байт уже получено. Клиент продолжает загрузку с этого смещения.
+// @ID upload-offset
+// @Tags Scan
+// @Param id path string true "Идентификатор загрузки"
+// @Success 200 "Смещение в заголовке Upload-Offset, размер в Upload-Length"
+// @Failure 401 "Пользователь не авторизован"
+// @Failure 404 "Загрузка не найдена или истекла"
+// @Router /api/scan/uploads/{id} [head]
+func (h *Handler) UploadOffset(w http.ResponseWriter, r *http.Request) {
+	tusHeaders(w)
+	w.Header().Set("Cache-Control", "no-store")
+
+	up, err := h.uploads.Get(mux.Vars(r)["id"], h.userID(r.Context()))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, upload.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		w.WriteHeader(status)
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(up.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(up.Size, 10))
+	w.Header().Set("Upload-Expires", up.ExpiresAt.Format(http.TimeFormat))
+	w.WriteHeader(http.StatusOK)
+}
+
+// UploadChunk
+// @Summary Отправка части файла (tus)
+// @Description Дописывает часть файла с позиции Upload-Offset. Необязательный Upload-Checksum (sha1, sha256 или md5 в base64)
+// @Description проверяется до сохранения, часть с несовпавшей суммой отбрасывается (код 460). После последней части
+// @Description запускается проверка файла.
+// @ID upload-chunk
+// @Tags Scan
+// @Accept application/offset+octet-stream
+// @Param id path string true "Идентификатор загрузки"
+// @Param Upload-Offset header int true "Смещение, с которого начинается часть"
+// @Param Upload-Checksum header string false "Контрольная сумма части, например: sha256 <base64>"
+// @Success 204 "Часть сохранена, новое смещение в заголовке Upload-Offset"
+// @Failure 400 {object} common.ErrorResponse "Bad Request: Upload-Offset header must be a non-negative integer."
+// @Failure 401 {object} common.ErrorResponse "Unauthorized"
+// @Failure 404 {object} common.ErrorResponse "Not Found: Upload does not exist or has expired."
+// @Failure 409 {object} common.ErrorResponse "Conflict: Upload-Offset does not match the current offset."
+// @Failure 413 {object} common.ErrorResponse "Payload Too Large: Chunk exceeds the declared upload length."
+// @Failure 415 {object} common.ErrorResponse "Unsupported Media Type: Content-Type must be application/offset+octet-stream."
+// @Failure 423 {object} common.ErrorResponse "Locked: Upload is being written by another request."
+// @Failure 460 {object} common.ErrorResponse "Checksum Mismatch: Chunk checksum does not match."
+// @Router /api/scan/uploads/{id} [patch]
+func (h *Handler) UploadChunk(w http.ResponseWriter, r *http.Request) {
+	logger := h.uploadLogger(r)
+	tusHeaders(w)
+
+	if r.Header.Get("Content-Type") != TusOffsetOctetMIME {
+		common.RespondWithError(w, http.StatusUnsupportedMediaType, UploadContentTypeMsg)
+		logger.Warn(UploadContentTypeMsg, slog.String("content_type", r.Header.Get("Content-Type")))
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		common.RespondWithError(w, http.StatusBadRequest, UploadOffsetRequiredMsg)
+		logger.Warn(UploadOffsetRequiredMsg, slog.String("upload_offset", r.Header.Get("Upload-Offset")))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxUploadSize)
+
+	up, err := h.uploads.Write(mux.Vars(r)["id"], h.userID(r.Context()), offset, r.Body, r.Header.Get("Upload-Checksum"))
+	if err != nil {
+		status, msg := uploadError(err)
+		common.RespondWithError(w, status, msg)
+		if status >= http.StatusInternalServerError {
+			logger.Error(msg, slog.Any("error", err))
+		} else {
+			logger.Warn(msg, slog.Any("error", err))
+		}
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(up.Offset, 10))
+	w.Header().Set("Upload-Expires", up.ExpiresAt.Format(http.TimeFormat))
+	w.WriteHeader(http.StatusNoContent)
+
+	if up.Status == models.UploadStatusScanning {
+		h.completeUpload(r, logger, up)
+	}
+}
+
+// UploadStatus
+// @Summary Состояние загрузки и результат проверки
+// @Description Возвращает, сколько байт получено, состояние (uploading, scanning, done, failed) и после завершения - результат проверки файла.
+// @ID upload-status
+// @Tags Scan
+// @Produce json
+// @Param id path string true "Идентификатор загрузки"
+// @Success 200 {object} models.Upload "Состояние загрузки"
+// @Header 200 {string} X-Cache "MISS: файл всегда проверяется в Kaspersky API (только после завершения проверки)"
+// @Header 200 {integer} Age "Возраст вердикта в секундах (только после завершения проверки)"
+// @Failure 401 {object} common.ErrorResponse "Unauthorized"
+// @Failure 404 {object} common.ErrorResponse "Not Found: Upload does not exist or has expired."
+// @Failure 500 {object} common.ErrorResponse "Internal Server Error"
+// @Router /api/scan/uploads/{id} [get]
+func (h *Handler) UploadStatus(w http.ResponseWriter, r *http.Request) {
+	logger := h.uploadLogger(r)
+	tusHeaders(w)
+
+	up, err := h.uploads.Get(mux.Vars(r)["id"], h.userID(r.Context()))
+	if err != nil {
+		status, msg := uploadError(err)
+		common.RespondWithError(w, status, msg)
+		logger.Warn(msg, slog.Any("error", err))
+		return
+	}
+
+	// Результат сохранён в момент проверки, возраст и срок в кэше пересчитываются на момент запроса
+	if up.Result != nil && up.Result.Provenance != nil {
+		up.Result.Provenance.Refresh(time.Now())
+		setProvenanceHeaders(w, up.Result.Provenance)
+	}
+
+	RespondWithJSON(w, http.StatusOK, up)
+}
+
+// DeleteUpload
+// @Summary Прерывание загрузки (tus)
+// @Description Удаляет загрузку и полученные части файла.
+// @ID upload-delete
+// @Tags Scan
+// @Param id path string true "Идентификатор загрузки"
+// @Success 204 "Загрузка удалена"
+// @Failure 401 {object} common.ErrorResponse "Unauthorized"
+// @Failure 404 {object} common.ErrorResponse "Not Found: Upload does not exist or has expired."
+// @Failure 423 {object} common.ErrorResponse "Locked: Upload is being written by another request."
+// @Router /api/scan/uploads/{id} [delete]
+func (h *Handler) DeleteUpload(w http.ResponseWriter, r *http.Request) {
+	logger := h.uploadLogger(r)
+	tusHeaders(w)
+
+	if err := h.uploads.Delete(mux.Vars(r)["id"], h.userID(r.Context())); err != nil {
+		status, msg := uploadError(err)
+		common.RespondWithError(w, status, msg)
+		logger.Warn(msg, slog.Any("error", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// completeUpload запускает проверку полученного файла в фоне. Контекст запроса не отменяет проверку,
+// но сохраняет данные сессии, чтобы результат попал в статистику пользователя
+func (h *Handler) completeUpload(r *http.Request, logger *slog.Logger, up *models.Upload) {
+	logger.Info("Upload completed, starting scan", slog.String("upload_id", up.ID), slog.String("sha256", up.Sha256))
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), UploadScanTimeout)
+		defer cancel()
+
+		var (
+			result *models.FileScanResponse
+			errMsg string
+		)
+
+		content, password, err := h.uploads.Content(up.ID)
+		if err == nil {
+			result, err = h.scanFileContent(ctx, logger, up.Filename, content, fileScanOptions{
+				unpack:   up.Unpack,
+				password: password,
+			})
+		}
+		if err != nil {
+			_, errMsg = fileScanError(err)
+			logger.Error("Failed to scan uploaded file", slog.String("upload_id", up.ID), slog.Any("error", err))
+		}
+
+		if err := h.uploads.Finish(up.ID, result, errMsg); err != nil {
+			logger.Error("Failed to save upload scan result", slog.String("upload_id", up.ID), slog.Any("error", err))
+			return
+		}
+		logger.Info("Successfully processed uploaded file scan", slog.String("upload_id", up.ID))
+	}()
+}
+
+func (h *Handler) uploadLogger(r *http.Request) *slog.Logger {
+	return h.logger.With(
+		slog.String("method", r.Method),
+		slog.String("url", r.URL.String()),
+		slog.String("remote_addr", r.RemoteAddr),
+	)
+}
+
+// uploadError возвращает код ответа и сообщение для ошибки хранилища загрузок
+func uploadError(err error) (int, string) {
+	switch {
+	case errors.Is(err, upload.ErrNotFound):
+		return http.StatusNotFound, UploadNotFoundMsg
+	case errors.Is(err, upload.ErrOffsetMismatch):
+		return http.StatusConflict, UploadOffsetMismatchMsg
+	case errors.Is(err, upload.ErrCompleted):
+		return http.StatusConflict, UploadCompletedMsg
+	case errors.Is(err, upload.ErrBusy):
+		return http.StatusLocked, UploadBusyMsg
+	case errors.Is(err, upload.ErrChecksumMismatch):
+		return StatusChecksumMismatch, UploadChecksumMsg
+	case errors.Is(err, upload.ErrUnsupportedChecksum):
+		return http.StatusBadRequest, UploadBadChecksumMsg
+	case errors.Is(err, upload.ErrTooLarge):
+		return http.StatusRequestEntityTooLarge, UploadChunkTooLargeMsg
+	case errors.Is(err, upload.ErrQuotaExceeded):
+		return http.StatusInsufficientStorage, UploadQuotaMsg
+	default:
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return http.StatusRequestEntityTooLarge, UploadTooLargeMsg
+		}
+		return http.StatusInternalServerError, UploadInternalErrorMsg
+	}
+}
+
+// tusHeaders заголовки протокола tus, которые отдаются в каждом ответе
+func tusHeaders(w http.ResponseWriter) {
+	w.Header().Set("Tus-Resumable", TusVersion)
+	w.Header().Set("Tus-Version", TusVersion)
+	w.Header().Set("Tus-Extension", TusExtensions)
+	w.Header().Set("Tus-Max-Size", strconv.Itoa(MaxUploadSize))
+	w.Header().Set("Tus-Checksum-Algorithm", strings.Join(upload.ChecksumAlgorithms, ","))
+}
+
+// parseUploadMetadata разбирает Upload-Metadata: пары "ключ значение_в_base64" через запятую
+func parseUploadMetadata(header string) map[string]string {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			continue
+		}
+
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			continue
+		}
+		metadata[key] = string(value)
+	}
+
+	return metadata
+}
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/CodeMaster482/minions-server/common"
+)
+
+const (
+	DefaultVerdictHistoryLimit = 50  // Количество изменений вердикта по умолчанию
+	MaxVerdictHistoryLimit     = 500 // Максимальное количество изменений вердикта в ответе
+)
+
+// VerdictHistory
+// @Summary История вердиктов индикатора
+// @Description Возвращает, как менялись зона и категории веб-адреса, IP, домена или хеша файла по ответам Kaspersky API.
+// @Description Запись появляется, только когда вердикт отличается от предыдущего, первая запись - первая проверка индикатора.
+// @Description Индикатор приводится к тому же виду, что и при проверке в /api/scan/uri, хеши - к нижнему регистру.
+// @ID scan-verdict-history
+// @Tags Scan
+// @Produce json
+// @Param request query string true "Веб-адрес, IP, домен или хеш MD5/SHA1/SHA256"
+// @Param limit query int false "Количество изменений (по умолчанию 50, не больше 500)"
+// @Success 200 {object} models.VerdictTimeline "Изменения вердикта, новые первыми"
+// @Failure 400 {object} common.ErrorResponse "Bad Request: Incorrect query."
+// @Failure 500 {object} common.ErrorResponse "Internal Server Error"
+// @Router /api/scan/history [get]
+func (h *Handler) VerdictHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := h.logger.With(
+		slog.String("method", r.Method),
+		slog.String("url", r.URL.String()),
+		slog.String("remote_addr", r.RemoteAddr),
+	)
+
+	requestParam := r.URL.Query().Get("request")
+	if requestParam == "" {
+		common.RespondWithError(w, http.StatusBadRequest, BadRequestMsg)
+		logger.Error(MissingRequestParam)
+		return
+	}
+
+	limit := DefaultVerdictHistoryLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			common.RespondWithError(w, http.StatusBadRequest, BadRequestMsg)
+			logger.Error(BadRequestMsg, slog.String("limit", raw))
+			return
+		}
+		limit = min(n, MaxVerdictHistoryLimit)
+	}
+
+	inputType, requestParam, err := h.usecase.IndicatorKey(requestParam)
+	if err != nil {
+		common.RespondWithError(w, http.StatusBadRequest, InvalidInput)
+		logger.Error(InvalidInput, slog.Any("error", err))
+		return
+	}
+
+	timeline, err := h.usecase.VerdictHistory(ctx, inputType, requestParam, limit)
+	if err != nil {
+		common.RespondWithError(w, http.StatusInternalServerError, InternalServerErrorMsg)
+		logger.Error(InternalServerErrorMsg, slog.Any("error", err))
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, timeline)
+}
@@ -2,31 +2,105 @@ package scan
 
 import (
 	"context"
+	"errors"
+	"io"
+	"time"
 
 	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
 )
 
 type Usecase interface {
 	DetermineInputType(input string) (string, string, error)
+	EstimateRisk(inputType, requestParam string, resp *models.ResponseFromAPI) *models.HeuristicVerdict
+	HeuristicResponse(inputType, requestParam, mode string) *models.ResponseFromAPI
 
-	GetTextOCRResponse(OCR models.ApiResponse) ([]string, error)
-	RequestKasperskyAPI(ctx context.Context, ioc string, apiKey string) (*models.ResponseFromAPI, error)
+	GetTextOCRResponse(OCR models.ApiResponse) ([]models.IOC, error)
+	LocateIOC(OCR models.ApiResponse, ioc models.IOC) []models.Region
+	DecodeQRCodes(content []byte) ([]models.QRCode, error)
+	DetectScreenMIME(content []byte) (string, error)
+	SplitPDF(content []byte, maxPages int) ([][]byte, error)
+	NormalizeImage(content []byte, mimeType string) ([]byte, string, error)
+	AnnotateImage(content []byte, lookups []models.IOCLookup) ([]byte, error)
+	Refang(text string) string
+	FetchRemoteFile(ctx context.Context, rawURL string, maxSize int64, timeout time.Duration) (*models.RemoteFile, error)
+	ParseEmail(content []byte) (*models.ParsedEmail, error)
+	UnpackArchive(content []byte, passwords []string) (*models.ArchiveReport, error)
+	ExtractIOCs(text string) []models.IOC
+	RequestKasperskyFile(ctx context.Context, filename string, content []byte, apiKey string) (*models.FileScanResponse, error)
+	DetectFileType(filename string, content []byte) *models.FileType
+	CheckUploadPolicy(policy models.UploadPolicy, fileType *models.FileType, size int64) error
+	AnalyzeBinary(content []byte) (*models.LocalAnalysis, error)
+	AnalyzeDocument(content []byte) (*models.DocumentAnalysis, []models.IOC, error)
+	SaveFileResult(ctx context.Context, sha256 string, result *models.FileScanResponse, userID int) error
 
-	CachedResponse(ctx context.Context, inputType, requestParam string) (string, error)
-	SetCachedResponse(ctx context.Context, savedResponse, inputType, requestParam string) error
+	Lookup(ctx context.Context, inputType, requestParam string, userID int, apiKey string) (*models.ResponseFromAPI, error)
+	LookupWithMode(ctx context.Context, inputType, requestParam, mode string, userID int, apiKey string) (*models.ResponseFromAPI, error)
+	AllowRefresh(ctx context.Context, subject string, perHour int) (bool, error)
+	LookupHash(ctx context.Context, hash string, userID int, apiKey string) (*models.FileScanResponse, error)
 
-	SavedResponse(ctx context.Context, inputType, requestParam string) (string, error)
+	CachedResponse(ctx context.Context, inputType, requestParam string) (*models.StoredResponse, error)
+	SetCachedResponse(ctx context.Context, savedResponse, inputType, requestParam string, obtainedAt time.Time) error
+
+	SavedResponse(ctx context.Context, inputType, requestParam string) (*models.StoredResponse, error)
 	SaveResponse(ctx context.Context, respJson, zone, inputType, requestParam string, userID int) error
-	SaveUserStats(ctx context.Context, zone, inputType, requestParam string, userID int) error
+	RecordScan(ctx context.Context, zone, inputType, requestParam string, userID int) error
+
+	IndicatorKey(input string) (string, string, error)
+	VerdictHistory(ctx context.Context, inputType, requestParam string, limit int) (*models.VerdictTimeline, error)
+	ZoneChange(ctx context.Context, inputType, requestParam string) *models.ZoneChange
+	Rescan(ctx context.Context, inputType, requestParam, apiKey string) (*models.Verdict, error)
+	NotifyRedVerdict(ctx context.Context, zone, inputType, requestParam string, userID int)
+	NotifyFileScanned(ctx context.Context, sha256, filename, fileType, zone string, result *models.FileScanResponse, userID int)
 }
 
 type Redis interface {
-	GetCachedResponse(ctx context.Context, inputType, requestParam string) (string, error)
-	SetCachedResponse(ctx context.Context, savedResponse, inputType, requestParam string) error
+	GetCachedResponse(ctx context.Context, inputType, requestParam string) (*models.StoredResponse, error)
+	SetCachedResponse(ctx context.Context, savedResponse, inputType, requestParam string, obtainedAt time.Time) error
+	Expiration() time.Duration
+	IncrRefreshCount(ctx context.Context, subject string, window time.Duration) (int64, error)
+	MarkNotified(ctx context.Context, key string, window time.Duration) (bool, error)
 }
 
 type Postgres interface {
-	GetSavedResponse(ctx context.Context, inputType, requestParam string) (string, error)
+	GetSavedResponse(ctx context.Context, inputType, requestParam string) (*models.StoredResponse, error)
 	SaveResponse(ctx context.Context, respJson, inputType, requestParam string) error
-	SaveUserResponse(ctx context.Context, userID int, zone, inputType, requestParam string) error
+	SaveScanEvent(ctx context.Context, userID int, zone, inputType, requestParam, source string) error
+	SaveVerdict(ctx context.Context, inputType, requestParam, zone string, categories []string) (bool, error)
+	VerdictHistory(ctx context.Context, inputType, requestParam string, limit int) ([]models.VerdictChange, error)
+	ZoneChange(ctx context.Context, inputType, requestParam string) (*models.ZoneChange, error)
+}
+
+// Notifier рассылает события проверки подписчикам. userID 0 - событие неавторизованного пользователя,
+// оно доходит только до общих подписок
+type Notifier interface {
+	Notify(ctx context.Context, event string, userID int, data any) error
+}
+
+// RuleEngine проверяет загруженный файл по локальным правилам
+type RuleEngine interface {
+	Match(content []byte, fileType *models.FileType) []models.RuleMatch
+}
+
+// UploadStore хранит файлы, которые загружаются по частям, и результаты их проверки.
+// owner - пользователь, создавший загрузку: чужие загрузки для него не существуют
+type UploadStore interface {
+	Create(owner int, size int64, filename string, unpack bool, password string) (*models.Upload, error)
+	Get(id string, owner int) (*models.Upload, error)
+	Write(id string, owner int, offset int64, r io.Reader, checksum string) (*models.Upload, error)
+	Content(id string) ([]byte, string, error)
+	Finish(id string, result *models.FileScanResponse, scanErr string) error
+	Delete(id string, owner int) error
+}
+
+// Quarantine сохраняет опасные образцы, чтобы аналитикам не приходилось запрашивать их у пользователя повторно
+type Quarantine interface {
+	Store(ctx context.Context, sha256, filename, fileType, zone string, content []byte, userID int) error
+}
+
+// ErrOCRUnsupportedFormat возвращается движком OCR, который не умеет распознавать файлы такого типа
+var ErrOCRUnsupportedFormat = errors.New("format is not supported by OCR engine")
+
+// OCREngine распознаёт текст на изображении. Результат всегда приводится к формату ответа Yandex OCR
+type OCREngine interface {
+	Recognize(ctx context.Context, content []byte, mimeType string) (*models.ApiResponse, error)
 }
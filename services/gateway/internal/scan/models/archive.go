@@ -0,0 +1,54 @@
+package models
+
+// Форматы архивов, которые распаковываются при проверке файла
+const (
+	ArchiveFormatZIP   = "zip"
+	ArchiveFormatTAR   = "tar"
+	ArchiveFormatGZIP  = "gzip"
+	ArchiveFormatBZIP2 = "bzip2"
+)
+
+// ArchiveReport представляет результат распаковки архива и проверки его содержимого
+type ArchiveReport struct {
+	// Формат архива: zip, tar, gzip, bzip2
+	Format string `json:"Format" example:"zip"`
+
+	// Итоговая зона - самая опасная из зон архива и всех его файлов
+	Zone string `json:"Zone" example:"Red"`
+
+	// Файлы архива, вложенные архивы содержат свои файлы в поле Entries
+	Entries []ArchiveEntry `json:"Entries"`
+
+	// Распаковка остановлена из-за ограничений на вложенность, количество файлов или размер
+	Truncated bool `json:"Truncated,omitempty" example:"false"`
+}
+
+// ArchiveEntry представляет файл внутри архива
+type ArchiveEntry struct {
+	// Путь файла внутри архива
+	Path string `json:"Path" example:"docs/invoice.exe"`
+
+	// Размер распакованного файла в байтах
+	Size int64 `json:"Size" example:"123456"`
+
+	// SHA256 хеш распакованного файла
+	Sha256 string `json:"Sha256,omitempty" example:"ghi789..."`
+
+	// Формат, если файл сам является архивом
+	Format string `json:"Format,omitempty" example:"gzip"`
+
+	// Файл зашифрован
+	Encrypted bool `json:"Encrypted,omitempty" example:"true"`
+
+	// Зона файла, для вложенного архива - самая опасная из зон его содержимого
+	Zone string `json:"Zone,omitempty" example:"Red"`
+
+	// Ответ Kaspersky API по хешу файла
+	Result *FileScanResponse `json:"Result,omitempty"`
+
+	// Ошибка распаковки или проверки файла (если была)
+	Error string `json:"Error,omitempty" example:"Not Found: Lookup results not found."`
+
+	// Содержимое вложенного архива
+	Entries []ArchiveEntry `json:"Entries,omitempty"`
+}
@@ -0,0 +1,54 @@
+package models
+
+// Форматы исполняемых файлов, которые разбираются локально
+const (
+	BinaryFormatPE    = "pe"
+	BinaryFormatELF   = "elf"
+	BinaryFormatMachO = "macho"
+)
+
+// LocalAnalysis представляет результат локального статического разбора исполняемого файла.
+// Заполняется независимо от вердикта Kaspersky API
+type LocalAnalysis struct {
+	// Формат файла: pe, elf, macho
+	Format string `json:"Format" example:"pe"`
+
+	// Архитектура процессора
+	Architecture string `json:"Architecture" example:"amd64"`
+
+	// Время компиляции из заголовка PE (может быть подделано)
+	CompileTime string `json:"CompileTime,omitempty" example:"2022-01-01T00:00:00Z"`
+
+	// Импортируемые функции в виде library!function
+	Imports []string `json:"Imports,omitempty" example:"[\"kernel32.dll!VirtualAlloc\"]"`
+
+	// Импорты не поместились в ответ целиком
+	ImportsTruncated bool `json:"ImportsTruncated,omitempty" example:"false"`
+
+	// Imphash - MD5 от нормализованного списка импортов (только PE)
+	ImpHash string `json:"ImpHash,omitempty" example:"f34d5f2d4577ed6d9ceec516c1f5a744"`
+
+	// Секции файла
+	Sections []BinarySection `json:"Sections,omitempty"`
+
+	// Хотя бы одна секция похожа на упакованную или зашифрованную
+	Packed bool `json:"Packed" example:"true"`
+
+	// В файле есть цифровая подпись (подлинность подписи не проверяется)
+	Signed bool `json:"Signed" example:"false"`
+}
+
+// BinarySection представляет секцию исполняемого файла
+type BinarySection struct {
+	// Имя секции
+	Name string `json:"Name" example:"UPX1"`
+
+	// Размер данных секции в файле
+	Size int64 `json:"Size" example:"40960"`
+
+	// Энтропия Шеннона данных секции, от 0 до 8 бит на байт
+	Entropy float64 `json:"Entropy" example:"7.91"`
+
+	// Высокая энтропия или имя секции известного упаковщика
+	Packed bool `json:"Packed,omitempty" example:"true"`
+}
@@ -0,0 +1,72 @@
+package models
+
+// Форматы документов, которые проверяются локальными эвристиками
+const (
+	DocumentFormatOOXML = "ooxml"
+	DocumentFormatOLE   = "ole"
+	DocumentFormatPDF   = "pdf"
+)
+
+// DocumentAnalysis представляет результат локальной проверки документа Office или PDF
+type DocumentAnalysis struct {
+	// Формат документа: ooxml (docx, xlsx, pptx), ole (doc, xls, ppt), pdf
+	Format string `json:"Format" example:"ooxml"`
+
+	// В документе есть макросы VBA
+	HasMacros bool `json:"HasMacros" example:"true"`
+
+	// Макросы, которые запускаются автоматически при открытии или закрытии документа
+	AutoExecMacros []string `json:"AutoExecMacros,omitempty" example:"[\"Document_Open\"]"`
+
+	// Подозрительные вызовы в коде макросов
+	SuspiciousKeywords []string `json:"SuspiciousKeywords,omitempty" example:"[\"Shell\",\"URLDownloadToFile\"]"`
+
+	// Внешние связи документа: шаблон, OLE-объекты, фреймы
+	ExternalLinks []DocumentLink `json:"ExternalLinks,omitempty"`
+
+	// Документ загружает внешний шаблон (template injection)
+	ExternalTemplate bool `json:"ExternalTemplate,omitempty" example:"true"`
+
+	// Документ содержит OLE-объект, загружаемый по сети
+	RemoteOLE bool `json:"RemoteOLE,omitempty" example:"false"`
+
+	// PDF содержит JavaScript
+	JavaScript bool `json:"JavaScript,omitempty" example:"true"`
+
+	// PDF выполняет действие при открытии (/OpenAction или /AA)
+	OpenAction bool `json:"OpenAction,omitempty" example:"true"`
+
+	// PDF запускает внешнюю программу (/Launch)
+	Launch bool `json:"Launch,omitempty" example:"false"`
+
+	// PDF содержит вложенные файлы
+	EmbeddedFiles bool `json:"EmbeddedFiles,omitempty" example:"false"`
+
+	// Индикаторы, найденные в документе, и результаты их проверки
+	Indicators []IOCLookup `json:"Indicators,omitempty"`
+
+	// Итоговая зона по локальным признакам и проверенным индикаторам
+	Zone string `json:"Zone" example:"Yellow"`
+}
+
+// DocumentLink представляет внешнюю связь документа OOXML или OLE
+type DocumentLink struct {
+	// Тип связи: attachedTemplate, oleObject, frame, hyperlink, includeText, includePicture и т.п.
+	Type string `json:"Type" example:"attachedTemplate"`
+
+	// Адрес, по которому документ обращается
+	Target string `json:"Target" example:"http://evil.com/template.dotm"`
+}
+
+// LocalZone возвращает зону только по локальным признакам, без учёта проверки индикаторов
+func (a *DocumentAnalysis) LocalZone() string {
+	switch {
+	case a.ExternalTemplate, a.RemoteOLE, a.Launch,
+		len(a.AutoExecMacros) > 0 && len(a.SuspiciousKeywords) > 0:
+		return "Red"
+	case a.HasMacros, a.JavaScript, a.OpenAction, a.EmbeddedFiles:
+		return "Yellow"
+	default:
+		return "Green"
+	}
+}
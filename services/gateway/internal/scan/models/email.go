@@ -0,0 +1,126 @@
+package models
+
+// Источники, из которых индикатор извлечён при проверке письма
+const (
+	IOCSourceSender   = "sender"
+	IOCSourceReceived = "received"
+	IOCSourceBody     = "body"
+	IOCSourceHref     = "href"
+)
+
+// ParsedEmail представляет разобранное письмо (.eml или .msg)
+type ParsedEmail struct {
+	Subject      string
+	From         string
+	SenderDomain string
+	ReceivedIPs  []string
+	Links        []EmailLink
+	// Текст всех текстовых частей письма, HTML без разметки
+	Text        string
+	Attachments []EmailAttachmentContent
+}
+
+// EmailAttachmentContent представляет вложение письма вместе с содержимым
+type EmailAttachmentContent struct {
+	Filename    string
+	ContentType string
+	Content     []byte
+}
+
+// EmailLink представляет ссылку из HTML-части письма
+type EmailLink struct {
+	// Адрес, на который ведёт ссылка (href)
+	URL string `json:"URL" example:"http://evil.com/login"`
+
+	// Видимый текст ссылки
+	Text string `json:"Text,omitempty" example:"https://bank.ru"`
+
+	// Видимый текст похож на адрес, но указывает на другой хост
+	Mismatch bool `json:"Mismatch,omitempty" example:"true"`
+}
+
+// EmailAttachment представляет результат проверки вложения письма
+type EmailAttachment struct {
+	// Имя файла вложения
+	Filename string `json:"Filename" example:"invoice.exe"`
+
+	// MIME-тип из заголовков письма
+	ContentType string `json:"ContentType,omitempty" example:"application/octet-stream"`
+
+	// Размер вложения в байтах
+	Size int `json:"Size" example:"123456"`
+
+	// SHA256 хеш вложения
+	Sha256 string `json:"Sha256" example:"ghi789..."`
+
+	// Ответ Kaspersky API для вложения
+	Result *FileScanResponse `json:"Result,omitempty"`
+
+	// Ошибка проверки вложения (если была)
+	Error string `json:"Error,omitempty" example:"Kaspersky API returned unexpected error"`
+
+	// Вложение не отправлено на проверку: тип или размер запрещён политикой загрузки
+	Denied bool `json:"Denied,omitempty" example:"false"`
+}
+
+// EmailScanResponse представляет сводный отчёт о проверке письма
+type EmailScanResponse struct {
+	// Итоговая зона письма - самая опасная из зон индикаторов и вложений
+	Zone string `json:"Zone" example:"Red"`
+
+	// Тема письма
+	Subject string `json:"Subject" example:"Invoice"`
+
+	// Адрес отправителя из заголовка From
+	From string `json:"From" example:"billing@evil.com"`
+
+	// Домен отправителя
+	SenderDomain string `json:"SenderDomain,omitempty" example:"evil.com"`
+
+	// Внешние IP из цепочки заголовков Received
+	ReceivedIPs []string `json:"ReceivedIPs,omitempty" example:"[\"203.0.113.5\"]"`
+
+	// Ссылки из HTML-части письма
+	Links []EmailLink `json:"Links,omitempty"`
+
+	// Индикаторы из заголовков и текста письма и результаты их проверки
+	Indicators []IOCLookup `json:"Indicators"`
+
+	// Вложения и результаты их проверки
+	Attachments []EmailAttachment `json:"Attachments"`
+
+	// Откуда взяты результаты проверок индикаторов и вложений
+	Provenance *ProvenanceSummary `json:"Provenance,omitempty"`
+}
+
+// zoneSeverity порядок зон от безопасной к опасной. Серая зона (нет данных) опаснее зелёной
+var zoneSeverity = map[string]int{
+	"Green":  1,
+	"Grey":   2,
+	"Yellow": 3,
+	"Orange": 4,
+	"Red":    5,
+}
+
+// WorstZone возвращает самую опасную из зон. Пустые и неизвестные зоны пропускаются,
+// если известных зон нет, возвращается Grey
+func WorstZone(zones ...string) string {
+	worst := ""
+	for _, zone := range zones {
+		if zoneSeverity[zone] > zoneSeverity[worst] {
+			worst = zone
+		}
+	}
+
+	if worst == "" {
+		return "Grey"
+	}
+
+	return worst
+}
+
+// IsZone проверяет, что строка - одна из известных зон
+func IsZone(zone string) bool {
+	_, ok := zoneSeverity[zone]
+	return ok
+}
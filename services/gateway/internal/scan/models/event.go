@@ -0,0 +1,40 @@
+package models
+
+// События проверки, на которые можно подписать вебхук
+const (
+	EventRedVerdict  = "scan.red_verdict"    // Проверка вернула красную зону
+	EventFileScanned = "scan.file_completed" // Проверка загруженного файла завершена
+)
+
+// VerdictEvent данные события scan.red_verdict
+type VerdictEvent struct {
+	// Тип индикатора: ip, domain, url, hash
+	InputType string `json:"InputType" example:"url"`
+
+	// Индикатор
+	Request string `json:"Request" example:"http://malicious.example.com/login"`
+
+	// Зона
+	Zone string `json:"Zone" example:"Red"`
+
+	// Через какой эндпоинт пришла проверка: uri, file, screen, batch, watchlist
+	Source string `json:"Source" example:"uri"`
+}
+
+// FileScanEvent данные события scan.file_completed
+type FileScanEvent struct {
+	// SHA256 содержимого файла
+	Sha256 string `json:"Sha256" example:"275a021bbfb6489e54d471899f7db9d1663fc695ec2fe2a2c4538aabf651fd0f"`
+
+	// Имя файла, присланное клиентом
+	Filename string `json:"Filename" example:"invoice.exe"`
+
+	// Тип файла по сигнатуре
+	FileType string `json:"FileType" example:"pe"`
+
+	// Итоговая зона с учётом локальных правил и содержимого архива
+	Zone string `json:"Zone" example:"Red"`
+
+	// Названия обнаружений Kaspersky
+	Detections []string `json:"Detections" example:"[\"Trojan.Win32.Generic\"]"`
+}
@@ -13,6 +13,27 @@ type FileScanResponse struct {
 
 	// Обнаружения, связанные с проанализированным файлом
 	DynamicDetections []DynamicDetection `json:"DynamicDetections,omitempty"`
+
+	// Тип файла по сигнатуре и сравнение с расширением из имени (только при загрузке файла)
+	FileType *FileType `json:"FileType,omitempty"`
+
+	// Сработавшие локальные правила (только при загрузке файла)
+	RuleMatches []RuleMatch `json:"RuleMatches,omitempty"`
+
+	// Результат локального разбора исполняемого файла (PE, ELF, Mach-O)
+	LocalAnalysis *LocalAnalysis `json:"LocalAnalysis,omitempty"`
+
+	// Результат локальной проверки документа Office или PDF
+	DocumentAnalysis *DocumentAnalysis `json:"DocumentAnalysis,omitempty"`
+
+	// Содержимое архива и вердикты по каждому файлу (только при распаковке)
+	Archive *ArchiveReport `json:"Archive,omitempty"`
+
+	// Когда зона файла менялась последний раз (по истории вердиктов, в БД и кэш не сохраняется)
+	ZoneChange *ZoneChange `json:"ZoneChange,omitempty"`
+
+	// Откуда взят ответ (в БД и кэш не сохраняется)
+	Provenance *Provenance `json:"Provenance,omitempty"`
 }
 
 // FileGeneralInfo представляет общую информацию о проанализированном файле
@@ -0,0 +1,72 @@
+package models
+
+// Типы файлов, которые определяются по сигнатуре
+const (
+	FileTypePE      = "pe"
+	FileTypeELF     = "elf"
+	FileTypeMachO   = "macho"
+	FileTypePDF     = "pdf"
+	FileTypeDOCX    = "docx"
+	FileTypeXLSX    = "xlsx"
+	FileTypePPTX    = "pptx"
+	FileTypeOLE     = "ole"
+	FileTypeRTF     = "rtf"
+	FileTypeZIP     = "zip"
+	FileTypeRAR     = "rar"
+	FileType7Z      = "7z"
+	FileTypeGZIP    = "gzip"
+	FileTypeBZIP2   = "bzip2"
+	FileTypeTAR     = "tar"
+	FileTypePNG     = "png"
+	FileTypeJPEG    = "jpeg"
+	FileTypeGIF     = "gif"
+	FileTypeBMP     = "bmp"
+	FileTypeWebP    = "webp"
+	FileTypeHEIC    = "heic"
+	FileTypeScript  = "script"
+	FileTypeText    = "text"
+	FileTypeUnknown = "unknown"
+
+	// FileTypeExecutable в политике загрузки и правилах обозначает любой исполняемый файл
+	FileTypeExecutable = "executable"
+)
+
+// FileType представляет тип файла, определённый по содержимому, и его сравнение с расширением из имени файла
+type FileType struct {
+	// Тип файла по сигнатуре
+	Type string `json:"Type" example:"pe"`
+
+	// MIME-тип файла по сигнатуре
+	MimeType string `json:"MimeType" example:"application/vnd.microsoft.portable-executable"`
+
+	// Расширение из имени файла, которое прислал клиент
+	Extension string `json:"Extension,omitempty" example:"jpg"`
+
+	// Расширения, которые ожидаются для такого содержимого
+	ExpectedExtensions []string `json:"ExpectedExtensions,omitempty" example:"[\"exe\",\"dll\"]"`
+
+	// Энтропия Шеннона всего файла, от 0 до 8 бит на байт
+	Entropy float64 `json:"Entropy" example:"7.93"`
+
+	// Содержимое файла исполняемое (PE, ELF, Mach-O или скрипт)
+	Executable bool `json:"Executable" example:"true"`
+
+	// Расширение не соответствует содержимому (например, PE-файл с именем .jpg)
+	ExtensionMismatch bool `json:"ExtensionMismatch" example:"true"`
+
+	// Двойное расширение, скрывающее исполняемый файл (например, invoice.pdf.exe)
+	DoubleExtension bool `json:"DoubleExtension" example:"false"`
+}
+
+// UploadPolicy задаёт, какие типы файлов принимаются на проверку и какого размера.
+// Типы указываются так же, как в FileType.Type
+type UploadPolicy struct {
+	// Разрешённые типы. Пустой список разрешает все типы, кроме запрещённых
+	Allowed []string
+
+	// Запрещённые типы, имеют приоритет над разрешёнными
+	Denied []string
+
+	// Максимальный размер файла в байтах по типу. Для типов без ограничения действует общий лимит загрузки
+	MaxSize map[string]int64
+}
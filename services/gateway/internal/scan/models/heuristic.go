@@ -0,0 +1,31 @@
+package models
+
+// HeuristicSource метка источника локальной эвристической оценки
+const HeuristicSource = "local-heuristic"
+
+// HeuristicVerdict представляет локальную оценку риска, вычисленную без обращения к Kaspersky API
+type HeuristicVerdict struct {
+	// Источник оценки. Всегда "local-heuristic", чтобы не путать с вердиктом Kaspersky
+	Source string `json:"Source" example:"local-heuristic"`
+
+	// Итоговый балл риска от 0 до 100
+	Score int `json:"Score" example:"65"`
+
+	// Цвет зоны, рассчитанный по баллу: Red, Yellow, Green
+	Zone string `json:"Zone" example:"Red"`
+
+	// Сработавшие признаки с пояснениями
+	Signals []HeuristicSignal `json:"Signals,omitempty"`
+}
+
+// HeuristicSignal представляет отдельный признак, повлиявший на балл риска
+type HeuristicSignal struct {
+	// Название признака
+	Name string `json:"Name" example:"suspicious_tld"`
+
+	// Вклад признака в итоговый балл (может быть отрицательным)
+	Weight int `json:"Weight" example:"20"`
+
+	// Пояснение для пользователя
+	Description string `json:"Description" example:"Domain uses TLD .xyz that is popular among phishing sites"`
+}
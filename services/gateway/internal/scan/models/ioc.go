@@ -0,0 +1,119 @@
+package models
+
+// Типы индикаторов компрометации, которые извлекаются из текста
+const (
+	IOCTypeURL    = "url"
+	IOCTypeDomain = "domain"
+	IOCTypeIP     = "ip"
+	IOCTypeEmail  = "email"
+	IOCTypeMD5    = "md5"
+	IOCTypeSHA1   = "sha1"
+	IOCTypeSHA256 = "sha256"
+)
+
+// Источники, из которых индикатор извлечён при проверке изображения
+const (
+	IOCSourceOCR = "ocr"
+	IOCSourceQR  = "qr"
+)
+
+// IOC представляет индикатор компрометации, найденный в тексте
+type IOC struct {
+	// Тип индикатора: url, domain, ip, email, md5, sha1, sha256
+	Type string `json:"Type" example:"domain"`
+
+	// Значение индикатора после восстановления (refang)
+	Value string `json:"Value" example:"evil.com"`
+}
+
+// TextScanRequest представляет запрос на поиск индикаторов в произвольном тексте
+type TextScanRequest struct {
+	// Исходный текст, индикаторы могут быть обезврежены (hxxp://evil[.]com)
+	Text string `json:"text" example:"Block hxxp://evil[.]com and 1.2.3[.]4"`
+}
+
+// IOCLookup представляет результат проверки одного индикатора
+type IOCLookup struct {
+	IOC
+
+	// Значение, по которому выполнялся запрос к Kaspersky API (для email - домен)
+	Request string `json:"Request" example:"evil.com"`
+
+	// Ответ Kaspersky API для веб-адресов, IP и доменов
+	Result *ResponseFromAPI `json:"Result,omitempty"`
+
+	// Ответ Kaspersky API для хешей файлов
+	FileResult *FileScanResponse `json:"FileResult,omitempty"`
+
+	// Откуда взят индикатор: ocr (распознанный текст), qr (QR-код) при проверке изображения;
+	// sender, received, body, href при проверке письма
+	Sources []string `json:"Sources,omitempty" example:"[\"qr\"]"`
+
+	// Области изображения, в которых найден индикатор (только для проверки изображений)
+	Regions []Region `json:"Regions,omitempty"`
+
+	// Изображения и страницы PDF, на которых найден индикатор (только для проверки изображений)
+	Pages []ScreenPage `json:"Pages,omitempty"`
+
+	// Ошибка проверки индикатора (если была)
+	Error string `json:"Error,omitempty" example:"Kaspersky API returned unexpected error"`
+}
+
+// TextScanResponse представляет результат проверки индикаторов из текста
+type TextScanResponse struct {
+	// Найденные индикаторы и результаты их проверки
+	Indicators []IOCLookup `json:"Indicators"`
+
+	// Откуда взяты результаты проверок индикаторов
+	Provenance *ProvenanceSummary `json:"Provenance,omitempty"`
+}
+
+// Zone возвращает цвет зоны индикатора из ответа Kaspersky API или пустую строку, если ответа нет
+func (l IOCLookup) Zone() string {
+	switch {
+	case l.Result != nil:
+		return l.Result.Zone
+	case l.FileResult != nil:
+		return l.FileResult.Zone
+	default:
+		return ""
+	}
+}
+
+// Provenance возвращает сведения о том, откуда взят ответ на проверку индикатора, или nil, если ответа нет
+func (l IOCLookup) Provenance() *Provenance {
+	switch {
+	case l.Result != nil:
+		return l.Result.Provenance
+	case l.FileResult != nil:
+		return l.FileResult.Provenance
+	default:
+		return nil
+	}
+}
+
+// LookupsProvenance сводит сведения об источниках ответов на проверки индикаторов
+func LookupsProvenance(lookups []IOCLookup) *ProvenanceSummary {
+	provenances := make([]*Provenance, 0, len(lookups))
+	for _, lookup := range lookups {
+		provenances = append(provenances, lookup.Provenance())
+	}
+
+	return SummarizeProvenance(provenances...)
+}
+
+// ScreenScanResponse представляет результат проверки индикаторов, найденных на изображении
+type ScreenScanResponse struct {
+	// Найденные индикаторы и результаты их проверки
+	Indicators []IOCLookup `json:"Indicators"`
+
+	// Декодированные QR-коды с изображений, включая те, в которых не нашлось индикаторов (Wi-Fi, текст).
+	// На страницах PDF QR-коды не ищутся
+	QRCodes []QRCode `json:"QRCodes,omitempty"`
+
+	// Распознанные изображения и страницы PDF
+	Pages []ScreenPage `json:"Pages"`
+
+	// Откуда взяты результаты проверок индикаторов
+	Provenance *ProvenanceSummary `json:"Provenance,omitempty"`
+}
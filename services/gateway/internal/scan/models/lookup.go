@@ -0,0 +1,132 @@
+package models
+
+import "time"
+
+// Режимы проверки индикатора
+const (
+	LookupModeDefault   = "default"    // Redis, затем PostgreSQL, затем Kaspersky API
+	LookupModeRefresh   = "refresh"    // сразу Kaspersky API, сохранённый ответ перезаписывается
+	LookupModeCacheOnly = "cache_only" // только Redis и PostgreSQL, Kaspersky API не вызывается
+)
+
+// Слои, из которых может быть взят ответ
+const (
+	LayerRedis    = "redis"
+	LayerPostgres = "postgres"
+	LayerUpstream = "upstream"
+	LayerLocal    = "local" // Kaspersky API не ответил, вердикт - локальная эвристика
+)
+
+// Поставщики вердикта
+const (
+	ProviderKaspersky = "kaspersky_opentip"
+	ProviderLocal     = "local_heuristic"
+)
+
+// Provenance откуда взят ответ на проверку (в БД и кэш не сохраняется)
+type Provenance struct {
+	// Слой, из которого взят ответ: redis, postgres, upstream (запрос в Kaspersky API) или local
+	Layer string `json:"Layer" example:"redis"`
+
+	// Режим проверки: default, refresh или cache_only
+	Mode string `json:"Mode" example:"default"`
+
+	// Кто вынес вердикт: kaspersky_opentip или local_heuristic
+	Provider string `json:"Provider" example:"kaspersky_opentip"`
+
+	// Тип индикатора, под которым шлюз искал ответ: ip, domain, url, hash
+	InputType string `json:"InputType" example:"domain"`
+
+	// Индикатор после приведения к каноническому виду (без порта, с раскрытой короткой ссылкой и т.п.)
+	Request string `json:"Request" example:"example.com"`
+
+	// Когда вердикт получен от поставщика, пусто для старых записей кэша
+	ObtainedAt *time.Time `json:"ObtainedAt,omitempty" example:"2024-01-02T15:04:05Z"`
+
+	// Возраст вердикта в секундах
+	Age int64 `json:"Age" example:"3600"`
+
+	// Сколько секунд ответ ещё будет отдаваться из кэша, 0 - ответ не кэшируется
+	TTL int64 `json:"TTL" example:"82800"`
+}
+
+// Refresh пересчитывает Age и TTL сохранённых ранее сведений на момент now. Сведения формировались
+// в момент ObtainedAt + Age, с тех пор вердикт постарел, а срок в кэше уменьшился на то же время
+func (p *Provenance) Refresh(now time.Time) {
+	if p.ObtainedAt == nil {
+		return
+	}
+
+	age := max(int64(now.Sub(*p.ObtainedAt).Seconds()), 0)
+	if p.TTL > 0 {
+		p.TTL = max(p.TTL-(age-p.Age), 0)
+	}
+	p.Age = max(age, p.Age)
+}
+
+// ProvenanceSummary сводка об источниках ответа, собранного из нескольких проверок.
+// Подробности по каждой проверке - в поле Provenance её результата
+type ProvenanceSummary struct {
+	// Сколько проверок взято из каждого слоя: redis, postgres, upstream, local
+	Layers map[string]int `json:"Layers"`
+
+	// Когда получен самый старый вердикт в ответе
+	OldestObtainedAt *time.Time `json:"OldestObtainedAt,omitempty" example:"2024-01-02T15:04:05Z"`
+
+	// Возраст самого старого вердикта в секундах
+	Age int64 `json:"Age" example:"3600"`
+}
+
+// SummarizeProvenance сводит сведения об источниках нескольких проверок. Пустые значения пропускаются,
+// если не осталось ни одного, возвращается nil
+func SummarizeProvenance(provenances ...*Provenance) *ProvenanceSummary {
+	var summary *ProvenanceSummary
+	for _, provenance := range provenances {
+		if provenance == nil {
+			continue
+		}
+		if summary == nil {
+			summary = &ProvenanceSummary{Layers: make(map[string]int)}
+		}
+
+		summary.Layers[provenance.Layer]++
+		summary.Age = max(summary.Age, provenance.Age)
+		if provenance.ObtainedAt != nil && (summary.OldestObtainedAt == nil || provenance.ObtainedAt.Before(*summary.OldestObtainedAt)) {
+			summary.OldestObtainedAt = provenance.ObtainedAt
+		}
+	}
+
+	return summary
+}
+
+// Cached сообщает, что все проверки взяты из кэша или БД без обращения к поставщику
+func (s *ProvenanceSummary) Cached() bool {
+	for layer := range s.Layers {
+		if layer != LayerRedis && layer != LayerPostgres {
+			return false
+		}
+	}
+
+	return true
+}
+
+// StoredResponse ответ Kaspersky API из кэша или БД
+type StoredResponse struct {
+	// Ответ в JSON
+	Body string
+
+	// Когда ответ получен от Kaspersky API, нулевое время - неизвестно
+	ObtainedAt time.Time
+
+	// Сколько ответ ещё хранится в кэше, 0 - без срока
+	TTL time.Duration
+}
+
+// RefreshPolicy кому и как часто разрешена проверка в обход кэша
+type RefreshPolicy struct {
+	// Минимальная роль: пусто - всем, включая неавторизованных, user - авторизованным, admin - администраторам
+	Role string
+
+	// Сколько проверок в обход кэша разрешено одному пользователю (или IP-адресу) за час
+	PerHour int
+}
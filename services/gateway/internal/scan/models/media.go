@@ -0,0 +1,28 @@
+package models
+
+// MIME-типы файлов. Проверка изображений принимает все, кроме HEIC
+const (
+	MimeTypePNG  = "image/png"
+	MimeTypeJPEG = "image/jpeg"
+	MimeTypeWebP = "image/webp"
+	MimeTypeHEIC = "image/heic"
+	MimeTypePDF  = "application/pdf"
+)
+
+// ScreenPage представляет одну страницу или изображение, отправленное на распознавание
+type ScreenPage struct {
+	// Порядковый номер загруженного файла, начиная с 0
+	Image int `json:"Image" example:"0"`
+
+	// Имя загруженного файла
+	Filename string `json:"Filename" example:"invoice.pdf"`
+
+	// Номер страницы PDF, начиная с 1 (для изображений не заполняется)
+	Page int `json:"Page,omitempty" example:"2"`
+
+	// Определённый по содержимому MIME-тип файла
+	MimeType string `json:"MimeType" example:"application/pdf"`
+
+	// Ошибка распознавания страницы (если была)
+	Error string `json:"Error,omitempty" example:"Failed to recognize text"`
+}
@@ -0,0 +1,27 @@
+package models
+
+// Типы содержимого QR-кода
+const (
+	QRKindURL   = "url"
+	QRKindWiFi  = "wifi"
+	QRKindEmail = "email"
+	QRKindPhone = "phone"
+	QRKindSMS   = "sms"
+	QRKindVCard = "vcard"
+	QRKindText  = "text"
+)
+
+// QRCode представляет QR-код, найденный и декодированный на изображении
+type QRCode struct {
+	// Тип содержимого: url, wifi, email, phone, sms, vcard, text
+	Kind string `json:"Kind" example:"url"`
+
+	// Декодированное содержимое QR-кода
+	Payload string `json:"Payload" example:"https://evil.com/login"`
+
+	// Область изображения, в которой найден QR-код
+	Region *Region `json:"Region,omitempty"`
+
+	// Порядковый номер загруженного файла, на котором найден QR-код
+	Image int `json:"Image" example:"0"`
+}
@@ -0,0 +1,42 @@
+package models
+
+// Region представляет прямоугольную область изображения, в которой найден индикатор
+type Region struct {
+	// Координата левого края в пикселях
+	X int `json:"X" example:"120"`
+
+	// Координата верхнего края в пикселях
+	Y int `json:"Y" example:"48"`
+
+	// Ширина области в пикселях
+	Width int `json:"Width" example:"240"`
+
+	// Высота области в пикселях
+	Height int `json:"Height" example:"22"`
+
+	// Порядковый номер загруженного файла, на котором находится область
+	Image int `json:"Image" example:"0"`
+
+	// Номер страницы PDF, начиная с 1 (для изображений не заполняется)
+	Page int `json:"Page,omitempty" example:"1"`
+}
+
+// RegionFromBoundingBox возвращает прямоугольник, описанный вокруг многоугольника
+func RegionFromBoundingBox(box BoundingBox) (Region, bool) {
+	if len(box.Vertices) == 0 {
+		return Region{}, false
+	}
+
+	minX, minY := int(box.Vertices[0].X), int(box.Vertices[0].Y)
+	maxX, maxY := minX, minY
+	for _, v := range box.Vertices[1:] {
+		minX, maxX = min(minX, int(v.X)), max(maxX, int(v.X))
+		minY, maxY = min(minY, int(v.Y)), max(maxY, int(v.Y))
+	}
+
+	if maxX <= minX || maxY <= minY {
+		return Region{}, false
+	}
+
+	return Region{X: minX, Y: minY, Width: maxX - minX, Height: maxY - minY}, true
+}
@@ -0,0 +1,61 @@
+package models
+
+// FileURLScanRequest представляет запрос на проверку файла по ссылке
+type FileURLScanRequest struct {
+	// Ссылка на файл (http или https)
+	URL string `json:"url" example:"https://example.com/files/invoice.zip"`
+
+	// Распаковать архив и проверить хеш каждого файла
+	Unpack bool `json:"unpack,omitempty" example:"false"`
+
+	// Пароль к зашифрованному ZIP, пароль infected пробуется всегда
+	Password string `json:"password,omitempty" example:"infected"`
+}
+
+// RemoteFile представляет скачанный по ссылке файл
+type RemoteFile struct {
+	// Запрошенная ссылка
+	URL string
+
+	// Ссылка после всех перенаправлений
+	FinalURL string
+
+	// Имя файла из Content-Disposition или из пути ссылки
+	Filename string
+
+	// Content-Type, который вернул сервер
+	ContentType string
+
+	// Содержимое файла
+	Content []byte
+}
+
+// FileURLScanResponse представляет результат проверки файла по ссылке: вердикт по самой ссылке и по скачанному файлу
+type FileURLScanResponse struct {
+	// Итоговая зона - самая опасная из зон ссылки и файла
+	Zone string `json:"Zone" example:"Red"`
+
+	// Запрошенная ссылка
+	URL string `json:"URL" example:"https://example.com/files/invoice.zip"`
+
+	// Ссылка после всех перенаправлений
+	FinalURL string `json:"FinalURL" example:"https://cdn.example.com/invoice.zip"`
+
+	// Имя файла из Content-Disposition или из пути ссылки
+	Filename string `json:"Filename" example:"invoice.zip"`
+
+	// Content-Type, который вернул сервер. Тип по содержимому - в File.FileType
+	ContentType string `json:"ContentType,omitempty" example:"application/zip"`
+
+	// Размер скачанного файла в байтах
+	Size int64 `json:"Size" example:"123456"`
+
+	// Проверка ссылки через Kaspersky API
+	URLLookup IOCLookup `json:"URLLookup"`
+
+	// Проверка скачанного файла
+	File *FileScanResponse `json:"File"`
+
+	// Откуда взяты результаты проверок ссылки и файла
+	Provenance *ProvenanceSummary `json:"Provenance,omitempty"`
+}
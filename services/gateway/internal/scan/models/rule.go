@@ -0,0 +1,31 @@
+package models
+
+// RuleMatch представляет срабатывание локального правила на загруженном файле
+type RuleMatch struct {
+	// Имя правила
+	Rule string `json:"Rule" example:"campaign_invoice_dropper"`
+
+	// Описание правила
+	Description string `json:"Description,omitempty" example:"Загрузчик из рассылки с поддельными счетами"`
+
+	// Зона, которую правило присваивает файлу
+	Zone string `json:"Zone" example:"Red"`
+
+	// Метки правила
+	Tags []string `json:"Tags,omitempty" example:"[\"phishing\"]"`
+
+	// Совпавшие шаблоны
+	Patterns []RulePatternMatch `json:"Patterns,omitempty"`
+
+	// Файл с правилом
+	Source string `json:"Source" example:"campaigns.yaml"`
+}
+
+// RulePatternMatch представляет совпадение одного шаблона правила
+type RulePatternMatch struct {
+	// Шаблон в том виде, в котором он записан в правиле
+	Pattern string `json:"Pattern" example:"4D 5A ?? ?? 50 45"`
+
+	// Смещение первого совпадения от начала файла
+	Offset int `json:"Offset" example:"0"`
+}
@@ -0,0 +1,10 @@
+package models
+
+// Источники проверки, которые попадают в историю пользователя
+const (
+	ScanSourceURI       = "uri"       // Проверка адреса, IP или домена через /api/scan/uri
+	ScanSourceFile      = "file"      // Проверка файла: загрузка, ссылка на файл или загрузка по частям
+	ScanSourceScreen    = "screen"    // Индикаторы со снимка экрана
+	ScanSourceBatch     = "batch"     // Индикаторы из текста или письма
+	ScanSourceWatchlist = "watchlist" // Повторная проверка индикатора из списка наблюдения
+)
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Состояния загрузки по частям
+const (
+	UploadStatusUploading = "uploading" // Ожидаются следующие части
+	UploadStatusScanning  = "scanning"  // Файл получен целиком и проверяется
+	UploadStatusDone      = "done"      // Проверка завершена, результат в поле Result
+	UploadStatusFailed    = "failed"    // Проверка завершилась ошибкой, описание в поле Error
+)
+
+// Upload представляет загрузку файла по частям и результат его проверки
+type Upload struct {
+	// Идентификатор загрузки
+	ID string `json:"ID" example:"3f2a9c0e6b7d4e1f8a5b2c3d4e5f6a7b"`
+
+	// Имя файла из метаданных загрузки
+	Filename string `json:"Filename" example:"backup.zip"`
+
+	// Полный размер файла в байтах
+	Size int64 `json:"Size" example:"268435456"`
+
+	// Сколько байт уже получено
+	Offset int64 `json:"Offset" example:"104857600"`
+
+	// Распаковать архив после загрузки
+	Unpack bool `json:"Unpack,omitempty" example:"false"`
+
+	// Состояние: uploading, scanning, done, failed
+	Status string `json:"Status" example:"uploading"`
+
+	// SHA256 полученного файла (после завершения загрузки)
+	Sha256 string `json:"Sha256,omitempty" example:"ghi789..."`
+
+	// Время создания загрузки
+	CreatedAt time.Time `json:"CreatedAt" example:"2024-01-01T00:00:00Z"`
+
+	// После этого времени незавершённая загрузка и результат проверки удаляются
+	ExpiresAt time.Time `json:"ExpiresAt" example:"2024-01-02T00:00:00Z"`
+
+	// Результат проверки файла
+	Result *FileScanResponse `json:"Result,omitempty"`
+
+	// Ошибка проверки файла (если была)
+	Error string `json:"Error,omitempty" example:"Unsupported Media Type: File type is not allowed."`
+}
@@ -26,6 +26,15 @@ type ResponseFromAPI struct {
 
 	// WHOIS информация об IP (если применимо)
 	IpWhoIs *IpWhoIs `json:"IpWhoIs,omitempty"`
+
+	// Локальная эвристическая оценка (если Kaspersky API не дал вердикта)
+	LocalHeuristic *HeuristicVerdict `json:"LocalHeuristic,omitempty"`
+
+	// Когда зона индикатора менялась последний раз (по истории вердиктов, в БД и кэш не сохраняется)
+	ZoneChange *ZoneChange `json:"ZoneChange,omitempty"`
+
+	// Откуда взят ответ (в БД и кэш не сохраняется)
+	Provenance *Provenance `json:"Provenance,omitempty"`
 }
 
 // CategoryWithZone представляет категорию и ее зону
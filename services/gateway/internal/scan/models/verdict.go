@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// Verdict зона и категории индикатора по ответу Kaspersky API
+type Verdict struct {
+	// Цвет зоны
+	Zone string `json:"Zone" example:"Red"`
+
+	// Категории, отсортированные по алфавиту (для файла - названия обнаруженных объектов)
+	Categories []string `json:"Categories" example:"[\"Phishing URL\"]"`
+}
+
+// VerdictChange запись истории вердиктов индикатора. Записи добавляются, только когда
+// у индикатора меняется зона или набор категорий
+type VerdictChange struct {
+	// Цвет зоны
+	Zone string `json:"Zone" example:"Red"`
+
+	// Категории, отсортированные по алфавиту
+	Categories []string `json:"Categories" example:"[\"Phishing URL\"]"`
+
+	// Когда Kaspersky API впервые вернул такой вердикт
+	ChangedAt time.Time `json:"ChangedAt" example:"2024-11-01T12:00:00Z"`
+}
+
+// VerdictTimeline история вердиктов индикатора
+type VerdictTimeline struct {
+	// Тип индикатора: url, domain, ip или hash
+	InputType string `json:"InputType" example:"domain"`
+
+	// Индикатор в том виде, в котором он хранится в БД
+	Request string `json:"Request" example:"example.com"`
+
+	// Изменения вердикта, новые первыми
+	Changes []VerdictChange `json:"Changes"`
+}
+
+// ZoneChange сведения о последней смене зоны индикатора
+type ZoneChange struct {
+	// Зона до последней смены, пусто, если зона не менялась с первой проверки
+	PreviousZone string `json:"PreviousZone,omitempty" example:"Green"`
+
+	// С какого момента у индикатора текущая зона
+	ChangedAt time.Time `json:"ChangedAt" example:"2024-11-01T12:00:00Z"`
+}
@@ -1,13 +1,76 @@
 package models
 
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// Coordinate координата вершины. Yandex OCR отдаёт int64 строкой ("123"), но может прислать и число
+type Coordinate int
+
+func (c *Coordinate) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		if s == "" {
+			*c = 0
+			return nil
+		}
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		*c = Coordinate(v)
+		return nil
+	}
+
+	var v int
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*c = Coordinate(v)
+
+	return nil
+}
+
+// Vertex представляет вершину ограничивающего многоугольника
+type Vertex struct {
+	X Coordinate `json:"x"`
+	Y Coordinate `json:"y"`
+}
+
+// BoundingBox представляет ограничивающий многоугольник распознанного элемента
+type BoundingBox struct {
+	Vertices []Vertex `json:"vertices"`
+}
+
+// Word представляет слово, распознанное Yandex OCR
+type Word struct {
+	BoundingBox BoundingBox `json:"boundingBox"`
+	Text        string      `json:"text"`
+}
+
+// Line представляет строку текста, распознанную Yandex OCR
+type Line struct {
+	BoundingBox BoundingBox `json:"boundingBox"`
+	Text        string      `json:"text"`
+	Words       []Word      `json:"words"`
+}
+
+// Block представляет блок текста (абзац, колонку), распознанный Yandex OCR
+type Block struct {
+	BoundingBox BoundingBox `json:"boundingBox"`
+	Lines       []Line      `json:"lines"`
+}
+
 type TextAnnotation struct {
-	FullText string `json:'full_text'`
+	Blocks   []Block `json:"blocks"`
+	FullText string  `json:"fullText"`
 }
 
 type Result struct {
-	TextAnnotation TextAnnotation `json:'text_annotation'`
+	TextAnnotation TextAnnotation `json:"textAnnotation"`
 }
 
 type ApiResponse struct {
-	Result Result `json:'result'`
+	Result Result `json:"result"`
 }
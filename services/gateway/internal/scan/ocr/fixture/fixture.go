@@ -0,0 +1,58 @@
+package fixture
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+// DefaultFixture файл, который используется, если для изображения нет отдельной фикстуры
+const DefaultFixture = "default.json"
+
+// Fixture отдаёт заранее сохранённые ответы Yandex OCR из каталога.
+// Ответ ищется в файле <sha256 изображения>.json, иначе берётся default.json.
+// Нужен для тестов и локальной разработки без доступа к облаку.
+type Fixture struct {
+	dir    string
+	logger *slog.Logger
+}
+
+func New(dir string, logger *slog.Logger) *Fixture {
+	return &Fixture{
+		dir:    dir,
+		logger: logger,
+	}
+}
+
+func (f *Fixture) Recognize(_ context.Context, content []byte, _ string) (*models.ApiResponse, error) {
+	sum := sha256.Sum256(content)
+
+	for _, name := range []string{hex.EncodeToString(sum[:]) + ".json", DefaultFixture} {
+		data, err := os.ReadFile(filepath.Join(f.dir, name))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+
+		var ocrResponse models.ApiResponse
+		if err := json.Unmarshal(data, &ocrResponse); err != nil {
+			return nil, fmt.Errorf("invalid fixture %s: %w", name, err)
+		}
+
+		f.logger.Debug("OCR fixture used", slog.String("fixture", name))
+
+		return &ocrResponse, nil
+	}
+
+	return nil, fmt.Errorf("no OCR fixture found in %s", f.dir)
+}
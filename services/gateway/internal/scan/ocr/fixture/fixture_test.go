@@ -0,0 +1,67 @@
+package fixture
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/usecase"
+)
+
+// testdataDir каталог из config.example.yaml
+const testdataDir = "../../../../testdata/ocr"
+
+func newFixture(dir string) *Fixture {
+	return New(dir, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestRecognize(t *testing.T) {
+	image, err := os.ReadFile(filepath.Join(testdataDir, "phishing.png"))
+	if err != nil {
+		t.Fatalf("os.ReadFile: %v", err)
+	}
+
+	response, err := newFixture(testdataDir).Recognize(context.Background(), image, models.MimeTypePNG)
+	if err != nil {
+		t.Fatalf("Recognize: %v", err)
+	}
+
+	// Ответ из фикстуры разбирается так же, как ответ Yandex OCR
+	iocs, err := (&usecase.Usecase{}).GetTextOCRResponse(*response)
+	if err != nil {
+		t.Fatalf("GetTextOCRResponse: %v", err)
+	}
+	want := []models.IOC{{Type: models.IOCTypeURL, Value: "https://secure-login.example.com/verify"}}
+	if !reflect.DeepEqual(iocs, want) {
+		t.Errorf("iocs = %+v, want %+v", iocs, want)
+	}
+}
+
+func TestRecognizeDefault(t *testing.T) {
+	response, err := newFixture(testdataDir).Recognize(context.Background(), []byte("unknown image"), models.MimeTypePNG)
+	if err != nil {
+		t.Fatalf("Recognize: %v", err)
+	}
+	if annotation := response.Result.TextAnnotation; len(annotation.Blocks) != 0 || annotation.FullText != "" {
+		t.Errorf("default fixture = %+v, want empty text", annotation)
+	}
+}
+
+func TestRecognizeErrors(t *testing.T) {
+	if _, err := newFixture(t.TempDir()).Recognize(context.Background(), []byte("image"), models.MimeTypePNG); err == nil {
+		t.Error("empty dir: expected error")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, DefaultFixture), []byte("{"), 0o600); err != nil {
+		t.Fatalf("os.WriteFile: %v", err)
+	}
+	if _, err := newFixture(dir).Recognize(context.Background(), []byte("image"), models.MimeTypePNG); err == nil {
+		t.Error("invalid fixture: expected error")
+	}
+}
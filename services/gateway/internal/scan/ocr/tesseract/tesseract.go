@@ -0,0 +1,185 @@
+package tesseract
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+// Уровни элементов в TSV-выводе tesseract
+const (
+	levelBlock = 2
+	levelLine  = 4
+	levelWord  = 5
+)
+
+// Tesseract распознаёт текст локально через CLI tesseract, изображение не покидает сервер
+type Tesseract struct {
+	path      string
+	languages string
+	logger    *slog.Logger
+}
+
+func New(path, languages string, logger *slog.Logger) *Tesseract {
+	return &Tesseract{
+		path:      path,
+		languages: languages,
+		logger:    logger,
+	}
+}
+
+// Recognize запускает tesseract с выводом в TSV и собирает из него ответ в формате Yandex OCR.
+// Tesseract читает только растровые изображения, поэтому PDF отклоняется
+func (t *Tesseract) Recognize(ctx context.Context, content []byte, mimeType string) (*models.ApiResponse, error) {
+	if mimeType == models.MimeTypePDF {
+		return nil, fmt.Errorf("%w: %s", scan.ErrOCRUnsupportedFormat, mimeType)
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, t.path, "stdin", "stdout", "-l", t.languages, "tsv")
+	cmd.Stdin = bytes.NewReader(content)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		t.logger.Error("tesseract failed",
+			slog.Any("error", err),
+			slog.String("stderr", stderr.String()),
+		)
+		return nil, fmt.Errorf("tesseract failed: %w", err)
+	}
+
+	ocrResponse, err := parseTSV(&stdout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse tesseract output: %w", err)
+	}
+
+	t.logger.Debug("Tesseract response", slog.Int("blocks", len(ocrResponse.Result.TextAnnotation.Blocks)))
+
+	return ocrResponse, nil
+}
+
+// parseTSV разбирает TSV-вывод tesseract:
+// level page_num block_num par_num line_num word_num left top width height conf text.
+// Слова с уверенностью -1 (tesseract так помечает пустые области) пропускаются, слова с низкой уверенностью
+// остаются: типичные ошибки OCR исправляются при извлечении индикаторов. Строки и блоки без слов отбрасываются
+func parseTSV(r *bytes.Buffer) (*models.ApiResponse, error) {
+	annotation := &models.TextAnnotation{}
+
+	var block *models.Block
+	var line *models.Line
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	header := true
+	for scanner.Scan() {
+		if header {
+			header = false
+			continue
+		}
+
+		fields := strings.SplitN(scanner.Text(), "\t", 12)
+		if len(fields) < 11 {
+			continue
+		}
+
+		level, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, err
+		}
+
+		box, err := boundingBox(fields[6:10])
+		if err != nil {
+			return nil, err
+		}
+
+		conf, err := strconv.ParseFloat(fields[10], 64)
+		if err != nil {
+			return nil, err
+		}
+
+		switch level {
+		case levelBlock:
+			annotation.Blocks = append(annotation.Blocks, models.Block{BoundingBox: box})
+			block = &annotation.Blocks[len(annotation.Blocks)-1]
+			line = nil
+		case levelLine:
+			if block == nil {
+				continue
+			}
+			block.Lines = append(block.Lines, models.Line{BoundingBox: box})
+			line = &block.Lines[len(block.Lines)-1]
+		case levelWord:
+			if line == nil || conf < 0 || len(fields) < 12 || strings.TrimSpace(fields[11]) == "" {
+				continue
+			}
+			line.Words = append(line.Words, models.Word{BoundingBox: box, Text: fields[11]})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Пустые строки разрывали бы склейку перенесённых адресов при построчном разборе
+	var fullText strings.Builder
+	blocks := annotation.Blocks[:0]
+	for _, block := range annotation.Blocks {
+		lines := block.Lines[:0]
+		for _, line := range block.Lines {
+			if len(line.Words) == 0 {
+				continue
+			}
+
+			words := make([]string, 0, len(line.Words))
+			for _, word := range line.Words {
+				words = append(words, word.Text)
+			}
+			line.Text = strings.Join(words, " ")
+			lines = append(lines, line)
+
+			fullText.WriteString(line.Text)
+			fullText.WriteByte('\n')
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		block.Lines = lines
+		blocks = append(blocks, block)
+	}
+	annotation.Blocks = blocks
+	annotation.FullText = fullText.String()
+
+	return &models.ApiResponse{Result: models.Result{TextAnnotation: *annotation}}, nil
+}
+
+// boundingBox строит многоугольник из left, top, width, height
+func boundingBox(fields []string) (models.BoundingBox, error) {
+	var v [4]int
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return models.BoundingBox{}, err
+		}
+		v[i] = n
+	}
+
+	left, top, right, bottom := v[0], v[1], v[0]+v[2], v[1]+v[3]
+
+	return models.BoundingBox{Vertices: []models.Vertex{
+		{X: models.Coordinate(left), Y: models.Coordinate(top)},
+		{X: models.Coordinate(left), Y: models.Coordinate(bottom)},
+		{X: models.Coordinate(right), Y: models.Coordinate(bottom)},
+		{X: models.Coordinate(right), Y: models.Coordinate(top)},
+	}}, nil
+}
@@ -0,0 +1,196 @@
+package tesseract
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/ocr/fixture"
+)
+
+const tsvHeader = "level\tpage_num\tblock_num\tpar_num\tline_num\tword_num\tleft\ttop\twidth\theight\tconf\ttext"
+
+// tsv собирает вывод tesseract из строк, поля в строке разделены пробелами
+func tsv(rows ...string) string {
+	lines := []string{tsvHeader}
+	for _, row := range rows {
+		lines = append(lines, strings.ReplaceAll(row, " ", "\t"))
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// lineTexts возвращает тексты строк по блокам
+func lineTexts(annotation models.TextAnnotation) [][]string {
+	var blocks [][]string
+	for _, block := range annotation.Blocks {
+		var lines []string
+		for _, line := range block.Lines {
+			lines = append(lines, line.Text)
+		}
+		blocks = append(blocks, lines)
+	}
+
+	return blocks
+}
+
+func TestParseTSV(t *testing.T) {
+	tests := []struct {
+		name     string
+		tsv      string
+		want     [][]string
+		fullText string
+	}{
+		{
+			name: "single line",
+			tsv: tsv(
+				"1 1 0 0 0 0 0 0 800 600 -1 ",
+				"2 1 1 0 0 0 10 10 300 20 -1 ",
+				"3 1 1 1 0 0 10 10 300 20 -1 ",
+				"4 1 1 1 1 0 10 10 300 20 -1 ",
+				"5 1 1 1 1 1 10 10 60 20 96.2 visit",
+				"5 1 1 1 1 2 80 10 200 20 91.0 evil.example.com",
+			),
+			want:     [][]string{{"visit evil.example.com"}},
+			fullText: "visit evil.example.com\n",
+		},
+		{
+			// Пустые области tesseract отдаёт с уверенностью -1, слова с низкой уверенностью остаются
+			name: "low confidence",
+			tsv: tsv(
+				"2 1 1 0 0 0 10 10 300 20 -1 ",
+				"4 1 1 1 1 0 10 10 300 20 -1 ",
+				"5 1 1 1 1 1 10 10 60 20 -1 ",
+				"5 1 1 1 1 2 80 10 60 20 12.5 hxxp://l0gin.example.com",
+				"5 1 1 1 1 3 150 10 60 20 0 now",
+			),
+			want:     [][]string{{"hxxp://l0gin.example.com now"}},
+			fullText: "hxxp://l0gin.example.com now\n",
+		},
+		{
+			name: "empty lines",
+			tsv: tsv(
+				"2 1 1 0 0 0 10 10 300 80 -1 ",
+				"4 1 1 1 1 0 10 10 300 20 -1 ",
+				"5 1 1 1 1 1 10 10 300 20 95 https://evil.example.com/",
+				"4 1 1 1 2 0 10 40 300 20 -1 ",
+				"5 1 1 1 2 1 10 40 300 20 95  ",
+				"4 1 1 1 3 0 10 70 300 20 -1 ",
+				"5 1 1 1 3 1 10 70 300 20 95 login/reset",
+				"",
+				"2 1 2 0 0 0 10 200 300 20 -1 ",
+				"4 1 2 1 1 0 10 200 300 20 -1 ",
+			),
+			want:     [][]string{{"https://evil.example.com/", "login/reset"}},
+			fullText: "https://evil.example.com/\nlogin/reset\n",
+		},
+		{
+			name: "multiple blocks",
+			tsv: tsv(
+				"2 1 1 0 0 0 10 10 300 50 -1 ",
+				"4 1 1 1 1 0 10 10 300 20 -1 ",
+				"5 1 1 1 1 1 10 10 100 20 93 Invoice",
+				"4 1 1 1 2 0 10 40 300 20 -1 ",
+				"5 1 1 1 2 1 10 40 100 20 93 8.8.8.8",
+				"2 1 2 0 0 0 400 10 300 20 -1 ",
+				"4 1 2 1 1 0 400 10 300 20 -1 ",
+				"5 1 2 1 1 1 400 10 100 20 88 Contact",
+				"5 1 2 1 1 2 510 10 150 20 88 admin@example.com",
+			),
+			want:     [][]string{{"Invoice", "8.8.8.8"}, {"Contact admin@example.com"}},
+			fullText: "Invoice\n8.8.8.8\nContact admin@example.com\n",
+		},
+		{
+			name:     "header only",
+			tsv:      tsv(),
+			fullText: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := parseTSV(bytes.NewBufferString(tt.tsv))
+			if err != nil {
+				t.Fatalf("parseTSV: %v", err)
+			}
+
+			annotation := response.Result.TextAnnotation
+			if got := lineTexts(annotation); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lines = %q, want %q", got, tt.want)
+			}
+			if annotation.FullText != tt.fullText {
+				t.Errorf("FullText = %q, want %q", annotation.FullText, tt.fullText)
+			}
+
+			// Ответ tesseract в виде фикстуры должен отдаваться движком fixture без изменений
+			image := []byte("image " + tt.name)
+			sum := sha256.Sum256(image)
+			dir := t.TempDir()
+			data, err := json.Marshal(response)
+			if err != nil {
+				t.Fatalf("json.Marshal: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, hex.EncodeToString(sum[:])+".json"), data, 0o600); err != nil {
+				t.Fatalf("os.WriteFile: %v", err)
+			}
+
+			replayed, err := fixture.New(dir, slog.New(slog.NewTextHandler(io.Discard, nil))).
+				Recognize(context.Background(), image, models.MimeTypePNG)
+			if err != nil {
+				t.Fatalf("fixture Recognize: %v", err)
+			}
+			if !reflect.DeepEqual(lineTexts(replayed.Result.TextAnnotation), tt.want) ||
+				replayed.Result.TextAnnotation.FullText != tt.fullText {
+				t.Errorf("fixture = %+v, want %+v", replayed.Result.TextAnnotation, annotation)
+			}
+		})
+	}
+}
+
+func TestParseTSVBoundingBox(t *testing.T) {
+	response, err := parseTSV(bytes.NewBufferString(tsv(
+		"2 1 1 0 0 0 10 20 300 40 -1 ",
+		"4 1 1 1 1 0 10 20 300 40 -1 ",
+		"5 1 1 1 1 1 15 25 50 30 90 word",
+	)))
+	if err != nil {
+		t.Fatalf("parseTSV: %v", err)
+	}
+
+	want := models.BoundingBox{Vertices: []models.Vertex{{X: 15, Y: 25}, {X: 15, Y: 55}, {X: 65, Y: 55}, {X: 65, Y: 25}}}
+	if got := response.Result.TextAnnotation.Blocks[0].Lines[0].Words[0].BoundingBox; !reflect.DeepEqual(got, want) {
+		t.Errorf("BoundingBox = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseTSVMalformed(t *testing.T) {
+	for _, row := range []string{
+		"x 1 1 0 0 0 10 20 300 40 -1 ",
+		"2 1 1 0 0 0 10 top 300 40 -1 ",
+		"5 1 1 1 1 1 15 25 50 30 high word",
+	} {
+		if _, err := parseTSV(bytes.NewBufferString(tsv(row))); err == nil {
+			t.Errorf("parseTSV(%q): expected error", row)
+		}
+	}
+}
+
+func TestRecognizeRejectsPDF(t *testing.T) {
+	engine := New("tesseract-not-called", "eng", slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	if _, err := engine.Recognize(context.Background(), []byte("%PDF-1.7"), models.MimeTypePDF); !errors.Is(err, scan.ErrOCRUnsupportedFormat) {
+		t.Errorf("Recognize: got %v, want ErrOCRUnsupportedFormat", err)
+	}
+}
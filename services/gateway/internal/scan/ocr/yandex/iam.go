@@ -0,0 +1,320 @@
+package yandex
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/pkg/health"
+)
+
+const (
+	IAMTokensURL = "https://iam.api.cloud.yandex.net/iam/v1/tokens"
+
+	// jwtLifetime время жизни JWT для обмена на IAM-токен (максимум по документации - 1 час)
+	jwtLifetime = time.Hour
+	// maxRefreshInterval IAM-токен живёт до 12 часов, но Yandex рекомендует обновлять его не реже раза в час
+	maxRefreshInterval = time.Hour
+	// minRetryInterval и maxRetryInterval задают экспоненциальную задержку повтора после неудачного обновления
+	minRetryInterval = 30 * time.Second
+	maxRetryInterval = 5 * time.Minute
+)
+
+var (
+	ErrNoIAMToken      = errors.New("no valid IAM token")
+	ErrIAMTokenExpired = errors.New("IAM token expiry is missing or already passed")
+)
+
+// TokenSource возвращает действующий IAM-токен для запросов к Yandex Cloud
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// StaticToken IAM-токен, заданный в конфигурации. Не обновляется и перестаёт работать через 12 часов
+type StaticToken string
+
+func (t StaticToken) Token() (string, error) {
+	if t == "" {
+		return "", ErrNoIAMToken
+	}
+	return string(t), nil
+}
+
+// ServiceAccountKey авторизованный ключ сервисного аккаунта в формате, который выдаёт `yc iam key create`
+type ServiceAccountKey struct {
+	ID               string `json:"id"`
+	ServiceAccountID string `json:"service_account_id"`
+	PrivateKey       string `json:"private_key"`
+}
+
+// IAMTokenRefresher получает IAM-токены по ключу сервисного аккаунта и обновляет их в фоне до истечения
+type IAMTokenRefresher struct {
+	key        ServiceAccountKey
+	privateKey *rsa.PrivateKey
+	client     *http.Client
+	logger     *slog.Logger
+
+	// exchange обменивает JWT на IAM-токен, в тестах подменяется
+	exchange func(ctx context.Context) (string, time.Time, error)
+
+	mu        sync.RWMutex
+	token     string
+	expiresAt time.Time
+	lastErr   error
+}
+
+// NewIAMTokenRefresher читает ключ сервисного аккаунта из файла. Токен запрашивается при вызове Run
+func NewIAMTokenRefresher(keyFile string, logger *slog.Logger) (*IAMTokenRefresher, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account key: %w", err)
+	}
+
+	var key ServiceAccountKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("failed to parse service account key: %w", err)
+	}
+	if key.ID == "" || key.ServiceAccountID == "" || key.PrivateKey == "" {
+		return nil, errors.New("service account key must contain id, service_account_id and private_key")
+	}
+
+	privateKey, err := parsePrivateKey(key.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &IAMTokenRefresher{
+		key:        key,
+		privateKey: privateKey,
+		client:     &http.Client{Timeout: 30 * time.Second},
+		logger:     logger,
+	}
+	r.exchange = r.requestToken
+
+	return r, nil
+}
+
+// Token возвращает текущий IAM-токен. Безопасен для вызова из нескольких горутин
+func (r *IAMTokenRefresher) Token() (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.token == "" || time.Now().After(r.expiresAt) {
+		if r.lastErr != nil {
+			return "", errors.Join(ErrNoIAMToken, r.lastErr)
+		}
+		return "", ErrNoIAMToken
+	}
+
+	return r.token, nil
+}
+
+// Name имя компонента для проверки здоровья
+func (r *IAMTokenRefresher) Name() string {
+	return "yandex_iam_token"
+}
+
+// Check возвращает ошибку, только если действующего токена нет. Неудачное обновление при действующем
+// токене и ожидание первого токена после запуска отмечаются как warning: распознавание ещё работает
+func (r *IAMTokenRefresher) Check(_ context.Context) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	valid := r.token != "" && time.Now().Before(r.expiresAt)
+	switch {
+	case valid && r.lastErr != nil:
+		return health.Warning(fmt.Errorf("last IAM token renewal failed, current token expires at %s: %w",
+			r.expiresAt.Format(time.RFC3339), r.lastErr))
+	case valid:
+		return nil
+	case r.token == "" && r.lastErr == nil:
+		return health.Warning(fmt.Errorf("%w: first IAM token is not obtained yet", ErrNoIAMToken))
+	case r.lastErr != nil:
+		return fmt.Errorf("IAM token renewal failed: %w", errors.Join(ErrNoIAMToken, r.lastErr))
+	default:
+		return ErrNoIAMToken
+	}
+}
+
+// Run получает первый токен и обновляет его в фоне, пока не будет отменён контекст
+func (r *IAMTokenRefresher) Run(ctx context.Context) {
+	retry := minRetryInterval
+
+	for {
+		var wait time.Duration
+
+		expiresAt, err := r.refresh(ctx)
+		if err != nil {
+			r.logger.Error("Failed to renew Yandex IAM token",
+				slog.Any("error", err),
+				slog.Duration("retry_in", retry),
+			)
+			wait = retry
+			retry = min(retry*2, maxRetryInterval)
+		} else {
+			retry = minRetryInterval
+			wait = refreshInterval(time.Until(expiresAt))
+			r.logger.Info("Yandex IAM token renewed",
+				slog.Time("expires_at", expiresAt),
+				slog.Duration("next_refresh_in", wait),
+			)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// refreshInterval возвращает, через сколько обновлять токен, который действует ещё ttl: на середине срока,
+// но не реже maxRefreshInterval и не чаще minRetryInterval, чтобы не завалить IAM запросами
+func refreshInterval(ttl time.Duration) time.Duration {
+	return max(min(ttl/2, maxRefreshInterval), minRetryInterval)
+}
+
+// refresh подписывает JWT и обменивает его на новый IAM-токен. Токен без срока действия
+// или с уже прошедшим сроком (например, из-за расхождения часов) считается ошибкой
+func (r *IAMTokenRefresher) refresh(ctx context.Context) (time.Time, error) {
+	token, expiresAt, err := r.exchange(ctx)
+	if err == nil && !expiresAt.After(time.Now()) {
+		err = fmt.Errorf("%w: expiresAt %q", ErrIAMTokenExpired, expiresAt.Format(time.RFC3339))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastErr = err
+	if err != nil {
+		return time.Time{}, err
+	}
+	r.token = token
+	r.expiresAt = expiresAt
+
+	return expiresAt, nil
+}
+
+// requestToken подписывает JWT и запрашивает по нему IAM-токен
+func (r *IAMTokenRefresher) requestToken(ctx context.Context) (string, time.Time, error) {
+	jwt, err := r.signedJWT(time.Now())
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign JWT: %w", err)
+	}
+
+	payload, err := json.Marshal(map[string]string{"jwt": jwt})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", IAMTokensURL, bytes.NewReader(payload))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("unexpected status code from Yandex IAM: %d: %s", resp.StatusCode, body)
+	}
+
+	var tokenResponse struct {
+		IamToken  string    `json:"iamToken"`
+		ExpiresAt time.Time `json:"expiresAt"`
+	}
+	if err := json.Unmarshal(body, &tokenResponse); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to parse IAM response: %w", err)
+	}
+	if tokenResponse.IamToken == "" {
+		return "", time.Time{}, errors.New("empty IAM token in response")
+	}
+
+	return tokenResponse.IamToken, tokenResponse.ExpiresAt, nil
+}
+
+// signedJWT собирает JWT, подписанный ключом сервисного аккаунта по алгоритму PS256
+func (r *IAMTokenRefresher) signedJWT(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{
+		"typ": "JWT",
+		"alg": "PS256",
+		"kid": r.key.ID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(map[string]interface{}{
+		"aud": IAMTokensURL,
+		"iss": r.key.ServiceAccountID,
+		"iat": now.Unix(),
+		"exp": now.Add(jwtLifetime).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPSS(rand.Reader, r.privateKey, crypto.SHA256, digest[:], &rsa.PSSOptions{
+		SaltLength: rsa.PSSSaltLengthEqualsHash,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parsePrivateKey разбирает PEM-ключ. Yandex добавляет перед ним служебную строку, её пропускаем
+func parsePrivateKey(data string) (*rsa.PrivateKey, error) {
+	if idx := strings.Index(data, "-----BEGIN"); idx > 0 {
+		data = data[idx:]
+	}
+
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("service account private key is not a PEM block")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("service account private key is not an RSA key")
+		}
+		return rsaKey, nil
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse service account private key: %w", err)
+	}
+
+	return key, nil
+}
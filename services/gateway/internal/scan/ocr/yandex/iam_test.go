@@ -0,0 +1,248 @@
+package yandex
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/pkg/health"
+)
+
+// stubRefresher возвращает обновлятель, который вместо запроса к IAM вызывает exchange
+func stubRefresher(exchange func(ctx context.Context) (string, time.Time, error)) *IAMTokenRefresher {
+	return &IAMTokenRefresher{
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		exchange: exchange,
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	tests := []struct {
+		name      string
+		token     string
+		expiresAt time.Time
+		err       error
+		wantErr   error
+	}{
+		{
+			name:      "valid token",
+			token:     "t1.valid",
+			expiresAt: time.Now().Add(12 * time.Hour),
+		},
+		{
+			name:    "missing expiry",
+			token:   "t1.noexpiry",
+			wantErr: ErrIAMTokenExpired,
+		},
+		{
+			name:      "expiry in the past",
+			token:     "t1.skewed",
+			expiresAt: time.Now().Add(-time.Minute),
+			wantErr:   ErrIAMTokenExpired,
+		},
+		{
+			name:    "exchange failed",
+			err:     errors.New("connection refused"),
+			wantErr: ErrNoIAMToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := stubRefresher(func(context.Context) (string, time.Time, error) {
+				return tt.token, tt.expiresAt, tt.err
+			})
+
+			_, refreshErr := r.refresh(context.Background())
+			token, tokenErr := r.Token()
+
+			if tt.wantErr == nil {
+				if refreshErr != nil || tokenErr != nil {
+					t.Fatalf("refresh: %v, Token: %v", refreshErr, tokenErr)
+				}
+				if token != tt.token {
+					t.Errorf("Token() = %q, want %q", token, tt.token)
+				}
+				if err := r.Check(context.Background()); err != nil {
+					t.Errorf("Check: %v", err)
+				}
+				return
+			}
+
+			if refreshErr == nil {
+				t.Fatal("refresh: expected error")
+			}
+			if !errors.Is(refreshErr, tt.wantErr) && !errors.Is(tokenErr, tt.wantErr) {
+				t.Errorf("refresh: %v, Token: %v, want %v", refreshErr, tokenErr, tt.wantErr)
+			}
+			if token != "" {
+				t.Errorf("Token() = %q, want empty", token)
+			}
+			if err := r.Check(context.Background()); err == nil {
+				t.Error("Check: expected error")
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	renewErr := errors.New("connection refused")
+
+	tests := []struct {
+		name        string
+		token       string
+		expiresAt   time.Time
+		lastErr     error
+		wantErr     bool
+		wantWarning bool
+	}{
+		{name: "valid token", token: "t1.valid", expiresAt: time.Now().Add(time.Hour)},
+		{name: "first token not obtained yet", wantErr: true, wantWarning: true},
+		{
+			// Токен ещё действует часами, распознавание работает
+			name:        "renewal failed, token still valid",
+			token:       "t1.valid",
+			expiresAt:   time.Now().Add(6 * time.Hour),
+			lastErr:     renewErr,
+			wantErr:     true,
+			wantWarning: true,
+		},
+		{name: "first renewal failed", lastErr: renewErr, wantErr: true},
+		{name: "token expired", token: "t1.old", expiresAt: time.Now().Add(-time.Minute), wantErr: true},
+		{name: "renewal failed, token expired", token: "t1.old", expiresAt: time.Now().Add(-time.Minute), lastErr: renewErr, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &IAMTokenRefresher{token: tt.token, expiresAt: tt.expiresAt, lastErr: tt.lastErr}
+
+			err := r.Check(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check: %v, want error %v", err, tt.wantErr)
+			}
+			if health.IsWarning(err) != tt.wantWarning {
+				t.Errorf("Check: %v, want warning %v", err, tt.wantWarning)
+			}
+			if tt.lastErr != nil && !errors.Is(err, tt.lastErr) {
+				t.Errorf("Check: %v, want wrapped %v", err, tt.lastErr)
+			}
+		})
+	}
+}
+
+func TestRefreshInterval(t *testing.T) {
+	tests := []struct {
+		ttl  time.Duration
+		want time.Duration
+	}{
+		{ttl: 12 * time.Hour, want: maxRefreshInterval},
+		{ttl: 40 * time.Minute, want: 20 * time.Minute},
+		{ttl: time.Second, want: minRetryInterval},
+		{ttl: -time.Hour, want: minRetryInterval},
+	}
+
+	for _, tt := range tests {
+		if got := refreshInterval(tt.ttl); got != tt.want {
+			t.Errorf("refreshInterval(%s) = %s, want %s", tt.ttl, got, tt.want)
+		}
+	}
+}
+
+// Ответ без срока действия не должен приводить к повторным запросам без паузы
+func TestRunDoesNotSpin(t *testing.T) {
+	var calls atomic.Int32
+	r := stubRefresher(func(context.Context) (string, time.Time, error) {
+		calls.Add(1)
+		return "t1.noexpiry", time.Time{}, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	r.Run(ctx)
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("exchange called %d times, want 1", n)
+	}
+}
+
+func TestSignedJWT(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("x509.MarshalPKCS8PrivateKey: %v", err)
+	}
+
+	// Ключ в том виде, в котором его выдаёт yc: со служебной строкой перед PEM
+	keyJSON, err := json.Marshal(ServiceAccountKey{
+		ID:               "ajekey",
+		ServiceAccountID: "ajeaccount",
+		PrivateKey:       "PLEASE DO NOT REMOVE THIS LINE!\n" + string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	})
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "key.json")
+	if err := os.WriteFile(keyFile, keyJSON, 0o600); err != nil {
+		t.Fatalf("os.WriteFile: %v", err)
+	}
+
+	r, err := NewIAMTokenRefresher(keyFile, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewIAMTokenRefresher: %v", err)
+	}
+
+	now := time.Now()
+	jwt, err := r.signedJWT(now)
+	if err != nil {
+		t.Fatalf("signedJWT: %v", err)
+	}
+
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("jwt has %d parts, want 3", len(parts))
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("signature is not base64url: %v", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	err = rsa.VerifyPSS(&privateKey.PublicKey, crypto.SHA256, digest[:], signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	if err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+
+	rawClaims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatalf("claims are not base64url: %v", err)
+	}
+	var claims struct {
+		Aud string `json:"aud"`
+		Iss string `json:"iss"`
+		Exp int64  `json:"exp"`
+	}
+	if err := json.Unmarshal(rawClaims, &claims); err != nil {
+		t.Fatalf("claims: %v", err)
+	}
+	if claims.Aud != IAMTokensURL || claims.Iss != "ajeaccount" || claims.Exp != now.Add(jwtLifetime).Unix() {
+		t.Errorf("claims = %+v", claims)
+	}
+}
@@ -0,0 +1,90 @@
+package yandex
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+const RecognizeTextURL = "https://ocr.api.cloud.yandex.net/ocr/v1/recognizeText"
+
+// Yandex распознаёт текст через Yandex Cloud OCR API
+type Yandex struct {
+	tokens   TokenSource
+	folderID string
+	client   *http.Client
+	logger   *slog.Logger
+}
+
+func New(tokens TokenSource, folderID string, logger *slog.Logger) *Yandex {
+	return &Yandex{
+		tokens:   tokens,
+		folderID: folderID,
+		client:   &http.Client{},
+		logger:   logger,
+	}
+}
+
+// Recognize отправляет изображение в Yandex OCR и возвращает распознанный текст с разметкой
+func (y *Yandex) Recognize(ctx context.Context, content []byte, mimeType string) (*models.ApiResponse, error) {
+	iamToken, err := y.tokens.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get IAM token: %w", err)
+	}
+
+	// Подготовка данных для отправки в Yandex OCR API
+	data := map[string]interface{}{
+		"mimeType":      mimeType,
+		"languageCodes": []string{"*"},
+		"content":       base64.StdEncoding.EncodeToString(content),
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing OCR request data: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", RecognizeTextURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", iamToken)) // IAM токен
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-folder-id", y.folderID)
+	req.Header.Set("x-data-logging-enabled", "true")
+
+	resp, err := y.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		y.logger.Error("Yandex OCR returned unexpected status",
+			slog.Int("status_code", resp.StatusCode),
+			slog.String("body", string(body)),
+		)
+		return nil, fmt.Errorf("unexpected status code from Yandex OCR: %d", resp.StatusCode)
+	}
+
+	var ocrResponse models.ApiResponse
+	if err := json.Unmarshal(body, &ocrResponse); err != nil {
+		return nil, err
+	}
+
+	y.logger.Debug("Yandex response", slog.Any("res", ocrResponse))
+
+	return &ocrResponse, nil
+}
@@ -6,6 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+
+	"github.com/lib/pq"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
 )
 
 const (
@@ -13,8 +17,9 @@ const (
 )
 
 const (
+	// created_at хранится без часового пояса во времени сервера БД
 	GetScanResults = `
-        SELECT response FROM scan_results
+        SELECT response, created_at AT TIME ZONE current_setting('TimeZone') FROM scan_results
         WHERE input_type = $1 AND request = $2
         FOR UPDATE
     `
@@ -33,12 +38,53 @@ const (
            created_at = NOW()
     `
 
-	SaveUserScanStats = `
-        INSERT INTO user_scan_stats (user_id, input_type, request, zone, access_count, last_accessed)
-        VALUES ($1, $2, $3, $4, 1, NOW())
-        ON CONFLICT (user_id, input_type, request) DO UPDATE
-        SET access_count = user_scan_stats.access_count + 1,
-            last_accessed = NOW()
+	SaveScanEvent = `
+        INSERT INTO scan_events (user_id, input_type, request, zone, source)
+        VALUES (NULLIF($1, 0), $2, $3, $4, $5)
+    `
+
+	// Блокировка на индикатор не даёт двум одновременным проверкам записать одно изменение дважды
+	LockVerdictHistory = `
+        SELECT pg_advisory_xact_lock(hashtext($1 || ':' || $2))
+    `
+	// Вердикт записывается, только если он отличается от последнего записанного
+	SaveVerdict = `
+        INSERT INTO verdict_history (input_type, request, zone, categories)
+        SELECT $1::text, $2::text, $3::text, $4::text[]
+        WHERE NOT EXISTS (
+            SELECT 1
+            FROM (
+                SELECT zone, categories
+                FROM verdict_history
+                WHERE input_type = $1 AND request = $2
+                ORDER BY id DESC
+                LIMIT 1
+            ) AS last
+            WHERE last.zone = $3 AND last.categories = $4::text[]
+        )
+    `
+	VerdictHistory = `
+        SELECT zone, categories, created_at
+        FROM verdict_history
+        WHERE input_type = $1 AND request = $2
+        ORDER BY id DESC
+        LIMIT $3
+    `
+	// Текущая зона - зона последней записи. Время смены - первая запись после последней записи с другой зоной
+	ZoneChange = `
+        WITH history AS (
+            SELECT id, zone, created_at
+            FROM verdict_history
+            WHERE input_type = $1 AND request = $2
+        ), previous AS (
+            SELECT id, zone
+            FROM history
+            WHERE zone <> (SELECT zone FROM history ORDER BY id DESC LIMIT 1)
+            ORDER BY id DESC
+            LIMIT 1
+        )
+        SELECT (SELECT MIN(created_at) FROM history WHERE id > COALESCE((SELECT id FROM previous), 0)),
+               COALESCE((SELECT zone FROM previous), '')
     `
 )
 
@@ -54,8 +100,9 @@ func New(db *sql.DB, logger *slog.Logger) *Postgres {
 	}
 }
 
-// GetSavedResponse берем сохраненный ответ из PostgreSQL и обновляем access_count
-func (p *Postgres) GetSavedResponse(ctx context.Context, inputType, requestParam string) (string, error) {
+// GetSavedResponse берем сохраненный ответ из PostgreSQL и обновляем access_count.
+// Вместе с ответом возвращается время его получения, nil - если ответа нет
+func (p *Postgres) GetSavedResponse(ctx context.Context, inputType, requestParam string) (*models.StoredResponse, error) {
 	p.logger.Debug("Starting GetSavedResponse",
 		slog.String("input_type", inputType),
 		slog.String("request_param", requestParam),
@@ -67,7 +114,7 @@ func (p *Postgres) GetSavedResponse(ctx context.Context, inputType, requestParam
 			slog.Any("error", err),
 		)
 
-		return "", fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 
 	defer func() {
@@ -78,20 +125,23 @@ func (p *Postgres) GetSavedResponse(ctx context.Context, inputType, requestParam
 		}
 	}()
 
-	var savedResponse string
-	err = tx.QueryRowContext(ctx, GetScanResults, inputType, requestParam).Scan(&savedResponse)
+	var (
+		saved      models.StoredResponse
+		obtainedAt sql.NullTime
+	)
+	err = tx.QueryRowContext(ctx, GetScanResults, inputType, requestParam).Scan(&saved.Body, &obtainedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			p.logger.Info("Record not found in PostgreSQL")
 
-			return "", nil
+			return nil, nil
 		}
 
 		p.logger.Error("Error executing SELECT query in PostgreSQL",
 			slog.Any("error", err),
 		)
 
-		return "", fmt.Errorf("error executing SELECT query: %w", err)
+		return nil, fmt.Errorf("error executing SELECT query: %w", err)
 	}
 
 	p.logger.Debug("Record found in PostgreSQL, updating access_count")
@@ -102,7 +152,7 @@ func (p *Postgres) GetSavedResponse(ctx context.Context, inputType, requestParam
 			slog.Any("error", err),
 		)
 
-		return "", fmt.Errorf("error updating access_count: %w", err)
+		return nil, fmt.Errorf("error updating access_count: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -110,12 +160,16 @@ func (p *Postgres) GetSavedResponse(ctx context.Context, inputType, requestParam
 			slog.Any("error", err),
 		)
 
-		return "", fmt.Errorf("failed to commit transaction: %w", err)
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	p.logger.Info("Successfully retrieved and updated response from PostgreSQL")
 
-	return savedResponse, nil
+	if obtainedAt.Valid {
+		saved.ObtainedAt = obtainedAt.Time.UTC()
+	}
+
+	return &saved, nil
 }
 
 func (p *Postgres) SaveResponse(ctx context.Context, responseJson, inputType, requestParam string) error {
@@ -159,23 +213,99 @@ func (p *Postgres) SaveResponse(ctx context.Context, responseJson, inputType, re
 	return nil
 }
 
-func (p *Postgres) SaveUserResponse(ctx context.Context, userID int, zone, inputType, requestParam string) error {
-	p.logger.Debug("Starting SaveUserResponse",
-		slog.String("user_id", fmt.Sprintf("%d", userID)),
-		slog.String("input_type", inputType),
-		slog.String("request_param", requestParam),
-	)
-
-	_, err := p.db.ExecContext(ctx, SaveUserScanStats, userID, inputType, requestParam, zone)
+// SaveScanEvent записывает одну проверку в журнал событий, userID 0 - неавторизованный пользователь
+func (p *Postgres) SaveScanEvent(ctx context.Context, userID int, zone, inputType, requestParam, source string) error {
+	_, err := p.db.ExecContext(ctx, SaveScanEvent, userID, inputType, requestParam, zone, source)
 	if err != nil {
-		p.logger.Error("Error inserting/updating user_scan_stats", slog.Any("error", err))
+		p.logger.Error("Error inserting scan_events", slog.Any("error", err))
 		return err
 	}
 
-	p.logger.Info("Successfully saved/updated user-specific response in PostgreSQL")
 	return nil
 }
 
+// SaveVerdict добавляет вердикт в историю индикатора, если зона или категории изменились.
+// Возвращает true, если запись добавлена
+func (p *Postgres) SaveVerdict(ctx context.Context, inputType, requestParam, zone string, categories []string) (bool, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		p.logger.Error("Failed to begin transaction", slog.Any("error", err))
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, LockVerdictHistory, inputType, requestParam); err != nil {
+		p.logger.Error("Error locking verdict history", slog.Any("error", err))
+		return false, fmt.Errorf("error locking verdict history: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx, SaveVerdict, inputType, requestParam, zone, pq.Array(categories))
+	if err != nil {
+		p.logger.Error("Error inserting verdict_history", slog.Any("error", err))
+		return false, fmt.Errorf("error inserting verdict_history: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		p.logger.Error("Failed to commit transaction", slog.Any("error", err))
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return inserted > 0, nil
+}
+
+// VerdictHistory возвращает последние изменения вердикта индикатора, новые первыми
+func (p *Postgres) VerdictHistory(ctx context.Context, inputType, requestParam string, limit int) ([]models.VerdictChange, error) {
+	rows, err := p.db.QueryContext(ctx, VerdictHistory, inputType, requestParam, limit)
+	if err != nil {
+		p.logger.Error("Error executing verdict history query", slog.Any("error", err))
+		return nil, fmt.Errorf("error executing verdict history query: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []models.VerdictChange{}
+	for rows.Next() {
+		var c models.VerdictChange
+		if err := rows.Scan(&c.Zone, pq.Array(&c.Categories), &c.ChangedAt); err != nil {
+			p.logger.Error("Error scanning row", slog.Any("error", err))
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		if c.Categories == nil {
+			c.Categories = []string{}
+		}
+		changes = append(changes, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		p.logger.Error("Rows iteration error", slog.Any("error", err))
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return changes, nil
+}
+
+// ZoneChange возвращает сведения о последней смене зоны индикатора, nil - если истории вердиктов нет
+func (p *Postgres) ZoneChange(ctx context.Context, inputType, requestParam string) (*models.ZoneChange, error) {
+	var (
+		changedAt    sql.NullTime
+		previousZone string
+	)
+	err := p.db.QueryRowContext(ctx, ZoneChange, inputType, requestParam).Scan(&changedAt, &previousZone)
+	if err != nil {
+		p.logger.Error("Error executing zone change query", slog.Any("error", err))
+		return nil, fmt.Errorf("error executing zone change query: %w", err)
+	}
+	if !changedAt.Valid {
+		return nil, nil
+	}
+
+	return &models.ZoneChange{PreviousZone: previousZone, ChangedAt: changedAt.Time}, nil
+}
+
 // cleanupLeastPopularRecords Функция для очистки самых непопулярных записей в PostgreSQL
 func (p *Postgres) cleanupLeastPopularRecords(ctx context.Context, tx *sql.Tx) error {
 	var count int
@@ -8,10 +8,30 @@ import (
 	"time"
 
 	"github.com/gomodule/redigo/redis"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
 )
 
 const RedisCacheExpiration = 24 * time.Hour
 
+var (
+	// Ответ, время его получения (0, если ключа нет) и оставшийся TTL в миллисекундах
+	getCachedScript = `
+        return {redis.call("GET", KEYS[1]) or "", redis.call("GET", KEYS[2]) or "0", redis.call("PTTL", KEYS[1])}
+    `
+	// Ответ и время его получения записываются вместе, чтобы ключи не расходились
+	setCachedScript = `
+        redis.call("SETEX", KEYS[1], ARGV[1], ARGV[2])
+        redis.call("SETEX", KEYS[2], ARGV[1], ARGV[3])
+        return "OK"
+    `
+)
+
+// metaKey ключ со временем получения ответа
+func metaKey(inputType, requestParam string) string {
+	return fmt.Sprintf("scan:meta:%s:%s", inputType, requestParam)
+}
+
 type Redis struct {
 	redisPool *redis.Pool
 	logger    *slog.Logger
@@ -24,7 +44,9 @@ func New(redisPool *redis.Pool, logger *slog.Logger) *Redis {
 	}
 }
 
-func (r *Redis) GetCachedResponse(ctx context.Context, inputType, requestParam string) (string, error) {
+// GetCachedResponse возвращает ответ из кэша вместе со временем его получения и оставшимся TTL.
+// При промахе возвращает nil без ошибки
+func (r *Redis) GetCachedResponse(ctx context.Context, inputType, requestParam string) (*models.StoredResponse, error) {
 	redisKey := fmt.Sprintf("scan:%s:%s", inputType, requestParam)
 
 	r.logger.Debug("Try to get cached response from Redis",
@@ -36,29 +58,45 @@ func (r *Redis) GetCachedResponse(ctx context.Context, inputType, requestParam s
 	conn := r.redisPool.Get()
 	defer conn.Close()
 
-	// Выполняем команду GET
-	cachedResponse, err := redis.String(conn.Do("GET", redisKey))
+	// Ответ, время его получения и TTL читаются за один проход
+	values, err := redis.Values(conn.Do("EVAL", getCachedScript, 2, redisKey, metaKey(inputType, requestParam)))
 	if err != nil {
-		if errors.Is(err, redis.ErrNil) {
-			r.logger.Info("Cache Miss",
-				slog.Any("error", err),
-			)
-			return "", nil
-		}
-
 		r.logger.Error("Invalid error",
 			slog.Any("error", err),
 		)
-		return "", err
+		return nil, err
+	}
+
+	var (
+		body       string
+		obtainedAt int64
+		ttl        int64
+	)
+	if _, err := redis.Scan(values, &body, &obtainedAt, &ttl); err != nil {
+		r.logger.Error("Invalid cached response", slog.Any("error", err))
+		return nil, err
+	}
+	if body == "" {
+		r.logger.Info("Cache Miss")
+		return nil, nil
 	}
 
 	r.logger.Info("Cache Hit")
 
-	return cachedResponse, nil
+	stored := &models.StoredResponse{Body: body}
+	if obtainedAt > 0 {
+		stored.ObtainedAt = time.Unix(obtainedAt, 0).UTC()
+	}
+	if ttl > 0 {
+		stored.TTL = time.Duration(ttl) * time.Millisecond
+	}
+
+	return stored, nil
 }
 
-// SetCachedResponse сохраняет ответ в Redis с установленным TTL
-func (r *Redis) SetCachedResponse(ctx context.Context, savedResponse, inputType, requestParam string) error {
+// SetCachedResponse сохраняет ответ в Redis с установленным TTL. Время получения ответа хранится
+// в соседнем ключе с тем же TTL, чтобы формат самого ответа в кэше не менялся
+func (r *Redis) SetCachedResponse(ctx context.Context, savedResponse, inputType, requestParam string, obtainedAt time.Time) error {
 	redisKey := fmt.Sprintf("scan:%s:%s", inputType, requestParam)
 
 	r.logger.Debug("Attempting to set cached response in Redis",
@@ -70,7 +108,8 @@ func (r *Redis) SetCachedResponse(ctx context.Context, savedResponse, inputType,
 	conn := r.redisPool.Get()
 	defer conn.Close()
 
-	_, err := conn.Do("SETEX", redisKey, int(RedisCacheExpiration.Seconds()), savedResponse)
+	ttl := int(RedisCacheExpiration.Seconds())
+	_, err := conn.Do("EVAL", setCachedScript, 2, redisKey, metaKey(inputType, requestParam), ttl, savedResponse, obtainedAt.Unix())
 	if err != nil {
 		r.logger.Error("Failed to set cached response in Redis",
 			slog.Any("error", err),
@@ -82,3 +121,61 @@ func (r *Redis) SetCachedResponse(ctx context.Context, savedResponse, inputType,
 
 	return nil
 }
+
+// Expiration срок хранения ответа в кэше
+func (r *Redis) Expiration() time.Duration {
+	return RedisCacheExpiration
+}
+
+// IncrRefreshCount увеличивает счётчик проверок в обход кэша для subject и возвращает новое значение.
+// Окно фиксированное: счётчик создаётся с TTL window при первой проверке и не продлевается
+func (r *Redis) IncrRefreshCount(ctx context.Context, subject string, window time.Duration) (int64, error) {
+	redisKey := fmt.Sprintf("scan:refresh:%s", subject)
+
+	conn := r.redisPool.Get()
+	defer conn.Close()
+
+	// SET NX до INCR, чтобы у ключа всегда был TTL, даже если соединение оборвётся между командами
+	if err := conn.Send("SET", redisKey, 0, "EX", int(window.Seconds()), "NX"); err != nil {
+		return 0, err
+	}
+	if err := conn.Send("INCR", redisKey); err != nil {
+		return 0, err
+	}
+	if err := conn.Flush(); err != nil {
+		r.logger.Error("Failed to count refresh in Redis", slog.Any("error", err))
+		return 0, err
+	}
+	if _, err := conn.Receive(); err != nil && !errors.Is(err, redis.ErrNil) {
+		r.logger.Error("Failed to count refresh in Redis", slog.Any("error", err))
+		return 0, err
+	}
+
+	count, err := redis.Int64(conn.Receive())
+	if err != nil {
+		r.logger.Error("Failed to count refresh in Redis", slog.Any("error", err))
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// MarkNotified отмечает, что событие key уже разослано, на время window. Возвращает true,
+// если отметки ещё не было и событие нужно разослать
+func (r *Redis) MarkNotified(ctx context.Context, key string, window time.Duration) (bool, error) {
+	redisKey := fmt.Sprintf("scan:notified:%s", key)
+
+	conn := r.redisPool.Get()
+	defer conn.Close()
+
+	_, err := redis.String(conn.Do("SET", redisKey, 1, "EX", int(window.Seconds()), "NX"))
+	if errors.Is(err, redis.ErrNil) {
+		return false, nil
+	}
+	if err != nil {
+		r.logger.Error("Failed to mark notified event in Redis", slog.Any("error", err))
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,101 @@
+package rules
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// hexPattern шаблон байтов в шестнадцатеричном виде, ?? обозначает любой байт: 4D 5A ?? ?? 50 45
+type hexPattern struct {
+	text     string
+	data     []byte
+	wildcard []bool
+
+	// Самый длинный участок без подстановок, по нему ищутся кандидаты через bytes.Index
+	anchor       []byte
+	anchorOffset int
+}
+
+// parseHexPattern разбирает шаблон. Пробелы между байтами необязательны
+func parseHexPattern(text string) (*hexPattern, error) {
+	compact := strings.Join(strings.Fields(text), "")
+	if compact == "" || len(compact)%2 != 0 {
+		return nil, fmt.Errorf("hex pattern %q must consist of whole bytes", text)
+	}
+
+	p := &hexPattern{text: text}
+	for i := 0; i < len(compact); i += 2 {
+		token := compact[i : i+2]
+		if token == "??" {
+			p.data = append(p.data, 0)
+			p.wildcard = append(p.wildcard, true)
+			continue
+		}
+
+		b, err := hex.DecodeString(token)
+		if err != nil {
+			return nil, fmt.Errorf("hex pattern %q: invalid byte %q", text, token)
+		}
+		p.data = append(p.data, b[0])
+		p.wildcard = append(p.wildcard, false)
+	}
+
+	for start := 0; start < len(p.data); {
+		if p.wildcard[start] {
+			start++
+			continue
+		}
+
+		end := start
+		for end < len(p.data) && !p.wildcard[end] {
+			end++
+		}
+		if end-start > len(p.anchor) {
+			p.anchor = p.data[start:end]
+			p.anchorOffset = start
+		}
+		start = end
+	}
+
+	if len(p.anchor) == 0 {
+		return nil, errors.New("hex pattern must contain at least one fixed byte")
+	}
+
+	return p, nil
+}
+
+// find возвращает смещение первого совпадения или -1
+func (p *hexPattern) find(content []byte) int {
+	for start := 0; start < len(content); {
+		i := bytes.Index(content[start:], p.anchor)
+		if i < 0 {
+			return -1
+		}
+
+		pos := start + i - p.anchorOffset
+		if pos >= 0 && p.matchAt(content, pos) {
+			return pos
+		}
+		start += i + 1
+	}
+
+	return -1
+}
+
+// matchAt проверяет совпадение шаблона с данными, начиная с pos
+func (p *hexPattern) matchAt(content []byte, pos int) bool {
+	if pos+len(p.data) > len(content) {
+		return false
+	}
+
+	for i, b := range p.data {
+		if !p.wildcard[i] && content[pos+i] != b {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,99 @@
+package rules
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseHexPattern(t *testing.T) {
+	tests := []struct {
+		text         string
+		wantAnchor   []byte
+		wantOffset   int
+		wantWildcard []bool
+	}{
+		{text: "4D 5A", wantAnchor: []byte("MZ"), wantWildcard: []bool{false, false}},
+		{text: "4d5a", wantAnchor: []byte("MZ"), wantWildcard: []bool{false, false}},
+		// Якорь - самый длинный участок без подстановок
+		{
+			text:         "4D 5A ?? ?? 50 45 00 00",
+			wantAnchor:   []byte("PE\x00\x00"),
+			wantOffset:   4,
+			wantWildcard: []bool{false, false, true, true, false, false, false, false},
+		},
+		// При равной длине берётся первый участок
+		{text: "?? 41 ?? 42", wantAnchor: []byte("A"), wantOffset: 1, wantWildcard: []bool{true, false, true, false}},
+	}
+
+	for _, tt := range tests {
+		p, err := parseHexPattern(tt.text)
+		if err != nil {
+			t.Errorf("parseHexPattern(%q): %v", tt.text, err)
+			continue
+		}
+		if !bytes.Equal(p.anchor, tt.wantAnchor) || p.anchorOffset != tt.wantOffset {
+			t.Errorf("parseHexPattern(%q): anchor %q at %d, want %q at %d", tt.text, p.anchor, p.anchorOffset, tt.wantAnchor, tt.wantOffset)
+		}
+		if len(p.wildcard) != len(tt.wantWildcard) {
+			t.Errorf("parseHexPattern(%q): wildcard = %v, want %v", tt.text, p.wildcard, tt.wantWildcard)
+			continue
+		}
+		for i := range p.wildcard {
+			if p.wildcard[i] != tt.wantWildcard[i] {
+				t.Errorf("parseHexPattern(%q): wildcard = %v, want %v", tt.text, p.wildcard, tt.wantWildcard)
+				break
+			}
+		}
+	}
+}
+
+func TestParseHexPatternInvalid(t *testing.T) {
+	for _, text := range []string{"", "   ", "4D5", "4D ZZ", "4D 5?", "?? ??"} {
+		if _, err := parseHexPattern(text); err == nil {
+			t.Errorf("parseHexPattern(%q): expected error", text)
+		}
+	}
+}
+
+func TestHexPatternFind(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		content string
+		want    int
+	}{
+		{name: "exact", pattern: "4D 5A", content: "MZ", want: 0},
+		{name: "empty content", pattern: "4D 5A", content: "", want: -1},
+		{name: "wildcards", pattern: "4D 5A ?? ?? 50 45", content: "xxMZ\x90\x00PE", want: 2},
+		{name: "no match", pattern: "4D 5A ?? ?? 50 45", content: "xxMZ\x90\x00PX", want: -1},
+		{
+			// Первое вхождение якоря ближе к началу, чем его смещение в шаблоне, и пропускается
+			name:    "anchor before pattern start",
+			pattern: "?? ?? 50 45",
+			content: "PExxPE",
+			want:    2,
+		},
+		{
+			// Первое вхождение якоря не совпадает в остальных байтах, совпадение находится дальше
+			name:    "anchor without full match",
+			pattern: "4D 5A ?? 90",
+			content: "MZ\x01\x00MZ\x02\x90",
+			want:    4,
+		},
+		{name: "pattern past the end", pattern: "50 45 ?? ??", content: "xxxPE\x00", want: -1},
+		{name: "pattern at the end", pattern: "50 45 ?? ??", content: "xxxPE\x00\x00", want: 3},
+		{name: "overlapping anchors", pattern: "41 41 42", content: "AAAAB", want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parseHexPattern(tt.pattern)
+			if err != nil {
+				t.Fatalf("parseHexPattern: %v", err)
+			}
+			if got := p.find([]byte(tt.content)); got != tt.want {
+				t.Errorf("find(%q) = %d, want %d", tt.content, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,349 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+const (
+	ConditionAny = "any" // Достаточно совпадения одного шаблона
+	ConditionAll = "all" // Должны совпасть все шаблоны
+
+	// defaultZone зона правила, если она не указана
+	defaultZone = "Yellow"
+)
+
+// ruleFile формат файла с правилами
+type ruleFile struct {
+	Rules []ruleConfig `yaml:"rules"`
+}
+
+// ruleConfig правило в том виде, в котором оно записано в файле.
+// Условия на тип, размер и энтропию должны выполняться все, шаблоны объединяются по Condition
+type ruleConfig struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Zone        string   `yaml:"zone"`      // зона файла при срабатывании, по умолчанию Yellow
+	Tags        []string `yaml:"tags"`      // произвольные метки, возвращаются в ответе
+	Condition   string   `yaml:"condition"` // any (по умолчанию) или all
+	Hex         []string `yaml:"hex"`       // шаблоны байтов, ?? - любой байт
+	Strings     []string `yaml:"strings"`   // регулярные выражения по содержимому файла
+	Types       []string `yaml:"types"`     // типы файлов по сигнатуре, executable - любой исполняемый
+	MinSize     int64    `yaml:"min_size"`
+	MaxSize     int64    `yaml:"max_size"`
+	MinEntropy  float64  `yaml:"min_entropy"`
+	MaxEntropy  float64  `yaml:"max_entropy"`
+}
+
+// rule правило с разобранными шаблонами
+type rule struct {
+	ruleConfig
+	source  string
+	hex     []*hexPattern
+	regexps []*regexp.Regexp
+}
+
+// Engine загружает правила из каталога и проверяет по ним загруженные файлы.
+// Каталог периодически перечитывается, если файлы с правилами изменились
+type Engine struct {
+	dir      string
+	interval time.Duration
+	logger   *slog.Logger
+
+	mu          sync.RWMutex
+	rules       []rule
+	fingerprint string
+	lastErr     error
+	dirErr      bool // lastErr - ошибка чтения каталога, а не разбора правил
+}
+
+// New создаёт движок правил. Правила загружаются вызовом Load, пустой dir отключает правила
+func New(dir string, interval time.Duration, logger *slog.Logger) *Engine {
+	return &Engine{
+		dir:      dir,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Load читает все файлы *.yaml и *.yml из каталога. Если хотя бы один файл содержит ошибку,
+// остаются прежние правила, чтобы опечатка в одном файле не отключала остальные
+func (e *Engine) Load() error {
+	if e.dir == "" {
+		return nil
+	}
+
+	fingerprint, files, err := e.scanDir()
+	if err != nil {
+		e.setDirError(err)
+		return err
+	}
+
+	var (
+		loaded []rule
+		errs   []error
+	)
+	names := make(map[string]string)
+	for _, file := range files {
+		fileRules, err := loadFile(file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, r := range fileRules {
+			if other, ok := names[r.Name]; ok {
+				errs = append(errs, fmt.Errorf("%s: rule %q is already defined in %s", r.source, r.Name, other))
+				continue
+			}
+			names[r.Name] = r.source
+			loaded = append(loaded, r)
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// Отпечаток запоминается и при ошибке, чтобы не перечитывать тот же сломанный каталог на каждой проверке
+	e.fingerprint = fingerprint
+	e.dirErr = false
+	if err := errors.Join(errs...); err != nil {
+		e.lastErr = err
+		return err
+	}
+
+	e.rules = loaded
+	e.lastErr = nil
+	e.logger.Info("Rules loaded", slog.String("dir", e.dir), slog.Int("rules", len(loaded)))
+
+	return nil
+}
+
+// Run перечитывает правила при изменении каталога, пока не будет отменён контекст
+func (e *Engine) Run(ctx context.Context) {
+	if e.dir == "" || e.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		fingerprint, _, err := e.scanDir()
+		if err != nil {
+			e.logger.Error("Failed to read rules directory", slog.String("dir", e.dir), slog.Any("error", err))
+			e.setDirError(err)
+			continue
+		}
+
+		// После ошибки чтения каталога правила перечитываются, даже если файлы не изменились,
+		// чтобы проверка здоровья не сообщала о давно исправленной ошибке
+		e.mu.RLock()
+		changed := fingerprint != e.fingerprint || e.dirErr
+		e.mu.RUnlock()
+		if !changed {
+			continue
+		}
+
+		if err := e.Load(); err != nil {
+			e.logger.Error("Failed to reload rules, previous rules are kept", slog.Any("error", err))
+		}
+	}
+}
+
+// Name имя компонента для проверки здоровья
+func (e *Engine) Name() string {
+	return "rules"
+}
+
+// Check возвращает ошибку последней загрузки правил. Прежние правила при этом продолжают работать
+func (e *Engine) Check(_ context.Context) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.lastErr
+}
+
+// Match проверяет файл по всем правилам и возвращает сработавшие
+func (e *Engine) Match(content []byte, fileType *models.FileType) []models.RuleMatch {
+	e.mu.RLock()
+	rules := e.rules
+	e.mu.RUnlock()
+
+	var matches []models.RuleMatch
+	for _, r := range rules {
+		if match, ok := r.match(content, fileType); ok {
+			matches = append(matches, match)
+		}
+	}
+
+	return matches
+}
+
+// match проверяет условия правила, затем его шаблоны
+func (r *rule) match(content []byte, fileType *models.FileType) (models.RuleMatch, bool) {
+	size := int64(len(content))
+	switch {
+	case len(r.Types) > 0 && !slices.ContainsFunc(r.Types, func(t string) bool {
+		return t == fileType.Type || (t == models.FileTypeExecutable && fileType.Executable)
+	}):
+		return models.RuleMatch{}, false
+	case r.MinSize > 0 && size < r.MinSize, r.MaxSize > 0 && size > r.MaxSize:
+		return models.RuleMatch{}, false
+	case r.MinEntropy > 0 && fileType.Entropy < r.MinEntropy, r.MaxEntropy > 0 && fileType.Entropy > r.MaxEntropy:
+		return models.RuleMatch{}, false
+	}
+
+	var patterns []models.RulePatternMatch
+	for _, p := range r.hex {
+		if offset := p.find(content); offset >= 0 {
+			patterns = append(patterns, models.RulePatternMatch{Pattern: p.text, Offset: offset})
+		}
+	}
+	for _, re := range r.regexps {
+		if loc := re.FindIndex(content); loc != nil {
+			patterns = append(patterns, models.RulePatternMatch{Pattern: re.String(), Offset: loc[0]})
+		}
+	}
+
+	total := len(r.hex) + len(r.regexps)
+	if total > 0 {
+		if len(patterns) == 0 || (r.Condition == ConditionAll && len(patterns) < total) {
+			return models.RuleMatch{}, false
+		}
+	}
+
+	return models.RuleMatch{
+		Rule:        r.Name,
+		Description: r.Description,
+		Zone:        r.Zone,
+		Tags:        r.Tags,
+		Patterns:    patterns,
+		Source:      r.source,
+	}, true
+}
+
+// loadFile разбирает файл с правилами и компилирует шаблоны
+func loadFile(file string) ([]rule, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed ruleFile
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	source := filepath.Base(file)
+	rules := make([]rule, 0, len(parsed.Rules))
+	for i, cfg := range parsed.Rules {
+		r, err := compileRule(cfg, source)
+		if err != nil {
+			return nil, fmt.Errorf("%s: rule #%d: %w", file, i+1, err)
+		}
+		rules = append(rules, r)
+	}
+
+	return rules, nil
+}
+
+// compileRule проверяет правило и разбирает его шаблоны
+func compileRule(cfg ruleConfig, source string) (rule, error) {
+	r := rule{ruleConfig: cfg, source: source}
+
+	if strings.TrimSpace(r.Name) == "" {
+		return rule{}, errors.New("name is required")
+	}
+	if r.Zone == "" {
+		r.Zone = defaultZone
+	}
+	if !models.IsZone(r.Zone) {
+		return rule{}, fmt.Errorf("%s: unknown zone %q", r.Name, r.Zone)
+	}
+	if r.Condition == "" {
+		r.Condition = ConditionAny
+	}
+	if r.Condition != ConditionAny && r.Condition != ConditionAll {
+		return rule{}, fmt.Errorf("%s: condition must be %q or %q", r.Name, ConditionAny, ConditionAll)
+	}
+
+	for _, text := range r.Hex {
+		p, err := parseHexPattern(text)
+		if err != nil {
+			return rule{}, fmt.Errorf("%s: %w", r.Name, err)
+		}
+		r.hex = append(r.hex, p)
+	}
+	for _, text := range r.Strings {
+		re, err := regexp.Compile(text)
+		if err != nil {
+			return rule{}, fmt.Errorf("%s: %w", r.Name, err)
+		}
+		r.regexps = append(r.regexps, re)
+	}
+
+	if len(r.hex) == 0 && len(r.regexps) == 0 && len(r.Types) == 0 &&
+		r.MinSize == 0 && r.MaxSize == 0 && r.MinEntropy == 0 && r.MaxEntropy == 0 {
+		return rule{}, fmt.Errorf("%s: rule has no patterns or conditions and would match every file", r.Name)
+	}
+
+	return r, nil
+}
+
+// scanDir возвращает файлы с правилами и отпечаток каталога по их именам, размерам и времени изменения
+func (e *Engine) scanDir() (string, []string, error) {
+	entries, err := os.ReadDir(e.dir)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read rules directory: %w", err)
+	}
+
+	var (
+		files       []string
+		fingerprint strings.Builder
+	)
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return "", nil, err
+		}
+
+		files = append(files, filepath.Join(e.dir, entry.Name()))
+		fmt.Fprintf(&fingerprint, "%s:%d:%d;", entry.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+
+	return fingerprint.String(), files, nil
+}
+
+// setDirError запоминает ошибку чтения каталога для проверки здоровья
+func (e *Engine) setDirError(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.lastErr = err
+	e.dirErr = true
+}
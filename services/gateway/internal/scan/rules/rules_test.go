@@ -0,0 +1,168 @@
+package rules
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+func TestRuleMatch(t *testing.T) {
+	pe := &models.FileType{Type: "pe", Executable: true, Entropy: 7.5}
+	script := &models.FileType{Type: "script", Executable: true, Entropy: 4.2}
+	pdf := &models.FileType{Type: "pdf", Entropy: 6}
+
+	content := []byte("MZ\x90\x00PE\x00\x00 powershell -enc AAAA")
+
+	tests := []struct {
+		name         string
+		cfg          ruleConfig
+		fileType     *models.FileType
+		wantMatch    bool
+		wantPatterns []models.RulePatternMatch
+	}{
+		{
+			name:         "any with one of two patterns",
+			cfg:          ruleConfig{Hex: []string{"4D 5A ?? ?? 50 45"}, Strings: []string{"cmd\\.exe"}},
+			fileType:     pe,
+			wantMatch:    true,
+			wantPatterns: []models.RulePatternMatch{{Pattern: "4D 5A ?? ?? 50 45", Offset: 0}},
+		},
+		{
+			name:     "any with no patterns matched",
+			cfg:      ruleConfig{Hex: []string{"7F 45 4C 46"}, Strings: []string{"cmd\\.exe"}},
+			fileType: pe,
+		},
+		{
+			name:     "all with partial match",
+			cfg:      ruleConfig{Condition: ConditionAll, Hex: []string{"4D 5A ?? ?? 50 45"}, Strings: []string{"cmd\\.exe"}},
+			fileType: pe,
+		},
+		{
+			name:      "all with every pattern",
+			cfg:       ruleConfig{Condition: ConditionAll, Hex: []string{"4D 5A ?? ?? 50 45", "50 45 00 00"}, Strings: []string{"powershell\\s+-enc"}},
+			fileType:  pe,
+			wantMatch: true,
+			wantPatterns: []models.RulePatternMatch{
+				{Pattern: "4D 5A ?? ?? 50 45", Offset: 0},
+				{Pattern: "50 45 00 00", Offset: 4},
+				{Pattern: "powershell\\s+-enc", Offset: 9},
+			},
+		},
+		{
+			// Шаблоны совпали, но тип файла не подходит
+			name:     "type mismatch",
+			cfg:      ruleConfig{Types: []string{"elf"}, Hex: []string{"4D 5A"}},
+			fileType: pe,
+		},
+		{name: "type only", cfg: ruleConfig{Types: []string{"pdf", "pe"}}, fileType: pe, wantMatch: true},
+		{name: "executable type", cfg: ruleConfig{Types: []string{models.FileTypeExecutable}}, fileType: script, wantMatch: true},
+		{name: "executable type, document", cfg: ruleConfig{Types: []string{models.FileTypeExecutable}}, fileType: pdf},
+		{name: "min size", cfg: ruleConfig{MinSize: int64(len(content))}, fileType: pe, wantMatch: true},
+		{name: "under min size", cfg: ruleConfig{MinSize: int64(len(content)) + 1}, fileType: pe},
+		{name: "max size", cfg: ruleConfig{MaxSize: int64(len(content))}, fileType: pe, wantMatch: true},
+		{name: "over max size", cfg: ruleConfig{MaxSize: int64(len(content)) - 1}, fileType: pe},
+		{name: "min entropy", cfg: ruleConfig{MinEntropy: 7.2}, fileType: pe, wantMatch: true},
+		{name: "under min entropy", cfg: ruleConfig{MinEntropy: 7.2}, fileType: script},
+		{name: "over max entropy", cfg: ruleConfig{MaxEntropy: 7}, fileType: pe},
+		{
+			// Условия на тип, размер и энтропию выполняются все, даже при condition: any
+			name:     "conditions are always combined",
+			cfg:      ruleConfig{Types: []string{"pe"}, MaxEntropy: 7, Strings: []string{"powershell"}},
+			fileType: pe,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Name = "test_rule"
+			r, err := compileRule(tt.cfg, "test.yaml")
+			if err != nil {
+				t.Fatalf("compileRule: %v", err)
+			}
+
+			match, ok := r.match(content, tt.fileType)
+			if ok != tt.wantMatch {
+				t.Fatalf("match = %v, want %v", ok, tt.wantMatch)
+			}
+			if !ok {
+				return
+			}
+			if match.Rule != "test_rule" || match.Zone != defaultZone || match.Source != "test.yaml" {
+				t.Errorf("match = %+v", match)
+			}
+			if !reflect.DeepEqual(match.Patterns, tt.wantPatterns) {
+				t.Errorf("Patterns = %+v, want %+v", match.Patterns, tt.wantPatterns)
+			}
+		})
+	}
+}
+
+func TestCompileRuleInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  ruleConfig
+	}{
+		{name: "no name", cfg: ruleConfig{Hex: []string{"4D 5A"}}},
+		{name: "unknown zone", cfg: ruleConfig{Name: "r", Zone: "Purple", Hex: []string{"4D 5A"}}},
+		{name: "unknown condition", cfg: ruleConfig{Name: "r", Condition: "most", Hex: []string{"4D 5A"}}},
+		{name: "invalid hex", cfg: ruleConfig{Name: "r", Hex: []string{"4D 5"}}},
+		{name: "invalid regexp", cfg: ruleConfig{Name: "r", Strings: []string{"(unclosed"}}},
+		{name: "matches every file", cfg: ruleConfig{Name: "r", Tags: []string{"noise"}}},
+	}
+
+	for _, tt := range tests {
+		if _, err := compileRule(tt.cfg, "test.yaml"); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+// Ошибка в одном файле оставляет прежние правила, пока её не исправят
+func TestLoadKeepsPreviousRules(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatalf("os.WriteFile: %v", err)
+		}
+	}
+
+	engine := New(dir, 0, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	fileType := &models.FileType{Type: "pe", Executable: true}
+
+	write("mz.yaml", "rules:\n  - name: mz\n    zone: Red\n    hex: [\"4D 5A\"]\n")
+	if err := engine.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if matches := engine.Match([]byte("MZ"), fileType); len(matches) != 1 || matches[0].Zone != "Red" {
+		t.Fatalf("Match = %+v", matches)
+	}
+
+	write("broken.yml", "rules:\n  - name: mz\n    hex: [\"4D 5A\"]\n")
+	if err := engine.Load(); err == nil {
+		t.Fatal("Load with duplicate rule name: expected error")
+	}
+	if err := engine.Check(context.Background()); err == nil {
+		t.Error("Check: expected error")
+	}
+	if matches := engine.Match([]byte("MZ"), fileType); len(matches) != 1 {
+		t.Errorf("previous rules are not kept: %+v", matches)
+	}
+
+	write("broken.yml", "rules:\n  - name: pe\n    strings: [\"PE\"]\n")
+	if err := engine.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := engine.Check(context.Background()); err != nil {
+		t.Errorf("Check: %v", err)
+	}
+	if matches := engine.Match([]byte("MZ PE"), fileType); len(matches) != 2 {
+		t.Errorf("Match = %+v, want 2 rules", matches)
+	}
+}
@@ -0,0 +1,494 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+var (
+	ErrNotFound            = errors.New("upload not found")
+	ErrOffsetMismatch      = errors.New("upload offset does not match")
+	ErrChecksumMismatch    = errors.New("chunk checksum does not match")
+	ErrUnsupportedChecksum = errors.New("unsupported checksum algorithm")
+	ErrTooLarge            = errors.New("chunk exceeds declared upload length")
+	ErrBusy                = errors.New("upload is being written by another request")
+	ErrCompleted           = errors.New("upload is already completed")
+	ErrQuotaExceeded       = errors.New("upload staging quota exceeded")
+)
+
+const (
+	cleanupInterval = time.Minute           // Как часто удаляются просроченные загрузки
+	lockRetry       = 50 * time.Millisecond // Как часто Finish пробует захватить загрузку, занятую другим запросом
+
+	dataExt = ".bin"  // Полученные байты файла
+	infoExt = ".json" // Состояние загрузки
+)
+
+var idRegexp = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// ChecksumAlgorithms алгоритмы, которые принимаются в заголовке Upload-Checksum
+var ChecksumAlgorithms = []string{"sha1", "sha256", "md5"}
+
+// record состояние загрузки на диске. Пароль к архиву и владелец в ответах не возвращаются
+type record struct {
+	models.Upload
+	Owner    int    `json:"owner"`
+	Password string `json:"password,omitempty"`
+}
+
+// Store хранит загрузки по частям на локальном диске. Части дописываются в файл по смещению,
+// состояние лежит рядом в JSON. Незавершённые загрузки и результаты удаляются по истечении срока.
+// Загрузка доступна только создавшему её пользователю
+type Store struct {
+	dir      string
+	expiry   time.Duration
+	maxTotal int64 // сколько байт могут занимать на диске все незавершённые загрузки вместе, 0 - без лимита
+	logger   *slog.Logger
+
+	mu   sync.Mutex
+	busy map[string]struct{}
+
+	// Проверка квоты и создание загрузки выполняются под одной блокировкой
+	createMu sync.Mutex
+}
+
+// New создаёт каталог для загрузок. Проверки, прерванные перезапуском, помечаются как неудавшиеся
+func New(dir string, expiry time.Duration, maxTotal int64, logger *slog.Logger) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+
+	s := &Store{
+		dir:      dir,
+		expiry:   expiry,
+		maxTotal: maxTotal,
+		logger:   logger,
+		busy:     make(map[string]struct{}),
+	}
+	s.failInterrupted()
+
+	return s, nil
+}
+
+// Create регистрирует новую загрузку пользователя owner размером size байт. Место на диске резервируется
+// под весь заявленный размер: если вместе с незавершёнными загрузками он превышает квоту, возвращается ErrQuotaExceeded
+func (s *Store) Create(owner int, size int64, filename string, unpack bool, password string) (*models.Upload, error) {
+	s.createMu.Lock()
+	defer s.createMu.Unlock()
+
+	if s.maxTotal > 0 && s.staged()+size > s.maxTotal {
+		return nil, ErrQuotaExceeded
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	rec := &record{
+		Upload: models.Upload{
+			ID:        id,
+			Filename:  filename,
+			Size:      size,
+			Unpack:    unpack,
+			Status:    models.UploadStatusUploading,
+			CreatedAt: now,
+			ExpiresAt: now.Add(s.expiry),
+		},
+		Owner:    owner,
+		Password: password,
+	}
+
+	f, err := os.OpenFile(s.path(id, dataExt), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+
+	if err := s.save(rec); err != nil {
+		os.Remove(s.path(id, dataExt))
+		return nil, err
+	}
+
+	return &rec.Upload, nil
+}
+
+// Get возвращает состояние загрузки пользователя owner
+func (s *Store) Get(id string, owner int) (*models.Upload, error) {
+	rec, err := s.loadOwned(id, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rec.Upload, nil
+}
+
+// Write дописывает часть файла с позиции offset. Если передана контрольная сумма в формате заголовка
+// Upload-Checksum ("sha256 <base64>"), часть с несовпавшей суммой отбрасывается целиком. Без контрольной суммы
+// при обрыве соединения сохраняется всё, что успело прийти, и клиент продолжает с нового смещения
+func (s *Store) Write(id string, owner int, offset int64, r io.Reader, checksum string) (*models.Upload, error) {
+	if !s.lock(id) {
+		return nil, ErrBusy
+	}
+	defer s.unlock(id)
+
+	rec, err := s.loadOwned(id, owner)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Status != models.UploadStatusUploading {
+		return nil, ErrCompleted
+	}
+	if offset != rec.Offset {
+		return nil, fmt.Errorf("%w: expected %d, got %d", ErrOffsetMismatch, rec.Offset, offset)
+	}
+
+	var (
+		hasher   hash.Hash
+		expected []byte
+	)
+	if checksum != "" {
+		if hasher, expected, err = parseChecksum(checksum); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.OpenFile(s.path(id, dataExt), os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	remaining := rec.Size - offset
+	reader := io.LimitReader(r, remaining+1)
+	if hasher != nil {
+		reader = io.TeeReader(reader, hasher)
+	}
+
+	n, copyErr := io.Copy(f, reader)
+
+	switch {
+	case n > remaining:
+		return nil, s.rollback(f, offset, ErrTooLarge)
+	case hasher != nil && copyErr != nil:
+		return nil, s.rollback(f, offset, copyErr)
+	case hasher != nil && !bytes.Equal(hasher.Sum(nil), expected):
+		return nil, s.rollback(f, offset, ErrChecksumMismatch)
+	case copyErr != nil:
+		s.logger.Warn("Upload chunk interrupted, received bytes are kept",
+			slog.String("upload_id", id),
+			slog.Int64("received", n),
+			slog.Any("error", copyErr),
+		)
+	}
+
+	rec.Offset += n
+	rec.ExpiresAt = time.Now().UTC().Add(s.expiry)
+
+	if rec.Offset == rec.Size {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		sum := sha256.New()
+		if _, err := io.Copy(sum, f); err != nil {
+			return nil, err
+		}
+		rec.Sha256 = hex.EncodeToString(sum.Sum(nil))
+		rec.Status = models.UploadStatusScanning
+	}
+
+	if err := s.save(rec); err != nil {
+		return nil, err
+	}
+
+	return &rec.Upload, nil
+}
+
+// Content возвращает полученный файл и пароль к архиву из метаданных загрузки
+func (s *Store) Content(id string) ([]byte, string, error) {
+	rec, err := s.load(id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	content, err := os.ReadFile(s.path(id, dataExt))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return content, rec.Password, nil
+}
+
+// Finish сохраняет результат проверки и удаляет файл с диска. Состояние хранится до истечения срока.
+// Finish ждёт, пока загрузку отпустит другой запрос, чтобы не восстановить состояние уже удалённой загрузки
+func (s *Store) Finish(id string, result *models.FileScanResponse, scanErr string) error {
+	for !s.lock(id) {
+		time.Sleep(lockRetry)
+	}
+	defer s.unlock(id)
+
+	rec, err := s.load(id)
+	if err != nil {
+		return err
+	}
+
+	rec.Result = result
+	rec.Error = scanErr
+	rec.Status = models.UploadStatusDone
+	if scanErr != "" {
+		rec.Status = models.UploadStatusFailed
+	}
+	rec.Password = ""
+	rec.ExpiresAt = time.Now().UTC().Add(s.expiry)
+
+	if err := s.save(rec); err != nil {
+		return err
+	}
+
+	if err := os.Remove(s.path(id, dataExt)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Delete прерывает загрузку пользователя owner и удаляет её с диска
+func (s *Store) Delete(id string, owner int) error {
+	if !s.lock(id) {
+		return ErrBusy
+	}
+	defer s.unlock(id)
+
+	if _, err := s.loadOwned(id, owner); err != nil {
+		return err
+	}
+
+	return s.remove(id)
+}
+
+// Run удаляет просроченные загрузки, пока не будет отменён контекст
+func (s *Store) Run(ctx context.Context) {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.cleanup()
+		}
+	}
+}
+
+// cleanup удаляет загрузки с истёкшим сроком
+func (s *Store) cleanup() {
+	now := time.Now()
+	for _, id := range s.ids() {
+		rec, err := s.load(id)
+		if err != nil || now.Before(rec.ExpiresAt) || !s.lock(id) {
+			continue
+		}
+
+		if err := s.remove(id); err != nil {
+			s.logger.Warn("Failed to remove expired upload", slog.String("upload_id", id), slog.Any("error", err))
+		}
+		s.unlock(id)
+	}
+}
+
+// failInterrupted помечает проверки, которые не успели завершиться до остановки сервера
+func (s *Store) failInterrupted() {
+	for _, id := range s.ids() {
+		rec, err := s.load(id)
+		if err != nil || rec.Status != models.UploadStatusScanning {
+			continue
+		}
+
+		if err := s.Finish(id, nil, "scan was interrupted by server restart"); err != nil {
+			s.logger.Warn("Failed to mark interrupted upload", slog.String("upload_id", id), slog.Any("error", err))
+		}
+	}
+}
+
+// staged возвращает, сколько байт зарезервировано под загрузки, файлы которых ещё лежат на диске
+func (s *Store) staged() int64 {
+	var total int64
+	for _, id := range s.ids() {
+		rec, err := s.load(id)
+		if err != nil {
+			continue
+		}
+		if rec.Status == models.UploadStatusUploading || rec.Status == models.UploadStatusScanning {
+			total += rec.Size
+		}
+	}
+
+	return total
+}
+
+// ids возвращает идентификаторы всех загрузок в каталоге
+func (s *Store) ids() []string {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		s.logger.Warn("Failed to read upload directory", slog.Any("error", err))
+		return nil
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if id, ok := strings.CutSuffix(entry.Name(), infoExt); ok && idRegexp.MatchString(id) {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// rollback отрезает недописанную часть, чтобы следующая попытка начиналась с прежнего смещения
+func (s *Store) rollback(f *os.File, offset int64, err error) error {
+	if truncErr := f.Truncate(offset); truncErr != nil {
+		return errors.Join(err, truncErr)
+	}
+
+	return err
+}
+
+func (s *Store) load(id string) (*record, error) {
+	if !idRegexp.MatchString(id) {
+		return nil, ErrNotFound
+	}
+
+	data, err := os.ReadFile(s.path(id, infoExt))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+
+	return &rec, nil
+}
+
+// loadOwned загружает состояние и проверяет владельца. Чужая загрузка неотличима от несуществующей
+func (s *Store) loadOwned(id string, owner int) (*record, error) {
+	rec, err := s.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Owner != owner {
+		return nil, ErrNotFound
+	}
+
+	return rec, nil
+}
+
+// save записывает состояние через временный файл, чтобы при сбое не остался обрезанный JSON
+func (s *Store) save(rec *record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path(rec.ID, infoExt+".tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path(rec.ID, infoExt))
+}
+
+func (s *Store) remove(id string) error {
+	if err := os.Remove(s.path(id, dataExt)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return os.Remove(s.path(id, infoExt))
+}
+
+func (s *Store) path(id, ext string) string {
+	return filepath.Join(s.dir, id+ext)
+}
+
+// lock не даёт двум запросам одновременно дописывать одну загрузку
+func (s *Store) lock(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.busy[id]; ok {
+		return false
+	}
+	s.busy[id] = struct{}{}
+
+	return true
+}
+
+func (s *Store) unlock(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.busy, id)
+}
+
+// parseChecksum разбирает заголовок Upload-Checksum: алгоритм и контрольная сумма в base64 через пробел
+func parseChecksum(header string) (hash.Hash, []byte, error) {
+	algorithm, encoded, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %q", ErrUnsupportedChecksum, header)
+	}
+
+	expected, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: checksum is not base64", ErrUnsupportedChecksum)
+	}
+
+	switch strings.ToLower(algorithm) {
+	case "sha1":
+		return sha1.New(), expected, nil
+	case "sha256":
+		return sha256.New(), expected, nil
+	case "md5":
+		return md5.New(), expected, nil
+	default:
+		return nil, nil, fmt.Errorf("%w: %s", ErrUnsupportedChecksum, algorithm)
+	}
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,144 @@
+package upload
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+func newStore(t *testing.T, maxTotal int64) *Store {
+	t.Helper()
+
+	s, err := New(t.TempDir(), time.Hour, maxTotal, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	return s
+}
+
+func TestWriteOffsets(t *testing.T) {
+	s := newStore(t, 0)
+
+	up, err := s.Create(1, 10, "file.bin", false, "")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	up, err = s.Write(up.ID, 1, 0, strings.NewReader("hello"), "")
+	if err != nil {
+		t.Fatalf("Write first chunk: %v", err)
+	}
+	if up.Offset != 5 || up.Status != models.UploadStatusUploading {
+		t.Fatalf("after first chunk: offset %d, status %s", up.Offset, up.Status)
+	}
+
+	if _, err := s.Write(up.ID, 1, 0, strings.NewReader("hello"), ""); !errors.Is(err, ErrOffsetMismatch) {
+		t.Fatalf("repeated chunk: got %v, want ErrOffsetMismatch", err)
+	}
+
+	// Часть с неверной суммой отбрасывается, смещение не меняется
+	wrong := "sha256 " + base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+	if _, err := s.Write(up.ID, 1, 5, strings.NewReader("world"), wrong); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("bad checksum: got %v, want ErrChecksumMismatch", err)
+	}
+
+	if _, err := s.Write(up.ID, 1, 5, strings.NewReader("world!"), ""); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("oversized chunk: got %v, want ErrTooLarge", err)
+	}
+
+	sum := sha256.Sum256([]byte("world"))
+	up, err = s.Write(up.ID, 1, 5, strings.NewReader("world"), "sha256 "+base64.StdEncoding.EncodeToString(sum[:]))
+	if err != nil {
+		t.Fatalf("Write last chunk: %v", err)
+	}
+	if up.Offset != 10 || up.Status != models.UploadStatusScanning {
+		t.Fatalf("after last chunk: offset %d, status %s", up.Offset, up.Status)
+	}
+
+	content, _, err := s.Content(up.ID)
+	if err != nil {
+		t.Fatalf("Content: %v", err)
+	}
+	if string(content) != "helloworld" {
+		t.Fatalf("content = %q, want %q", content, "helloworld")
+	}
+
+	if _, err := s.Write(up.ID, 1, 10, strings.NewReader("x"), ""); !errors.Is(err, ErrCompleted) {
+		t.Fatalf("write after completion: got %v, want ErrCompleted", err)
+	}
+}
+
+func TestOwner(t *testing.T) {
+	s := newStore(t, 0)
+
+	up, err := s.Create(1, 4, "file.bin", false, "")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if _, err := s.Get(up.ID, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get by another user: got %v, want ErrNotFound", err)
+	}
+	if _, err := s.Write(up.ID, 2, 0, strings.NewReader("data"), ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Write by another user: got %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(up.ID, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete by another user: got %v, want ErrNotFound", err)
+	}
+	if _, err := s.Get(up.ID, 1); err != nil {
+		t.Errorf("Get by owner: %v", err)
+	}
+}
+
+func TestQuota(t *testing.T) {
+	s := newStore(t, 10)
+
+	first, err := s.Create(1, 6, "a.bin", false, "")
+	if err != nil {
+		t.Fatalf("Create first: %v", err)
+	}
+	if _, err := s.Create(2, 5, "b.bin", false, ""); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Create over quota: got %v, want ErrQuotaExceeded", err)
+	}
+
+	// Завершённая загрузка больше не занимает место
+	if _, err := s.Write(first.ID, 1, 0, strings.NewReader("123456"), ""); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := s.Finish(first.ID, nil, ""); err != nil {
+		t.Fatalf("Finish: %v", err)
+	}
+	if _, err := s.Create(2, 5, "b.bin", false, ""); err != nil {
+		t.Fatalf("Create after finish: %v", err)
+	}
+}
+
+func TestFinishAfterDelete(t *testing.T) {
+	s := newStore(t, 0)
+
+	up, err := s.Create(1, 4, "file.bin", false, "")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := s.Write(up.ID, 1, 0, strings.NewReader("data"), ""); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := s.Delete(up.ID, 1); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if err := s.Finish(up.ID, nil, ""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Finish after Delete: got %v, want ErrNotFound", err)
+	}
+	if _, err := s.Get(up.ID, 1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("deleted upload is back: %v", err)
+	}
+}
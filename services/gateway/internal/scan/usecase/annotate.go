@@ -0,0 +1,103 @@
+package usecase
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"log/slog"
+
+	_ "golang.org/x/image/webp"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+var ErrUnsupportedImage = errors.New("unsupported image format")
+
+var (
+	annotateRed    = color.RGBA{R: 220, G: 20, B: 60, A: 255}
+	annotateYellow = color.RGBA{R: 255, G: 190, B: 0, A: 255}
+	annotateGreen  = color.RGBA{R: 0, G: 170, B: 70, A: 255}
+)
+
+// AnnotateImage рисует на изображении рамки вокруг найденных индикаторов и возвращает PNG.
+// Красная рамка - опасный индикатор, жёлтая - подозрительный, зелёная - безопасный.
+// Индикаторы без вердикта (серая зона, ошибка проверки) не выделяются.
+func (uc *Usecase) AnnotateImage(content []byte, lookups []models.IOCLookup) ([]byte, error) {
+	src, format, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, errors.Join(ErrUnsupportedImage, err)
+	}
+
+	bounds := src.Bounds()
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, src, bounds.Min, draw.Src)
+
+	// Толщина рамки зависит от размера изображения, чтобы рамка была видна и на больших скриншотах
+	thickness := max(2, min(bounds.Dx(), bounds.Dy())/300)
+
+	for _, lookup := range lookups {
+		c, ok := zoneColor(lookup.Zone())
+		if !ok {
+			continue
+		}
+
+		for _, region := range lookup.Regions {
+			rect := image.Rect(region.X, region.Y, region.X+region.Width, region.Y+region.Height).
+				Add(bounds.Min).
+				Inset(-thickness - 1).
+				Intersect(bounds)
+			drawFrame(dst, rect, thickness, c)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, fmt.Errorf("failed to encode png: %w", err)
+	}
+
+	uc.logger.Debug("Image annotated",
+		slog.String("source_format", format),
+		slog.Int("lookups", len(lookups)),
+	)
+
+	return buf.Bytes(), nil
+}
+
+// zoneColor возвращает цвет рамки для зоны Kaspersky API
+func zoneColor(zone string) (color.RGBA, bool) {
+	switch zone {
+	case "Red":
+		return annotateRed, true
+	case "Orange", "Yellow":
+		return annotateYellow, true
+	case "Green":
+		return annotateGreen, true
+	default:
+		return color.RGBA{}, false
+	}
+}
+
+// drawFrame рисует рамку заданной толщины по внутреннему краю прямоугольника
+func drawFrame(dst draw.Image, rect image.Rectangle, thickness int, c color.Color) {
+	if rect.Empty() {
+		return
+	}
+
+	fill := image.NewUniform(c)
+	sides := []image.Rectangle{
+		image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X, rect.Min.Y+thickness),
+		image.Rect(rect.Min.X, rect.Max.Y-thickness, rect.Max.X, rect.Max.Y),
+		image.Rect(rect.Min.X, rect.Min.Y, rect.Min.X+thickness, rect.Max.Y),
+		image.Rect(rect.Max.X-thickness, rect.Min.Y, rect.Max.X, rect.Max.Y),
+	}
+
+	for _, side := range sides {
+		draw.Draw(dst, side.Intersect(rect), fill, image.Point{}, draw.Src)
+	}
+}
@@ -0,0 +1,312 @@
+package usecase
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+	"github.com/CodeMaster482/minions-server/services/gateway/pkg/zipcrypto"
+)
+
+var (
+	ErrNotArchive         = errors.New("file is not a supported archive")
+	ErrArchiveLimit       = errors.New("archive limits exceeded")
+	ErrArchiveWrongPasswd = errors.New("archive password is not known")
+)
+
+const (
+	maxArchiveDepth     = 3         // Максимальная вложенность архивов
+	maxArchiveEntries   = 1000      // Максимальное количество файлов во всех архивах
+	maxArchiveSize      = 128 << 20 // Максимальный суммарный размер распакованных данных
+	maxParallelArchives = 4         // Сколько архивов распаковывается одновременно
+)
+
+// archiveSlots ограничивает число одновременных распаковок, чтобы в памяти было
+// не больше maxParallelArchives * maxArchiveSize распакованных данных
+var archiveSlots = make(chan struct{}, maxParallelArchives)
+
+// defaultArchivePasswords пароль, которым принято защищать архивы с образцами вредоносных программ
+var defaultArchivePasswords = []string{"infected"}
+
+// archiveUnpacker распаковывает архив в памяти и следит за ограничениями против zip-бомб
+type archiveUnpacker struct {
+	passwords []string
+	entries   int
+	size      int64
+	truncated bool
+}
+
+// UnpackArchive распаковывает ZIP (в том числе зашифрованный ZipCrypto), TAR, GZ и BZ2 в памяти
+// и возвращает дерево файлов с хешами. Вложенные архивы распаковываются до maxArchiveDepth.
+// Кроме переданных паролей всегда пробуется общепринятый "infected".
+// Одновременно распаковывается не больше maxParallelArchives архивов, остальные ждут очереди.
+// Вердикты по файлам не заполняются, их проверяет вызывающий код
+func (uc *Usecase) UnpackArchive(content []byte, passwords []string) (*models.ArchiveReport, error) {
+	format := archiveFormat(content)
+	if format == "" {
+		return nil, ErrNotArchive
+	}
+
+	archiveSlots <- struct{}{}
+	defer func() { <-archiveSlots }()
+
+	u := &archiveUnpacker{passwords: slices.Concat(passwords, defaultArchivePasswords)}
+	entries, err := u.unpack(format, content, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ArchiveReport{
+		Format:    format,
+		Entries:   entries,
+		Truncated: u.truncated,
+	}, nil
+}
+
+// archiveFormat определяет формат архива по сигнатуре
+func archiveFormat(content []byte) string {
+	switch {
+	case bytes.HasPrefix(content, []byte("PK\x03\x04")), bytes.HasPrefix(content, []byte("PK\x05\x06")):
+		return models.ArchiveFormatZIP
+	case bytes.HasPrefix(content, []byte{0x1F, 0x8B}):
+		return models.ArchiveFormatGZIP
+	case bytes.HasPrefix(content, []byte("BZh")):
+		return models.ArchiveFormatBZIP2
+	case len(content) > 262 && string(content[257:262]) == "ustar":
+		return models.ArchiveFormatTAR
+	default:
+		return ""
+	}
+}
+
+// unpack распаковывает архив одного уровня вложенности
+func (u *archiveUnpacker) unpack(format string, content []byte, depth int) ([]models.ArchiveEntry, error) {
+	switch format {
+	case models.ArchiveFormatZIP:
+		return u.unpackZIP(content, depth)
+	case models.ArchiveFormatTAR:
+		return u.unpackTAR(bytes.NewReader(content), depth)
+	case models.ArchiveFormatGZIP:
+		zr, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+
+		return u.unpackStream(zr, zr.Name, depth)
+	case models.ArchiveFormatBZIP2:
+		return u.unpackStream(bzip2.NewReader(bytes.NewReader(content)), "", depth)
+	default:
+		return nil, ErrNotArchive
+	}
+}
+
+// unpackStream распаковывает сжатый поток: tar.gz и tar.bz2 разбираются как tar, остальное - один файл
+func (u *archiveUnpacker) unpackStream(r io.Reader, name string, depth int) ([]models.ArchiveEntry, error) {
+	if !u.next() {
+		return nil, nil
+	}
+
+	entry := models.ArchiveEntry{Path: name}
+	if entry.Path == "" {
+		entry.Path = "data"
+	}
+
+	data, err := u.read(r)
+	if err != nil {
+		entry.Error = err.Error()
+		return []models.ArchiveEntry{entry}, nil
+	}
+
+	if archiveFormat(data) == models.ArchiveFormatTAR {
+		return u.unpackTAR(bytes.NewReader(data), depth)
+	}
+
+	return []models.ArchiveEntry{u.entry(entry, data, depth)}, nil
+}
+
+// unpackZIP распаковывает ZIP, зашифрованные файлы расшифровываются известными паролями
+func (u *archiveUnpacker) unpackZIP(content []byte, depth int) ([]models.ArchiveEntry, error) {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []models.ArchiveEntry
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if !u.next() {
+			break
+		}
+
+		entry := models.ArchiveEntry{
+			Path:      f.Name,
+			Encrypted: f.Flags&0x1 != 0,
+		}
+
+		var data []byte
+		if entry.Encrypted {
+			data, err = u.readEncryptedZIP(f)
+		} else {
+			var rc io.ReadCloser
+			if rc, err = f.Open(); err == nil {
+				data, err = u.read(rc)
+				rc.Close()
+			}
+		}
+		if err != nil {
+			entry.Error = err.Error()
+			entries = append(entries, entry)
+			continue
+		}
+
+		entries = append(entries, u.entry(entry, data, depth))
+	}
+
+	return entries, nil
+}
+
+// readEncryptedZIP перебирает известные пароли для файла, зашифрованного ZipCrypto
+func (u *archiveUnpacker) readEncryptedZIP(f *zip.File) ([]byte, error) {
+	for _, password := range u.passwords {
+		raw, err := f.OpenRaw()
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := zipcrypto.Decrypt(f, raw, password)
+		if errors.Is(err, zipcrypto.ErrPassword) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		size := u.size
+		data, err := u.read(r)
+		if errors.Is(err, zipcrypto.ErrPassword) {
+			// Проверочный байт совпадает случайно примерно в 1 случае из 256, окончательно пароль проверяет CRC.
+			// Прочитанное с неверным паролем не распаковано на самом деле и в лимит не засчитывается
+			u.size = size
+			continue
+		}
+
+		return data, err
+	}
+
+	return nil, ErrArchiveWrongPasswd
+}
+
+// unpackTAR распаковывает TAR, кроме обычных файлов все записи пропускаются
+func (u *archiveUnpacker) unpackTAR(r io.Reader, depth int) ([]models.ArchiveEntry, error) {
+	tr := tar.NewReader(r)
+
+	var entries []models.ArchiveEntry
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			if len(entries) > 0 {
+				// Обрезанный tar: отдаём то, что успели распаковать
+				u.truncated = true
+				return entries, nil
+			}
+			return nil, err
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if !u.next() {
+			return entries, nil
+		}
+
+		entry := models.ArchiveEntry{Path: header.Name}
+		data, err := u.read(tr)
+		if err != nil {
+			entry.Error = err.Error()
+			entries = append(entries, entry)
+			continue
+		}
+
+		entries = append(entries, u.entry(entry, data, depth))
+	}
+}
+
+// entry хеширует файл и, если это архив, распаковывает его следующим уровнем
+func (u *archiveUnpacker) entry(entry models.ArchiveEntry, data []byte, depth int) models.ArchiveEntry {
+	sum := sha256.Sum256(data)
+	entry.Sha256 = hex.EncodeToString(sum[:])
+	entry.Size = int64(len(data))
+
+	format := archiveFormat(data)
+	if format == "" {
+		return entry
+	}
+	entry.Format = format
+
+	if depth >= maxArchiveDepth {
+		u.truncated = true
+		return entry
+	}
+
+	children, err := u.unpack(format, data, depth+1)
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	prefixPaths(children, entry.Path)
+	entry.Entries = children
+
+	return entry
+}
+
+// prefixPaths добавляет путь вложенного архива к путям всех его файлов
+func prefixPaths(entries []models.ArchiveEntry, prefix string) {
+	for i := range entries {
+		entries[i].Path = path.Join(prefix, entries[i].Path)
+		prefixPaths(entries[i].Entries, prefix)
+	}
+}
+
+// next учитывает очередной файл и сообщает, можно ли распаковывать дальше
+func (u *archiveUnpacker) next() bool {
+	if u.entries >= maxArchiveEntries {
+		u.truncated = true
+		return false
+	}
+	u.entries++
+
+	return true
+}
+
+// read читает файл целиком, не выходя за оставшийся лимит распакованных данных
+func (u *archiveUnpacker) read(r io.Reader) ([]byte, error) {
+	remaining := maxArchiveSize - u.size
+	data, err := io.ReadAll(io.LimitReader(r, remaining+1))
+	u.size += int64(len(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > remaining {
+		u.truncated = true
+		return nil, fmt.Errorf("%w: decompressed size is over %d bytes", ErrArchiveLimit, maxArchiveSize)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,183 @@
+package usecase
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/pkg/zipcrypto"
+)
+
+// zipWithFiles собирает ZIP из count одинаковых файлов
+func zipWithFiles(t *testing.T, count int, content string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i := 0; i < count; i++ {
+		w, err := zw.Create(fmt.Sprintf("file%d.txt", i))
+		if err != nil {
+			t.Fatalf("zip: %v", err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+// gzipped сжимает content в gzip
+func gzipped(t *testing.T, content []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(content); err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestUnpackArchiveEntryLimit(t *testing.T) {
+	uc := &Usecase{}
+
+	report, err := uc.UnpackArchive(zipWithFiles(t, maxArchiveEntries+1, "x"), nil)
+	if err != nil {
+		t.Fatalf("UnpackArchive: %v", err)
+	}
+	if !report.Truncated {
+		t.Error("Truncated = false, want true")
+	}
+	if len(report.Entries) != maxArchiveEntries {
+		t.Errorf("len(Entries) = %d, want %d", len(report.Entries), maxArchiveEntries)
+	}
+}
+
+func TestUnpackArchiveSizeLimit(t *testing.T) {
+	uc := &Usecase{}
+
+	report, err := uc.UnpackArchive(gzipped(t, make([]byte, maxArchiveSize+1)), nil)
+	if err != nil {
+		t.Fatalf("UnpackArchive: %v", err)
+	}
+	if !report.Truncated {
+		t.Error("Truncated = false, want true")
+	}
+	if len(report.Entries) != 1 || !strings.Contains(report.Entries[0].Error, ErrArchiveLimit.Error()) {
+		t.Errorf("Entries = %+v, want one entry with limit error", report.Entries)
+	}
+}
+
+func TestUnpackArchiveDepthLimit(t *testing.T) {
+	uc := &Usecase{}
+
+	content := []byte("payload")
+	for i := 0; i <= maxArchiveDepth; i++ {
+		content = gzipped(t, content)
+	}
+
+	report, err := uc.UnpackArchive(content, nil)
+	if err != nil {
+		t.Fatalf("UnpackArchive: %v", err)
+	}
+	if !report.Truncated {
+		t.Error("Truncated = false, want true")
+	}
+
+	depth := 0
+	for entries := report.Entries; len(entries) > 0; entries = entries[0].Entries {
+		depth++
+	}
+	if depth != maxArchiveDepth {
+		t.Errorf("unpacked %d levels, want %d", depth, maxArchiveDepth)
+	}
+}
+
+// Переданный срез паролей не должен меняться, даже если в нём есть свободная ёмкость
+func TestUnpackArchivePasswordsNotModified(t *testing.T) {
+	uc := &Usecase{}
+
+	passwords := make([]string, 1, 2)
+	passwords[0] = "secret"
+	backing := passwords[:2]
+
+	if _, err := uc.UnpackArchive(zipWithFiles(t, 1, "x"), passwords); err != nil {
+		t.Fatalf("UnpackArchive: %v", err)
+	}
+	if backing[1] != "" {
+		t.Errorf("caller slice was modified: %q", backing)
+	}
+}
+
+// falsePassword подбирает неверный пароль, который проходит проверочный байт заголовка ZipCrypto
+func falsePassword(t *testing.T, f *zip.File, password string) string {
+	t.Helper()
+
+	for i := 0; i < 1<<16; i++ {
+		candidate := fmt.Sprintf("wrong%d", i)
+		if candidate == password {
+			continue
+		}
+
+		raw, err := f.OpenRaw()
+		if err != nil {
+			t.Fatalf("OpenRaw: %v", err)
+		}
+		if _, err := zipcrypto.Decrypt(f, raw, candidate); err == nil {
+			return candidate
+		}
+	}
+
+	t.Fatal("no password passes the check byte")
+	return ""
+}
+
+func TestReadEncryptedZIP(t *testing.T) {
+	content := bytes.Repeat([]byte("malware sample "), 1000)
+	archive, err := zipcrypto.Encrypt("sample.exe", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), content, "infected")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("zip.NewReader: %v", err)
+	}
+	f := zr.File[0]
+	wrong := falsePassword(t, f, "infected")
+
+	t.Run("right password after false match", func(t *testing.T) {
+		u := &archiveUnpacker{passwords: []string{wrong, "infected"}}
+		data, err := u.readEncryptedZIP(f)
+		if err != nil {
+			t.Fatalf("readEncryptedZIP: %v", err)
+		}
+		if !bytes.Equal(data, content) {
+			t.Error("content does not match")
+		}
+		// Прочитанное с неверным паролем в лимит не засчитывается
+		if u.size != int64(len(content)) {
+			t.Errorf("size = %d, want %d", u.size, len(content))
+		}
+	})
+
+	t.Run("unknown password", func(t *testing.T) {
+		u := &archiveUnpacker{passwords: []string{wrong}}
+		if _, err := u.readEncryptedZIP(f); !errors.Is(err, ErrArchiveWrongPasswd) {
+			t.Fatalf("readEncryptedZIP: got %v, want ErrArchiveWrongPasswd", err)
+		}
+		if u.size != 0 {
+			t.Errorf("size = %d, want 0", u.size)
+		}
+	})
+}
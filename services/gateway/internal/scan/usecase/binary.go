@@ -0,0 +1,310 @@
+package usecase
+
+import (
+	"bytes"
+	"crypto/md5"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+var ErrNotExecutable = errors.New("file is not a PE, ELF or Mach-O executable")
+
+const (
+	maxBinaryImports = 1000 // Максимальное количество импортов в ответе
+	packedEntropy    = 7.2  // Энтропия, начиная с которой секция считается упакованной или зашифрованной
+
+	peDirectoryEntrySecurity  = 4    // IMAGE_DIRECTORY_ENTRY_SECURITY, в ней лежит подпись Authenticode
+	machoLoadCmdCodeSignature = 0x1d // LC_CODE_SIGNATURE
+)
+
+// packerSections имена секций, которые оставляют известные упаковщики
+var packerSections = []string{"upx0", "upx1", "upx2", ".aspack", ".adata", ".mpress1", ".mpress2", ".petite", ".themida", ".vmp0", ".vmp1", ".nsp0", ".nsp1"}
+
+// AnalyzeBinary разбирает исполняемый файл PE, ELF или Mach-O без запуска: архитектура, время компиляции,
+// импорты, секции с энтропией, наличие подписи и imphash
+func (uc *Usecase) AnalyzeBinary(content []byte) (*models.LocalAnalysis, error) {
+	switch {
+	case bytes.HasPrefix(content, []byte("MZ")):
+		return analyzePE(content)
+	case bytes.HasPrefix(content, []byte(elf.ELFMAG)):
+		return analyzeELF(content)
+	case isMachO(content):
+		return analyzeMachO(content)
+	default:
+		return nil, ErrNotExecutable
+	}
+}
+
+// isMachO проверяет сигнатуру Mach-O (32 и 64 бита, оба порядка байт)
+func isMachO(content []byte) bool {
+	if len(content) < 4 {
+		return false
+	}
+
+	switch magic := uint32(content[0])<<24 | uint32(content[1])<<16 | uint32(content[2])<<8 | uint32(content[3]); magic {
+	case 0xFEEDFACE, 0xFEEDFACF, 0xCEFAEDFE, 0xCFFAEDFE:
+		return true
+	default:
+		return false
+	}
+}
+
+func analyzePE(content []byte) (*models.LocalAnalysis, error) {
+	f, err := pe.NewFile(bytes.NewReader(content))
+	if err != nil {
+		return nil, errors.Join(ErrNotExecutable, err)
+	}
+	defer f.Close()
+
+	analysis := &models.LocalAnalysis{
+		Format:       models.BinaryFormatPE,
+		Architecture: peMachine(f.Machine),
+	}
+	if f.TimeDateStamp != 0 {
+		analysis.CompileTime = time.Unix(int64(f.TimeDateStamp), 0).UTC().Format(time.RFC3339)
+	}
+
+	// Повреждённая таблица импорта не мешает остальному анализу: берём то, что успели прочитать
+	imports, importsTruncated, _ := peImports(f)
+	for i, imp := range imports {
+		library, function, _ := strings.Cut(imp, "!")
+		imports[i] = strings.ToLower(library) + "!" + function
+	}
+	analysis.ImpHash = impHash(imports)
+	analysis.Imports, analysis.ImportsTruncated = truncateImports(imports)
+	analysis.ImportsTruncated = analysis.ImportsTruncated || importsTruncated
+
+	for _, section := range f.Sections {
+		data, err := section.Data()
+		if err != nil {
+			data = nil
+		}
+		analysis.Sections = append(analysis.Sections, binarySection(section.Name, data))
+	}
+
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		analysis.Signed = len(oh.DataDirectory) > peDirectoryEntrySecurity && oh.DataDirectory[peDirectoryEntrySecurity].Size > 0
+	case *pe.OptionalHeader64:
+		analysis.Signed = len(oh.DataDirectory) > peDirectoryEntrySecurity && oh.DataDirectory[peDirectoryEntrySecurity].Size > 0
+	}
+
+	analysis.Packed = anyPacked(analysis.Sections)
+
+	return analysis, nil
+}
+
+func analyzeELF(content []byte) (*models.LocalAnalysis, error) {
+	f, err := elf.NewFile(bytes.NewReader(content))
+	if err != nil {
+		return nil, errors.Join(ErrNotExecutable, err)
+	}
+	defer f.Close()
+
+	analysis := &models.LocalAnalysis{
+		Format:       models.BinaryFormatELF,
+		Architecture: strings.ToLower(strings.TrimPrefix(f.Machine.String(), "EM_")),
+	}
+
+	if symbols, err := f.ImportedSymbols(); err == nil {
+		imports := make([]string, 0, len(symbols))
+		for _, symbol := range symbols {
+			imports = append(imports, symbol.Library+"!"+symbol.Name)
+		}
+		analysis.Imports, analysis.ImportsTruncated = truncateImports(imports)
+	}
+
+	for _, section := range f.Sections {
+		if section.Type == elf.SHT_NULL {
+			continue
+		}
+
+		var data []byte
+		if section.Type != elf.SHT_NOBITS {
+			data, _ = section.Data()
+		}
+		analysis.Sections = append(analysis.Sections, binarySection(section.Name, data))
+
+		// Подпись ELF не стандартизована, ищем секции, которые добавляют распространённые инструменты подписи
+		if section.Name == ".sig" || section.Name == ".signature" || section.Name == ".note.signature" {
+			analysis.Signed = true
+		}
+	}
+
+	analysis.Packed = anyPacked(analysis.Sections)
+
+	return analysis, nil
+}
+
+func analyzeMachO(content []byte) (*models.LocalAnalysis, error) {
+	f, err := macho.NewFile(bytes.NewReader(content))
+	if err != nil {
+		return nil, errors.Join(ErrNotExecutable, err)
+	}
+	defer f.Close()
+
+	analysis := &models.LocalAnalysis{
+		Format:       models.BinaryFormatMachO,
+		Architecture: strings.ToLower(strings.TrimPrefix(f.Cpu.String(), "Cpu")),
+	}
+
+	if symbols, err := f.ImportedSymbols(); err == nil {
+		libraries, _ := f.ImportedLibraries()
+		library := ""
+		if len(libraries) == 1 {
+			library = path.Base(libraries[0])
+		}
+
+		imports := make([]string, 0, len(symbols))
+		for _, symbol := range symbols {
+			// В Mach-O без двухуровневых имён библиотеку символа не узнать
+			imports = append(imports, library+"!"+strings.TrimPrefix(symbol, "_"))
+		}
+		analysis.Imports, analysis.ImportsTruncated = truncateImports(imports)
+	}
+
+	for _, section := range f.Sections {
+		data, err := section.Data()
+		if err != nil {
+			data = nil
+		}
+		analysis.Sections = append(analysis.Sections, binarySection(section.Seg+","+section.Name, data))
+	}
+
+	for _, load := range f.Loads {
+		raw := load.Raw()
+		if len(raw) >= 4 && f.ByteOrder.Uint32(raw) == machoLoadCmdCodeSignature {
+			analysis.Signed = true
+		}
+	}
+
+	analysis.Packed = anyPacked(analysis.Sections)
+
+	return analysis, nil
+}
+
+// peMachine возвращает название архитектуры для поля Machine заголовка PE
+func peMachine(machine uint16) string {
+	switch machine {
+	case pe.IMAGE_FILE_MACHINE_I386:
+		return "386"
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		return "amd64"
+	case pe.IMAGE_FILE_MACHINE_ARM, pe.IMAGE_FILE_MACHINE_ARMNT:
+		return "arm"
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		return "arm64"
+	case pe.IMAGE_FILE_MACHINE_IA64:
+		return "ia64"
+	default:
+		return fmt.Sprintf("0x%04x", machine)
+	}
+}
+
+// impHash считает imphash так же, как pefile: библиотека без расширения и функция
+// в нижнем регистре через точку, элементы через запятую, от результата берётся MD5.
+// Импорты по ординалу уже приведены к имени или ordN в peImports
+func impHash(imports []string) string {
+	if len(imports) == 0 {
+		return ""
+	}
+
+	parts := make([]string, 0, len(imports))
+	for _, imp := range imports {
+		library, function, _ := strings.Cut(imp, "!")
+		library = strings.ToLower(library)
+		if base, ext, ok := cutLast(library, "."); ok && (ext == "dll" || ext == "ocx" || ext == "sys") {
+			library = base
+		}
+		parts = append(parts, library+"."+strings.ToLower(function))
+	}
+
+	sum := md5.Sum([]byte(strings.Join(parts, ",")))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// cutLast делит строку по последнему вхождению sep
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i == -1 {
+		return s, "", false
+	}
+
+	return s[:i], s[i+len(sep):], true
+}
+
+// truncateImports ограничивает список импортов в ответе
+func truncateImports(imports []string) ([]string, bool) {
+	if len(imports) > maxBinaryImports {
+		return imports[:maxBinaryImports], true
+	}
+
+	return imports, false
+}
+
+// binarySection считает энтропию секции и отмечает признаки упаковки
+func binarySection(name string, data []byte) models.BinarySection {
+	entropy := byteEntropy(data)
+
+	// Сжатые отладочные секции всегда имеют высокую энтропию, упаковкой это не является
+	lower := strings.ToLower(name)
+	packed := entropy >= packedEntropy && !strings.Contains(lower, "debug")
+	for _, packer := range packerSections {
+		if lower == packer {
+			packed = true
+		}
+	}
+
+	return models.BinarySection{
+		Name:    name,
+		Size:    int64(len(data)),
+		Entropy: math.Round(entropy*100) / 100,
+		Packed:  packed,
+	}
+}
+
+// anyPacked проверяет, есть ли среди секций упакованные
+func anyPacked(sections []models.BinarySection) bool {
+	for _, section := range sections {
+		if section.Packed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// byteEntropy вычисляет энтропию Шеннона данных в битах на байт
+func byteEntropy(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+
+	var freq [256]int
+	for _, b := range data {
+		freq[b]++
+	}
+
+	var entropy float64
+	total := float64(len(data))
+	for _, count := range freq {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / total
+		entropy -= p * math.Log2(p)
+	}
+
+	return entropy
+}
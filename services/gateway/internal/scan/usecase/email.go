@@ -0,0 +1,341 @@
+package usecase
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+	"github.com/CodeMaster482/minions-server/services/gateway/pkg/netguard"
+)
+
+var ErrInvalidEmail = errors.New("invalid email message")
+
+const (
+	maxEmailDepth = 10  // Максимальная вложенность multipart и пересланных писем
+	maxEmailParts = 500 // Максимальное количество частей письма
+)
+
+// receivedIPRegexp IP в квадратных или круглых скобках заголовка Received: from host ([203.0.113.5])
+var receivedIPRegexp = regexp.MustCompile(`[\[(]\s*(?:IPv6:)?([0-9a-fA-F.:]+)\s*[\])]`)
+
+// mimeWordDecoder декодирует заголовки вида =?koi8-r?B?...?=
+var mimeWordDecoder = &mime.WordDecoder{
+	CharsetReader: charset.NewReaderLabel,
+}
+
+// ParseEmail разбирает письмо в формате RFC 822 (.eml) или Outlook (.msg): достаёт домен отправителя,
+// внешние IP из цепочки Received, ссылки из HTML, текст всех текстовых частей и вложения
+func (uc *Usecase) ParseEmail(content []byte) (*models.ParsedEmail, error) {
+	if bytes.HasPrefix(content, cfbSignature) {
+		return parseMSG(content)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(content))
+	if err != nil {
+		return nil, errors.Join(ErrInvalidEmail, err)
+	}
+
+	parsed := &models.ParsedEmail{
+		Subject: decodeHeader(msg.Header.Get("Subject")),
+	}
+	parsed.From, parsed.SenderDomain = senderAddress(decodeHeader(msg.Header.Get("From")))
+	parsed.ReceivedIPs = receivedIPs(msg.Header["Received"])
+
+	p := &emailParser{parsed: parsed}
+	if err := p.walk(textproto.MIMEHeader(msg.Header), msg.Body, 0); err != nil {
+		return nil, errors.Join(ErrInvalidEmail, err)
+	}
+	parsed.Text = p.text.String()
+
+	return parsed, nil
+}
+
+// emailParser обходит дерево MIME-частей письма
+type emailParser struct {
+	parsed *models.ParsedEmail
+	text   strings.Builder
+	parts  int
+}
+
+// walk обрабатывает одну MIME-часть и рекурсивно все вложенные
+func (p *emailParser) walk(header textproto.MIMEHeader, body io.Reader, depth int) error {
+	p.parts++
+	if depth > maxEmailDepth || p.parts > maxEmailParts {
+		return fmt.Errorf("message is too deeply nested or has too many parts")
+	}
+
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "text/plain; charset=us-ascii"
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		// Битый Content-Type трактуем как текст, как это делают почтовые клиенты
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			if err := p.walk(part.Header, part, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	decoded, err := io.ReadAll(decodeTransferEncoding(header.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return fmt.Errorf("failed to decode part: %w", err)
+	}
+
+	filename := partFilename(header, params)
+	disposition, _, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+
+	switch {
+	case disposition == "attachment" || (filename != "" && !strings.HasPrefix(mediaType, "text/")):
+		if filename == "" {
+			filename = "attachment"
+		}
+		p.parsed.Attachments = append(p.parsed.Attachments, models.EmailAttachmentContent{
+			Filename:    filename,
+			ContentType: mediaType,
+			Content:     decoded,
+		})
+
+	case mediaType == "message/rfc822":
+		// Пересланное письмо: ссылки и текст из него тоже проверяем
+		msg, err := mail.ReadMessage(bytes.NewReader(decoded))
+		if err != nil {
+			return err
+		}
+		return p.walk(textproto.MIMEHeader(msg.Header), msg.Body, depth+1)
+
+	case mediaType == "text/html":
+		r, err := charset.NewReader(bytes.NewReader(decoded), contentType)
+		if err != nil {
+			r = bytes.NewReader(decoded)
+		}
+		links, text := parseHTML(r)
+		p.parsed.Links = append(p.parsed.Links, links...)
+		p.text.WriteString(text)
+		p.text.WriteByte('\n')
+
+	case strings.HasPrefix(mediaType, "text/"):
+		p.text.WriteString(decodeCharset(decoded, params["charset"]))
+		p.text.WriteByte('\n')
+	}
+
+	return nil
+}
+
+// decodeTransferEncoding снимает base64 или quoted-printable
+func decodeTransferEncoding(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	default:
+		return body
+	}
+}
+
+// decodeCharset перекодирует текст в UTF-8, неизвестная кодировка оставляется как есть
+func decodeCharset(content []byte, label string) string {
+	if label == "" {
+		return string(content)
+	}
+
+	r, err := charset.NewReaderLabel(label, bytes.NewReader(content))
+	if err != nil {
+		return string(content)
+	}
+
+	decoded, err := io.ReadAll(r)
+	if err != nil {
+		return string(content)
+	}
+
+	return string(decoded)
+}
+
+// decodeHeader декодирует закодированные слова RFC 2047
+func decodeHeader(value string) string {
+	decoded, err := mimeWordDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+
+	return decoded
+}
+
+// partFilename возвращает имя файла из Content-Disposition или параметра name в Content-Type
+func partFilename(header textproto.MIMEHeader, contentTypeParams map[string]string) string {
+	if _, params, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		return decodeHeader(params["filename"])
+	}
+
+	return decodeHeader(contentTypeParams["name"])
+}
+
+// senderAddress возвращает адрес отправителя и его домен
+func senderAddress(from string) (string, string) {
+	address := strings.TrimSpace(from)
+	if parsed, err := mail.ParseAddress(from); err == nil {
+		address = parsed.Address
+	}
+
+	at := strings.LastIndex(address, "@")
+	if at == -1 {
+		return address, ""
+	}
+
+	return address, strings.ToLower(strings.Trim(address[at+1:], "<> "))
+}
+
+// receivedIPs извлекает внешние IP из цепочки заголовков Received. Частные и служебные адреса
+// внутренних серверов пропускаются, порядок сохраняется от последнего узла к первому
+func receivedIPs(headers []string) []string {
+	var ips []string
+	seen := make(map[string]struct{})
+
+	for _, header := range headers {
+		for _, match := range receivedIPRegexp.FindAllStringSubmatch(header, -1) {
+			ip := net.ParseIP(match[1])
+			if ip == nil || !netguard.IsPublicIP(ip) {
+				continue
+			}
+
+			value := ip.String()
+			if _, ok := seen[value]; ok {
+				continue
+			}
+			seen[value] = struct{}{}
+			ips = append(ips, value)
+		}
+	}
+
+	return ips
+}
+
+// parseHTML возвращает ссылки и видимый текст HTML-документа. Текст скриптов и стилей пропускается
+func parseHTML(r io.Reader) ([]models.EmailLink, string) {
+	var (
+		links    []models.EmailLink
+		text     strings.Builder
+		linkText strings.Builder
+		href     string
+		inLink   bool
+		skip     int
+	)
+
+	tokenizer := html.NewTokenizer(r)
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return links, text.String()
+
+		case html.StartTagToken:
+			name, hasAttr := tokenizer.TagName()
+			switch string(name) {
+			case "script", "style":
+				skip++
+			case "a":
+				href, inLink = "", true
+				linkText.Reset()
+				for hasAttr {
+					var key, val []byte
+					key, val, hasAttr = tokenizer.TagAttr()
+					if string(key) == "href" {
+						href = strings.TrimSpace(string(val))
+					}
+				}
+			case "br", "p", "div", "tr", "li":
+				text.WriteByte('\n')
+			}
+
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "script", "style":
+				skip = max(0, skip-1)
+			case "a":
+				if inLink {
+					if link, ok := emailLink(href, strings.TrimSpace(linkText.String())); ok {
+						links = append(links, link)
+					}
+				}
+				inLink = false
+			}
+
+		case html.TextToken:
+			if skip > 0 {
+				continue
+			}
+			data := html.UnescapeString(string(tokenizer.Text()))
+			text.WriteString(data)
+			text.WriteByte(' ')
+			if inLink {
+				linkText.WriteString(data)
+			}
+		}
+	}
+}
+
+// emailLink собирает ссылку и проверяет, не выдаёт ли видимый текст себя за другой адрес
+func emailLink(href, text string) (models.EmailLink, bool) {
+	target, err := url.Parse(href)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" {
+		return models.EmailLink{}, false
+	}
+
+	link := models.EmailLink{URL: href, Text: text}
+
+	// Видимый текст сравниваем, только если он сам выглядит как адрес
+	shown := strings.ToLower(strings.TrimSpace(text))
+	if !strings.Contains(shown, "://") {
+		shown = "http://" + shown
+	}
+	if shownURL, err := url.Parse(shown); err == nil {
+		host := shownURL.Hostname()
+		if net.ParseIP(host) != nil || isPublicDomain(host) {
+			link.Mismatch = registrableDomain(host) != registrableDomain(target.Hostname())
+		}
+	}
+
+	return link, true
+}
+
+// registrableDomain возвращает домен, зарегистрированный владельцем (mail.bank.ru -> bank.ru)
+func registrableDomain(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if domain, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return domain
+	}
+
+	return host
+}
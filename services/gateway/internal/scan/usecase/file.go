@@ -0,0 +1,92 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+var ErrKasperskyPayloadTooLarge = errors.New("kaspersky api: file is too large")
+
+// RequestKasperskyFile отправляет файл на сканирование в Kaspersky API и возвращает базовый отчёт
+func (uc *Usecase) RequestKasperskyFile(ctx context.Context, filename string, content []byte, apiKey string) (*models.FileScanResponse, error) {
+	apiURL := fmt.Sprintf("https://opentip.kaspersky.com/api/v1/scan/file?filename=%s", url.QueryEscape(filename))
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("x-api-key", apiKey)
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Join(ErrKasperskyUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// Всё прошло хорошо, парсим ответ
+	case http.StatusBadRequest:
+		return nil, ErrKasperskyBadRequest
+	case http.StatusUnauthorized:
+		return nil, ErrKasperskyUnauthorized
+	case http.StatusForbidden:
+		return nil, ErrKasperskyForbidden
+	case http.StatusRequestEntityTooLarge:
+		return nil, ErrKasperskyPayloadTooLarge
+	default:
+		return nil, fmt.Errorf("%w: status code %d", ErrKasperskyUnexpected, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Join(ErrKasperskyUnavailable, err)
+	}
+
+	var apiResponse models.FileScanResponse
+	if err := json.Unmarshal(body, &apiResponse); err != nil {
+		return nil, errors.Join(ErrKasperskyUnexpected, err)
+	}
+
+	return &apiResponse, nil
+}
+
+// SaveFileResult сохраняет отчёт о загруженном файле в БД и кэш под его SHA256,
+// чтобы последующие проверки по хешу возвращали его вместе с локальным разбором
+func (uc *Usecase) SaveFileResult(ctx context.Context, sha256 string, result *models.FileScanResponse, userID int) error {
+	// Время смены зоны и сведения об источнике считаются при каждой выдаче и не сохраняются
+	stored := *result
+	stored.ZoneChange = nil
+	stored.Provenance = nil
+	respJson, err := json.Marshal(&stored)
+	if err != nil {
+		return err
+	}
+
+	obtainedAt := time.Now()
+	result.Provenance = Provenance(models.LayerUpstream, models.LookupModeDefault, "hash", sha256, obtainedAt, 0)
+
+	if err := uc.SaveResponse(ctx, string(respJson), result.Zone, "hash", sha256, userID); err != nil {
+		return err
+	}
+	result.ZoneChange = uc.ZoneChange(ctx, "hash", sha256)
+
+	if err := uc.SetCachedResponse(ctx, string(respJson), "hash", sha256, obtainedAt); err != nil {
+		return err
+	}
+	result.Provenance.TTL = int64(uc.redisRepo.Expiration().Seconds())
+
+	return nil
+}
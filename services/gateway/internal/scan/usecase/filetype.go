@@ -0,0 +1,256 @@
+package usecase
+
+import (
+	"archive/zip"
+	"bytes"
+	"debug/elf"
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+var (
+	ErrFileTypeDenied = errors.New("file type is not allowed by upload policy")
+	ErrFileTooLarge   = errors.New("file size exceeds upload policy limit")
+)
+
+const (
+	textSniffSize = 8 << 10 // Сколько байт проверяется, чтобы отнести файл к тексту
+)
+
+// fileSignature описывает тип файла: MIME-тип и расширения, которые для него ожидаются
+type fileSignature struct {
+	mimeType   string
+	extensions []string
+	executable bool
+}
+
+var (
+	fileSignatures = map[string]fileSignature{
+		models.FileTypePE:      {"application/vnd.microsoft.portable-executable", []string{"exe", "dll", "sys", "scr", "cpl", "ocx", "com", "drv", "efi", "mui"}, true},
+		models.FileTypeELF:     {"application/x-executable", []string{"so", "elf", "bin", "o", "ko", "out", "run"}, true},
+		models.FileTypeMachO:   {"application/x-mach-binary", []string{"dylib", "bundle", "o", "macho"}, true},
+		models.FileTypePDF:     {models.MimeTypePDF, []string{"pdf"}, false},
+		models.FileTypeDOCX:    {"application/vnd.openxmlformats-officedocument.wordprocessingml.document", []string{"docx", "docm", "dotx", "dotm"}, false},
+		models.FileTypeXLSX:    {"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", []string{"xlsx", "xlsm", "xltx", "xltm", "xlam"}, false},
+		models.FileTypePPTX:    {"application/vnd.openxmlformats-officedocument.presentationml.presentation", []string{"pptx", "pptm", "potx", "potm", "ppsx", "ppsm"}, false},
+		models.FileTypeOLE:     {"application/x-ole-storage", []string{"doc", "dot", "xls", "xlt", "xla", "ppt", "pps", "pot", "msg", "msi", "msp"}, false},
+		models.FileTypeRTF:     {"application/rtf", []string{"rtf", "doc"}, false},
+		models.FileTypeZIP:     {"application/zip", []string{"zip", "jar", "apk", "ipa", "xpi", "epub", "odt", "ods", "odp", "whl", "nupkg", "vsix", "kmz"}, false},
+		models.FileTypeRAR:     {"application/vnd.rar", []string{"rar"}, false},
+		models.FileType7Z:      {"application/x-7z-compressed", []string{"7z"}, false},
+		models.FileTypeGZIP:    {"application/gzip", []string{"gz", "tgz"}, false},
+		models.FileTypeBZIP2:   {"application/x-bzip2", []string{"bz2", "tbz", "tbz2"}, false},
+		models.FileTypeTAR:     {"application/x-tar", []string{"tar"}, false},
+		models.FileTypePNG:     {models.MimeTypePNG, []string{"png"}, false},
+		models.FileTypeJPEG:    {models.MimeTypeJPEG, []string{"jpg", "jpeg", "jpe", "jfif"}, false},
+		models.FileTypeGIF:     {"image/gif", []string{"gif"}, false},
+		models.FileTypeBMP:     {"image/bmp", []string{"bmp", "dib"}, false},
+		models.FileTypeWebP:    {models.MimeTypeWebP, []string{"webp"}, false},
+		models.FileTypeHEIC:    {models.MimeTypeHEIC, []string{"heic", "heif"}, false},
+		models.FileTypeScript:  {"text/x-script", []string{"sh", "bash", "zsh", "ksh", "py", "pl", "rb", "php", "command"}, true},
+		models.FileTypeText:    {"text/plain", nil, false},
+		models.FileTypeUnknown: {"application/octet-stream", nil, false},
+	}
+
+	// executableExtensions расширения, с которыми файл запускается двойным щелчком
+	executableExtensions = []string{
+		"exe", "dll", "scr", "com", "pif", "cpl", "msi", "msp", "bat", "cmd", "ps1", "psm1", "vbs", "vbe", "js", "jse",
+		"wsf", "wsh", "hta", "jar", "lnk", "reg", "sh", "command", "app", "application", "gadget", "iso", "img",
+	}
+
+	// extensionExecutableTypes типы, для которых исполняемость определяет расширение, а не сигнатура:
+	// скрипты Windows - это текст, установщики msi/msp - контейнеры OLE, jar - ZIP,
+	// а у ярлыков lnk и образов iso/img своей сигнатуры в fileTypeBySignature нет
+	extensionExecutableTypes = []string{models.FileTypeText, models.FileTypeOLE, models.FileTypeZIP, models.FileTypeUnknown}
+
+	// decoyExtensions расширения безобидных файлов, за которыми прячут исполняемое расширение
+	decoyExtensions = []string{
+		"pdf", "doc", "docx", "xls", "xlsx", "ppt", "pptx", "rtf", "txt", "csv", "odt",
+		"jpg", "jpeg", "png", "gif", "bmp", "mp3", "mp4", "avi", "mov", "wav", "zip", "rar", "7z", "htm", "html",
+	}
+)
+
+// DetectFileType определяет тип файла по сигнатуре и сравнивает его с расширением из имени файла.
+// Отмечает исполняемое содержимое под чужим расширением и двойные расширения вида invoice.pdf.exe
+func (uc *Usecase) DetectFileType(filename string, content []byte) *models.FileType {
+	fileType := fileTypeBySignature(content)
+	signature := fileSignatures[fileType]
+	extension := fileExtension(filename)
+
+	result := &models.FileType{
+		Type:               fileType,
+		MimeType:           signature.mimeType,
+		Extension:          extension,
+		ExpectedExtensions: signature.extensions,
+		Entropy:            math.Round(byteEntropy(content)*100) / 100,
+		Executable:         signature.executable,
+	}
+
+	if slices.Contains(extensionExecutableTypes, fileType) && slices.Contains(executableExtensions, extension) {
+		result.Executable = true
+	}
+
+	if extension != "" && len(signature.extensions) > 0 && !slices.Contains(signature.extensions, extension) {
+		result.ExtensionMismatch = true
+	}
+
+	result.DoubleExtension = hasDoubleExtension(filename)
+
+	return result
+}
+
+// CheckUploadPolicy проверяет, что файл такого типа и размера можно принять на проверку
+func (uc *Usecase) CheckUploadPolicy(policy models.UploadPolicy, fileType *models.FileType, size int64) error {
+	matches := func(policyType string) bool {
+		return policyType == fileType.Type || (policyType == models.FileTypeExecutable && fileType.Executable)
+	}
+
+	if slices.ContainsFunc(policy.Denied, matches) {
+		return fmt.Errorf("%w: %s", ErrFileTypeDenied, fileType.Type)
+	}
+	if len(policy.Allowed) > 0 && !slices.ContainsFunc(policy.Allowed, matches) {
+		return fmt.Errorf("%w: %s", ErrFileTypeDenied, fileType.Type)
+	}
+
+	limit, ok := policy.MaxSize[fileType.Type]
+	if !ok && fileType.Executable {
+		limit, ok = policy.MaxSize[models.FileTypeExecutable]
+	}
+	if ok && size > limit {
+		return fmt.Errorf("%w: %s is limited to %d bytes", ErrFileTooLarge, fileType.Type, limit)
+	}
+
+	return nil
+}
+
+// fileTypeBySignature определяет тип файла по магическим байтам
+func fileTypeBySignature(content []byte) string {
+	switch {
+	case bytes.HasPrefix(content, []byte("MZ")):
+		return models.FileTypePE
+	case bytes.HasPrefix(content, []byte(elf.ELFMAG)):
+		return models.FileTypeELF
+	case isMachO(content):
+		return models.FileTypeMachO
+	case bytes.HasPrefix(content, []byte("%PDF-")):
+		return models.FileTypePDF
+	case bytes.HasPrefix(content, cfbSignature):
+		return models.FileTypeOLE
+	case bytes.HasPrefix(content, []byte(`{\rtf`)):
+		return models.FileTypeRTF
+	case bytes.HasPrefix(content, []byte("Rar!\x1a\x07")):
+		return models.FileTypeRAR
+	case bytes.HasPrefix(content, []byte("7z\xbc\xaf\x27\x1c")):
+		return models.FileType7Z
+	case bytes.HasPrefix(content, []byte("\x89PNG\r\n\x1a\n")):
+		return models.FileTypePNG
+	case bytes.HasPrefix(content, []byte{0xFF, 0xD8, 0xFF}):
+		return models.FileTypeJPEG
+	case bytes.HasPrefix(content, []byte("GIF87a")), bytes.HasPrefix(content, []byte("GIF89a")):
+		return models.FileTypeGIF
+	case len(content) >= 14 && string(content[:2]) == "BM" && content[6] == 0 && content[7] == 0 && content[8] == 0 && content[9] == 0:
+		// У BMP после сигнатуры идёт размер файла и четыре зарезервированных нулевых байта
+		return models.FileTypeBMP
+	case len(content) >= 12 && string(content[:4]) == "RIFF" && string(content[8:12]) == "WEBP":
+		return models.FileTypeWebP
+	case len(content) >= 12 && string(content[4:8]) == "ftyp" && isHEIFBrand(string(content[8:12])):
+		return models.FileTypeHEIC
+	case bytes.HasPrefix(content, []byte("#!")):
+		return models.FileTypeScript
+	}
+
+	switch archiveFormat(content) {
+	case models.ArchiveFormatZIP:
+		return zipFileType(content)
+	case models.ArchiveFormatGZIP:
+		return models.FileTypeGZIP
+	case models.ArchiveFormatBZIP2:
+		return models.FileTypeBZIP2
+	case models.ArchiveFormatTAR:
+		return models.FileTypeTAR
+	}
+
+	if isText(content) {
+		return models.FileTypeText
+	}
+
+	return models.FileTypeUnknown
+}
+
+// zipFileType отличает документы OOXML от обычного ZIP по каталогам внутри архива
+func zipFileType(content []byte) string {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return models.FileTypeZIP
+	}
+
+	for _, f := range zr.File {
+		switch {
+		case strings.HasPrefix(f.Name, "word/"):
+			return models.FileTypeDOCX
+		case strings.HasPrefix(f.Name, "xl/"):
+			return models.FileTypeXLSX
+		case strings.HasPrefix(f.Name, "ppt/"):
+			return models.FileTypePPTX
+		}
+	}
+
+	return models.FileTypeZIP
+}
+
+// isText проверяет, что начало файла - текст в UTF-8 без нулевых байтов
+func isText(content []byte) bool {
+	if len(content) == 0 {
+		return false
+	}
+
+	sample := content[:min(len(content), textSniffSize)]
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return false
+	}
+
+	// Последний символ мог обрезаться на границе выборки
+	if len(content) > textSniffSize {
+		for i := 1; i < utf8.UTFMax && i <= len(sample); i++ {
+			if !utf8.RuneStart(sample[len(sample)-i]) {
+				continue
+			}
+			if !utf8.FullRune(sample[len(sample)-i:]) {
+				sample = sample[:len(sample)-i]
+			}
+			break
+		}
+	}
+
+	return utf8.Valid(sample)
+}
+
+// fileExtension возвращает последнее расширение имени файла в нижнем регистре без точки
+func fileExtension(filename string) string {
+	return strings.ToLower(strings.TrimPrefix(path.Ext(strings.TrimSpace(filename)), "."))
+}
+
+// hasDoubleExtension ищет исполняемое расширение, замаскированное под документ или картинку:
+// invoice.pdf.exe, photo.jpg    .scr или символ RLO, переворачивающий отображение имени
+func hasDoubleExtension(filename string) bool {
+	if strings.ContainsRune(filename, '\u202e') {
+		return true
+	}
+
+	parts := strings.Split(strings.ToLower(path.Base(filename)), ".")
+	if len(parts) < 3 {
+		return false
+	}
+
+	last := strings.TrimSpace(parts[len(parts)-1])
+	decoy := strings.TrimSpace(parts[len(parts)-2])
+
+	return slices.Contains(executableExtensions, last) && slices.Contains(decoyExtensions, decoy)
+}
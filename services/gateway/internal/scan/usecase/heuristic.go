@@ -0,0 +1,218 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+// Пороги итогового балла для определения зоны
+const (
+	HeuristicRedScore    = 60
+	HeuristicYellowScore = 30
+)
+
+var (
+	// suspiciousTLDs доменные зоны, которые непропорционально часто используются в фишинге
+	suspiciousTLDs = map[string]struct{}{
+		"tk": {}, "ml": {}, "ga": {}, "cf": {}, "gq": {},
+		"xyz": {}, "top": {}, "zip": {}, "mov": {}, "click": {},
+		"country": {}, "kim": {}, "work": {}, "loan": {}, "gdn": {},
+		"men": {}, "review": {}, "stream": {}, "support": {}, "buzz": {},
+		"rest": {}, "fit": {}, "cam": {}, "icu": {}, "cyou": {},
+	}
+
+	// phishingKeywords слова, характерные для фишинговых ссылок
+	phishingKeywords = []string{
+		"login", "signin", "verify", "account", "update", "secure",
+		"confirm", "password", "banking", "wallet", "webscr", "unlock",
+	}
+)
+
+// HeuristicFallback сообщает, можно ли вместо ошибки Kaspersky API ответить локальной оценкой:
+// индикатор неизвестен, исчерпана квота, API недоступен или ответил неожиданно
+func HeuristicFallback(err error) bool {
+	return errors.Is(err, ErrKasperskyNotFound) ||
+		errors.Is(err, ErrKasperskyForbidden) ||
+		errors.Is(err, ErrKasperskyUnavailable) ||
+		errors.Is(err, ErrKasperskyUnexpected)
+}
+
+// HeuristicResponse строит серый ответ с локальной оценкой риска, когда Kaspersky API не дал вердикта.
+// Поставщик local_heuristic в Provenance отличает такой ответ от ответа Kaspersky API, в БД и кэш он не сохраняется
+func (uc *Usecase) HeuristicResponse(inputType, requestParam, mode string) *models.ResponseFromAPI {
+	return &models.ResponseFromAPI{
+		Zone:           "Grey",
+		LocalHeuristic: uc.EstimateRisk(inputType, requestParam, nil),
+		Provenance:     Provenance(models.LayerLocal, mode, inputType, requestParam, time.Now(), 0),
+	}
+}
+
+// EstimateRisk вычисляет локальную эвристическую оценку риска по адресу и WHOIS из ответа (если он есть).
+// Используется, когда Kaspersky API недоступен, исчерпана квота или вердикт серый.
+func (uc *Usecase) EstimateRisk(inputType, requestParam string, resp *models.ResponseFromAPI) *models.HeuristicVerdict {
+	verdict := &models.HeuristicVerdict{
+		Source: models.HeuristicSource,
+	}
+
+	add := func(name string, weight int, description string) {
+		verdict.Score += weight
+		verdict.Signals = append(verdict.Signals, models.HeuristicSignal{
+			Name:        name,
+			Weight:      weight,
+			Description: description,
+		})
+	}
+
+	var host, fullURL string
+	switch inputType {
+	case "ip", "domain":
+		host = requestParam
+		fullURL = requestParam
+	case "url":
+		fullURL = requestParam
+		if u, err := url.Parse("http://" + requestParam); err == nil {
+			host = u.Hostname()
+		}
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	if strings.Contains(fullURL, "@") {
+		add("at_sign", 25, "URL contains '@', which can hide the real destination host")
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if inputType == "url" {
+			add("raw_ip_host", 25, "URL points to a raw IP address instead of a domain name")
+		}
+	} else if host != "" {
+		uc.domainSignals(host, add)
+	}
+
+	if inputType == "url" {
+		switch length := len(fullURL); {
+		case length > 150:
+			add("url_length", 20, fmt.Sprintf("URL is very long (%d characters)", length))
+		case length > 75:
+			add("url_length", 10, fmt.Sprintf("URL is long (%d characters)", length))
+		}
+	}
+
+	if inputType != "ip" {
+		lowered := strings.ToLower(fullURL)
+		var found []string
+		for _, keyword := range phishingKeywords {
+			if strings.Contains(lowered, keyword) {
+				found = append(found, keyword)
+			}
+		}
+		if len(found) > 0 {
+			add("phishing_keywords", min(10*len(found), 30), fmt.Sprintf("Contains words typical for phishing: %s", strings.Join(found, ", ")))
+		}
+	}
+
+	if whois := whoIsFromResponse(resp); whois != nil {
+		if created, err := time.Parse(time.RFC3339, whois.Created); err == nil {
+			switch age := time.Since(created); {
+			case age < 30*24*time.Hour:
+				add("domain_age", 30, fmt.Sprintf("Domain was registered recently (%s)", created.Format(time.DateOnly)))
+			case age < 180*24*time.Hour:
+				add("domain_age", 15, fmt.Sprintf("Domain is less than half a year old (%s)", created.Format(time.DateOnly)))
+			case age > 5*365*24*time.Hour:
+				add("domain_age", -10, fmt.Sprintf("Domain has been registered for years (%s)", created.Format(time.DateOnly)))
+			}
+		}
+	}
+
+	verdict.Score = max(0, min(verdict.Score, 100))
+
+	switch {
+	case verdict.Score >= HeuristicRedScore:
+		verdict.Zone = "Red"
+	case verdict.Score >= HeuristicYellowScore:
+		verdict.Zone = "Yellow"
+	default:
+		verdict.Zone = "Green"
+	}
+
+	uc.logger.Debug("Local heuristic verdict",
+		slog.String("request_param", requestParam),
+		slog.Int("score", verdict.Score),
+		slog.String("zone", verdict.Zone),
+	)
+
+	return verdict
+}
+
+// domainSignals добавляет признаки, вычисляемые по имени хоста
+func (uc *Usecase) domainSignals(host string, add func(name string, weight int, description string)) {
+	registrable, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		registrable = host
+	}
+	suffix, _ := publicsuffix.PublicSuffix(host)
+
+	if _, ok := suspiciousTLDs[suffix]; ok {
+		add("suspicious_tld", 20, fmt.Sprintf("Domain uses TLD .%s that is popular among phishing sites", suffix))
+	}
+
+	if subdomains := strings.TrimSuffix(strings.TrimSuffix(host, registrable), "."); subdomains != "" {
+		if depth := strings.Count(subdomains, ".") + 1; depth >= 3 {
+			add("subdomain_depth", 15, fmt.Sprintf("Host has %d levels of subdomains", depth))
+		}
+	}
+
+	label := strings.TrimSuffix(strings.TrimSuffix(registrable, suffix), ".")
+	if entropy := shannonEntropy(label); len(label) >= 12 && entropy > 3.5 {
+		add("hostname_entropy", 20, fmt.Sprintf("Domain name looks randomly generated (entropy %.2f)", entropy))
+	}
+
+	if strings.Contains(host, "xn--") {
+		add("punycode", 15, "Host uses punycode and may imitate another domain")
+	}
+
+	if hyphens := strings.Count(registrable, "-"); hyphens >= 3 {
+		add("hyphens", 10, fmt.Sprintf("Domain contains %d hyphens", hyphens))
+	}
+}
+
+// whoIsFromResponse возвращает WHOIS домена из ответа Kaspersky API, если он там есть
+func whoIsFromResponse(resp *models.ResponseFromAPI) *models.WhoIsInfo {
+	if resp == nil {
+		return nil
+	}
+	if resp.DomainWhoIsInfo != nil {
+		return resp.DomainWhoIsInfo
+	}
+	return resp.UrlDomainWhoIs
+}
+
+// shannonEntropy вычисляет энтропию Шеннона строки в битах на символ
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+
+	freq := make(map[rune]int)
+	for _, r := range s {
+		freq[r]++
+	}
+
+	var entropy float64
+	total := float64(len([]rune(s)))
+	for _, count := range freq {
+		p := float64(count) / total
+		entropy -= p * math.Log2(p)
+	}
+
+	return entropy
+}
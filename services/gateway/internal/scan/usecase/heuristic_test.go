@@ -0,0 +1,182 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+// withWhoIs возвращает ответ с WHOIS домена, зарегистрированного age назад
+func withWhoIs(age time.Duration) *models.ResponseFromAPI {
+	return &models.ResponseFromAPI{
+		DomainWhoIsInfo: &models.WhoIsInfo{Created: time.Now().Add(-age).Format(time.RFC3339)},
+	}
+}
+
+func TestEstimateRisk(t *testing.T) {
+	uc := &Usecase{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	tests := []struct {
+		name         string
+		inputType    string
+		requestParam string
+		resp         *models.ResponseFromAPI
+		wantScore    int
+		wantZone     string
+		wantSignals  []string
+	}{
+		{
+			name:         "plain domain",
+			inputType:    "domain",
+			requestParam: "example.com",
+			wantZone:     "Green",
+		},
+		{
+			name:         "ip is not scored as url",
+			inputType:    "ip",
+			requestParam: "8.8.8.8",
+			wantZone:     "Green",
+		},
+		{
+			name:         "raw ip url",
+			inputType:    "url",
+			requestParam: "192.168.1.10/login",
+			wantScore:    35,
+			wantZone:     "Yellow",
+			wantSignals:  []string{"raw_ip_host", "phishing_keywords"},
+		},
+		{
+			// Адрес до @ - это имя пользователя, на самом деле запрос уходит на evil.tk
+			name:         "at sign hides host",
+			inputType:    "url",
+			requestParam: "paypal.com@evil.tk/verify/account",
+			wantScore:    65,
+			wantZone:     "Red",
+			wantSignals:  []string{"at_sign", "suspicious_tld", "phishing_keywords"},
+		},
+		{
+			name:         "generated domain",
+			inputType:    "domain",
+			requestParam: "qx7kz9vw2mpl4r.top",
+			wantScore:    40,
+			wantZone:     "Yellow",
+			wantSignals:  []string{"suspicious_tld", "hostname_entropy"},
+		},
+		{
+			name:         "deep subdomains and punycode",
+			inputType:    "domain",
+			requestParam: "a.b.c.xn--e1afmkfd.com",
+			wantScore:    30,
+			wantZone:     "Yellow",
+			wantSignals:  []string{"subdomain_depth", "punycode"},
+		},
+		{
+			name:         "hyphens",
+			inputType:    "domain",
+			requestParam: "pay-pal-log-in.com",
+			wantScore:    10,
+			wantZone:     "Green",
+			wantSignals:  []string{"hyphens"},
+		},
+		{
+			// Балл за ключевые слова ограничен 30, итоговый - 100
+			name:         "score capped at 100",
+			inputType:    "url",
+			requestParam: "user@10.0.0.1/login/verify/account/update/secure/password/" + strings.Repeat("a", 100),
+			wantScore:    100,
+			wantZone:     "Red",
+			wantSignals:  []string{"at_sign", "raw_ip_host", "url_length", "phishing_keywords"},
+		},
+		{
+			name:         "recently registered domain",
+			inputType:    "domain",
+			requestParam: "example.com",
+			resp:         withWhoIs(10 * 24 * time.Hour),
+			wantScore:    30,
+			wantZone:     "Yellow",
+			wantSignals:  []string{"domain_age"},
+		},
+		{
+			// Старый домен снижает балл, но не ниже нуля
+			name:         "old domain does not go below zero",
+			inputType:    "domain",
+			requestParam: "example.com",
+			resp:         withWhoIs(10 * 365 * 24 * time.Hour),
+			wantZone:     "Green",
+			wantSignals:  []string{"domain_age"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict := uc.EstimateRisk(tt.inputType, tt.requestParam, tt.resp)
+
+			if verdict.Source != models.HeuristicSource {
+				t.Errorf("Source = %q, want %q", verdict.Source, models.HeuristicSource)
+			}
+			if verdict.Score != tt.wantScore || verdict.Zone != tt.wantZone {
+				t.Errorf("Score = %d, Zone = %s, want %d, %s", verdict.Score, verdict.Zone, tt.wantScore, tt.wantZone)
+			}
+
+			var signals []string
+			for _, signal := range verdict.Signals {
+				signals = append(signals, signal.Name)
+			}
+			if !reflect.DeepEqual(signals, tt.wantSignals) {
+				t.Errorf("Signals = %v, want %v", signals, tt.wantSignals)
+			}
+		})
+	}
+}
+
+func TestShannonEntropy(t *testing.T) {
+	tests := []struct {
+		s    string
+		want float64
+	}{
+		{s: "", want: 0},
+		{s: "aaaa", want: 0},
+		{s: "ab", want: 1},
+		{s: "aabb", want: 1},
+		{s: "abcd", want: 2},
+		{s: "abcdefgh", want: 3},
+		// Считаются символы, а не байты
+		{s: "ааbb", want: 1},
+	}
+
+	for _, tt := range tests {
+		if got := shannonEntropy(tt.s); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("shannonEntropy(%q) = %f, want %f", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestHeuristicFallback(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: ErrKasperskyNotFound, want: true},
+		{err: ErrKasperskyForbidden, want: true},
+		{err: ErrKasperskyUnavailable, want: true},
+		{err: fmt.Errorf("lookup: %w", ErrKasperskyUnexpected), want: true},
+		{err: ErrKasperskyBadRequest, want: false},
+		{err: ErrKasperskyUnauthorized, want: false},
+		{err: ErrNotCached, want: false},
+		{err: errors.New("connection refused"), want: false},
+	}
+
+	for _, tt := range tests {
+		if got := HeuristicFallback(tt.err); got != tt.want {
+			t.Errorf("HeuristicFallback(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
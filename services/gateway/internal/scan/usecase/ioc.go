@@ -0,0 +1,176 @@
+package usecase
+
+import (
+	"net"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+	"mvdan.cc/xurls"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+var (
+	// refangReplacements восстанавливают обезвреженные индикаторы (hxxp://evil[.]com -> http://evil.com)
+	refangReplacements = []struct {
+		re  *regexp.Regexp
+		new string
+	}{
+		{regexp.MustCompile(`(?i)\bh(?:xx|\*\*|__)ps`), "https"},
+		{regexp.MustCompile(`(?i)\bh(?:xx|\*\*|__)p`), "http"},
+		{regexp.MustCompile(`(?i)\bf(?:x|\*)p\b`), "ftp"},
+		{regexp.MustCompile(`\[://\]`), "://"},
+		{regexp.MustCompile(`\[:\]`), ":"},
+		{regexp.MustCompile(`(?i)\s*(?:\[\.\]|\(\.\)|\{\.\}|\[dot\]|\(dot\)|\{dot\}|\\\.)\s*`), "."},
+		{regexp.MustCompile(`(?i)\s*(?:\[@\]|\(@\)|\{@\}|\[at\]|\(at\)|\{at\})\s*`), "@"},
+		{regexp.MustCompile(`\[/\]`), "/"},
+	}
+
+	emailRegexp  = regexp.MustCompile(`[a-zA-Z0-9._%+\-]+@(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)+[a-zA-Z]{2,63}`)
+	sha256Regexp = regexp.MustCompile(`\b[a-fA-F0-9]{64}\b`)
+	sha1Regexp   = regexp.MustCompile(`\b[a-fA-F0-9]{40}\b`)
+	md5Regexp    = regexp.MustCompile(`\b[a-fA-F0-9]{32}\b`)
+	ipv4Regexp   = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	// ipv6Regexp захватывает вместе с кандидатом соседние буквы и цифры, чтобы std::string
+	// или Foo::Bar попали в проверку целиком и были отброшены isIPv6
+	ipv6Regexp      = regexp.MustCompile(`(?i)[0-9a-z_]*(?:[0-9a-f]*:){2,}[0-9a-z_]*`)
+	urlSchemeRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*://`)
+	domainRegexp    = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z][a-z0-9-]{0,61}[a-z0-9]\b`)
+)
+
+// fileExtensionTLDs зоны, совпадающие с расширениями файлов. Голое name.zip в тексте почти всегда
+// имя файла, поэтому такие домены извлекаются только из URL или вместе с поддоменом
+var fileExtensionTLDs = map[string]struct{}{
+	"zip": {},
+	"mov": {},
+}
+
+// Refang восстанавливает обезвреженные индикаторы в тексте: hxxp -> http, [.] -> ., [@] -> @ и т.п.
+func (uc *Usecase) Refang(text string) string {
+	for _, r := range refangReplacements {
+		text = r.re.ReplaceAllString(text, r.new)
+	}
+
+	return text
+}
+
+// ExtractIOCs восстанавливает обезвреженные индикаторы и извлекает из текста веб-адреса, домены,
+// IPv4/IPv6, email и хеши MD5/SHA1/SHA256. Повторы удаляются, порядок появления сохраняется.
+func (uc *Usecase) ExtractIOCs(text string) []models.IOC {
+	text = uc.Refang(text)
+
+	var iocs []models.IOC
+	seen := make(map[models.IOC]struct{})
+	add := func(iocType, value string) {
+		ioc := models.IOC{Type: iocType, Value: value}
+		if _, ok := seen[ioc]; ok {
+			return
+		}
+		seen[ioc] = struct{}{}
+		iocs = append(iocs, ioc)
+	}
+
+	// Порядок важен: найденные совпадения затираются, чтобы домен из URL
+	// или email не попал в результат второй раз отдельным индикатором
+	extractors := []struct {
+		iocType string
+		re      *regexp.Regexp
+		valid   func(string) bool
+	}{
+		{models.IOCTypeURL, xurls.Strict, nil},
+		{models.IOCTypeEmail, emailRegexp, func(s string) bool { return isPublicDomain(s[strings.LastIndex(s, "@")+1:]) }},
+		{models.IOCTypeSHA256, sha256Regexp, nil},
+		{models.IOCTypeSHA1, sha1Regexp, nil},
+		{models.IOCTypeMD5, md5Regexp, nil},
+		{models.IOCTypeIP, ipv4Regexp, func(s string) bool { return net.ParseIP(s) != nil }},
+		{models.IOCTypeIP, ipv6Regexp, isIPv6},
+		{models.IOCTypeDomain, domainRegexp, isBareDomain},
+	}
+
+	for _, extractor := range extractors {
+		var matches []string
+		text, matches = blankMatches(text, extractor.re, extractor.valid)
+
+		for _, match := range matches {
+			// Регистр значим только в пути URL, схему приводим к нижнему регистру (HTTPS:// -> https://)
+			if extractor.iocType == models.IOCTypeURL {
+				match = lowerScheme(match)
+			} else {
+				match = strings.ToLower(match)
+			}
+			add(extractor.iocType, match)
+		}
+	}
+
+	return iocs
+}
+
+// blankMatches находит все валидные совпадения и заменяет их в тексте пробелами той же длины
+func blankMatches(text string, re *regexp.Regexp, valid func(string) bool) (string, []string) {
+	var matches []string
+
+	text = re.ReplaceAllStringFunc(text, func(match string) string {
+		if valid != nil && !valid(match) {
+			return match
+		}
+		matches = append(matches, match)
+
+		return strings.Repeat(" ", len(match))
+	})
+
+	return text, matches
+}
+
+// isIPv6 проверяет, что кандидат является IPv6-адресом хотя бы из двух непустых групп.
+// Отсекает фрагменты кода и времени вида d::, ::ba, 12:30:45
+func isIPv6(candidate string) bool {
+	if net.ParseIP(candidate) == nil {
+		return false
+	}
+
+	groups := 0
+	for _, group := range strings.Split(candidate, ":") {
+		if group != "" {
+			groups++
+		}
+	}
+
+	return groups >= 2
+}
+
+// isPublicDomain проверяет, что домен оканчивается на публичный суффикс из списка ICANN
+// и не является самим суффиксом. Отсекает имена файлов вида invoice.pdf
+func isPublicDomain(domain string) bool {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if !isValidDomain(domain) {
+		return false
+	}
+
+	suffix, icann := publicsuffix.PublicSuffix(domain)
+
+	return icann && suffix != domain
+}
+
+// isBareDomain проверяет домен, найденный в тексте вне URL. Кроме проверки isPublicDomain
+// отсекает имена файлов вида invoice.zip и video.mov: в зонах из fileExtensionTLDs
+// домен принимается только с поддоменом (cdn.evil.zip)
+func isBareDomain(domain string) bool {
+	if !isPublicDomain(domain) {
+		return false
+	}
+
+	labels := strings.Split(strings.ToLower(strings.TrimSuffix(domain, ".")), ".")
+	if _, ok := fileExtensionTLDs[labels[len(labels)-1]]; ok {
+		return len(labels) > 2
+	}
+
+	return true
+}
+
+// lowerScheme приводит схему URL к нижнему регистру, остальная часть адреса не меняется
+func lowerScheme(rawURL string) string {
+	scheme := urlSchemeRegexp.FindString(rawURL)
+
+	return strings.ToLower(scheme) + rawURL[len(scheme):]
+}
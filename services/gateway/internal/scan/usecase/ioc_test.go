@@ -0,0 +1,125 @@
+package usecase
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+func TestExtractIOCs(t *testing.T) {
+	uc := &Usecase{}
+
+	tests := []struct {
+		name string
+		text string
+		want []models.IOC
+	}{
+		{
+			name: "code-like text",
+			text: "call std::string and Foo::Bar, then a::b::c",
+			want: nil,
+		},
+		{
+			name: "timestamps and mac",
+			text: "at 12:30:45 from 00:1a:2b:3c:4d:5e",
+			want: nil,
+		},
+		{
+			name: "single-group ipv6 fragments",
+			text: "d:: and ::ba and ::1",
+			want: nil,
+		},
+		{
+			name: "ipv6 address",
+			text: "connect to 2001:DB8::dead:beef.",
+			want: []models.IOC{{Type: models.IOCTypeIP, Value: "2001:db8::dead:beef"}},
+		},
+		{
+			name: "defanged ipv6",
+			text: "peer 2001[:]db8[:]:1",
+			want: []models.IOC{{Type: models.IOCTypeIP, Value: "2001:db8::1"}},
+		},
+		{
+			name: "defanged url and ipv4",
+			text: "hxxps://evil[.]com/login from 8.8.8[.]8",
+			want: []models.IOC{
+				{Type: models.IOCTypeURL, Value: "https://evil.com/login"},
+				{Type: models.IOCTypeIP, Value: "8.8.8.8"},
+			},
+		},
+		{
+			name: "defanged email and domain",
+			text: "mail admin[at]evil[dot]com about bad(.)org",
+			want: []models.IOC{
+				{Type: models.IOCTypeEmail, Value: "admin@evil.com"},
+				{Type: models.IOCTypeDomain, Value: "bad.org"},
+			},
+		},
+		{
+			name: "hash and file name",
+			text: "invoice.pdf d41d8cd98f00b204e9800998ecf8427e",
+			want: []models.IOC{{Type: models.IOCTypeMD5, Value: "d41d8cd98f00b204e9800998ecf8427e"}},
+		},
+		{
+			name: "mixed case scheme",
+			text: "hxxpS://evil[.]com/Login and HTTP://bad.org/A",
+			want: []models.IOC{
+				{Type: models.IOCTypeURL, Value: "https://evil.com/Login"},
+				{Type: models.IOCTypeURL, Value: "http://bad.org/A"},
+			},
+		},
+		{
+			name: "file names in zones zip and mov",
+			text: "open invoice.zip and clip.MOV",
+			want: nil,
+		},
+		{
+			name: "zip domain in url or with subdomain",
+			text: "get https://evil.zip/payload from cdn.evil.zip",
+			want: []models.IOC{
+				{Type: models.IOCTypeURL, Value: "https://evil.zip/payload"},
+				{Type: models.IOCTypeDomain, Value: "cdn.evil.zip"},
+			},
+		},
+		{
+			name: "duplicates",
+			text: "evil.com EVIL.com",
+			want: []models.IOC{{Type: models.IOCTypeDomain, Value: "evil.com"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := uc.ExtractIOCs(tt.text)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractIOCs(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefang(t *testing.T) {
+	uc := &Usecase{}
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"hxxp://evil[.]com", "http://evil.com"},
+		{"HXXPS[://]evil(.)com", "https://evil.com"},
+		{"hxxpS://evil[.]com", "https://evil.com"},
+		{"fxp://files[dot]evil[.]net", "ftp://files.evil.net"},
+		{"user[@]mail[.]ru", "user@mail.ru"},
+		{"user [at] mail [dot] ru", "user@mail.ru"},
+		{"10[.]0[.]0[.]1[:]8080", "10.0.0.1:8080"},
+		{"evil\\.com[/]path", "evil.com/path"},
+		{"plain text", "plain text"},
+	}
+
+	for _, tt := range tests {
+		if got := uc.Refang(tt.text); got != tt.want {
+			t.Errorf("Refang(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+var ErrNotCached = errors.New("no cached or saved response")
+
+// Lookup проверяет веб-адрес, IP или домен: сначала в кэше Redis, затем в PostgreSQL и только потом в Kaspersky API.
+// Ответ Kaspersky API сохраняется в БД и кэш, каждая проверка записывается в журнал событий.
+// В ответ добавляется время последней смены зоны из истории вердиктов и сведения о том, откуда взят ответ.
+func (uc *Usecase) Lookup(ctx context.Context, inputType, requestParam string, userID int, apiKey string) (*models.ResponseFromAPI, error) {
+	return uc.LookupWithMode(ctx, inputType, requestParam, models.LookupModeDefault, userID, apiKey)
+}
+
+// LookupWithMode проверяет индикатор как Lookup, но позволяет обойти кэш (refresh) или не обращаться
+// к Kaspersky API (cache_only). В режиме cache_only без сохранённого ответа возвращается ErrNotCached
+func (uc *Usecase) LookupWithMode(ctx context.Context, inputType, requestParam, mode string, userID int, apiKey string) (*models.ResponseFromAPI, error) {
+	body, provenance, err := uc.lookup(ctx, inputType, requestParam, mode, userID, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var response models.ResponseFromAPI
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	response.ZoneChange = uc.ZoneChange(ctx, inputType, requestParam)
+	response.Provenance = provenance
+
+	return &response, nil
+}
+
+// LookupHash проверяет хеш файла по той же схеме, что и Lookup
+func (uc *Usecase) LookupHash(ctx context.Context, hash string, userID int, apiKey string) (*models.FileScanResponse, error) {
+	body, provenance, err := uc.lookup(ctx, "hash", hash, models.LookupModeDefault, userID, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var response models.FileScanResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	response.ZoneChange = uc.ZoneChange(ctx, "hash", hash)
+	response.Provenance = provenance
+
+	return &response, nil
+}
+
+// AllowRefresh учитывает проверку в обход кэша и сообщает, укладывается ли subject в perHour за текущий час.
+// subject - пользователь или IP-адрес неавторизованного клиента
+func (uc *Usecase) AllowRefresh(ctx context.Context, subject string, perHour int) (bool, error) {
+	count, err := uc.redisRepo.IncrRefreshCount(ctx, subject, time.Hour)
+	if err != nil {
+		return false, err
+	}
+
+	return count <= int64(perHour), nil
+}
+
+// Provenance описывает, откуда взят ответ. Нулевое obtainedAt - время получения неизвестно,
+// ttl - сколько ответ ещё будет отдаваться из кэша
+func Provenance(layer, mode, inputType, requestParam string, obtainedAt time.Time, ttl time.Duration) *models.Provenance {
+	provider := models.ProviderKaspersky
+	if layer == models.LayerLocal {
+		provider = models.ProviderLocal
+	}
+
+	provenance := &models.Provenance{
+		Layer:     layer,
+		Mode:      mode,
+		Provider:  provider,
+		InputType: inputType,
+		Request:   requestParam,
+		TTL:       int64(ttl.Seconds()),
+	}
+	if !obtainedAt.IsZero() {
+		obtainedAt = obtainedAt.UTC().Truncate(time.Second)
+		provenance.ObtainedAt = &obtainedAt
+		provenance.Age = max(int64(time.Since(obtainedAt).Seconds()), 0)
+	}
+
+	return provenance
+}
+
+// lookup реализует цепочку кэш -> БД -> Kaspersky API и возвращает ответ в виде JSON и сведения о том, откуда он взят.
+// В режиме refresh кэш и БД пропускаются, в режиме cache_only не вызывается Kaspersky API
+func (uc *Usecase) lookup(ctx context.Context, inputType, requestParam, mode string, userID int, apiKey string) ([]byte, *models.Provenance, error) {
+	logger := uc.logger.With(
+		slog.String("input_type", inputType),
+		slog.String("request_param", requestParam),
+		slog.String("mode", mode),
+	)
+
+	if mode != models.LookupModeRefresh {
+		body, provenance, err := uc.lookupStored(ctx, logger, inputType, requestParam, mode, userID)
+		if err != nil || body != nil {
+			return body, provenance, err
+		}
+	}
+
+	if mode == models.LookupModeCacheOnly {
+		return nil, nil, ErrNotCached
+	}
+
+	body, err := uc.requestKaspersky(ctx, inputType, requestParam, apiKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	obtainedAt := time.Now()
+
+	zone, err := responseZone(body)
+	if err != nil {
+		return nil, nil, errors.Join(ErrKasperskyUnexpected, err)
+	}
+
+	var ttl time.Duration
+	if err := uc.SaveResponse(ctx, string(body), zone, inputType, requestParam, userID); err != nil {
+		logger.Warn("Error saving response", slog.Any("error", err))
+	} else if err := uc.SetCachedResponse(ctx, string(body), inputType, requestParam, obtainedAt); err != nil {
+		logger.Warn("Cache is not updated in Redis", slog.Any("error", err))
+	} else {
+		ttl = uc.redisRepo.Expiration()
+	}
+
+	logger.Info("Successfully processed request", slog.String("zone", zone))
+
+	return body, Provenance(models.LayerUpstream, mode, inputType, requestParam, obtainedAt, ttl), nil
+}
+
+// lookupStored ищет ответ в Redis, затем в PostgreSQL. Если ответа нет ни там, ни там, возвращает nil без ошибки
+func (uc *Usecase) lookupStored(ctx context.Context, logger *slog.Logger, inputType, requestParam, mode string, userID int) ([]byte, *models.Provenance, error) {
+	// Проверяем наличие в Redis
+	cached, err := uc.CachedResponse(ctx, inputType, requestParam)
+	if err == nil {
+		if zone, err := responseZone([]byte(cached.Body)); err == nil {
+			// Обновляем счётчики
+			if _, err := uc.SavedResponse(ctx, inputType, requestParam); err != nil {
+				logger.Warn("Can't update count in PostgreSQL", slog.Any("error", err))
+			}
+
+			uc.recordScan(ctx, zone, inputType, requestParam, userID)
+
+			logger.Info("Returning cached response from Redis")
+			return []byte(cached.Body), Provenance(models.LayerRedis, mode, inputType, requestParam, cached.ObtainedAt, cached.TTL), nil
+		}
+		// Если произошла ошибка при разборе кэша, продолжаем обработку
+	}
+
+	// Ищем в PostgreSQL, счётчик обращений обновляется при чтении
+	saved, err := uc.SavedResponse(ctx, inputType, requestParam)
+	if err == nil {
+		if zone, err := responseZone([]byte(saved.Body)); err == nil {
+			// В кэш попадает время получения ответа из БД, а не время копирования
+			var ttl time.Duration
+			if err := uc.SetCachedResponse(ctx, saved.Body, inputType, requestParam, saved.ObtainedAt); err != nil {
+				logger.Warn("Cache is not updated in Redis", slog.Any("error", err))
+			} else {
+				ttl = uc.redisRepo.Expiration()
+			}
+
+			uc.recordScan(ctx, zone, inputType, requestParam, userID)
+
+			logger.Info("Response from DB was successfully found")
+			return []byte(saved.Body), Provenance(models.LayerPostgres, mode, inputType, requestParam, saved.ObtainedAt, ttl), nil
+		}
+		logger.Warn("Got invalid saved response")
+	} else if !errors.Is(err, ErrRowNotFound) {
+		return nil, nil, err
+	}
+
+	return nil, nil, nil
+}
+
+// recordScan записывает проверку в журнал событий. Ошибка журнала не мешает ответу и кэшированию
+func (uc *Usecase) recordScan(ctx context.Context, zone, inputType, requestParam string, userID int) {
+	if err := uc.RecordScan(ctx, zone, inputType, requestParam, userID); err != nil {
+		uc.logger.Warn("Can't record scan event in PostgreSQL", slog.Any("error", err))
+	}
+}
+
+// requestKaspersky выполняет запрос поиска в Kaspersky API. Ответ перекодируется, чтобы
+// в БД и кэш попадали только известные поля. Статусы, отличные от 200, возвращаются ошибками
+func (uc *Usecase) requestKaspersky(ctx context.Context, inputType, requestParam string, apiKey string) ([]byte, error) {
+	apiPath, err := kasperskySearchPath(inputType)
+	if err != nil {
+		return nil, err
+	}
+
+	apiURL := fmt.Sprintf("https://opentip.kaspersky.com%s?request=%s", apiPath, url.QueryEscape(requestParam))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("x-api-key", apiKey)
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Join(ErrKasperskyUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// Всё прошло хорошо, парсим ответ
+	case http.StatusBadRequest:
+		return nil, ErrKasperskyBadRequest
+	case http.StatusUnauthorized:
+		return nil, ErrKasperskyUnauthorized
+	case http.StatusForbidden:
+		return nil, ErrKasperskyForbidden
+	case http.StatusNotFound:
+		return nil, ErrKasperskyNotFound
+	default:
+		return nil, fmt.Errorf("%w: status code %d", ErrKasperskyUnexpected, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Join(ErrKasperskyUnavailable, err)
+	}
+
+	var normalized interface{}
+	if inputType == "hash" {
+		normalized = &models.FileScanResponse{}
+	} else {
+		normalized = &models.ResponseFromAPI{}
+	}
+
+	if err := json.Unmarshal(body, normalized); err != nil {
+		return nil, errors.Join(ErrKasperskyUnexpected, err)
+	}
+
+	return json.Marshal(normalized)
+}
+
+// responseZone достаёт цвет зоны из сохранённого ответа
+func responseZone(body []byte) (string, error) {
+	var response struct {
+		Zone string `json:"Zone"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", err
+	}
+
+	return response.Zone, nil
+}
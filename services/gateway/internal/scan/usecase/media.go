@@ -0,0 +1,111 @@
+package usecase
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported file format")
+	ErrInvalidPDF        = errors.New("invalid pdf document")
+)
+
+// heifBrands марки контейнера ISO BMFF, которыми помечаются файлы HEIC/HEIF
+var heifBrands = []string{"heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1"}
+
+func init() {
+	// Без этого pdfcpu пишет конфигурацию в домашний каталог и завершает процесс, если не смог
+	model.ConfigPath = "disable"
+}
+
+// DetectScreenMIME определяет тип файла по сигнатуре, не доверяя расширению и заголовкам запроса.
+// Поддерживаются PNG, JPEG, WebP и PDF. HEIC не поддерживается: ни OCR, ни декодер QR-кодов его не читают,
+// а перекодировать HEVC без cgo нечем
+func (uc *Usecase) DetectScreenMIME(content []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(content, []byte("\x89PNG\r\n\x1a\n")):
+		return models.MimeTypePNG, nil
+	case bytes.HasPrefix(content, []byte{0xFF, 0xD8, 0xFF}):
+		return models.MimeTypeJPEG, nil
+	case len(content) >= 12 && string(content[:4]) == "RIFF" && string(content[8:12]) == "WEBP":
+		return models.MimeTypeWebP, nil
+	case bytes.HasPrefix(content, []byte("%PDF-")):
+		return models.MimeTypePDF, nil
+	default:
+		return "", ErrUnsupportedFormat
+	}
+}
+
+// isHEIFBrand проверяет, что основная марка контейнера относится к HEIF
+func isHEIFBrand(brand string) bool {
+	for _, b := range heifBrands {
+		if brand == b {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SplitPDF разбивает PDF на одностраничные документы. Если страниц больше maxPages, лишние отбрасываются
+// до разбиения: документ разбирается один раз, а в отдельные документы выделяются только первые maxPages страниц
+func (uc *Usecase) SplitPDF(content []byte, maxPages int) ([][]byte, error) {
+	ctx, err := api.ReadAndValidate(bytes.NewReader(content), model.NewDefaultConfiguration())
+	if err != nil {
+		return nil, errors.Join(ErrInvalidPDF, err)
+	}
+
+	count := ctx.PageCount
+	if count > maxPages {
+		uc.logger.Warn("Too many pages in PDF, extra ones are skipped",
+			slog.Int("pages", count),
+			slog.Int("limit", maxPages),
+		)
+		count = maxPages
+	}
+
+	pages := make([][]byte, 0, count)
+	for pageNr := 1; pageNr <= count; pageNr++ {
+		reader, err := api.ExtractPage(ctx, pageNr)
+		if err != nil {
+			return nil, errors.Join(ErrInvalidPDF, fmt.Errorf("failed to extract pdf page %d: %w", pageNr, err))
+		}
+		page, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pdf page %d: %w", pageNr, err)
+		}
+		pages = append(pages, page)
+	}
+
+	return pages, nil
+}
+
+// NormalizeImage перекодирует WebP в PNG: OCR принимает только PNG, JPEG и PDF.
+// Остальные форматы возвращаются без изменений
+func (uc *Usecase) NormalizeImage(content []byte, mimeType string) ([]byte, string, error) {
+	if mimeType != models.MimeTypeWebP {
+		return content, mimeType, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, "", errors.Join(ErrUnsupportedImage, err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, "", fmt.Errorf("failed to encode png: %w", err)
+	}
+
+	return buf.Bytes(), models.MimeTypePNG, nil
+}
@@ -0,0 +1,94 @@
+package usecase
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// pdfWithPages собирает корректный PDF из pages страниц, на странице N лежит поток "% page N"
+func pdfWithPages(pages int) []byte {
+	var objects []string
+	kids := ""
+	for i := 0; i < pages; i++ {
+		pageObj, contentObj := 3+2*i, 4+2*i
+		kids += fmt.Sprintf("%d 0 R ", pageObj)
+		content := fmt.Sprintf("%% page %d", i+1)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Contents %d 0 R >>", contentObj),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		)
+	}
+	objects = append([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, pages),
+	}, objects...)
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.7\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = pdf.Len()
+		fmt.Fprintf(&pdf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := pdf.Len()
+	fmt.Fprintf(&pdf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&pdf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&pdf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return pdf.Bytes()
+}
+
+func TestSplitPDF(t *testing.T) {
+	uc := &Usecase{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	tests := []struct {
+		name      string
+		pages     int
+		maxPages  int
+		wantPages int
+	}{
+		{name: "single page", pages: 1, maxPages: 5, wantPages: 1},
+		{name: "under limit", pages: 3, maxPages: 5, wantPages: 3},
+		{name: "at limit", pages: 5, maxPages: 5, wantPages: 5},
+		{name: "extra pages skipped", pages: 40, maxPages: 5, wantPages: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages, err := uc.SplitPDF(pdfWithPages(tt.pages), tt.maxPages)
+			if err != nil {
+				t.Fatalf("SplitPDF: %v", err)
+			}
+			if len(pages) != tt.wantPages {
+				t.Fatalf("len(pages) = %d, want %d", len(pages), tt.wantPages)
+			}
+
+			for i, page := range pages {
+				count, err := api.PageCount(bytes.NewReader(page), nil)
+				if err != nil || count != 1 {
+					t.Errorf("page %d: PageCount = %d, %v, want 1", i+1, count, err)
+				}
+				if marker := fmt.Sprintf("%% page %d\n", i+1); !bytes.Contains(page, []byte(marker)) {
+					t.Errorf("page %d does not contain %q", i+1, marker)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitPDFInvalid(t *testing.T) {
+	uc := &Usecase{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	if _, err := uc.SplitPDF([]byte("%PDF-1.7\nnot a pdf\n%%EOF\n"), 5); !errors.Is(err, ErrInvalidPDF) {
+		t.Errorf("SplitPDF: got %v, want ErrInvalidPDF", err)
+	}
+}
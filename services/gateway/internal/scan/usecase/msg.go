@@ -0,0 +1,180 @@
+package usecase
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/mail"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/richardlehane/mscfb"
+	"golang.org/x/net/html/charset"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+// cfbSignature сигнатура составного файла Microsoft (OLE CFB), в котором Outlook хранит .msg
+var cfbSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// Свойства MAPI, которые нужны для проверки письма
+const (
+	msgSubject          = "0037"
+	msgTransportHeaders = "007D"
+	msgSenderEmail      = "0C1F"
+	msgSenderSMTP       = "5D01"
+	msgBody             = "1000"
+	msgHTMLBody         = "1013"
+	msgAttachData       = "3701"
+	msgAttachFilename   = "3704"
+	msgAttachLongName   = "3707"
+	msgAttachMimeTag    = "370E"
+
+	msgPropertyPrefix = "__substg1.0_"
+	msgAttachPrefix   = "__attach_version1.0_"
+)
+
+// msgProperties свойства одного объекта .msg: тег свойства (4 hex-символа) -> тип и значение
+type msgProperties map[string]msgProperty
+
+type msgProperty struct {
+	kind  string // 001F - UTF-16LE строка, 001E - 8-битная строка, 0102 - двоичные данные
+	value []byte
+}
+
+// parseMSG разбирает письмо Outlook (.msg) в то же представление, что и .eml
+func parseMSG(content []byte) (*models.ParsedEmail, error) {
+	reader, err := mscfb.New(bytes.NewReader(content))
+	if err != nil {
+		return nil, errors.Join(ErrInvalidEmail, err)
+	}
+
+	message := make(msgProperties)
+	attachments := make(map[string]msgProperties)
+
+	for entry, err := reader.Next(); err == nil; entry, err = reader.Next() {
+		name := entry.Name
+		if !strings.HasPrefix(name, msgPropertyPrefix) || len(name) != len(msgPropertyPrefix)+8 {
+			continue
+		}
+
+		value, err := io.ReadAll(entry)
+		if err != nil {
+			return nil, errors.Join(ErrInvalidEmail, err)
+		}
+		property := msgProperty{kind: name[len(msgPropertyPrefix)+4:], value: value}
+		tag := strings.ToUpper(name[len(msgPropertyPrefix) : len(msgPropertyPrefix)+4])
+
+		// Свойства вложенных писем (глубже одного уровня) не разбираем
+		switch {
+		case len(entry.Path) == 0:
+			message[tag] = property
+		case len(entry.Path) == 1 && strings.HasPrefix(entry.Path[0], msgAttachPrefix):
+			if attachments[entry.Path[0]] == nil {
+				attachments[entry.Path[0]] = make(msgProperties)
+			}
+			attachments[entry.Path[0]][tag] = property
+		}
+	}
+
+	parsed := &models.ParsedEmail{
+		Subject: message.text(msgSubject),
+	}
+
+	// Заголовки транспорта содержат цепочку Received и исходный From
+	var header mail.Header
+	if headers := message.text(msgTransportHeaders); headers != "" {
+		msg, err := mail.ReadMessage(strings.NewReader(strings.TrimRight(headers, "\r\n") + "\r\n\r\n"))
+		if err == nil {
+			header = msg.Header
+			parsed.ReceivedIPs = receivedIPs(header["Received"])
+		}
+	}
+
+	from := message.text(msgSenderSMTP)
+	if !strings.Contains(from, "@") {
+		from = message.text(msgSenderEmail)
+	}
+	if !strings.Contains(from, "@") && header != nil {
+		from = decodeHeader(header.Get("From"))
+	}
+	parsed.From, parsed.SenderDomain = senderAddress(from)
+
+	var text strings.Builder
+	text.WriteString(message.text(msgBody))
+	text.WriteByte('\n')
+
+	if htmlBody, ok := message[msgHTMLBody]; ok {
+		var r io.Reader = bytes.NewReader(htmlBody.value)
+		if htmlBody.kind == "001F" {
+			r = strings.NewReader(decodeUTF16(htmlBody.value))
+		} else if decoded, err := charset.NewReader(r, "text/html"); err == nil {
+			r = decoded
+		}
+		links, htmlText := parseHTML(r)
+		parsed.Links = links
+		text.WriteString(htmlText)
+	}
+	parsed.Text = text.String()
+
+	// Вложения храним в порядке номеров хранилищ __attach_version1.0_#00000000
+	names := make([]string, 0, len(attachments))
+	for name := range attachments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		attachment := attachments[name]
+		data, ok := attachment[msgAttachData]
+		if !ok || data.kind != "0102" {
+			// Вложенное письмо или OLE-объект хранится не потоком, а хранилищем
+			continue
+		}
+
+		filename := attachment.text(msgAttachLongName)
+		if filename == "" {
+			filename = attachment.text(msgAttachFilename)
+		}
+		if filename == "" {
+			filename = "attachment"
+		}
+
+		parsed.Attachments = append(parsed.Attachments, models.EmailAttachmentContent{
+			Filename:    filename,
+			ContentType: attachment.text(msgAttachMimeTag),
+			Content:     data.value,
+		})
+	}
+
+	return parsed, nil
+}
+
+// text возвращает строковое свойство, пустую строку, если его нет
+func (p msgProperties) text(tag string) string {
+	property, ok := p[tag]
+	if !ok {
+		return ""
+	}
+
+	switch property.kind {
+	case "001F":
+		return decodeUTF16(property.value)
+	case "001E":
+		return strings.TrimRight(string(property.value), "\x00")
+	default:
+		return ""
+	}
+}
+
+// decodeUTF16 декодирует строку UTF-16LE, в которой Outlook хранит строковые свойства
+func decodeUTF16(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, binary.LittleEndian.Uint16(b[i:]))
+	}
+
+	return strings.TrimRight(string(utf16.Decode(units)), "\x00")
+}
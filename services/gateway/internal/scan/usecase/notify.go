@@ -0,0 +1,62 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+// redVerdictWindow в течение этого времени красный вердикт того же индикатора тому же пользователю повторно не рассылается
+const redVerdictWindow = time.Hour
+
+// NotifyRedVerdict сообщает подписчикам, что пользователю выдан красный вердикт, откуда бы он ни был взят:
+// из кэша, БД или Kaspersky API. Повторные проверки того же индикатора тем же пользователем в течение
+// redVerdictWindow не рассылаются. Если Redis недоступен, событие отправляется: повтор лучше пропуска
+func (uc *Usecase) NotifyRedVerdict(ctx context.Context, zone, inputType, requestParam string, userID int) {
+	if zone != "Red" {
+		return
+	}
+
+	key := fmt.Sprintf("%s:%d:%s:%s", models.EventRedVerdict, userID, inputType, requestParam)
+	if first, err := uc.redisRepo.MarkNotified(ctx, key, redVerdictWindow); err != nil {
+		uc.logger.Warn("Can't deduplicate red verdict event", slog.Any("error", err))
+	} else if !first {
+		return
+	}
+
+	uc.notify(ctx, models.EventRedVerdict, userID, models.VerdictEvent{
+		InputType: inputType,
+		Request:   requestParam,
+		Zone:      zone,
+		Source:    sourceFromContext(ctx),
+	})
+}
+
+// NotifyFileScanned сообщает подписчикам о завершённой проверке файла. zone - итоговая зона,
+// которая может быть выше зоны Kaspersky из-за локальных правил или содержимого архива
+func (uc *Usecase) NotifyFileScanned(ctx context.Context, sha256, filename, fileType, zone string, result *models.FileScanResponse, userID int) {
+	detections := []string{}
+	for _, detection := range result.DetectionsInfo {
+		if detection.DetectionName != "" {
+			detections = append(detections, detection.DetectionName)
+		}
+	}
+
+	uc.notify(ctx, models.EventFileScanned, userID, models.FileScanEvent{
+		Sha256:     sha256,
+		Filename:   filename,
+		FileType:   fileType,
+		Zone:       zone,
+		Detections: detections,
+	})
+}
+
+// notify ставит событие в очередь рассылки. Ошибка рассылки на результат проверки не влияет
+func (uc *Usecase) notify(ctx context.Context, event string, userID int, data any) {
+	if err := uc.notifier.Notify(ctx, event, userID, data); err != nil {
+		uc.logger.Warn("Failed to enqueue event", slog.String("event", event), slog.Any("error", err))
+	}
+}
@@ -0,0 +1,134 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+// notifiedRedis помнит отмеченные события, остальные методы Redis не вызываются
+type notifiedRedis struct {
+	scan.Redis
+	marked map[string]bool
+	err    error
+}
+
+func (r *notifiedRedis) MarkNotified(_ context.Context, key string, _ time.Duration) (bool, error) {
+	if r.err != nil {
+		return false, r.err
+	}
+	if r.marked[key] {
+		return false, nil
+	}
+	r.marked[key] = true
+	return true, nil
+}
+
+// scanEventPostgres принимает записи журнала, остальные методы Postgres не вызываются
+type scanEventPostgres struct {
+	scan.Postgres
+}
+
+func (scanEventPostgres) SaveScanEvent(context.Context, int, string, string, string, string) error {
+	return nil
+}
+
+// sentEvent событие, поставленное в очередь рассылки
+type sentEvent struct {
+	event  string
+	userID int
+	data   any
+}
+
+// recordingNotifier запоминает события
+type recordingNotifier struct {
+	events []sentEvent
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, event string, userID int, data any) error {
+	n.events = append(n.events, sentEvent{event: event, userID: userID, data: data})
+	return nil
+}
+
+func TestRecordScanNotifiesRedVerdict(t *testing.T) {
+	type scanCall struct {
+		zone    string
+		request string
+		userID  int
+	}
+
+	tests := []struct {
+		name     string
+		scans    []scanCall
+		redisErr error
+		want     []int
+	}{
+		{
+			name:  "red verdict",
+			scans: []scanCall{{zone: "Red", request: "evil.example.com", userID: 5}},
+			want:  []int{5},
+		},
+		{
+			name:  "other zones are not sent",
+			scans: []scanCall{{zone: "Green", request: "example.com", userID: 5}, {zone: "Grey", request: "example.org", userID: 5}},
+		},
+		{
+			// Повторная проверка того же индикатора тем же пользователем не рассылается, другим - рассылается
+			name: "deduplicated per user and indicator",
+			scans: []scanCall{
+				{zone: "Red", request: "evil.example.com", userID: 5},
+				{zone: "Red", request: "evil.example.com", userID: 5},
+				{zone: "Red", request: "evil.example.com", userID: 7},
+				{zone: "Red", request: "bad.example.com", userID: 5},
+				{zone: "Red", request: "evil.example.com", userID: 0},
+			},
+			want: []int{5, 7, 5, 0},
+		},
+		{
+			name:     "sent without redis",
+			scans:    []scanCall{{zone: "Red", request: "evil.example.com", userID: 5}, {zone: "Red", request: "evil.example.com", userID: 5}},
+			redisErr: errors.New("connection refused"),
+			want:     []int{5, 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier := &recordingNotifier{}
+			uc := New(scanEventPostgres{}, &notifiedRedis{marked: make(map[string]bool), err: tt.redisErr}, notifier,
+				slog.New(slog.NewTextHandler(io.Discard, nil)))
+			ctx := WithSource(context.Background(), models.ScanSourceScreen)
+
+			for _, s := range tt.scans {
+				if err := uc.RecordScan(ctx, s.zone, "domain", s.request, s.userID); err != nil {
+					t.Fatalf("RecordScan: %v", err)
+				}
+			}
+
+			var users []int
+			for _, e := range notifier.events {
+				users = append(users, e.userID)
+				if e.event != models.EventRedVerdict {
+					t.Errorf("event = %s, want %s", e.event, models.EventRedVerdict)
+				}
+			}
+			if !reflect.DeepEqual(users, tt.want) {
+				t.Errorf("notified users %v, want %v", users, tt.want)
+			}
+
+			if len(notifier.events) > 0 {
+				want := models.VerdictEvent{InputType: "domain", Request: "evil.example.com", Zone: "Red", Source: models.ScanSourceScreen}
+				if got := notifier.events[0].data; !reflect.DeepEqual(got, want) {
+					t.Errorf("data = %+v, want %+v", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package usecase
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+var (
+	ErrNoIOCFound = errors.New("couldn't find ioc")
+
+	// ocrIPRegexp IPv4, в котором OCR мог спутать 0 с O и 1 с l/I
+	ocrIPRegexp = regexp.MustCompile(`^[0-9OoIl|]{1,3}(?:[.,][0-9OoIl|]{1,3}){3}(?::\d+)?$`)
+	// ocrHashRegexp хеш, в котором OCR мог спутать 0 с O и 1 с l/I
+	ocrHashRegexp = regexp.MustCompile(`^(?:[0-9a-fA-FOolI]{32}|[0-9a-fA-FOolI]{40}|[0-9a-fA-FOolI]{64})$`)
+
+	// ocrDigitToLetter замены для доменной зоны, где цифр быть не может
+	ocrDigitToLetter = strings.NewReplacer("0", "o", "1", "l")
+	// ocrLetterToDigit замены для IP и хешей, где букв O и l быть не может
+	ocrLetterToDigit = strings.NewReplacer("O", "0", "o", "0", "I", "1", "l", "1", "|", "1", ",", ".")
+)
+
+// ocrWrapSuffixes символы, на которых OCR обычно разрывает длинный URL на несколько строк.
+// Точки здесь нет: ей заканчиваются обычные предложения
+const ocrWrapSuffixes = "/-_?&=#"
+
+// GetTextOCRResponse извлекает индикаторы компрометации из ответа OCR.
+// Текст обрабатывается построчно внутри блоков: соседние слова не склеиваются,
+// а перенесённый на следующую строку URL собирается обратно.
+func (uc *Usecase) GetTextOCRResponse(OCR models.ApiResponse) ([]models.IOC, error) {
+	blocks := OCR.Result.TextAnnotation.Blocks
+	if len(blocks) == 0 && OCR.Result.TextAnnotation.FullText != "" {
+		// Ответ без разметки - считаем весь текст одним блоком
+		var lines []models.Line
+		for _, text := range strings.Split(OCR.Result.TextAnnotation.FullText, "\n") {
+			lines = append(lines, models.Line{Text: text})
+		}
+		blocks = []models.Block{{Lines: lines}}
+	}
+
+	var iocs []models.IOC
+	seen := make(map[models.IOC]struct{})
+	for _, block := range blocks {
+		for _, ioc := range uc.ExtractIOCs(blockText(block)) {
+			if _, ok := seen[ioc]; ok {
+				continue
+			}
+			seen[ioc] = struct{}{}
+			iocs = append(iocs, ioc)
+		}
+	}
+
+	if len(iocs) == 0 {
+		return nil, ErrNoIOCFound
+	}
+
+	return iocs, nil
+}
+
+// blockText собирает текст блока построчно, исправляя типичные ошибки OCR в каждом слове.
+// Строка склеивается со следующей без разделителя, только если она оборвана на незаконченном URL.
+func blockText(block models.Block) string {
+	var sb strings.Builder
+
+	for i, line := range block.Lines {
+		text := lineText(line)
+		sb.WriteString(text)
+
+		if i == len(block.Lines)-1 {
+			break
+		}
+
+		if endsWithWrappedURL(text) {
+			continue
+		}
+		sb.WriteByte('\n')
+	}
+
+	return sb.String()
+}
+
+// endsWithWrappedURL проверяет, что последнее слово строки похоже на URL, оборванный переносом:
+// содержит :// или / и заканчивается символом из ocrWrapSuffixes
+func endsWithWrappedURL(text string) bool {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return false
+	}
+
+	last := fields[len(fields)-1]
+	if !strings.Contains(last, "/") {
+		return false
+	}
+
+	return strings.ContainsRune(ocrWrapSuffixes, rune(last[len(last)-1]))
+}
+
+// lineText возвращает текст строки с исправленными словами
+func lineText(line models.Line) string {
+	var words []string
+	if len(line.Words) > 0 {
+		for _, word := range line.Words {
+			words = append(words, word.Text)
+		}
+	} else {
+		words = strings.Fields(line.Text)
+	}
+
+	for i, word := range words {
+		words[i] = fixOCRConfusions(word)
+	}
+
+	return strings.Join(words, " ")
+}
+
+// fixOCRConfusions исправляет путаницу 0/O и 1/l в словах, похожих на IP, хеш или домен
+func fixOCRConfusions(word string) string {
+	trimmed := strings.Trim(word, `()[]{}<>"'«»,;`)
+	if trimmed == "" {
+		return word
+	}
+
+	fixed := trimmed
+	switch {
+	case ocrIPRegexp.MatchString(trimmed) && strings.ContainsAny(trimmed, "0123456789"):
+		fixed = ocrLetterToDigit.Replace(trimmed)
+	case ocrHashRegexp.MatchString(trimmed) && strings.ContainsAny(trimmed, "0123456789"):
+		fixed = ocrLetterToDigit.Replace(trimmed)
+	case strings.Contains(trimmed, "."):
+		fixed = fixOCRDomainZone(trimmed)
+	}
+
+	return strings.Replace(word, trimmed, fixed, 1)
+}
+
+// fixOCRDomainZone исправляет цифры в доменной зоне хоста (evil.c0m -> evil.com),
+// если после замены зона есть в списке публичных суффиксов
+func fixOCRDomainZone(word string) string {
+	hostStart := 0
+	if idx := strings.Index(word, "://"); idx != -1 {
+		hostStart = idx + 3
+	}
+
+	hostEnd := len(word)
+	if idx := strings.IndexAny(word[hostStart:], "/?#:"); idx != -1 {
+		hostEnd = hostStart + idx
+	}
+
+	host := strings.TrimSuffix(word[hostStart:hostEnd], ".")
+	dot := strings.LastIndex(host, ".")
+	if dot == -1 || isPublicDomain(host) {
+		return word
+	}
+
+	zone := host[dot+1:]
+	if !strings.ContainsAny(zone, "01") {
+		return word
+	}
+
+	fixedHost := host[:dot+1] + ocrDigitToLetter.Replace(zone)
+	if !isPublicDomain(fixedHost) {
+		return word
+	}
+
+	return word[:hostStart] + fixedHost + word[hostStart+len(host):]
+}
+
+// LocateIOC находит на изображении области слов, из которых был собран индикатор.
+// URL, перенесённый на несколько строк, даёт несколько областей.
+func (uc *Usecase) LocateIOC(OCR models.ApiResponse, ioc models.IOC) []models.Region {
+	value := strings.ToLower(ioc.Value)
+
+	var regions []models.Region
+	for _, block := range OCR.Result.TextAnnotation.Blocks {
+		for _, line := range block.Lines {
+			for _, word := range line.Words {
+				text := strings.ToLower(uc.Refang(fixOCRConfusions(word.Text)))
+				text = strings.Trim(text, `()[]{}<>"'«»,;`)
+
+				if !wordBelongsToIOC(text, value) {
+					continue
+				}
+
+				if region, ok := models.RegionFromBoundingBox(word.BoundingBox); ok {
+					regions = append(regions, region)
+				}
+			}
+		}
+	}
+
+	return regions
+}
+
+// wordBelongsToIOC проверяет, что слово содержит индикатор целиком или является его заметной частью
+func wordBelongsToIOC(word, ioc string) bool {
+	if len(word) < 4 {
+		return false
+	}
+
+	if strings.Contains(word, ioc) {
+		return true
+	}
+
+	// Часть перенесённого URL: короткие обычные слова не считаем, чтобы не подсвечивать лишнее
+	return strings.Contains(ioc, word) && (strings.ContainsAny(word, "./:@") || len(word) >= 16)
+}
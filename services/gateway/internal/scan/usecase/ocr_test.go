@@ -0,0 +1,75 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+func TestBlockText(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{
+			name:  "sentence-ending lines",
+			lines: []string{"Payment received.", "Online banking is open"},
+			want:  "Payment received.\nOnline banking is open",
+		},
+		{
+			name:  "domain at end of sentence",
+			lines: []string{"Visit example.com.", "Thanks"},
+			want:  "Visit example.com.\nThanks",
+		},
+		{
+			name:  "hyphenated word",
+			lines: []string{"well-", "known"},
+			want:  "well-\nknown",
+		},
+		{
+			name:  "url wrapped on slash",
+			lines: []string{"Go to https://evil.com/login/", "verify?id=1"},
+			want:  "Go to https://evil.com/login/verify?id=1",
+		},
+		{
+			name:  "url wrapped on query separator",
+			lines: []string{"evil.com/a?user=", "bob&x=1"},
+			want:  "evil.com/a?user=bob&x=1",
+		},
+		{
+			name:  "finished url",
+			lines: []string{"https://evil.com/login", "Thanks"},
+			want:  "https://evil.com/login\nThanks",
+		},
+		{
+			name:  "ocr confusions fixed per word",
+			lines: []string{"evil.c0m 192.168.l.1"},
+			want:  "evil.com 192.168.1.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var block models.Block
+			for _, text := range tt.lines {
+				block.Lines = append(block.Lines, models.Line{Text: text})
+			}
+
+			if got := blockText(block); got != tt.want {
+				t.Errorf("blockText(%q) = %q, want %q", tt.lines, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetTextOCRResponseSentences(t *testing.T) {
+	uc := &Usecase{}
+
+	var ocr models.ApiResponse
+	ocr.Result.TextAnnotation.FullText = "Payment received.\nOnline banking is open"
+
+	if iocs, err := uc.GetTextOCRResponse(ocr); err != ErrNoIOCFound {
+		t.Errorf("GetTextOCRResponse() = %v, %v, want ErrNoIOCFound", iocs, err)
+	}
+}
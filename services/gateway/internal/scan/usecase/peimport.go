@@ -0,0 +1,231 @@
+package usecase
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const (
+	peDirectoryEntryImport = 1  // IMAGE_DIRECTORY_ENTRY_IMPORT
+	peImportDescriptorSize = 20 // sizeof(IMAGE_IMPORT_DESCRIPTOR)
+	maxPEImportLibraries   = 4096
+	maxPEImports           = 4096 // Общий лимит импортов: дескрипторы могут ссылаться на один и тот же массив thunk
+)
+
+var errPEImportOutOfRange = errors.New("import table points outside of sections")
+
+// winsockOrdinals имена функций winsock, которые часто импортируются по ординалу.
+// pefile подставляет их в imphash вместо ordN, поэтому без таблицы хеш не совпадёт
+var winsockOrdinals = map[uint16]string{
+	1: "accept", 2: "bind", 3: "closesocket", 4: "connect", 5: "getpeername", 6: "getsockname",
+	7: "getsockopt", 8: "htonl", 9: "htons", 10: "ioctlsocket", 11: "inet_addr", 12: "inet_ntoa",
+	13: "listen", 14: "ntohl", 15: "ntohs", 16: "recv", 17: "recvfrom", 18: "select", 19: "send",
+	20: "sendto", 21: "setsockopt", 22: "shutdown", 23: "socket",
+	51: "gethostbyaddr", 52: "gethostbyname", 53: "getprotobyname", 54: "getprotobynumber",
+	55: "getservbyname", 56: "getservbyport", 57: "gethostname",
+	101: "WSAAsyncSelect", 102: "WSAAsyncGetHostByAddr", 103: "WSAAsyncGetHostByName",
+	104: "WSAAsyncGetProtoByNumber", 105: "WSAAsyncGetProtoByName", 106: "WSAAsyncGetServByPort",
+	107: "WSAAsyncGetServByName", 108: "WSACancelAsyncRequest", 109: "WSASetBlockingHook",
+	110: "WSAUnhookBlockingHook", 111: "WSAGetLastError", 112: "WSASetLastError",
+	113: "WSACancelBlockingCall", 114: "WSAIsBlocking", 115: "WSAStartup", 116: "WSACleanup",
+	151: "__WSAFDIsSet", 500: "WEP",
+}
+
+// oleautOrdinals имена функций oleaut32, которые компиляторы импортируют по ординалу
+var oleautOrdinals = map[uint16]string{
+	2: "SysAllocString", 3: "SysReAllocString", 4: "SysAllocStringLen", 5: "SysReAllocStringLen",
+	6: "SysFreeString", 7: "SysStringLen", 8: "VariantInit", 9: "VariantClear", 10: "VariantCopy",
+	11: "VariantCopyInd", 12: "VariantChangeType", 13: "VariantTimeToDosDateTime",
+	14: "DosDateTimeToVariantTime", 15: "SafeArrayCreate", 16: "SafeArrayDestroy",
+	17: "SafeArrayGetDim", 18: "SafeArrayGetElemsize", 19: "SafeArrayGetUBound",
+	20: "SafeArrayGetLBound", 21: "SafeArrayLock", 22: "SafeArrayUnlock", 23: "SafeArrayAccessData",
+	24: "SafeArrayUnaccessData", 25: "SafeArrayGetElement", 26: "SafeArrayPutElement",
+	27: "SafeArrayCopy", 28: "SafeArrayAllocDescriptor", 29: "SafeArrayAllocData",
+	30: "SafeArrayDestroyDescriptor", 31: "SafeArrayDestroyData", 32: "SafeArrayRedim",
+	149: "SysStringByteLen", 150: "SysAllocStringByteLen",
+}
+
+// ordinalNames таблицы имён по ординалу, как в ordlookup из pefile
+var ordinalNames = map[string]map[uint16]string{
+	"ws2_32.dll":   winsockOrdinals,
+	"wsock32.dll":  winsockOrdinals,
+	"oleaut32.dll": oleautOrdinals,
+}
+
+// peImports обходит таблицу импорта PE и возвращает импорты вида library.dll!function в порядке таблицы.
+// В отличие от pe.File.ImportedSymbols, импорты по ординалу не теряются: известные ординалы
+// заменяются именем функции, остальные записываются как ordN. После maxPEImports импортов
+// разбор останавливается и возвращается truncated
+func peImports(f *pe.File) (imports []string, truncated bool, err error) {
+	var dir pe.DataDirectory
+	is64 := false
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		if len(oh.DataDirectory) > peDirectoryEntryImport {
+			dir = oh.DataDirectory[peDirectoryEntryImport]
+		}
+	case *pe.OptionalHeader64:
+		is64 = true
+		if len(oh.DataDirectory) > peDirectoryEntryImport {
+			dir = oh.DataDirectory[peDirectoryEntryImport]
+		}
+	}
+
+	if dir.VirtualAddress == 0 || dir.Size == 0 {
+		return nil, false, nil
+	}
+
+	image := peImage{file: f, data: make(map[*pe.Section][]byte)}
+
+	for i := uint32(0); i < maxPEImportLibraries; i++ {
+		descriptor, err := image.read(dir.VirtualAddress+i*peImportDescriptorSize, peImportDescriptorSize)
+		if err != nil {
+			return imports, false, err
+		}
+
+		originalFirstThunk := binary.LittleEndian.Uint32(descriptor[0:4])
+		nameRVA := binary.LittleEndian.Uint32(descriptor[12:16])
+		firstThunk := binary.LittleEndian.Uint32(descriptor[16:20])
+		if originalFirstThunk == 0 && nameRVA == 0 && firstThunk == 0 {
+			break
+		}
+
+		library, err := image.cstring(nameRVA)
+		if err != nil {
+			return imports, false, err
+		}
+
+		thunk := originalFirstThunk
+		if thunk == 0 {
+			thunk = firstThunk
+		}
+
+		functions, truncated, err := image.thunks(thunk, is64, strings.ToLower(library), maxPEImports-len(imports))
+		for _, function := range functions {
+			imports = append(imports, library+"!"+function)
+		}
+		if err != nil || truncated {
+			return imports, truncated, err
+		}
+	}
+
+	return imports, false, nil
+}
+
+// peImage читает данные образа PE по относительным виртуальным адресам
+type peImage struct {
+	file *pe.File
+	data map[*pe.Section][]byte
+}
+
+// section возвращает содержимое секции, в которую попадает rva, и смещение rva внутри неё
+func (img peImage) section(rva uint32) ([]byte, uint32, error) {
+	for _, section := range img.file.Sections {
+		start := section.VirtualAddress
+		if rva < start || rva >= start+max(section.VirtualSize, section.Size) {
+			continue
+		}
+
+		data, ok := img.data[section]
+		if !ok {
+			var err error
+			if data, err = section.Data(); err != nil {
+				return nil, 0, err
+			}
+			img.data[section] = data
+		}
+
+		return data, rva - start, nil
+	}
+
+	return nil, 0, errPEImportOutOfRange
+}
+
+// read возвращает size байт по адресу rva
+func (img peImage) read(rva, size uint32) ([]byte, error) {
+	data, offset, err := img.section(rva)
+	if err != nil {
+		return nil, err
+	}
+
+	if uint64(offset)+uint64(size) > uint64(len(data)) {
+		return nil, errPEImportOutOfRange
+	}
+
+	return data[offset : offset+size], nil
+}
+
+// cstring читает строку, оканчивающуюся нулевым байтом
+func (img peImage) cstring(rva uint32) (string, error) {
+	data, offset, err := img.section(rva)
+	if err != nil {
+		return "", err
+	}
+
+	if offset >= uint32(len(data)) {
+		return "", errPEImportOutOfRange
+	}
+
+	name := data[offset:]
+	if end := bytes.IndexByte(name, 0); end != -1 {
+		name = name[:end]
+	}
+
+	return string(name), nil
+}
+
+// thunks читает массив IMAGE_THUNK_DATA одной библиотеки, но не больше limit имён.
+// Если массив длиннее, возвращает прочитанное и truncated
+func (img peImage) thunks(rva uint32, is64 bool, library string, limit int) (functions []string, truncated bool, err error) {
+	size := uint32(4)
+	ordinalFlag := uint64(1) << 31
+	if is64 {
+		size = 8
+		ordinalFlag = 1 << 63
+	}
+
+	for i := uint32(0); ; i++ {
+		raw, err := img.read(rva+i*size, size)
+		if err != nil {
+			return functions, false, err
+		}
+
+		var entry uint64
+		if is64 {
+			entry = binary.LittleEndian.Uint64(raw)
+		} else {
+			entry = uint64(binary.LittleEndian.Uint32(raw))
+		}
+		if entry == 0 {
+			return functions, false, nil
+		}
+		if len(functions) >= limit {
+			return functions, true, nil
+		}
+
+		if entry&ordinalFlag != 0 {
+			functions = append(functions, ordinalName(library, uint16(entry)))
+			continue
+		}
+
+		// IMAGE_IMPORT_BY_NAME: двухбайтовая подсказка и имя функции
+		name, err := img.cstring(uint32(entry) + 2)
+		if err != nil {
+			return functions, false, err
+		}
+		functions = append(functions, name)
+	}
+}
+
+// ordinalName возвращает имя функции по ординалу, если оно известно, иначе ordN
+func ordinalName(library string, ordinal uint16) string {
+	if name, ok := ordinalNames[library][ordinal]; ok {
+		return name
+	}
+
+	return "ord" + strconv.Itoa(int(ordinal))
+}
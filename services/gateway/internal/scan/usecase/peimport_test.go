@@ -0,0 +1,163 @@
+package usecase
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// sleepThunk в testPE заменяется ссылкой на импорт Sleep по имени
+const sleepThunk = 0xFFFFFFFF
+
+// testPE собирает 32-битный PE с одной секцией, в которой descriptors дескрипторов импорта
+// ссылаются на одну и ту же библиотеку library и один и тот же массив thunks
+func testPE(t *testing.T, library string, thunks []uint32, descriptors int) *pe.File {
+	t.Helper()
+
+	const (
+		headerSize  = 0x400
+		sectionRVA  = 0x1000
+		peOffset    = 0x40
+		dataDirSize = 16
+	)
+
+	// Содержимое секции: дескрипторы с завершающим нулевым, имя библиотеки, IMAGE_IMPORT_BY_NAME и массив thunk
+	nameOff := uint32((descriptors + 1) * peImportDescriptorSize)
+	hintOff := nameOff + uint32(len(library)) + 1
+	thunkOff := hintOff + uint32(len("\x00\x00Sleep\x00"))
+
+	var section bytes.Buffer
+	for i := 0; i < descriptors; i++ {
+		descriptor := make([]byte, peImportDescriptorSize)
+		binary.LittleEndian.PutUint32(descriptor[0:4], sectionRVA+thunkOff)
+		binary.LittleEndian.PutUint32(descriptor[12:16], sectionRVA+nameOff)
+		binary.LittleEndian.PutUint32(descriptor[16:20], sectionRVA+thunkOff)
+		section.Write(descriptor)
+	}
+	section.Write(make([]byte, peImportDescriptorSize))
+	section.WriteString(library + "\x00")
+	section.WriteString("\x00\x00Sleep\x00")
+	for _, thunk := range thunks {
+		if thunk == sleepThunk {
+			thunk = sectionRVA + hintOff
+		}
+		binary.Write(&section, binary.LittleEndian, thunk)
+	}
+	binary.Write(&section, binary.LittleEndian, uint32(0))
+
+	var optional pe.OptionalHeader32
+	optional.Magic = 0x10b
+	optional.NumberOfRvaAndSizes = dataDirSize
+	optional.DataDirectory[peDirectoryEntryImport] = pe.DataDirectory{
+		VirtualAddress: sectionRVA,
+		Size:           uint32(descriptors * peImportDescriptorSize),
+	}
+
+	header := pe.FileHeader{
+		Machine:              pe.IMAGE_FILE_MACHINE_I386,
+		NumberOfSections:     1,
+		SizeOfOptionalHeader: uint16(binary.Size(optional)),
+	}
+
+	sectionHeader := pe.SectionHeader32{
+		VirtualSize:      uint32(section.Len()),
+		VirtualAddress:   sectionRVA,
+		SizeOfRawData:    uint32(section.Len()),
+		PointerToRawData: headerSize,
+	}
+	copy(sectionHeader.Name[:], ".idata")
+
+	var image bytes.Buffer
+	image.WriteString("MZ")
+	image.Write(make([]byte, 0x3c-image.Len()))
+	binary.Write(&image, binary.LittleEndian, uint32(peOffset))
+	image.Write(make([]byte, peOffset-image.Len()))
+	image.WriteString("PE\x00\x00")
+	binary.Write(&image, binary.LittleEndian, header)
+	binary.Write(&image, binary.LittleEndian, optional)
+	binary.Write(&image, binary.LittleEndian, sectionHeader)
+	image.Write(make([]byte, headerSize-image.Len()))
+	image.Write(section.Bytes())
+
+	f, err := pe.NewFile(bytes.NewReader(image.Bytes()))
+	if err != nil {
+		t.Fatalf("pe.NewFile: %v", err)
+	}
+	t.Cleanup(func() { f.Close() })
+
+	return f
+}
+
+func TestPEImports(t *testing.T) {
+	tests := []struct {
+		name          string
+		library       string
+		thunks        []uint32
+		descriptors   int
+		want          []string
+		wantTruncated bool
+	}{
+		{
+			name:        "by name",
+			library:     "KERNEL32.dll",
+			thunks:      []uint32{sleepThunk},
+			descriptors: 1,
+			want:        []string{"KERNEL32.dll!Sleep"},
+		},
+		{
+			name:        "known and unknown ordinals",
+			library:     "WS2_32.dll",
+			thunks:      []uint32{1<<31 | 115, 1<<31 | 9999, sleepThunk},
+			descriptors: 1,
+			want:        []string{"WS2_32.dll!WSAStartup", "WS2_32.dll!ord9999", "WS2_32.dll!Sleep"},
+		},
+		{
+			name:        "shared thunk array",
+			library:     "user32.dll",
+			thunks:      []uint32{sleepThunk, sleepThunk},
+			descriptors: 3,
+			want: []string{
+				"user32.dll!Sleep", "user32.dll!Sleep",
+				"user32.dll!Sleep", "user32.dll!Sleep",
+				"user32.dll!Sleep", "user32.dll!Sleep",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imports, truncated, err := peImports(testPE(t, tt.library, tt.thunks, tt.descriptors))
+			if err != nil {
+				t.Fatalf("peImports: %v", err)
+			}
+			if truncated != tt.wantTruncated {
+				t.Errorf("truncated = %v, want %v", truncated, tt.wantTruncated)
+			}
+			if !reflect.DeepEqual(imports, tt.want) {
+				t.Errorf("imports = %v, want %v", imports, tt.want)
+			}
+		})
+	}
+}
+
+// Все дескрипторы указывают на один большой массив thunk: без общего лимита разбор
+// построил бы maxPEImportLibraries * len(thunks) строк
+func TestPEImportsTotalLimit(t *testing.T) {
+	thunks := make([]uint32, 2*maxPEImports)
+	for i := range thunks {
+		thunks[i] = sleepThunk
+	}
+
+	imports, truncated, err := peImports(testPE(t, "kernel32.dll", thunks, maxPEImportLibraries))
+	if err != nil {
+		t.Fatalf("peImports: %v", err)
+	}
+	if !truncated {
+		t.Error("truncated = false, want true")
+	}
+	if len(imports) != maxPEImports {
+		t.Errorf("len(imports) = %d, want %d", len(imports), maxPEImports)
+	}
+}
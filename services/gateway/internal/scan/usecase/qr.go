@@ -0,0 +1,113 @@
+package usecase
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"strings"
+
+	"github.com/makiuchi-d/gozxing"
+	multiqrcode "github.com/makiuchi-d/gozxing/multi/qrcode"
+	"github.com/makiuchi-d/gozxing/qrcode"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+// DecodeQRCodes находит и декодирует все QR-коды на изображении локально, без обращения к внешним сервисам
+func (uc *Usecase) DecodeQRCodes(content []byte) ([]models.QRCode, error) {
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, errors.Join(ErrUnsupportedImage, err)
+	}
+
+	bmp, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return nil, err
+	}
+
+	hints := map[gozxing.DecodeHintType]interface{}{
+		gozxing.DecodeHintType_TRY_HARDER: true,
+	}
+
+	results, err := multiqrcode.NewQRCodeMultiReader().DecodeMultiple(bmp, hints)
+	if err != nil || len(results) == 0 {
+		// Поиск нескольких кодов иногда пропускает единственный крупный код
+		result, singleErr := qrcode.NewQRCodeReader().Decode(bmp, hints)
+		if singleErr != nil {
+			uc.logger.Debug("No QR codes found on image")
+			return nil, nil
+		}
+		results = []*gozxing.Result{result}
+	}
+
+	codes := make([]models.QRCode, 0, len(results))
+	for _, result := range results {
+		payload := result.GetText()
+		if payload == "" {
+			continue
+		}
+
+		code := models.QRCode{
+			Kind:    qrKind(payload),
+			Payload: payload,
+		}
+		if region, ok := qrRegion(result.GetResultPoints()); ok {
+			code.Region = &region
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+// qrKind определяет тип содержимого QR-кода по общепринятым префиксам
+func qrKind(payload string) string {
+	upper := strings.ToUpper(payload)
+
+	switch {
+	case strings.HasPrefix(upper, "HTTP://"), strings.HasPrefix(upper, "HTTPS://"):
+		return models.QRKindURL
+	case strings.HasPrefix(upper, "WIFI:"):
+		return models.QRKindWiFi
+	case strings.HasPrefix(upper, "MAILTO:"), strings.HasPrefix(upper, "MATMSG:"):
+		return models.QRKindEmail
+	case strings.HasPrefix(upper, "TEL:"):
+		return models.QRKindPhone
+	case strings.HasPrefix(upper, "SMSTO:"), strings.HasPrefix(upper, "SMS:"):
+		return models.QRKindSMS
+	case strings.HasPrefix(upper, "BEGIN:VCARD"), strings.HasPrefix(upper, "MECARD:"):
+		return models.QRKindVCard
+	default:
+		return models.QRKindText
+	}
+}
+
+// qrRegion строит область QR-кода по центрам поисковых узоров с запасом на их размер
+func qrRegion(points []gozxing.ResultPoint) (models.Region, bool) {
+	if len(points) == 0 {
+		return models.Region{}, false
+	}
+
+	minX, minY := points[0].GetX(), points[0].GetY()
+	maxX, maxY := minX, minY
+	for _, p := range points[1:] {
+		minX, maxX = min(minX, p.GetX()), max(maxX, p.GetX())
+		minY, maxY = min(minY, p.GetY()), max(maxY, p.GetY())
+	}
+
+	// Точки - центры поисковых узоров, сам код шире примерно на их размер
+	margin := max(maxX-minX, maxY-minY) / 6
+	minX, minY = max(0, minX-margin), max(0, minY-margin)
+	maxX, maxY = maxX+margin, maxY+margin
+
+	if maxX <= minX || maxY <= minY {
+		return models.Region{}, false
+	}
+
+	return models.Region{
+		X:      int(minX),
+		Y:      int(minY),
+		Width:  int(maxX - minX),
+		Height: int(maxY - minY),
+	}, true
+}
@@ -0,0 +1,136 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+	"github.com/CodeMaster482/minions-server/services/gateway/pkg/netguard"
+)
+
+var (
+	ErrInvalidRemoteURL = errors.New("remote url must be an absolute http or https url")
+	ErrForbiddenAddress = netguard.ErrForbiddenAddress
+	ErrRemoteTooLarge   = errors.New("remote file exceeds size limit")
+	ErrRemoteTimeout    = errors.New("remote file download timed out")
+	ErrRemoteFetch      = errors.New("failed to download remote file")
+)
+
+// remoteDialControl проверяет адрес каждого соединения при скачивании. В тестах подменяется,
+// чтобы скачивать с локального сервера
+var remoteDialControl = netguard.Control
+
+const (
+	maxRemoteRedirects = 5                // Максимальное количество перенаправлений при скачивании
+	remoteDialTimeout  = 10 * time.Second // Таймаут установки соединения с удалённым сервером
+	defaultRemoteName  = "download"       // Имя файла, если его не удалось узнать ни из заголовков, ни из адреса
+)
+
+// FetchRemoteFile скачивает файл по ссылке для проверки. Соединения с частными, локальными и служебными
+// адресами запрещены на уровне dial, поэтому защита действует и после DNS, и после перенаправлений
+func (uc *Usecase) FetchRemoteFile(ctx context.Context, rawURL string, maxSize int64, timeout time.Duration) (*models.RemoteFile, error) {
+	target, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, ErrInvalidRemoteURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidRemoteURL, err)
+	}
+
+	resp, err := remoteClient(timeout).Do(req)
+	if err != nil {
+		return nil, remoteError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%w: server returned %s", ErrRemoteFetch, resp.Status)
+	}
+	if resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("%w: content length %d is over %d bytes", ErrRemoteTooLarge, resp.ContentLength, maxSize)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, remoteError(err)
+	}
+	if int64(len(content)) > maxSize {
+		return nil, fmt.Errorf("%w: body is over %d bytes", ErrRemoteTooLarge, maxSize)
+	}
+
+	return &models.RemoteFile{
+		URL:         target.String(),
+		FinalURL:    resp.Request.URL.String(),
+		Filename:    remoteFilename(resp),
+		ContentType: resp.Header.Get("Content-Type"),
+		Content:     content,
+	}, nil
+}
+
+// remoteClient создаёт клиента без прокси из окружения: через прокси адрес назначения не проверить
+func remoteClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: remoteDialTimeout,
+		Control: remoteDialControl,
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   remoteDialTimeout,
+			ResponseHeaderTimeout: timeout,
+			DisableKeepAlives:     true,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRemoteRedirects {
+				return fmt.Errorf("%w: stopped after %d redirects", ErrRemoteFetch, maxRemoteRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrInvalidRemoteURL
+			}
+			return nil
+		},
+	}
+}
+
+// remoteError приводит ошибку скачивания к одной из ошибок usecase
+func remoteError(err error) error {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, ErrForbiddenAddress), errors.Is(err, ErrInvalidRemoteURL),
+		errors.Is(err, ErrRemoteFetch), errors.Is(err, ErrRemoteTooLarge):
+		return err
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return errors.Join(ErrRemoteTimeout, err)
+	default:
+		return errors.Join(ErrRemoteFetch, err)
+	}
+}
+
+// remoteFilename берёт имя файла из Content-Disposition, иначе из последнего сегмента пути
+func remoteFilename(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		if name := path.Base(strings.ReplaceAll(params["filename"], `\`, "/")); name != "" && name != "." && name != "/" {
+			return name
+		}
+	}
+
+	if name := path.Base(resp.Request.URL.Path); name != "" && name != "." && name != "/" {
+		return name
+	}
+
+	return defaultRemoteName
+}
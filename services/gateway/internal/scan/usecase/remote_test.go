@@ -0,0 +1,170 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/pkg/netguard"
+)
+
+// allowDial разрешает соединения только с адресами allowed, остальные проверяет netguard.
+// Без подмены тестовый сервер на loopback недоступен
+func allowDial(t *testing.T, allowed ...string) {
+	t.Helper()
+
+	previous := remoteDialControl
+	remoteDialControl = func(network, address string, c syscall.RawConn) error {
+		for _, a := range allowed {
+			if address == a {
+				return nil
+			}
+		}
+		return netguard.Control(network, address, c)
+	}
+	t.Cleanup(func() { remoteDialControl = previous })
+}
+
+func serverAddr(server *httptest.Server) string {
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+func TestFetchRemoteFile(t *testing.T) {
+	var hits atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/file.exe", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("MZ payload"))
+	})
+	mux.HandleFunc("/attachment", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", `attachment; filename="..\\invoice.pdf"`)
+		w.Write([]byte("%PDF-1.7"))
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/file.exe", http.StatusFound)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/ftp", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "ftp://example.com/file.exe", http.StatusFound)
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 2048))
+	})
+	mux.HandleFunc("/chunked", func(w http.ResponseWriter, r *http.Request) {
+		// Без Content-Length размер становится известен только при чтении
+		for i := 0; i < 4; i++ {
+			w.Write(make([]byte, 512))
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte("x"))
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	allowDial(t, serverAddr(server))
+
+	tests := []struct {
+		name         string
+		path         string
+		wantErr      error
+		wantFilename string
+		wantFinal    string
+	}{
+		{name: "file", path: "/file.exe", wantFilename: "file.exe", wantFinal: "/file.exe"},
+		{name: "content disposition", path: "/attachment", wantFilename: "invoice.pdf", wantFinal: "/attachment"},
+		{name: "redirect", path: "/redirect", wantFilename: "file.exe", wantFinal: "/file.exe"},
+		{name: "redirect limit", path: "/loop", wantErr: ErrRemoteFetch},
+		{name: "redirect to other scheme", path: "/ftp", wantErr: ErrInvalidRemoteURL},
+		{name: "content length over limit", path: "/large", wantErr: ErrRemoteTooLarge},
+		{name: "body over limit", path: "/chunked", wantErr: ErrRemoteTooLarge},
+		{name: "timeout", path: "/slow", wantErr: ErrRemoteTimeout},
+		{name: "not found", path: "/missing", wantErr: ErrRemoteFetch},
+	}
+
+	uc := &Usecase{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote, err := uc.FetchRemoteFile(context.Background(), server.URL+tt.path, 2000, 500*time.Millisecond)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("FetchRemoteFile: got %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FetchRemoteFile: %v", err)
+			}
+
+			if remote.Filename != tt.wantFilename {
+				t.Errorf("Filename = %q, want %q", remote.Filename, tt.wantFilename)
+			}
+			if remote.URL != server.URL+tt.path || remote.FinalURL != server.URL+tt.wantFinal {
+				t.Errorf("URL = %s, FinalURL = %s", remote.URL, remote.FinalURL)
+			}
+		})
+	}
+
+	if got := hits.Load(); got != maxRemoteRedirects {
+		t.Errorf("redirect loop followed %d times, want %d", got, maxRemoteRedirects)
+	}
+}
+
+func TestFetchRemoteFileInvalidURL(t *testing.T) {
+	uc := &Usecase{}
+
+	for _, rawURL := range []string{"", "example.com/file.exe", "/file.exe", "ftp://example.com/file.exe", "file:///etc/passwd", "http://"} {
+		if _, err := uc.FetchRemoteFile(context.Background(), rawURL, 1024, time.Second); !errors.Is(err, ErrInvalidRemoteURL) {
+			t.Errorf("FetchRemoteFile(%q): got %v, want ErrInvalidRemoteURL", rawURL, err)
+		}
+	}
+}
+
+// Внутренние адреса запрещены и напрямую, и через имя, и после перенаправления с разрешённого адреса
+func TestFetchRemoteFileForbiddenAddress(t *testing.T) {
+	var internalHit atomic.Bool
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		internalHit.Store(true)
+	}))
+	defer internal.Close()
+
+	public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL+"/admin", http.StatusFound)
+	}))
+	defer public.Close()
+	allowDial(t, serverAddr(public))
+
+	_, port, _ := net.SplitHostPort(serverAddr(internal))
+	uc := &Usecase{}
+
+	for _, rawURL := range []string{
+		internal.URL,
+		"http://localhost:" + port,
+		"http://[::1]:" + port,
+		"http://169.254.169.254/latest/meta-data/",
+		public.URL,
+	} {
+		if _, err := uc.FetchRemoteFile(context.Background(), rawURL, 1024, time.Second); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("FetchRemoteFile(%s): got %v, want ErrForbiddenAddress", rawURL, err)
+		}
+	}
+
+	if internalHit.Load() {
+		t.Error("internal server was reached")
+	}
+}
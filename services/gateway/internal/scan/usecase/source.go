@@ -0,0 +1,24 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+type sourceKey struct{}
+
+// WithSource запоминает в контексте, через какой эндпоинт пришла проверка. Источник нужен только
+// для истории пользователя, поэтому не протаскивается параметром через всю цепочку проверки
+func WithSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+// sourceFromContext возвращает источник проверки, по умолчанию - проверка адреса
+func sourceFromContext(ctx context.Context) string {
+	if source, ok := ctx.Value(sourceKey{}).(string); ok {
+		return source
+	}
+
+	return models.ScanSourceURI
+}
@@ -2,10 +2,8 @@ package usecase
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log/slog"
 	"net"
 	"net/http"
@@ -13,9 +11,9 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan"
-	"mvdan.cc/xurls"
 
 	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
 )
@@ -26,6 +24,13 @@ var (
 	ErrUnsavedZone     = errors.New("zone to save is not Red or Green")
 	ErrUnsupportedFlow = errors.New("unsupported request flow")
 
+	ErrKasperskyBadRequest   = errors.New("kaspersky api: incorrect query")
+	ErrKasperskyUnauthorized = errors.New("kaspersky api: authentication failed")
+	ErrKasperskyForbidden    = errors.New("kaspersky api: quota or request limit exceeded")
+	ErrKasperskyNotFound     = errors.New("kaspersky api: lookup results not found")
+	ErrKasperskyUnexpected   = errors.New("kaspersky api: unexpected response")
+	ErrKasperskyUnavailable  = errors.New("kaspersky api: request failed")
+
 	allowedHosts = map[string]struct{}{
 		"bit.ly":      {},
 		"tinyurl.com": {},
@@ -42,13 +47,15 @@ var (
 type Usecase struct {
 	postgresRepo scan.Postgres
 	redisRepo    scan.Redis
+	notifier     scan.Notifier
 	logger       *slog.Logger
 }
 
-func New(postgres scan.Postgres, redis scan.Redis, logger *slog.Logger) *Usecase {
+func New(postgres scan.Postgres, redis scan.Redis, notifier scan.Notifier, logger *slog.Logger) *Usecase {
 	return &Usecase{
 		postgresRepo: postgres,
 		redisRepo:    redis,
+		notifier:     notifier,
 		logger:       logger,
 	}
 }
@@ -197,100 +204,20 @@ func (uc *Usecase) resolveRedirects(inputURL string) (string, error) {
 	return inputURL, nil // Если перенаправлений не было
 }
 
-// возвращает слова из запроса OCR без побелов
-func (uc *Usecase) GetTextOCRResponse(OCR models.ApiResponse) ([]string, error) {
-	rxRelaxed := xurls.Strict
-	withoutString := strings.ReplaceAll(OCR.Result.TextAnnotation.FullText, " ", "")
-	matches := rxRelaxed.FindAllString(withoutString, -1)
-
-	var urls []string
-
-	// Сохраняем URL
-	urls = append(urls, matches...)
-
-	if len(urls) == 0 {
-		return nil, errors.New("couldn't find ioc")
-	}
-
-	urlsWithoutDub := removeDuplicateStr(urls)
-	return urlsWithoutDub, nil
-}
-
-func (uc *Usecase) RequestKasperskyAPI(ctx context.Context, ioc string, apiKey string) (*models.ResponseFromAPI, error) {
-	apiPath, err := uc.getIocFlow(ioc)
-	if err != nil {
-		return nil, fmt.Errorf("getIocFlow: %w", err)
-	}
-
-	apiURL := fmt.Sprintf("https://opentip.kaspersky.com%s?request=%s", apiPath, url.QueryEscape(ioc))
-
-	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("x-api-key", apiKey)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-
-	if err != nil || resp.StatusCode != http.StatusOK {
-		return nil, err
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var apiResponse *models.ResponseFromAPI
-	if err := json.Unmarshal(body, &apiResponse); err != nil {
-		return nil, err
-	}
-
-	defer resp.Body.Close()
-
-	return apiResponse, nil
-}
-
-func (uc *Usecase) getIocFlow(inputType string) (string, error) {
-	determineInput, _, err := uc.DetermineInputType(inputType)
-	if err != nil {
-		return "", fmt.Errorf("uc.DetermineInputType: %w", err)
-	}
-
-	switch determineInput {
+// kasperskySearchPath возвращает путь поиска Kaspersky API для типа индикатора
+func kasperskySearchPath(inputType string) (string, error) {
+	switch inputType {
 	case "ip":
-		apiPath = "/api/v1/search/ip"
+		return "/api/v1/search/ip", nil
 	case "url":
-		apiPath = "/api/v1/search/url"
+		return "/api/v1/search/url", nil
 	case "domain":
-		apiPath = "/api/v1/search/domain"
+		return "/api/v1/search/domain", nil
+	case "hash":
+		return "/api/v1/search/hash", nil
 	default:
 		return "", ErrUnsupportedFlow
 	}
-
-	return apiPath, nil
-}
-
-var apiPath string
-
-func filterWords(words []string) []string {
-	urlRegex := regexp.MustCompile(`https?://[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`)
-	ipRegex := regexp.MustCompile(`\b\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}\b`)
-	domainRegex := regexp.MustCompile(`\b[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}\b`)
-
-	var result []string
-	for _, word := range words {
-		// Проверяем, соответствует ли слово одному из шаблонов
-		if urlRegex.MatchString(word) || ipRegex.MatchString(word) || domainRegex.MatchString(word) {
-			result = append(result, word)
-		}
-	}
-	return result
 }
 
 // isValidDomain проверяет, является ли строка валидным доменным именем.
@@ -299,7 +226,7 @@ func isValidDomain(domain string) bool {
 	return domainRegexp.MatchString(domain)
 }
 
-func (uc *Usecase) CachedResponse(ctx context.Context, inputType, requestParam string) (string, error) {
+func (uc *Usecase) CachedResponse(ctx context.Context, inputType, requestParam string) (*models.StoredResponse, error) {
 	uc.logger.Debug("Attempting to retrieve cached response",
 		slog.String("input_type", inputType),
 		slog.String("request_param", requestParam),
@@ -311,13 +238,13 @@ func (uc *Usecase) CachedResponse(ctx context.Context, inputType, requestParam s
 			slog.Any("error", err),
 		)
 
-		return "", errors.Join(ErrCacheMiss, fmt.Errorf("failed to get cached response: %w", err))
+		return nil, errors.Join(ErrCacheMiss, fmt.Errorf("failed to get cached response: %w", err))
 	}
 
-	if cachedResponse == "" {
+	if cachedResponse == nil {
 		uc.logger.Info("Cache miss: no cached response found")
 
-		return "", ErrCacheMiss
+		return nil, ErrCacheMiss
 	}
 
 	uc.logger.Info("Cache hit: cached response found")
@@ -325,13 +252,13 @@ func (uc *Usecase) CachedResponse(ctx context.Context, inputType, requestParam s
 	return cachedResponse, nil
 }
 
-func (uc *Usecase) SetCachedResponse(ctx context.Context, savedResponse, inputType, requestParam string) error {
+func (uc *Usecase) SetCachedResponse(ctx context.Context, savedResponse, inputType, requestParam string, obtainedAt time.Time) error {
 	uc.logger.Error("Attempting to set cached response in Redis",
 		slog.String("input_type", inputType),
 		slog.String("request_param", requestParam),
 	)
 
-	err := uc.redisRepo.SetCachedResponse(ctx, savedResponse, inputType, requestParam)
+	err := uc.redisRepo.SetCachedResponse(ctx, savedResponse, inputType, requestParam, obtainedAt)
 	if err != nil {
 		uc.logger.Error("Failed to set cached response in Redis",
 			slog.Any("error", err),
@@ -345,7 +272,7 @@ func (uc *Usecase) SetCachedResponse(ctx context.Context, savedResponse, inputTy
 	return nil
 }
 
-func (uc *Usecase) SavedResponse(ctx context.Context, inputType, requestParam string) (string, error) {
+func (uc *Usecase) SavedResponse(ctx context.Context, inputType, requestParam string) (*models.StoredResponse, error) {
 	uc.logger.Debug("Attempting to retrieve saved response",
 		slog.String("input_type", inputType),
 		slog.String("request_param", requestParam),
@@ -357,13 +284,13 @@ func (uc *Usecase) SavedResponse(ctx context.Context, inputType, requestParam st
 			slog.Any("error", err),
 		)
 
-		return "", errors.Join(ErrRowNotFound, fmt.Errorf("failed to get saved response: %w", err))
+		return nil, errors.Join(ErrRowNotFound, fmt.Errorf("failed to get saved response: %w", err))
 	}
 
-	if savedResponse == "" {
+	if savedResponse == nil {
 		uc.logger.Info("No saved response found")
 
-		return "", ErrRowNotFound
+		return nil, ErrRowNotFound
 	}
 
 	uc.logger.Info("Saved response found")
@@ -387,46 +314,49 @@ func (uc *Usecase) SaveResponse(ctx context.Context, respJson, zone, inputType,
 	//	return ErrUnsavedZone
 	//}
 
+	if err := uc.saveResult(ctx, respJson, zone, inputType, requestParam); err != nil {
+		return err
+	}
+
+	uc.recordScan(ctx, zone, inputType, requestParam, userID)
+
+	return nil
+}
+
+// saveResult перезаписывает ответ в scan_results и дописывает историю вердиктов, не трогая журнал событий
+func (uc *Usecase) saveResult(ctx context.Context, respJson, zone, inputType, requestParam string) error {
 	err := uc.postgresRepo.SaveResponse(ctx, respJson, inputType, requestParam)
 	if err != nil {
 		uc.logger.Error("Error saving general response", slog.Any("error", err))
 		return err
 	}
 
-	if userID != 0 {
-		err = uc.SaveUserStats(ctx, zone, inputType, requestParam, userID)
-		if err != nil {
-			uc.logger.Error("Error saving user-specific response", slog.Any("error", err))
-			return err
-		}
+	// Ответ в scan_results перезаписывается, поэтому смена вердикта сохраняется отдельно.
+	// История вердиктов и журнал событий не должны мешать кэшированию ответа, поэтому их ошибки только пишутся в лог
+	if err := uc.saveVerdict(ctx, respJson, zone, inputType, requestParam); err != nil {
+		uc.logger.Warn("Can't save verdict history in PostgreSQL", slog.Any("error", err))
 	}
 
 	return nil
 }
 
-func (uc *Usecase) SaveUserStats(ctx context.Context, zone, inputType, requestParam string, userID int) error {
-	uc.logger.Debug("Attempting to save user stats",
+// RecordScan записывает проверку в журнал событий, из которого строятся история пользователя и статистика.
+// Проверки неавторизованных пользователей тоже записываются и учитываются в общей статистике.
+// Красный вердикт дополнительно рассылается подписчикам scan.red_verdict
+func (uc *Usecase) RecordScan(ctx context.Context, zone, inputType, requestParam string, userID int) error {
+	uc.logger.Debug("Attempting to record scan event",
 		slog.String("user_id", fmt.Sprintf("%d", userID)),
 		slog.String("input_type", inputType),
 		slog.String("request_param", requestParam),
 	)
 
-	err := uc.postgresRepo.SaveUserResponse(ctx, userID, zone, inputType, requestParam)
+	uc.NotifyRedVerdict(ctx, zone, inputType, requestParam, userID)
+
+	err := uc.postgresRepo.SaveScanEvent(ctx, userID, zone, inputType, requestParam, sourceFromContext(ctx))
 	if err != nil {
-		uc.logger.Error("Error saving user stats", slog.Any("error", err))
+		uc.logger.Error("Error saving scan event", slog.Any("error", err))
 		return err
 	}
 
 	return nil
 }
-func removeDuplicateStr(strSlice []string) []string {
-	allKeys := make(map[string]bool)
-	list := []string{}
-	for _, item := range strSlice {
-		if _, value := allKeys[item]; !value {
-			allKeys[item] = true
-			list = append(list, item)
-		}
-	}
-	return list
-}
@@ -0,0 +1,186 @@
+package usecase
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/richardlehane/mscfb"
+)
+
+var errVBACompressed = errors.New("vba: invalid compressed container")
+
+// Записи потока dir проекта VBA (MS-OVBA 2.3.4.2)
+const (
+	vbaDirProjectVersion    = 0x0009
+	vbaDirModuleStreamName  = 0x001A
+	vbaDirModuleOffset      = 0x0031
+	vbaDirModuleTerminator  = 0x002B
+	vbaChunkSize            = 4096
+	vbaMaxDecompressedBytes = 16 << 20 // Защита от распаковки бесконечного потока
+)
+
+// vbaSources извлекает исходный код всех модулей VBA из составного файла (vbaProject.bin или .doc/.xls)
+func vbaSources(content []byte) (bool, []string, error) {
+	reader, err := mscfb.New(bytes.NewReader(content))
+	if err != nil {
+		return false, nil, err
+	}
+
+	// Потоки проекта лежат в хранилище VBA: в vbaProject.bin - в корне, в .doc - в Macros, в .xls - в _VBA_PROJECT_CUR
+	streams := make(map[string][]byte)
+	var dir []byte
+	for entry, err := reader.Next(); err == nil; entry, err = reader.Next() {
+		if len(entry.Path) == 0 || !strings.EqualFold(entry.Path[len(entry.Path)-1], "VBA") {
+			continue
+		}
+
+		data, err := io.ReadAll(io.LimitReader(entry, vbaMaxDecompressedBytes))
+		if err != nil {
+			return true, nil, err
+		}
+
+		if strings.EqualFold(entry.Name, "dir") {
+			dir = data
+			continue
+		}
+		streams[entry.Name] = data
+	}
+
+	if dir == nil {
+		return false, nil, nil
+	}
+
+	decompressed, err := decompressVBA(dir)
+	if err != nil {
+		return true, nil, err
+	}
+
+	var sources []string
+	for _, module := range vbaModules(decompressed) {
+		stream, ok := streams[module.stream]
+		if !ok || int(module.offset) > len(stream) {
+			continue
+		}
+
+		source, err := decompressVBA(stream[module.offset:])
+		if err != nil {
+			continue
+		}
+		sources = append(sources, string(source))
+	}
+
+	return true, sources, nil
+}
+
+type vbaModule struct {
+	stream string
+	offset uint32
+}
+
+// vbaModules разбирает записи потока dir и возвращает имя потока и смещение исходного кода каждого модуля
+func vbaModules(dir []byte) []vbaModule {
+	var (
+		modules []vbaModule
+		current vbaModule
+	)
+
+	for pos := 0; pos+6 <= len(dir); {
+		id := binary.LittleEndian.Uint16(dir[pos:])
+		size := int(binary.LittleEndian.Uint32(dir[pos+2:]))
+		pos += 6
+
+		// У PROJECTVERSION в поле размера всегда 4, хотя данных 6 байт
+		if id == vbaDirProjectVersion {
+			size = 6
+		}
+		if size < 0 || pos+size > len(dir) {
+			break
+		}
+		data := dir[pos : pos+size]
+		pos += size
+
+		switch id {
+		case vbaDirModuleStreamName:
+			current.stream = string(data)
+		case vbaDirModuleOffset:
+			if len(data) >= 4 {
+				current.offset = binary.LittleEndian.Uint32(data)
+			}
+		case vbaDirModuleTerminator:
+			if current.stream != "" {
+				modules = append(modules, current)
+			}
+			current = vbaModule{}
+		}
+	}
+
+	return modules
+}
+
+// decompressVBA распаковывает контейнер MS-OVBA (2.4.1): блоки по 4096 байт, сжатые вариантом LZ77
+func decompressVBA(data []byte) ([]byte, error) {
+	if len(data) == 0 || data[0] != 0x01 {
+		return nil, errVBACompressed
+	}
+
+	var out []byte
+	for pos := 1; pos+2 <= len(data); {
+		header := binary.LittleEndian.Uint16(data[pos:])
+		chunkEnd := min(pos+int(header&0x0FFF)+3, len(data))
+		pos += 2
+
+		if header&0x8000 == 0 {
+			// Несжатый блок всегда содержит ровно 4096 байт
+			end := min(pos+vbaChunkSize, len(data))
+			out = append(out, data[pos:end]...)
+			pos = end
+			continue
+		}
+
+		chunkStart := len(out)
+		for pos < chunkEnd {
+			flags := data[pos]
+			pos++
+
+			for bit := 0; bit < 8 && pos < chunkEnd; bit++ {
+				if flags&(1<<bit) == 0 {
+					out = append(out, data[pos])
+					pos++
+					continue
+				}
+
+				if pos+2 > chunkEnd {
+					return nil, errVBACompressed
+				}
+				token := binary.LittleEndian.Uint16(data[pos:])
+				pos += 2
+
+				bitCount := 4
+				for (1 << bitCount) < len(out)-chunkStart {
+					bitCount++
+				}
+				lengthMask := uint16(0xFFFF) >> bitCount
+				length := int(token&lengthMask) + 3
+				offset := int(token>>(16-bitCount)) + 1
+
+				src := len(out) - offset
+				if src < chunkStart {
+					return nil, errVBACompressed
+				}
+				for i := 0; i < length; i++ {
+					out = append(out, out[src+i])
+				}
+			}
+		}
+		pos = chunkEnd
+
+		if len(out) > vbaMaxDecompressedBytes {
+			return nil, errVBACompressed
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,136 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+)
+
+var hashRegexp = regexp.MustCompile(`^(?:[a-f0-9]{32}|[a-f0-9]{40}|[a-f0-9]{64})$`)
+
+// IndicatorKey приводит индикатор к виду, в котором он хранится в БД: хеши MD5/SHA1/SHA256
+// в нижнем регистре, остальное - как при проверке через DetermineInputType
+func (uc *Usecase) IndicatorKey(input string) (string, string, error) {
+	if hash := strings.ToLower(strings.TrimSpace(input)); hashRegexp.MatchString(hash) {
+		return "hash", hash, nil
+	}
+
+	return uc.DetermineInputType(input)
+}
+
+// VerdictHistory возвращает последние изменения вердикта индикатора, новые первыми
+func (uc *Usecase) VerdictHistory(ctx context.Context, inputType, requestParam string, limit int) (*models.VerdictTimeline, error) {
+	changes, err := uc.postgresRepo.VerdictHistory(ctx, inputType, requestParam, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.VerdictTimeline{
+		InputType: inputType,
+		Request:   requestParam,
+		Changes:   changes,
+	}, nil
+}
+
+// ZoneChange возвращает сведения о последней смене зоны. Ошибка не мешает ответу на проверку,
+// поэтому только записывается в лог
+func (uc *Usecase) ZoneChange(ctx context.Context, inputType, requestParam string) *models.ZoneChange {
+	change, err := uc.postgresRepo.ZoneChange(ctx, inputType, requestParam)
+	if err != nil {
+		uc.logger.Warn("Can't get zone change from PostgreSQL", slog.Any("error", err))
+		return nil
+	}
+
+	return change
+}
+
+// Rescan запрашивает вердикт в Kaspersky API в обход кэша и БД и перезаписывает сохранённый ответ и кэш.
+// Используется для повторных проверок по расписанию: это не проверка пользователя, поэтому она не пишется
+// в журнал событий и не попадает в статистику. Смена вердикта сохраняется в историю как обычно
+func (uc *Usecase) Rescan(ctx context.Context, inputType, requestParam, apiKey string) (*models.Verdict, error) {
+	ctx = WithSource(ctx, models.ScanSourceWatchlist)
+	logger := uc.logger.With(
+		slog.String("input_type", inputType),
+		slog.String("request_param", requestParam),
+	)
+
+	body, err := uc.requestKaspersky(ctx, inputType, requestParam, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	obtainedAt := time.Now()
+
+	zone, err := responseZone(body)
+	if err != nil {
+		return nil, errors.Join(ErrKasperskyUnexpected, err)
+	}
+
+	categories, err := responseCategories(inputType, body)
+	if err != nil {
+		return nil, errors.Join(ErrKasperskyUnexpected, err)
+	}
+
+	if err := uc.saveResult(ctx, string(body), zone, inputType, requestParam); err != nil {
+		logger.Warn("Error saving rescanned response", slog.Any("error", err))
+	} else if err := uc.SetCachedResponse(ctx, string(body), inputType, requestParam, obtainedAt); err != nil {
+		logger.Warn("Cache is not updated in Redis", slog.Any("error", err))
+	}
+
+	return &models.Verdict{Zone: zone, Categories: categories}, nil
+}
+
+// saveVerdict записывает вердикт в историю индикатора, если зона или категории изменились
+func (uc *Usecase) saveVerdict(ctx context.Context, respJson, zone, inputType, requestParam string) error {
+	categories, err := responseCategories(inputType, []byte(respJson))
+	if err != nil {
+		return err
+	}
+
+	changed, err := uc.postgresRepo.SaveVerdict(ctx, inputType, requestParam, zone, categories)
+	if err != nil {
+		return err
+	}
+	if changed {
+		uc.logger.Info("Verdict changed",
+			slog.String("input_type", inputType),
+			slog.String("request_param", requestParam),
+			slog.String("zone", zone),
+			slog.Any("categories", categories),
+		)
+	}
+
+	return nil
+}
+
+// responseCategories достаёт из сохранённого ответа отсортированный список категорий без повторов.
+// Для файла категориями считаются названия обнаруженных объектов
+func responseCategories(inputType string, body []byte) ([]string, error) {
+	var categories []string
+	if inputType == "hash" {
+		var response models.FileScanResponse
+		if err := json.Unmarshal(body, &response); err != nil {
+			return nil, err
+		}
+		for _, detection := range response.DetectionsInfo {
+			categories = append(categories, detection.DetectionName)
+		}
+	} else {
+		var response models.ResponseFromAPI
+		if err := json.Unmarshal(body, &response); err != nil {
+			return nil, err
+		}
+		categories = append(categories, response.Categories...)
+	}
+
+	categories = slices.DeleteFunc(categories, func(c string) bool { return c == "" })
+	slices.Sort(categories)
+
+	return append([]string{}, slices.Compact(categories)...), nil
+}
@@ -14,5 +14,17 @@ type Usecase interface {
 
 type Repo interface {
 	TopLinksByUserZoneAndPeriod(ctx context.Context, userID *int, zone string, since time.Time, limit int) ([]models.LinkStat, error)
-	TopLinksByZone(ctx context.Context, zone string, limit int) ([]models.LinkStat, error)
+	TopLinksByZone(ctx context.Context, zone string, rawSince time.Time, limit int) ([]models.LinkStat, error)
+}
+
+// ScanLogRepo обслуживает журнал проверок: дневные секции событий и дневные итоги
+type ScanLogRepo interface {
+	CreatePartition(ctx context.Context, day time.Time) error
+	Partitions(ctx context.Context) ([]time.Time, error)
+	DropPartition(ctx context.Context, day time.Time) error
+	DeleteDefaultEvents(ctx context.Context, before time.Time) error
+	OldestEventDay(ctx context.Context) (time.Time, bool, error)
+	LastRollupDay(ctx context.Context) (time.Time, bool, error)
+	RollupDay(ctx context.Context, day time.Time) error
+	DeleteRollups(ctx context.Context, before time.Time) error
 }
@@ -11,24 +11,33 @@ import (
 )
 
 var (
+	// Считаются события за период, а не накопленные за всё время счётчики
 	TopLinksByUserZoneAndPeriod = `
-        SELECT request, SUM(access_count) as total_access_count
-        FROM user_scan_stats
+        SELECT request, COUNT(*) as total_access_count
+        FROM scan_events
         WHERE user_id = $1
           AND zone = $2
-          AND last_accessed >= $3
+          AND created_at >= $3
         GROUP BY request
         ORDER BY total_access_count DESC
         LIMIT $4
     `
 
+	// За всё время: дневные итоги до $2 и сами события начиная с $3 (ещё не подведённые дни)
 	TopLinksByZone = `
-        SELECT request, SUM(access_count) as total_access_count
-        FROM scan_results
-        WHERE response->>'Zone' = $1
+        SELECT request, SUM(scan_count) as total_access_count
+        FROM (
+            SELECT request, scan_count
+            FROM scan_event_rollups
+            WHERE zone = $1 AND day < $2::date
+            UNION ALL
+            SELECT request, 1
+            FROM scan_events
+            WHERE zone = $1 AND created_at >= $3
+        ) AS scans
         GROUP BY request
         ORDER BY total_access_count DESC
-        LIMIT $2
+        LIMIT $4
     `
 )
 
@@ -72,8 +81,8 @@ func (p *Postgres) TopLinksByUserZoneAndPeriod(ctx context.Context, userID *int,
 	return results, nil
 }
 
-func (p *Postgres) TopLinksByZone(ctx context.Context, zone string, limit int) ([]models.LinkStat, error) {
-	rows, err := p.db.QueryContext(ctx, TopLinksByZone, zone, limit)
+func (p *Postgres) TopLinksByZone(ctx context.Context, zone string, rawSince time.Time, limit int) ([]models.LinkStat, error) {
+	rows, err := p.db.QueryContext(ctx, TopLinksByZone, zone, rawSince.UTC().Format(dateLayout), rawSince, limit)
 	if err != nil {
 		p.logger.Error("Error executing top links by zone query", slog.Any("error", err))
 		return nil, fmt.Errorf("error executing top links by zone query: %w", err)
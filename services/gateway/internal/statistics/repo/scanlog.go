@@ -0,0 +1,224 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	partitionPrefix = "scan_events_p"
+	partitionLayout = "20060102"
+	dateLayout      = "2006-01-02"
+)
+
+var (
+	PartitionExists = `
+        SELECT to_regclass($1) IS NOT NULL
+    `
+	// Секции создаются отдельно от родительской таблицы и присоединяются: если события за этот день
+	// уже попали в секцию по умолчанию, они переносятся в новую секцию в той же транзакции
+	CreatePartitionTable = `
+        CREATE TABLE %s (LIKE scan_events INCLUDING DEFAULTS INCLUDING CONSTRAINTS)
+    `
+	MoveDefaultEvents = `
+        WITH moved AS (
+            DELETE FROM scan_events_default
+            WHERE created_at >= $1 AND created_at < $2
+            RETURNING *
+        )
+        INSERT INTO %s SELECT * FROM moved
+    `
+	AttachPartition = `
+        ALTER TABLE scan_events ATTACH PARTITION %s FOR VALUES FROM ('%s') TO ('%s')
+    `
+	ListPartitions = `
+        SELECT c.relname
+        FROM pg_inherits i
+        JOIN pg_class c ON c.oid = i.inhrelid
+        JOIN pg_class p ON p.oid = i.inhparent
+        WHERE p.relname = 'scan_events'
+    `
+	DropPartition = `
+        DROP TABLE IF EXISTS %s
+    `
+	DeleteDefaultEvents = `
+        DELETE FROM scan_events_default
+        WHERE created_at < $1
+    `
+	OldestEvent = `
+        SELECT MIN(created_at) FROM scan_events
+    `
+	LastRollupDay = `
+        SELECT MAX(day) FROM scan_event_rollups
+        WHERE NOT backfilled
+    `
+	DeleteRollupDay = `
+        DELETE FROM scan_event_rollups
+        WHERE day = $1::date AND NOT backfilled
+    `
+	RollupDay = `
+        INSERT INTO scan_event_rollups (day, user_id, input_type, request, zone, scan_count)
+        SELECT $1::date, COALESCE(user_id, 0), input_type, request, zone, COUNT(*)
+        FROM scan_events
+        WHERE created_at >= $2 AND created_at < $3
+        GROUP BY COALESCE(user_id, 0), input_type, request, zone
+    `
+	DeleteRollups = `
+        DELETE FROM scan_event_rollups
+        WHERE day < $1::date
+    `
+)
+
+// CreatePartition создаёт секцию журнала проверок за день (UTC), если её ещё нет
+func (p *Postgres) CreatePartition(ctx context.Context, day time.Time) error {
+	name := partitionName(day)
+
+	var exists bool
+	if err := p.db.QueryRowContext(ctx, PartitionExists, name).Scan(&exists); err != nil {
+		return fmt.Errorf("error checking partition %s: %w", name, err)
+	}
+	if exists {
+		return nil
+	}
+
+	from, to := day, day.AddDate(0, 0, 1)
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(CreatePartitionTable, name)); err != nil {
+		return fmt.Errorf("error creating partition %s: %w", name, err)
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(MoveDefaultEvents, name), from, to); err != nil {
+		return fmt.Errorf("error moving events to partition %s: %w", name, err)
+	}
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(AttachPartition, name, from.Format(time.RFC3339), to.Format(time.RFC3339)))
+	if err != nil {
+		return fmt.Errorf("error attaching partition %s: %w", name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing partition %s: %w", name, err)
+	}
+
+	p.logger.Info("Scan events partition created", slog.String("partition", name))
+	return nil
+}
+
+// Partitions возвращает дни, за которые есть секции журнала проверок
+func (p *Postgres) Partitions(ctx context.Context) ([]time.Time, error) {
+	rows, err := p.db.QueryContext(ctx, ListPartitions)
+	if err != nil {
+		return nil, fmt.Errorf("error listing partitions: %w", err)
+	}
+	defer rows.Close()
+
+	var days []time.Time
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+
+		// Секция по умолчанию и созданные вручную секции не трогаем
+		suffix, ok := strings.CutPrefix(name, partitionPrefix)
+		if !ok {
+			continue
+		}
+		day, err := time.Parse(partitionLayout, suffix)
+		if err != nil {
+			continue
+		}
+		days = append(days, day)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return days, nil
+}
+
+// DropPartition удаляет секцию журнала проверок за день вместе с событиями
+func (p *Postgres) DropPartition(ctx context.Context, day time.Time) error {
+	name := partitionName(day)
+	if _, err := p.db.ExecContext(ctx, fmt.Sprintf(DropPartition, name)); err != nil {
+		return fmt.Errorf("error dropping partition %s: %w", name, err)
+	}
+
+	p.logger.Info("Scan events partition dropped", slog.String("partition", name))
+	return nil
+}
+
+// DeleteDefaultEvents удаляет старые события, попавшие в секцию по умолчанию
+func (p *Postgres) DeleteDefaultEvents(ctx context.Context, before time.Time) error {
+	if _, err := p.db.ExecContext(ctx, DeleteDefaultEvents, before); err != nil {
+		return fmt.Errorf("error deleting default partition events: %w", err)
+	}
+
+	return nil
+}
+
+// OldestEventDay возвращает день самого старого события, false - если событий нет
+func (p *Postgres) OldestEventDay(ctx context.Context) (time.Time, bool, error) {
+	var oldest sql.NullTime
+	if err := p.db.QueryRowContext(ctx, OldestEvent).Scan(&oldest); err != nil {
+		return time.Time{}, false, fmt.Errorf("error getting oldest event: %w", err)
+	}
+
+	return oldest.Time, oldest.Valid, nil
+}
+
+// LastRollupDay возвращает последний день с итогами, false - если итогов нет
+func (p *Postgres) LastRollupDay(ctx context.Context) (time.Time, bool, error) {
+	var day sql.NullTime
+	if err := p.db.QueryRowContext(ctx, LastRollupDay).Scan(&day); err != nil {
+		return time.Time{}, false, fmt.Errorf("error getting last rollup day: %w", err)
+	}
+
+	return day.Time, day.Valid, nil
+}
+
+// RollupDay пересчитывает итоги за день (UTC) по событиям. Итоги дня заменяются целиком,
+// поэтому пересчёт можно повторять, пока день не закончился
+func (p *Postgres) RollupDay(ctx context.Context, day time.Time) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	date := day.Format(dateLayout)
+	if _, err := tx.ExecContext(ctx, DeleteRollupDay, date); err != nil {
+		return fmt.Errorf("error deleting rollup for %s: %w", date, err)
+	}
+	if _, err := tx.ExecContext(ctx, RollupDay, date, day, day.AddDate(0, 0, 1)); err != nil {
+		return fmt.Errorf("error rolling up %s: %w", date, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing rollup for %s: %w", date, err)
+	}
+
+	return nil
+}
+
+// DeleteRollups удаляет итоги за дни до before
+func (p *Postgres) DeleteRollups(ctx context.Context, before time.Time) error {
+	if _, err := p.db.ExecContext(ctx, DeleteRollups, before.Format(dateLayout)); err != nil {
+		return fmt.Errorf("error deleting rollups: %w", err)
+	}
+
+	return nil
+}
+
+func partitionName(day time.Time) string {
+	return partitionPrefix + day.UTC().Format(partitionLayout)
+}
@@ -0,0 +1,162 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/statistics"
+)
+
+// partitionsAhead на сколько дней вперёд создаются секции журнала проверок
+const partitionsAhead = 7
+
+// ScanLog обслуживает журнал проверок: заранее создаёт дневные секции, подводит дневные итоги
+// и удаляет события и итоги старше срока хранения. Все дни считаются в UTC
+type ScanLog struct {
+	repo            statistics.ScanLogRepo
+	retention       time.Duration
+	rollupRetention time.Duration
+	interval        time.Duration
+	logger          *slog.Logger
+
+	mu      sync.RWMutex
+	lastErr error
+}
+
+// NewScanLog создаёт обслуживание журнала. rollupRetention 0 - итоги хранятся бессрочно
+func NewScanLog(repo statistics.ScanLogRepo, retention, rollupRetention, interval time.Duration, logger *slog.Logger) *ScanLog {
+	return &ScanLog{
+		repo:            repo,
+		retention:       retention,
+		rollupRetention: rollupRetention,
+		interval:        interval,
+		logger:          logger,
+	}
+}
+
+// Run периодически обслуживает журнал до отмены контекста
+func (s *ScanLog) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Maintain(ctx)
+		}
+	}
+}
+
+// Name имя проверки для /api/health
+func (s *ScanLog) Name() string {
+	return "scan_log"
+}
+
+// Check возвращает ошибку последнего обслуживания журнала
+func (s *ScanLog) Check(_ context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.lastErr
+}
+
+// Maintain выполняет одно обслуживание журнала. События удаляются, только если итоги подведены без ошибок
+func (s *ScanLog) Maintain(ctx context.Context) error {
+	today := startOfDay(time.Now())
+
+	err := s.createPartitions(ctx, today)
+	if rollupErr := s.rollup(ctx, today); rollupErr != nil {
+		err = errors.Join(err, rollupErr)
+	} else {
+		err = errors.Join(err, s.expire(ctx, today))
+	}
+
+	s.mu.Lock()
+	s.lastErr = err
+	s.mu.Unlock()
+
+	if err != nil {
+		s.logger.Error("Scan log maintenance failed", slog.Any("error", err))
+	}
+	return err
+}
+
+func (s *ScanLog) createPartitions(ctx context.Context, today time.Time) error {
+	var errs []error
+	for i := 0; i <= partitionsAhead; i++ {
+		if err := s.repo.CreatePartition(ctx, today.AddDate(0, 0, i)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// rollup пересчитывает итоги с последнего дня, за который они есть, по сегодняшний.
+// Последний день пересчитывается заново: при прошлом запуске он мог быть ещё не закончен
+func (s *ScanLog) rollup(ctx context.Context, today time.Time) error {
+	from, ok, err := s.repo.LastRollupDay(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		from, ok, err = s.repo.OldestEventDay(ctx)
+		if err != nil || !ok {
+			return err
+		}
+	}
+
+	for day := startOfDay(from); !day.After(today); day = day.AddDate(0, 0, 1) {
+		if err := s.repo.RollupDay(ctx, day); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// expire удаляет секции старше срока хранения событий и итоги старше срока хранения итогов
+func (s *ScanLog) expire(ctx context.Context, today time.Time) error {
+	cutoff := startOfDay(today.Add(-s.retention))
+
+	days, err := s.repo.Partitions(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, day := range days {
+		if day.Before(cutoff) {
+			if err := s.repo.DropPartition(ctx, day); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if err := s.repo.DeleteDefaultEvents(ctx, cutoff); err != nil {
+		errs = append(errs, err)
+	}
+
+	if s.rollupRetention > 0 {
+		if err := s.repo.DeleteRollups(ctx, startOfDay(today.Add(-s.rollupRetention))); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// rollupBoundary возвращает начало дня, с которого статистика считается по событиям, а не по итогам.
+// Итоги вчерашнего дня окончательно пересчитываются при первом обслуживании после полуночи
+func rollupBoundary(now time.Time) time.Time {
+	return startOfDay(now).AddDate(0, 0, -1)
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
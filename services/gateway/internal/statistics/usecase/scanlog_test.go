@@ -0,0 +1,174 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// scanLogRepo запоминает вызовы обслуживания журнала
+type scanLogRepo struct {
+	partitions   []time.Time
+	oldestEvent  time.Time
+	lastRollup   time.Time
+	rollupErr    error
+	created      []time.Time
+	dropped      []time.Time
+	rolledUp     []time.Time
+	eventsBefore time.Time
+	rollupBefore time.Time
+}
+
+func (r *scanLogRepo) CreatePartition(_ context.Context, day time.Time) error {
+	r.created = append(r.created, day)
+	return nil
+}
+
+func (r *scanLogRepo) Partitions(context.Context) ([]time.Time, error) { return r.partitions, nil }
+
+func (r *scanLogRepo) DropPartition(_ context.Context, day time.Time) error {
+	r.dropped = append(r.dropped, day)
+	return nil
+}
+
+func (r *scanLogRepo) DeleteDefaultEvents(_ context.Context, before time.Time) error {
+	r.eventsBefore = before
+	return nil
+}
+
+func (r *scanLogRepo) OldestEventDay(context.Context) (time.Time, bool, error) {
+	return r.oldestEvent, !r.oldestEvent.IsZero(), nil
+}
+
+func (r *scanLogRepo) LastRollupDay(context.Context) (time.Time, bool, error) {
+	return r.lastRollup, !r.lastRollup.IsZero(), nil
+}
+
+func (r *scanLogRepo) RollupDay(_ context.Context, day time.Time) error {
+	if r.rollupErr != nil {
+		return r.rollupErr
+	}
+	r.rolledUp = append(r.rolledUp, day)
+	return nil
+}
+
+func (r *scanLogRepo) DeleteRollups(_ context.Context, before time.Time) error {
+	r.rollupBefore = before
+	return nil
+}
+
+func newScanLog(repo *scanLogRepo, rollupRetention time.Duration) *ScanLog {
+	return NewScanLog(repo, 30*24*time.Hour, rollupRetention, time.Hour, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+// days возвращает дни today+from .. today+to включительно
+func days(today time.Time, from, to int) []time.Time {
+	var result []time.Time
+	for i := from; i <= to; i++ {
+		result = append(result, today.AddDate(0, 0, i))
+	}
+	return result
+}
+
+func TestMaintainRollup(t *testing.T) {
+	today := startOfDay(time.Now())
+
+	tests := []struct {
+		name string
+		repo scanLogRepo
+		want []time.Time
+	}{
+		{
+			// Последний день итогов пересчитывается заново, он мог быть не закончен
+			name: "from last rollup day",
+			repo: scanLogRepo{lastRollup: today.AddDate(0, 0, -2), oldestEvent: today.AddDate(0, 0, -10)},
+			want: days(today, -2, 0),
+		},
+		{
+			name: "from oldest event without rollups",
+			repo: scanLogRepo{oldestEvent: today.AddDate(0, 0, -1).Add(5 * time.Hour)},
+			want: days(today, -1, 0),
+		},
+		{
+			name: "empty log",
+			repo: scanLogRepo{},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := newScanLog(&tt.repo, 0).Maintain(context.Background()); err != nil {
+				t.Fatalf("Maintain: %v", err)
+			}
+			if !reflect.DeepEqual(tt.repo.rolledUp, tt.want) {
+				t.Errorf("rolled up %v, want %v", tt.repo.rolledUp, tt.want)
+			}
+			if want := days(today, 0, partitionsAhead); !reflect.DeepEqual(tt.repo.created, want) {
+				t.Errorf("created partitions %v, want %v", tt.repo.created, want)
+			}
+		})
+	}
+}
+
+func TestMaintainRetention(t *testing.T) {
+	today := startOfDay(time.Now())
+	cutoff := today.AddDate(0, 0, -30)
+
+	tests := []struct {
+		name            string
+		rollupRetention time.Duration
+		wantRollups     time.Time
+	}{
+		{name: "rollups kept forever", rollupRetention: 0},
+		{name: "rollups expire", rollupRetention: 365 * 24 * time.Hour, wantRollups: today.AddDate(0, 0, -365)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &scanLogRepo{
+				lastRollup: today,
+				partitions: []time.Time{cutoff.AddDate(0, 0, -10), cutoff.AddDate(0, 0, -1), cutoff, today},
+			}
+
+			if err := newScanLog(repo, tt.rollupRetention).Maintain(context.Background()); err != nil {
+				t.Fatalf("Maintain: %v", err)
+			}
+
+			if want := []time.Time{cutoff.AddDate(0, 0, -10), cutoff.AddDate(0, 0, -1)}; !reflect.DeepEqual(repo.dropped, want) {
+				t.Errorf("dropped partitions %v, want %v", repo.dropped, want)
+			}
+			if !repo.eventsBefore.Equal(cutoff) {
+				t.Errorf("default partition cleaned before %v, want %v", repo.eventsBefore, cutoff)
+			}
+			if !repo.rollupBefore.Equal(tt.wantRollups) {
+				t.Errorf("rollups deleted before %v, want %v", repo.rollupBefore, tt.wantRollups)
+			}
+		})
+	}
+}
+
+// Пока итоги не подведены, события не удаляются, иначе они пропадут из статистики
+func TestMaintainKeepsEventsOnRollupError(t *testing.T) {
+	today := startOfDay(time.Now())
+	repo := &scanLogRepo{
+		lastRollup: today,
+		partitions: []time.Time{today.AddDate(0, 0, -60)},
+		rollupErr:  errors.New("connection reset"),
+	}
+	scanLog := newScanLog(repo, 0)
+
+	if err := scanLog.Maintain(context.Background()); !errors.Is(err, repo.rollupErr) {
+		t.Fatalf("Maintain: got %v, want rollup error", err)
+	}
+	if len(repo.dropped) != 0 || !repo.eventsBefore.IsZero() {
+		t.Errorf("events expired despite rollup error: dropped %v", repo.dropped)
+	}
+	if err := scanLog.Check(context.Background()); err == nil {
+		t.Error("Check: expected error")
+	}
+}
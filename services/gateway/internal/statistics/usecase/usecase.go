@@ -45,8 +45,9 @@ func (uc *Usecase) GetTopLinksByUserAndPeriod(ctx context.Context, userID *int,
 	return stats, nil
 }
 
+// GetTopLinksByZone считает обращения за всё время: по дневным итогам и по событиям дней, итоги которых ещё могут меняться
 func (uc *Usecase) GetTopLinksByZone(ctx context.Context, zone string, limit int) ([]models.LinkStat, error) {
-	stats, err := uc.repo.TopLinksByZone(ctx, zone, limit)
+	stats, err := uc.repo.TopLinksByZone(ctx, zone, rollupBoundary(time.Now()), limit)
 	if err != nil {
 		uc.logger.Error("Error getting top links by zone", slog.Any("error", err))
 		return nil, fmt.Errorf("error getting top links by zone: %w", err)
@@ -0,0 +1,306 @@
+package http
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/gorilla/mux"
+
+	"github.com/CodeMaster482/minions-server/common"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/watchlist"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/watchlist/models"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/watchlist/usecase"
+)
+
+const (
+	DefaultLimit = 50  // Размер страницы ленты оповещений по умолчанию
+	MaxLimit     = 200 // Максимальный размер страницы ленты оповещений
+
+	MaxBodySize = 16 << 10 // Максимальный размер тела запроса
+
+	BadRequestMsg          = "Bad Request: Incorrect query."
+	InvalidInputMsg        = "Bad Request: Unsupported indicator."
+	InvalidIntervalMsg     = "Bad Request: Check interval is out of range."
+	InvalidCursorMsg       = "Bad Request: Invalid cursor."
+	UnauthorizedMsg        = "Unauthorized"
+	NotFoundMsg            = "Not Found: Watch not found."
+	AlreadyWatchedMsg      = "Conflict: Indicator is already in watchlist."
+	TooManyWatchesMsg      = "Conflict: Watchlist limit reached."
+	InternalServerErrorMsg = "Internal Server Error"
+)
+
+type Handler struct {
+	usecase        watchlist.Usecase
+	sessionManager *scs.SessionManager
+	logger         *slog.Logger
+}
+
+func New(uc watchlist.Usecase, sessionManager *scs.SessionManager, logger *slog.Logger) *Handler {
+	return &Handler{
+		usecase:        uc,
+		sessionManager: sessionManager,
+		logger:         logger,
+	}
+}
+
+// List
+// @Summary Список отслеживания
+// @Description Возвращает индикаторы, которые отслеживает текущий пользователь, с вердиктом последней проверки.
+// @ID watchlist-list
+// @Tags Watchlist
+// @Produce json
+// @Success 200 {array} models.Watch "Отслеживаемые индикаторы"
+// @Failure 401 {object} common.ErrorResponse "Unauthorized"
+// @Failure 500 {object} common.ErrorResponse "Internal Server Error"
+// @Router /api/watchlist [get]
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	logger := h.requestLogger(r)
+
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	watches, err := h.usecase.List(r.Context(), userID)
+	if err != nil {
+		h.respondError(w, logger, err)
+		return
+	}
+
+	common.RespondWithJSON(w, http.StatusOK, watches)
+}
+
+// Create
+// @Summary Добавление индикатора в список отслеживания
+// @Description Индикатор (веб-адрес, IP, домен или хеш) перепроверяется в Kaspersky API раз в interval_minutes в обход кэша.
+// @Description Первая проверка выполняется в ближайшую минуту и запоминает вердикт, при следующих смена зоны
+// @Description или категорий попадает в ленту оповещений /api/watchlist/alerts.
+// @ID watchlist-create
+// @Tags Watchlist
+// @Accept json
+// @Produce json
+// @Param request body models.WatchRequest true "Индикатор, интервал проверки и заметка"
+// @Success 201 {object} models.Watch "Индикатор добавлен"
+// @Failure 400 {object} common.ErrorResponse "Bad Request: Unsupported indicator."
+// @Failure 401 {object} common.ErrorResponse "Unauthorized"
+// @Failure 409 {object} common.ErrorResponse "Conflict: Indicator is already in watchlist."
+// @Failure 500 {object} common.ErrorResponse "Internal Server Error"
+// @Router /api/watchlist [post]
+func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
+	logger := h.requestLogger(r)
+
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxBodySize)
+
+	var req models.WatchRequest
+	if err := common.DecodeJSONBody(w, r, &req); err != nil {
+		common.RespondWithError(w, http.StatusBadRequest, err.Error())
+		logger.Warn(BadRequestMsg, slog.Any("error", err))
+		return
+	}
+
+	watch, err := h.usecase.Create(r.Context(), userID, req)
+	if err != nil {
+		h.respondError(w, logger, err)
+		return
+	}
+
+	common.RespondWithJSON(w, http.StatusCreated, watch)
+}
+
+// Get
+// @Summary Запись списка отслеживания
+// @ID watchlist-get
+// @Tags Watchlist
+// @Produce json
+// @Param id path int true "Идентификатор записи"
+// @Success 200 {object} models.Watch "Запись списка отслеживания"
+// @Failure 401 {object} common.ErrorResponse "Unauthorized"
+// @Failure 404 {object} common.ErrorResponse "Not Found: Watch not found."
+// @Failure 500 {object} common.ErrorResponse "Internal Server Error"
+// @Router /api/watchlist/{id} [get]
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	logger := h.requestLogger(r)
+
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	watch, err := h.usecase.Get(r.Context(), userID, watchID(r))
+	if err != nil {
+		h.respondError(w, logger, err)
+		return
+	}
+
+	common.RespondWithJSON(w, http.StatusOK, watch)
+}
+
+// Update
+// @Summary Изменение записи списка отслеживания
+// @Description Меняет интервал проверки и заметку. Новый интервал отсчитывается от последней проверки.
+// @ID watchlist-update
+// @Tags Watchlist
+// @Accept json
+// @Produce json
+// @Param id path int true "Идентификатор записи"
+// @Param request body models.WatchUpdate true "Новые значения, пропущенные поля не меняются"
+// @Success 200 {object} models.Watch "Запись изменена"
+// @Failure 400 {object} common.ErrorResponse "Bad Request: Check interval is out of range."
+// @Failure 401 {object} common.ErrorResponse "Unauthorized"
+// @Failure 404 {object} common.ErrorResponse "Not Found: Watch not found."
+// @Failure 500 {object} common.ErrorResponse "Internal Server Error"
+// @Router /api/watchlist/{id} [patch]
+func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
+	logger := h.requestLogger(r)
+
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxBodySize)
+
+	var upd models.WatchUpdate
+	if err := common.DecodeJSONBody(w, r, &upd); err != nil {
+		common.RespondWithError(w, http.StatusBadRequest, err.Error())
+		logger.Warn(BadRequestMsg, slog.Any("error", err))
+		return
+	}
+
+	watch, err := h.usecase.Update(r.Context(), userID, watchID(r), upd)
+	if err != nil {
+		h.respondError(w, logger, err)
+		return
+	}
+
+	common.RespondWithJSON(w, http.StatusOK, watch)
+}
+
+// Delete
+// @Summary Удаление индикатора из списка отслеживания
+// @Description Оповещения по индикатору остаются в ленте.
+// @ID watchlist-delete
+// @Tags Watchlist
+// @Param id path int true "Идентификатор записи"
+// @Success 204 "Запись удалена"
+// @Failure 401 {object} common.ErrorResponse "Unauthorized"
+// @Failure 404 {object} common.ErrorResponse "Not Found: Watch not found."
+// @Failure 500 {object} common.ErrorResponse "Internal Server Error"
+// @Router /api/watchlist/{id} [delete]
+func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
+	logger := h.requestLogger(r)
+
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.usecase.Delete(r.Context(), userID, watchID(r)); err != nil {
+		h.respondError(w, logger, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Alerts
+// @Summary Лента оповещений
+// @Description Возвращает оповещения о смене зоны или категорий отслеживаемых индикаторов, новые первыми.
+// @Description Для следующей страницы передайте cursor из NextCursor.
+// @ID watchlist-alerts
+// @Tags Watchlist
+// @Produce json
+// @Param limit query int false "Размер страницы (по умолчанию 50, не больше 200)"
+// @Param cursor query string false "Курсор следующей страницы"
+// @Success 200 {object} models.AlertPage "Страница оповещений"
+// @Failure 400 {object} common.ErrorResponse "Bad Request: Incorrect query."
+// @Failure 401 {object} common.ErrorResponse "Unauthorized"
+// @Failure 500 {object} common.ErrorResponse "Internal Server Error"
+// @Router /api/watchlist/alerts [get]
+func (h *Handler) Alerts(w http.ResponseWriter, r *http.Request) {
+	logger := h.requestLogger(r)
+
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	limit := DefaultLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			common.RespondWithError(w, http.StatusBadRequest, BadRequestMsg)
+			logger.Warn(BadRequestMsg, slog.String("limit", raw))
+			return
+		}
+		limit = min(n, MaxLimit)
+	}
+
+	page, err := h.usecase.Alerts(r.Context(), userID, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		h.respondError(w, logger, err)
+		return
+	}
+
+	common.RespondWithJSON(w, http.StatusOK, page)
+}
+
+// respondError отвечает клиенту кодом, соответствующим ошибке usecase
+func (h *Handler) respondError(w http.ResponseWriter, logger *slog.Logger, err error) {
+	switch {
+	case errors.Is(err, usecase.ErrInvalidInterval):
+		common.RespondWithError(w, http.StatusBadRequest, InvalidIntervalMsg)
+		logger.Warn(InvalidIntervalMsg, slog.Any("error", err))
+	case errors.Is(err, usecase.ErrInvalidInput):
+		common.RespondWithError(w, http.StatusBadRequest, InvalidInputMsg)
+		logger.Warn(InvalidInputMsg, slog.Any("error", err))
+	case errors.Is(err, usecase.ErrInvalidCursor):
+		common.RespondWithError(w, http.StatusBadRequest, InvalidCursorMsg)
+		logger.Warn(InvalidCursorMsg)
+	case errors.Is(err, usecase.ErrNotFound):
+		common.RespondWithError(w, http.StatusNotFound, NotFoundMsg)
+		logger.Warn(NotFoundMsg)
+	case errors.Is(err, usecase.ErrAlreadyWatched):
+		common.RespondWithError(w, http.StatusConflict, AlreadyWatchedMsg)
+		logger.Warn(AlreadyWatchedMsg)
+	case errors.Is(err, usecase.ErrTooManyWatches):
+		common.RespondWithError(w, http.StatusConflict, TooManyWatchesMsg)
+		logger.Warn(TooManyWatchesMsg)
+	default:
+		common.RespondWithError(w, http.StatusInternalServerError, InternalServerErrorMsg)
+		logger.Error(InternalServerErrorMsg, slog.Any("error", err))
+	}
+}
+
+func (h *Handler) requestLogger(r *http.Request) *slog.Logger {
+	return h.logger.With(
+		slog.String("method", r.Method),
+		slog.String("url", r.URL.String()),
+		slog.String("remote_addr", r.RemoteAddr),
+	)
+}
+
+// userID возвращает идентификатор пользователя из сессии и отвечает 401, если его нет
+func (h *Handler) userID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	userID := h.sessionManager.GetInt(r.Context(), "user_id")
+	if userID == 0 {
+		common.RespondWithError(w, http.StatusUnauthorized, UnauthorizedMsg)
+		return 0, false
+	}
+
+	return userID, true
+}
+
+// watchID возвращает идентификатор записи из пути, маршрут пропускает только цифры
+func watchID(r *http.Request) int64 {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	return id
+}
@@ -0,0 +1,49 @@
+package watchlist
+
+import (
+	"context"
+	"time"
+
+	scanModels "github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/watchlist/models"
+)
+
+type Usecase interface {
+	List(ctx context.Context, userID int) ([]models.Watch, error)
+	Get(ctx context.Context, userID int, id int64) (*models.Watch, error)
+	Create(ctx context.Context, userID int, req models.WatchRequest) (*models.Watch, error)
+	Update(ctx context.Context, userID int, id int64, upd models.WatchUpdate) (*models.Watch, error)
+	Delete(ctx context.Context, userID int, id int64) error
+	Alerts(ctx context.Context, userID int, cursor string, limit int) (*models.AlertPage, error)
+}
+
+// Repo хранит списки отслеживания и оповещения. Методы, возвращающие одну запись, возвращают nil, nil,
+// если записи нет или она принадлежит другому пользователю. CreateWatch возвращает nil, nil,
+// если пользователь уже отслеживает этот индикатор
+type Repo interface {
+	ListWatches(ctx context.Context, userID int) ([]models.Watch, error)
+	GetWatch(ctx context.Context, userID int, id int64) (*models.Watch, error)
+	CountWatches(ctx context.Context, userID int) (int, error)
+	CreateWatch(ctx context.Context, w models.Watch) (*models.Watch, error)
+	UpdateWatch(ctx context.Context, w models.Watch) (*models.Watch, error)
+	DeleteWatch(ctx context.Context, userID int, id int64) (bool, error)
+
+	DueIndicators(ctx context.Context, now time.Time, limit int) ([]models.Indicator, error)
+	DueWatches(ctx context.Context, inputType, request string, now time.Time) ([]models.Watch, error)
+	SaveCheck(ctx context.Context, w models.Watch, alert *models.Alert) error
+	SaveCheckError(ctx context.Context, inputType, request, checkErr string, now time.Time) error
+
+	ListAlerts(ctx context.Context, userID int, beforeID int64, limit int) ([]models.Alert, error)
+}
+
+// Scanner повторно проверяет индикатор в Kaspersky API в обход кэша
+type Scanner interface {
+	IndicatorKey(input string) (string, string, error)
+	Rescan(ctx context.Context, inputType, requestParam, apiKey string) (*scanModels.Verdict, error)
+	NotifyRedVerdict(ctx context.Context, zone, inputType, requestParam string, userID int)
+}
+
+// Notifier рассылает оповещения подписчикам вебхуков
+type Notifier interface {
+	Notify(ctx context.Context, event string, userID int, data any) error
+}
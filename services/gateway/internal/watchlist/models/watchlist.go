@@ -0,0 +1,113 @@
+package models
+
+import "time"
+
+// EventWatchChanged событие вебхука о смене вердикта отслеживаемого индикатора, данные - Alert
+const EventWatchChanged = "watchlist.verdict_changed"
+
+// Watch индикатор, который пользователь отслеживает. Kaspersky API опрашивается заново
+// раз в IntervalMinutes в обход кэша, при смене зоны или категорий создаётся оповещение
+type Watch struct {
+	// Идентификатор записи
+	ID int64 `json:"ID" example:"12"`
+
+	// Тип индикатора: ip, domain, url, hash
+	InputType string `json:"InputType" example:"domain"`
+
+	// Индикатор в том виде, в котором он проверяется
+	Request string `json:"Request" example:"partner.example.com"`
+
+	// Заметка пользователя
+	Note string `json:"Note,omitempty" example:"Партнёрский домен"`
+
+	// Как часто проверять индикатор, в минутах
+	IntervalMinutes int `json:"IntervalMinutes" example:"1440"`
+
+	// Зона по последней проверке, пусто до первой проверки
+	Zone string `json:"Zone,omitempty" example:"Green"`
+
+	// Категории по последней проверке
+	Categories []string `json:"Categories" example:"[]"`
+
+	// Время последней проверки
+	LastCheckedAt *time.Time `json:"LastCheckedAt,omitempty" example:"2024-01-02T15:04:05Z"`
+
+	// Ошибка последней проверки
+	LastError string `json:"LastError,omitempty" example:"Not Found: Lookup results not found."`
+
+	// Когда индикатор будет проверен в следующий раз
+	NextCheckAt time.Time `json:"NextCheckAt" example:"2024-01-03T15:04:05Z"`
+
+	// Время добавления
+	CreatedAt time.Time `json:"CreatedAt" example:"2024-01-01T10:00:00Z"`
+
+	UserID int `json:"-"`
+}
+
+// Indicator индикатор, который отслеживает хотя бы один пользователь
+type Indicator struct {
+	InputType string
+	Request   string
+}
+
+// WatchRequest запрос на добавление индикатора в список отслеживания
+type WatchRequest struct {
+	// Веб-адрес, IP, домен или хеш MD5/SHA1/SHA256
+	Request string `json:"request" example:"partner.example.com"`
+
+	// Как часто проверять индикатор, в минутах; 0 - интервал по умолчанию
+	IntervalMinutes int `json:"interval_minutes,omitempty" example:"1440"`
+
+	// Заметка
+	Note string `json:"note,omitempty" example:"Партнёрский домен"`
+}
+
+// WatchUpdate изменение записи списка отслеживания. Пустые поля не меняются
+type WatchUpdate struct {
+	// Как часто проверять индикатор, в минутах
+	IntervalMinutes *int `json:"interval_minutes,omitempty" example:"360"`
+
+	// Заметка
+	Note *string `json:"note,omitempty" example:"Проверить после разбора инцидента"`
+}
+
+// Alert оповещение о смене вердикта отслеживаемого индикатора
+type Alert struct {
+	// Идентификатор оповещения
+	ID int64 `json:"ID" example:"301"`
+
+	// Идентификатор записи списка отслеживания, 0 - если запись уже удалена
+	WatchID int64 `json:"WatchID" example:"12"`
+
+	// Тип индикатора
+	InputType string `json:"InputType" example:"domain"`
+
+	// Индикатор
+	Request string `json:"Request" example:"partner.example.com"`
+
+	// Зона до изменения
+	PreviousZone string `json:"PreviousZone" example:"Green"`
+
+	// Зона после изменения
+	Zone string `json:"Zone" example:"Red"`
+
+	// Категории до изменения
+	PreviousCategories []string `json:"PreviousCategories" example:"[]"`
+
+	// Категории после изменения
+	Categories []string `json:"Categories" example:"[\"Phishing URL\"]"`
+
+	// Время обнаружения изменения
+	CreatedAt time.Time `json:"CreatedAt" example:"2024-01-03T15:04:05Z"`
+
+	UserID int `json:"-"`
+}
+
+// AlertPage страница ленты оповещений
+type AlertPage struct {
+	// Оповещения, новые первыми
+	Items []Alert `json:"Items"`
+
+	// Курсор следующей страницы, пустой на последней странице
+	NextCursor string `json:"NextCursor,omitempty" example:"MzAx"`
+}
@@ -0,0 +1,310 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/watchlist/models"
+)
+
+var (
+	watchColumns = `id, user_id, input_type, request, note, interval_minutes, COALESCE(zone, ''), categories,
+            last_checked_at, last_error, next_check_at, created_at`
+	alertColumns = `id, COALESCE(watch_id, 0), user_id, input_type, request, previous_zone, zone,
+            previous_categories, categories, created_at`
+
+	ListWatches = `
+        SELECT ` + watchColumns + `
+        FROM watchlist
+        WHERE user_id = $1
+        ORDER BY id
+    `
+	GetWatch = `
+        SELECT ` + watchColumns + `
+        FROM watchlist
+        WHERE id = $1 AND user_id = $2
+    `
+	CountWatches = `
+        SELECT COUNT(*) FROM watchlist WHERE user_id = $1
+    `
+	CreateWatch = `
+        INSERT INTO watchlist (user_id, input_type, request, note, interval_minutes)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (user_id, input_type, request) DO NOTHING
+        RETURNING ` + watchColumns + `
+    `
+	UpdateWatch = `
+        UPDATE watchlist
+        SET note = $3, interval_minutes = $4, next_check_at = $5
+        WHERE id = $1 AND user_id = $2
+        RETURNING ` + watchColumns + `
+    `
+	DeleteWatch = `
+        DELETE FROM watchlist WHERE id = $1 AND user_id = $2
+    `
+	// Индикатор, который отслеживают несколько пользователей, проверяется один раз за всех
+	DueIndicators = `
+        SELECT input_type, request
+        FROM watchlist
+        WHERE next_check_at <= $1
+        GROUP BY input_type, request
+        ORDER BY MIN(next_check_at)
+        LIMIT $2
+    `
+	DueWatches = `
+        SELECT ` + watchColumns + `
+        FROM watchlist
+        WHERE input_type = $1 AND request = $2 AND next_check_at <= $3
+    `
+	SaveCheck = `
+        UPDATE watchlist
+        SET zone = $2, categories = $3, last_checked_at = $4, next_check_at = $5, last_error = ''
+        WHERE id = $1
+    `
+	SaveAlert = `
+        INSERT INTO watch_alerts (watch_id, user_id, input_type, request, previous_zone, zone, previous_categories, categories)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id, created_at
+    `
+	// Ошибка не меняет последний известный вердикт, следующая попытка - через обычный интервал
+	SaveCheckError = `
+        UPDATE watchlist
+        SET last_error = $3, last_checked_at = $4, next_check_at = $4 + make_interval(mins => interval_minutes)
+        WHERE input_type = $1 AND request = $2 AND next_check_at <= $4
+    `
+	ListAlerts = `
+        SELECT ` + alertColumns + `
+        FROM watch_alerts
+        WHERE user_id = $1
+          AND ($2 = 0 OR id < $2)
+        ORDER BY id DESC
+        LIMIT $3
+    `
+)
+
+type Postgres struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func New(db *sql.DB, logger *slog.Logger) *Postgres {
+	return &Postgres{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (p *Postgres) ListWatches(ctx context.Context, userID int) ([]models.Watch, error) {
+	return p.queryWatches(ctx, ListWatches, userID)
+}
+
+func (p *Postgres) GetWatch(ctx context.Context, userID int, id int64) (*models.Watch, error) {
+	return p.queryWatch(ctx, "getting watch", GetWatch, id, userID)
+}
+
+func (p *Postgres) CountWatches(ctx context.Context, userID int) (int, error) {
+	var count int
+	if err := p.db.QueryRowContext(ctx, CountWatches, userID).Scan(&count); err != nil {
+		p.logger.Error("Error counting watches", slog.Any("error", err))
+		return 0, fmt.Errorf("error counting watches: %w", err)
+	}
+
+	return count, nil
+}
+
+func (p *Postgres) CreateWatch(ctx context.Context, w models.Watch) (*models.Watch, error) {
+	return p.queryWatch(ctx, "creating watch", CreateWatch, w.UserID, w.InputType, w.Request, w.Note, w.IntervalMinutes)
+}
+
+func (p *Postgres) UpdateWatch(ctx context.Context, w models.Watch) (*models.Watch, error) {
+	return p.queryWatch(ctx, "updating watch", UpdateWatch, w.ID, w.UserID, w.Note, w.IntervalMinutes, w.NextCheckAt)
+}
+
+func (p *Postgres) DeleteWatch(ctx context.Context, userID int, id int64) (bool, error) {
+	res, err := p.db.ExecContext(ctx, DeleteWatch, id, userID)
+	if err != nil {
+		p.logger.Error("Error deleting watch", slog.Any("error", err))
+		return false, fmt.Errorf("error deleting watch: %w", err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return deleted > 0, nil
+}
+
+func (p *Postgres) DueIndicators(ctx context.Context, now time.Time, limit int) ([]models.Indicator, error) {
+	rows, err := p.db.QueryContext(ctx, DueIndicators, now, limit)
+	if err != nil {
+		p.logger.Error("Error executing due indicators query", slog.Any("error", err))
+		return nil, fmt.Errorf("error executing due indicators query: %w", err)
+	}
+	defer rows.Close()
+
+	var indicators []models.Indicator
+	for rows.Next() {
+		var i models.Indicator
+		if err := rows.Scan(&i.InputType, &i.Request); err != nil {
+			p.logger.Error("Error scanning row", slog.Any("error", err))
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		indicators = append(indicators, i)
+	}
+
+	if err := rows.Err(); err != nil {
+		p.logger.Error("Rows iteration error", slog.Any("error", err))
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return indicators, nil
+}
+
+func (p *Postgres) DueWatches(ctx context.Context, inputType, request string, now time.Time) ([]models.Watch, error) {
+	return p.queryWatches(ctx, DueWatches, inputType, request, now)
+}
+
+// SaveCheck сохраняет результат проверки и оповещение в одной транзакции. ID и время созданного
+// оповещения записываются в alert
+func (p *Postgres) SaveCheck(ctx context.Context, w models.Watch, alert *models.Alert) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		p.logger.Error("Failed to begin transaction", slog.Any("error", err))
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, SaveCheck, w.ID, w.Zone, pq.Array(w.Categories), w.LastCheckedAt, w.NextCheckAt)
+	if err != nil {
+		p.logger.Error("Error saving watch check", slog.Any("error", err))
+		return fmt.Errorf("error saving watch check: %w", err)
+	}
+
+	if alert != nil {
+		err = tx.QueryRowContext(ctx, SaveAlert,
+			alert.WatchID, alert.UserID, alert.InputType, alert.Request,
+			alert.PreviousZone, alert.Zone, pq.Array(alert.PreviousCategories), pq.Array(alert.Categories),
+		).Scan(&alert.ID, &alert.CreatedAt)
+		if err != nil {
+			p.logger.Error("Error saving watch alert", slog.Any("error", err))
+			return fmt.Errorf("error saving watch alert: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		p.logger.Error("Failed to commit transaction", slog.Any("error", err))
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) SaveCheckError(ctx context.Context, inputType, request, checkErr string, now time.Time) error {
+	if _, err := p.db.ExecContext(ctx, SaveCheckError, inputType, request, checkErr, now); err != nil {
+		p.logger.Error("Error saving watch check error", slog.Any("error", err))
+		return fmt.Errorf("error saving watch check error: %w", err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) ListAlerts(ctx context.Context, userID int, beforeID int64, limit int) ([]models.Alert, error) {
+	rows, err := p.db.QueryContext(ctx, ListAlerts, userID, beforeID, limit)
+	if err != nil {
+		p.logger.Error("Error executing alerts query", slog.Any("error", err))
+		return nil, fmt.Errorf("error executing alerts query: %w", err)
+	}
+	defer rows.Close()
+
+	alerts := []models.Alert{}
+	for rows.Next() {
+		var a models.Alert
+		err := rows.Scan(&a.ID, &a.WatchID, &a.UserID, &a.InputType, &a.Request, &a.PreviousZone, &a.Zone,
+			pq.Array(&a.PreviousCategories), pq.Array(&a.Categories), &a.CreatedAt)
+		if err != nil {
+			p.logger.Error("Error scanning row", slog.Any("error", err))
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		if a.PreviousCategories == nil {
+			a.PreviousCategories = []string{}
+		}
+		if a.Categories == nil {
+			a.Categories = []string{}
+		}
+		alerts = append(alerts, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		p.logger.Error("Rows iteration error", slog.Any("error", err))
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return alerts, nil
+}
+
+func (p *Postgres) queryWatch(ctx context.Context, action, query string, args ...any) (*models.Watch, error) {
+	w, err := scanWatch(p.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		p.logger.Error("Error "+action, slog.Any("error", err))
+		return nil, fmt.Errorf("error %s: %w", action, err)
+	}
+
+	return w, nil
+}
+
+func (p *Postgres) queryWatches(ctx context.Context, query string, args ...any) ([]models.Watch, error) {
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		p.logger.Error("Error executing watchlist query", slog.Any("error", err))
+		return nil, fmt.Errorf("error executing watchlist query: %w", err)
+	}
+	defer rows.Close()
+
+	watches := []models.Watch{}
+	for rows.Next() {
+		w, err := scanWatch(rows)
+		if err != nil {
+			p.logger.Error("Error scanning row", slog.Any("error", err))
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		watches = append(watches, *w)
+	}
+
+	if err := rows.Err(); err != nil {
+		p.logger.Error("Rows iteration error", slog.Any("error", err))
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return watches, nil
+}
+
+// scanWatch читает строку с колонками watchColumns
+func scanWatch(row interface{ Scan(...any) error }) (*models.Watch, error) {
+	var (
+		w           models.Watch
+		lastChecked sql.NullTime
+	)
+	err := row.Scan(&w.ID, &w.UserID, &w.InputType, &w.Request, &w.Note, &w.IntervalMinutes, &w.Zone,
+		pq.Array(&w.Categories), &lastChecked, &w.LastError, &w.NextCheckAt, &w.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if lastChecked.Valid {
+		w.LastCheckedAt = &lastChecked.Time
+	}
+	if w.Categories == nil {
+		w.Categories = []string{}
+	}
+
+	return &w, nil
+}
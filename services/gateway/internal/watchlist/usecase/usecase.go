@@ -0,0 +1,383 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	scanModels "github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+	scanUsecase "github.com/CodeMaster482/minions-server/services/gateway/internal/scan/usecase"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/watchlist"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/watchlist/models"
+	"github.com/CodeMaster482/minions-server/services/gateway/pkg/cursor"
+)
+
+var (
+	ErrNotFound        = errors.New("watch not found")
+	ErrAlreadyWatched  = errors.New("indicator is already in watchlist")
+	ErrInvalidInput    = errors.New("invalid indicator")
+	ErrInvalidInterval = errors.New("invalid check interval")
+	ErrTooManyWatches  = errors.New("watchlist limit reached")
+	ErrInvalidCursor   = errors.New("invalid alerts cursor")
+)
+
+const (
+	tickInterval  = time.Minute // Как часто планировщик ищет индикаторы, которые пора проверить
+	maxNoteLength = 1024        // Максимальная длина заметки в байтах
+)
+
+// Config параметры списков отслеживания
+type Config struct {
+	APIKey          string        // ключ Kaspersky API
+	DefaultInterval time.Duration // интервал проверки, если пользователь его не указал
+	MinInterval     time.Duration
+	MaxInterval     time.Duration
+	MaxWatches      int           // сколько индикаторов может отслеживать один пользователь
+	ChecksPerHour   int           // сколько запросов к Kaspersky API планировщик делает за час
+	QuotaBackoff    time.Duration // пауза после ответа Kaspersky API об исчерпанной квоте
+}
+
+type Usecase struct {
+	repo     watchlist.Repo
+	scanner  watchlist.Scanner
+	notifier watchlist.Notifier
+	cfg      Config
+	logger   *slog.Logger
+
+	// Состояние планировщика меняется только в Run, мьютекс нужен для Check
+	mu          sync.Mutex
+	tokens      float64
+	lastRefill  time.Time
+	pausedUntil time.Time
+}
+
+func New(repo watchlist.Repo, scanner watchlist.Scanner, notifier watchlist.Notifier, cfg Config, logger *slog.Logger) *Usecase {
+	return &Usecase{
+		repo:     repo,
+		scanner:  scanner,
+		notifier: notifier,
+		cfg:      cfg,
+		logger:   logger,
+	}
+}
+
+// List возвращает список отслеживания пользователя
+func (uc *Usecase) List(ctx context.Context, userID int) ([]models.Watch, error) {
+	return uc.repo.ListWatches(ctx, userID)
+}
+
+// Get возвращает запись списка отслеживания пользователя
+func (uc *Usecase) Get(ctx context.Context, userID int, id int64) (*models.Watch, error) {
+	w, err := uc.repo.GetWatch(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if w == nil {
+		return nil, ErrNotFound
+	}
+
+	return w, nil
+}
+
+// Create добавляет индикатор в список отслеживания. Индикатор приводится к тому же виду, что и при
+// проверке, и проверяется планировщиком при ближайшем запуске: первая проверка только запоминает вердикт
+func (uc *Usecase) Create(ctx context.Context, userID int, req models.WatchRequest) (*models.Watch, error) {
+	inputType, request, err := uc.scanner.IndicatorKey(req.Request)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidInput, err)
+	}
+
+	interval := req.IntervalMinutes
+	if interval == 0 {
+		interval = int(uc.cfg.DefaultInterval / time.Minute)
+	}
+	if err := uc.validate(interval, req.Note); err != nil {
+		return nil, err
+	}
+
+	count, err := uc.repo.CountWatches(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= uc.cfg.MaxWatches {
+		return nil, ErrTooManyWatches
+	}
+
+	w, err := uc.repo.CreateWatch(ctx, models.Watch{
+		UserID:          userID,
+		InputType:       inputType,
+		Request:         request,
+		Note:            strings.TrimSpace(req.Note),
+		IntervalMinutes: interval,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if w == nil {
+		return nil, ErrAlreadyWatched
+	}
+
+	uc.logger.Info("Indicator added to watchlist",
+		slog.Int("user_id", userID),
+		slog.String("input_type", inputType),
+		slog.String("request", request),
+	)
+
+	return w, nil
+}
+
+// Update меняет интервал и заметку. Новый интервал отсчитывается от последней проверки
+func (uc *Usecase) Update(ctx context.Context, userID int, id int64, upd models.WatchUpdate) (*models.Watch, error) {
+	w, err := uc.Get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if upd.Note != nil {
+		w.Note = strings.TrimSpace(*upd.Note)
+	}
+	if upd.IntervalMinutes != nil && *upd.IntervalMinutes != w.IntervalMinutes {
+		w.IntervalMinutes = *upd.IntervalMinutes
+		if w.LastCheckedAt != nil {
+			w.NextCheckAt = w.LastCheckedAt.Add(time.Duration(w.IntervalMinutes) * time.Minute)
+		}
+	}
+	if err := uc.validate(w.IntervalMinutes, w.Note); err != nil {
+		return nil, err
+	}
+
+	updated, err := uc.repo.UpdateWatch(ctx, *w)
+	if err != nil {
+		return nil, err
+	}
+	if updated == nil {
+		return nil, ErrNotFound
+	}
+
+	return updated, nil
+}
+
+// Delete удаляет индикатор из списка отслеживания. Оповещения по нему остаются в ленте
+func (uc *Usecase) Delete(ctx context.Context, userID int, id int64) error {
+	deleted, err := uc.repo.DeleteWatch(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Alerts возвращает страницу ленты оповещений пользователя, новые первыми
+func (uc *Usecase) Alerts(ctx context.Context, userID int, pageCursor string, limit int) (*models.AlertPage, error) {
+	beforeID, err := cursor.Decode(pageCursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	// Одна лишняя запись показывает, есть ли следующая страница
+	alerts, err := uc.repo.ListAlerts(ctx, userID, beforeID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.AlertPage{Items: alerts}
+	if len(alerts) > limit {
+		page.Items = alerts[:limit]
+		page.NextCursor = cursor.Encode(page.Items[limit-1].ID)
+	}
+
+	return page, nil
+}
+
+// Name имя проверки для /api/health
+func (uc *Usecase) Name() string {
+	return "watchlist"
+}
+
+// Check сообщает, что проверки приостановлены из-за исчерпанной квоты Kaspersky API
+func (uc *Usecase) Check(_ context.Context) error {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	if time.Now().Before(uc.pausedUntil) {
+		return fmt.Errorf("kaspersky api quota exceeded, checks paused until %s", uc.pausedUntil.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// Run проверяет индикаторы по расписанию до отмены контекста
+func (uc *Usecase) Run(ctx context.Context) {
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			uc.checkDue(ctx)
+		}
+	}
+}
+
+// checkDue проверяет индикаторы, которым пора, не превышая ChecksPerHour. Индикатор, который отслеживают
+// несколько пользователей, проверяется одним запросом
+func (uc *Usecase) checkDue(ctx context.Context) {
+	now := time.Now()
+	budget := uc.budget(now)
+	if budget == 0 {
+		return
+	}
+
+	indicators, err := uc.repo.DueIndicators(ctx, now, budget)
+	if err != nil {
+		uc.logger.Error("Failed to get due watches", slog.Any("error", err))
+		return
+	}
+
+	for _, indicator := range indicators {
+		if ctx.Err() != nil {
+			return
+		}
+		uc.spend()
+
+		if err := uc.checkIndicator(ctx, indicator); err != nil {
+			if errors.Is(err, scanUsecase.ErrKasperskyForbidden) {
+				uc.pause(now)
+				return
+			}
+		}
+	}
+}
+
+// checkIndicator повторно проверяет индикатор и обновляет все записи, которым пора проверку
+func (uc *Usecase) checkIndicator(ctx context.Context, indicator models.Indicator) error {
+	logger := uc.logger.With(
+		slog.String("input_type", indicator.InputType),
+		slog.String("request", indicator.Request),
+	)
+
+	verdict, err := uc.scanner.Rescan(ctx, indicator.InputType, indicator.Request, uc.cfg.APIKey)
+	now := time.Now()
+	if err != nil {
+		logger.Warn("Watched indicator check failed", slog.Any("error", err))
+
+		// При исчерпанной квоте запись не трогаем: индикатор проверится, как только квота восстановится
+		if errors.Is(err, scanUsecase.ErrKasperskyForbidden) {
+			return err
+		}
+		if saveErr := uc.repo.SaveCheckError(ctx, indicator.InputType, indicator.Request, err.Error(), now); saveErr != nil {
+			return saveErr
+		}
+		return err
+	}
+
+	watches, err := uc.repo.DueWatches(ctx, indicator.InputType, indicator.Request, now)
+	if err != nil {
+		return err
+	}
+
+	for _, w := range watches {
+		turnedRed := w.Zone != "Red" && verdict.Zone == "Red"
+
+		var alert *models.Alert
+		// Первая проверка только запоминает вердикт
+		if w.Zone != "" && (w.Zone != verdict.Zone || !slices.Equal(w.Categories, verdict.Categories)) {
+			alert = &models.Alert{
+				WatchID:            w.ID,
+				UserID:             w.UserID,
+				InputType:          w.InputType,
+				Request:            w.Request,
+				PreviousZone:       w.Zone,
+				Zone:               verdict.Zone,
+				PreviousCategories: w.Categories,
+				Categories:         verdict.Categories,
+			}
+		}
+
+		w.Zone = verdict.Zone
+		w.Categories = verdict.Categories
+		w.LastCheckedAt = &now
+		w.NextCheckAt = now.Add(time.Duration(w.IntervalMinutes) * time.Minute)
+
+		if err := uc.repo.SaveCheck(ctx, w, alert); err != nil {
+			logger.Error("Failed to save watch check", slog.Int64("watch_id", w.ID), slog.Any("error", err))
+			continue
+		}
+		if alert != nil {
+			logger.Info("Watched indicator verdict changed",
+				slog.Int64("watch_id", w.ID),
+				slog.String("previous_zone", alert.PreviousZone),
+				slog.String("zone", alert.Zone),
+			)
+			if err := uc.notifier.Notify(ctx, models.EventWatchChanged, w.UserID, alert); err != nil {
+				logger.Warn("Failed to enqueue watch alert event", slog.Int64("alert_id", alert.ID), slog.Any("error", err))
+			}
+		}
+		// Индикатор, ставший красным, доходит и до подписок владельца на scan.red_verdict
+		if turnedRed {
+			uc.scanner.NotifyRedVerdict(scanUsecase.WithSource(ctx, scanModels.ScanSourceWatchlist), verdict.Zone, w.InputType, w.Request, w.UserID)
+		}
+	}
+
+	return nil
+}
+
+// budget пополняет запас запросов пропорционально прошедшему времени и возвращает, сколько
+// индикаторов можно проверить сейчас. Запас не копится больше, чем на час вперёд
+func (uc *Usecase) budget(now time.Time) int {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	if now.Before(uc.pausedUntil) {
+		return 0
+	}
+
+	limit := float64(uc.cfg.ChecksPerHour)
+	if uc.lastRefill.IsZero() {
+		// После запуска не тратим часовой запас разом
+		uc.tokens = limit * tickInterval.Hours()
+	} else {
+		uc.tokens = min(limit, uc.tokens+limit*now.Sub(uc.lastRefill).Hours())
+	}
+	uc.lastRefill = now
+
+	return int(uc.tokens)
+}
+
+func (uc *Usecase) spend() {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	uc.tokens--
+}
+
+func (uc *Usecase) pause(now time.Time) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	uc.pausedUntil = now.Add(uc.cfg.QuotaBackoff)
+	uc.tokens = 0
+	uc.logger.Warn("Kaspersky API quota exceeded, watchlist checks paused", slog.Time("until", uc.pausedUntil))
+}
+
+func (uc *Usecase) validate(intervalMinutes int, note string) error {
+	interval := time.Duration(intervalMinutes) * time.Minute
+	if interval < uc.cfg.MinInterval || interval > uc.cfg.MaxInterval {
+		return fmt.Errorf("%w: must be between %s and %s", ErrInvalidInterval, uc.cfg.MinInterval, uc.cfg.MaxInterval)
+	}
+	if len(note) > maxNoteLength {
+		return fmt.Errorf("%w: note is longer than %d bytes", ErrInvalidInput, maxNoteLength)
+	}
+
+	return nil
+}
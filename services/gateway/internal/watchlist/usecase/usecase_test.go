@@ -0,0 +1,187 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	scanModels "github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+	scanUsecase "github.com/CodeMaster482/minions-server/services/gateway/internal/scan/usecase"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/watchlist"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/watchlist/models"
+)
+
+// dueRepo отдаёт планировщику indicators индикаторов и записи watches, остальные методы Repo не вызываются
+type dueRepo struct {
+	watchlist.Repo
+	indicators int
+	limit      int
+	watches    []models.Watch
+	alerts     []*models.Alert
+}
+
+func (r *dueRepo) DueIndicators(_ context.Context, _ time.Time, limit int) ([]models.Indicator, error) {
+	r.limit = limit
+	indicators := make([]models.Indicator, min(limit, r.indicators))
+	for i := range indicators {
+		indicators[i] = models.Indicator{InputType: "domain", Request: "example.com"}
+	}
+	return indicators, nil
+}
+
+func (r *dueRepo) DueWatches(context.Context, string, string, time.Time) ([]models.Watch, error) {
+	return r.watches, nil
+}
+
+func (r *dueRepo) SaveCheck(_ context.Context, _ models.Watch, alert *models.Alert) error {
+	r.alerts = append(r.alerts, alert)
+	return nil
+}
+
+func (r *dueRepo) SaveCheckError(context.Context, string, string, string, time.Time) error {
+	return nil
+}
+
+// countingScanner считает повторные проверки и отвечает зоной zone (Green по умолчанию) или err
+type countingScanner struct {
+	calls int
+	zone  string
+	err   error
+	red   []int
+}
+
+func (s *countingScanner) IndicatorKey(input string) (string, string, error) {
+	return "domain", input, nil
+}
+
+func (s *countingScanner) Rescan(context.Context, string, string, string) (*scanModels.Verdict, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.zone == "" {
+		return &scanModels.Verdict{Zone: "Green"}, nil
+	}
+	return &scanModels.Verdict{Zone: s.zone}, nil
+}
+
+func (s *countingScanner) NotifyRedVerdict(_ context.Context, zone, _, _ string, userID int) {
+	if zone == "Red" {
+		s.red = append(s.red, userID)
+	}
+}
+
+// discardNotifier принимает оповещения и ничего не делает
+type discardNotifier struct{}
+
+func (discardNotifier) Notify(context.Context, string, int, any) error { return nil }
+
+func newWatchlist(repo watchlist.Repo, scanner watchlist.Scanner, checksPerHour int) *Usecase {
+	return New(repo, scanner, discardNotifier{}, Config{
+		ChecksPerHour: checksPerHour,
+		QuotaBackoff:  time.Hour,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestBudget(t *testing.T) {
+	uc := newWatchlist(nil, nil, 120)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// После запуска доступна только доля часового запаса за один тик
+	if got := uc.budget(start); got != 2 {
+		t.Fatalf("budget at start = %d, want 2", got)
+	}
+	uc.spend()
+	uc.spend()
+
+	if got := uc.budget(start.Add(30 * time.Second)); got != 1 {
+		t.Errorf("budget after 30s = %d, want 1", got)
+	}
+
+	// Запас не копится больше, чем на час
+	if got := uc.budget(start.Add(5 * time.Hour)); got != 120 {
+		t.Errorf("budget after 5h = %d, want 120", got)
+	}
+}
+
+func TestBudgetPause(t *testing.T) {
+	uc := newWatchlist(nil, nil, 120)
+	now := time.Now()
+
+	uc.budget(now)
+	uc.pause(now)
+
+	if got := uc.budget(now.Add(30 * time.Minute)); got != 0 {
+		t.Errorf("budget during pause = %d, want 0", got)
+	}
+	if err := uc.Check(context.Background()); err == nil {
+		t.Error("Check during pause: expected error")
+	}
+
+	// После паузы запас снова пополняется, но не больше часового
+	if got := uc.budget(now.Add(time.Hour + time.Minute)); got != 120 {
+		t.Errorf("budget after pause = %d, want 120", got)
+	}
+}
+
+func TestCheckDue(t *testing.T) {
+	tests := []struct {
+		name       string
+		indicators int
+		err        error
+		wantCalls  int
+		wantPaused bool
+	}{
+		{name: "limited by budget", indicators: 10, wantCalls: 2},
+		{name: "fewer due than budget", indicators: 1, wantCalls: 1},
+		{name: "quota exceeded pauses checks", indicators: 10, err: scanUsecase.ErrKasperskyForbidden, wantCalls: 1, wantPaused: true},
+		{name: "other errors do not pause", indicators: 10, err: errors.New("timeout"), wantCalls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &dueRepo{indicators: tt.indicators}
+			scanner := &countingScanner{err: tt.err}
+			uc := newWatchlist(repo, scanner, 120)
+
+			uc.checkDue(context.Background())
+
+			if repo.limit != 2 {
+				t.Errorf("DueIndicators limit = %d, want 2", repo.limit)
+			}
+			if scanner.calls != tt.wantCalls {
+				t.Errorf("Rescan called %d times, want %d", scanner.calls, tt.wantCalls)
+			}
+			if paused := uc.Check(context.Background()) != nil; paused != tt.wantPaused {
+				t.Errorf("paused = %v, want %v", paused, tt.wantPaused)
+			}
+		})
+	}
+}
+
+// Подписки владельца на scan.red_verdict получают индикатор, только когда он становится красным
+func TestCheckIndicatorNotifiesRed(t *testing.T) {
+	repo := &dueRepo{watches: []models.Watch{
+		{ID: 1, UserID: 10, InputType: "domain", Request: "example.com", Zone: "Green"},
+		{ID: 2, UserID: 20, InputType: "domain", Request: "example.com", Zone: "Red"},
+		{ID: 3, UserID: 30, InputType: "domain", Request: "example.com"},
+	}}
+	scanner := &countingScanner{zone: "Red"}
+	uc := newWatchlist(repo, scanner, 120)
+
+	if err := uc.checkIndicator(context.Background(), models.Indicator{InputType: "domain", Request: "example.com"}); err != nil {
+		t.Fatalf("checkIndicator: %v", err)
+	}
+
+	if want := []int{10, 30}; !slices.Equal(scanner.red, want) {
+		t.Errorf("red verdict notified for users %v, want %v", scanner.red, want)
+	}
+	// Первая проверка только запоминает вердикт, оповещение о смене - только для первой записи
+	if len(repo.alerts) != 3 || repo.alerts[0] == nil || repo.alerts[1] != nil || repo.alerts[2] != nil {
+		t.Errorf("alerts = %v", repo.alerts)
+	}
+}
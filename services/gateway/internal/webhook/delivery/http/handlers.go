@@ -0,0 +1,279 @@
+package http
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/gorilla/mux"
+
+	"github.com/CodeMaster482/minions-server/common"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/webhook"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/webhook/models"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/webhook/usecase"
+)
+
+const (
+	DefaultLimit = 50  // Размер страницы недоставленных событий по умолчанию
+	MaxLimit     = 200 // Максимальный размер страницы недоставленных событий
+
+	MaxBodySize = 16 << 10 // Максимальный размер тела запроса
+
+	BadRequestMsg          = "Bad Request: Incorrect query."
+	InvalidURLMsg          = "Bad Request: Webhook URL must be a public http or https address."
+	InvalidEventsMsg       = "Bad Request: Unknown or empty event list."
+	InvalidCursorMsg       = "Bad Request: Invalid cursor."
+	UnauthorizedMsg        = "Unauthorized"
+	ForbiddenMsg           = "Forbidden: Global webhooks require admin role."
+	NotFoundMsg            = "Not Found: Webhook not found."
+	DeadLetterNotFoundMsg  = "Not Found: Dead letter not found."
+	TooManyWebhooksMsg     = "Conflict: Webhook limit reached."
+	InternalServerErrorMsg = "Internal Server Error"
+)
+
+type Handler struct {
+	usecase        webhook.Usecase
+	sessionManager *scs.SessionManager
+	logger         *slog.Logger
+}
+
+func New(uc webhook.Usecase, sessionManager *scs.SessionManager, logger *slog.Logger) *Handler {
+	return &Handler{
+		usecase:        uc,
+		sessionManager: sessionManager,
+		logger:         logger,
+	}
+}
+
+// List
+// @Summary Список подписок на события
+// @Description Возвращает подписки текущего пользователя, администратору - ещё и общие подписки. Ключ подписи не возвращается.
+// @ID webhooks-list
+// @Tags Webhooks
+// @Produce json
+// @Success 200 {array} models.Webhook "Подписки"
+// @Failure 401 {object} common.ErrorResponse "Unauthorized"
+// @Failure 500 {object} common.ErrorResponse "Internal Server Error"
+// @Router /api/webhooks [get]
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	logger := h.requestLogger(r)
+
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	webhooks, err := h.usecase.List(r.Context(), userID, h.isAdmin(r))
+	if err != nil {
+		h.respondError(w, logger, err)
+		return
+	}
+
+	common.RespondWithJSON(w, http.StatusOK, webhooks)
+}
+
+// Create
+// @Summary Создание подписки на события
+// @Description События отправляются POST-запросом с JSON-телом models.Payload. Заголовки запроса:
+// @Description X-Minions-Event - тип события, X-Minions-Delivery - идентификатор события (не меняется при повторах),
+// @Description X-Minions-Timestamp - время отправки в секундах Unix,
+// @Description X-Minions-Signature - "sha256=" и hex от HMAC-SHA256(Secret, timestamp + "." + тело).
+// @Description Ответ, отличный от 2xx, повторяется с растущей паузой, после последней попытки событие попадает в недоставленные.
+// @Description Ключ подписи Secret возвращается только в ответе на этот запрос.
+// @Description Общую подписку на события всех пользователей (global) может создать только администратор.
+// @ID webhooks-create
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param request body models.WebhookRequest true "Адрес и события"
+// @Success 201 {object} models.Webhook "Подписка создана"
+// @Failure 400 {object} common.ErrorResponse "Bad Request: Unknown or empty event list."
+// @Failure 401 {object} common.ErrorResponse "Unauthorized"
+// @Failure 403 {object} common.ErrorResponse "Forbidden: Global webhooks require admin role."
+// @Failure 409 {object} common.ErrorResponse "Conflict: Webhook limit reached."
+// @Failure 500 {object} common.ErrorResponse "Internal Server Error"
+// @Router /api/webhooks [post]
+func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
+	logger := h.requestLogger(r)
+
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxBodySize)
+
+	var req models.WebhookRequest
+	if err := common.DecodeJSONBody(w, r, &req); err != nil {
+		common.RespondWithError(w, http.StatusBadRequest, err.Error())
+		logger.Warn(BadRequestMsg, slog.Any("error", err))
+		return
+	}
+
+	created, err := h.usecase.Create(r.Context(), userID, h.isAdmin(r), req)
+	if err != nil {
+		h.respondError(w, logger, err)
+		return
+	}
+
+	logger.Info("Webhook created", slog.Int64("webhook_id", created.ID), slog.Bool("global", created.Global))
+	common.RespondWithJSON(w, http.StatusCreated, created)
+}
+
+// Delete
+// @Summary Удаление подписки
+// @Description Вместе с подпиской удаляются её очередь доставки и недоставленные события.
+// @ID webhooks-delete
+// @Tags Webhooks
+// @Param id path int true "Идентификатор подписки"
+// @Success 204 "Подписка удалена"
+// @Failure 401 {object} common.ErrorResponse "Unauthorized"
+// @Failure 404 {object} common.ErrorResponse "Not Found: Webhook not found."
+// @Failure 500 {object} common.ErrorResponse "Internal Server Error"
+// @Router /api/webhooks/{id} [delete]
+func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
+	logger := h.requestLogger(r)
+
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.usecase.Delete(r.Context(), userID, h.isAdmin(r), pathID(r)); err != nil {
+		h.respondError(w, logger, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeadLetters
+// @Summary Недоставленные события
+// @Description Возвращает события, которые не удалось доставить за все попытки, новые первыми.
+// @Description Для следующей страницы передайте cursor из NextCursor.
+// @ID webhooks-dead-letters
+// @Tags Webhooks
+// @Produce json
+// @Param limit query int false "Размер страницы (по умолчанию 50, не больше 200)"
+// @Param cursor query string false "Курсор следующей страницы"
+// @Success 200 {object} models.DeadLetterPage "Страница недоставленных событий"
+// @Failure 400 {object} common.ErrorResponse "Bad Request: Incorrect query."
+// @Failure 401 {object} common.ErrorResponse "Unauthorized"
+// @Failure 500 {object} common.ErrorResponse "Internal Server Error"
+// @Router /api/webhooks/dead-letters [get]
+func (h *Handler) DeadLetters(w http.ResponseWriter, r *http.Request) {
+	logger := h.requestLogger(r)
+
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	limit := DefaultLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			common.RespondWithError(w, http.StatusBadRequest, BadRequestMsg)
+			logger.Warn(BadRequestMsg, slog.String("limit", raw))
+			return
+		}
+		limit = min(n, MaxLimit)
+	}
+
+	page, err := h.usecase.DeadLetters(r.Context(), userID, h.isAdmin(r), r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		h.respondError(w, logger, err)
+		return
+	}
+
+	common.RespondWithJSON(w, http.StatusOK, page)
+}
+
+// Redeliver
+// @Summary Повторная доставка события
+// @Description Возвращает недоставленное событие в очередь с полным набором попыток. Идентификатор события не меняется.
+// @ID webhooks-redeliver
+// @Tags Webhooks
+// @Param id path int true "Идентификатор недоставленного события"
+// @Success 202 "Событие поставлено в очередь"
+// @Failure 401 {object} common.ErrorResponse "Unauthorized"
+// @Failure 404 {object} common.ErrorResponse "Not Found: Dead letter not found."
+// @Failure 500 {object} common.ErrorResponse "Internal Server Error"
+// @Router /api/webhooks/dead-letters/{id}/redeliver [post]
+func (h *Handler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	logger := h.requestLogger(r)
+
+	userID, ok := h.userID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.usecase.Redeliver(r.Context(), userID, h.isAdmin(r), pathID(r)); err != nil {
+		h.respondError(w, logger, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// respondError отвечает клиенту кодом, соответствующим ошибке usecase
+func (h *Handler) respondError(w http.ResponseWriter, logger *slog.Logger, err error) {
+	switch {
+	case errors.Is(err, usecase.ErrInvalidURL):
+		common.RespondWithError(w, http.StatusBadRequest, InvalidURLMsg)
+		logger.Warn(InvalidURLMsg, slog.Any("error", err))
+	case errors.Is(err, usecase.ErrInvalidEvents):
+		common.RespondWithError(w, http.StatusBadRequest, InvalidEventsMsg)
+		logger.Warn(InvalidEventsMsg, slog.Any("error", err))
+	case errors.Is(err, usecase.ErrInvalidCursor):
+		common.RespondWithError(w, http.StatusBadRequest, InvalidCursorMsg)
+		logger.Warn(InvalidCursorMsg)
+	case errors.Is(err, usecase.ErrForbidden):
+		common.RespondWithError(w, http.StatusForbidden, ForbiddenMsg)
+		logger.Warn(ForbiddenMsg)
+	case errors.Is(err, usecase.ErrNotFound):
+		common.RespondWithError(w, http.StatusNotFound, NotFoundMsg)
+		logger.Warn(NotFoundMsg)
+	case errors.Is(err, usecase.ErrDeadLetterAbsent):
+		common.RespondWithError(w, http.StatusNotFound, DeadLetterNotFoundMsg)
+		logger.Warn(DeadLetterNotFoundMsg)
+	case errors.Is(err, usecase.ErrTooManyWebhooks):
+		common.RespondWithError(w, http.StatusConflict, TooManyWebhooksMsg)
+		logger.Warn(TooManyWebhooksMsg)
+	default:
+		common.RespondWithError(w, http.StatusInternalServerError, InternalServerErrorMsg)
+		logger.Error(InternalServerErrorMsg, slog.Any("error", err))
+	}
+}
+
+func (h *Handler) requestLogger(r *http.Request) *slog.Logger {
+	return h.logger.With(
+		slog.String("method", r.Method),
+		slog.String("url", r.URL.String()),
+		slog.String("remote_addr", r.RemoteAddr),
+	)
+}
+
+// userID возвращает идентификатор пользователя из сессии и отвечает 401, если его нет
+func (h *Handler) userID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	userID := h.sessionManager.GetInt(r.Context(), "user_id")
+	if userID == 0 {
+		common.RespondWithError(w, http.StatusUnauthorized, UnauthorizedMsg)
+		return 0, false
+	}
+
+	return userID, true
+}
+
+// isAdmin сообщает, что у пользователя роль администратора
+func (h *Handler) isAdmin(r *http.Request) bool {
+	return h.sessionManager.GetString(r.Context(), "role") == common.RoleAdmin
+}
+
+// pathID возвращает идентификатор из пути, маршрут пропускает только цифры
+func pathID(r *http.Request) int64 {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	return id
+}
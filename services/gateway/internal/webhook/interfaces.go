@@ -0,0 +1,36 @@
+package webhook
+
+import (
+	"context"
+	"time"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/webhook/models"
+)
+
+// Usecase управляет подписками. admin - роль из сессии: администратор видит и меняет
+// общие подписки и их недоставленные события наравне со своими
+type Usecase interface {
+	List(ctx context.Context, userID int, admin bool) ([]models.Webhook, error)
+	Create(ctx context.Context, userID int, admin bool, req models.WebhookRequest) (*models.Webhook, error)
+	Delete(ctx context.Context, userID int, admin bool, id int64) error
+	DeadLetters(ctx context.Context, userID int, admin bool, cursor string, limit int) (*models.DeadLetterPage, error)
+	Redeliver(ctx context.Context, userID int, admin bool, id int64) error
+}
+
+// Repo хранит подписки и очередь доставки. Методы с userID и admin видят только подписки пользователя
+// и, для администратора, общие подписки
+type Repo interface {
+	ListWebhooks(ctx context.Context, userID int, admin bool) ([]models.Webhook, error)
+	CountWebhooks(ctx context.Context, userID int) (int, error)
+	CreateWebhook(ctx context.Context, w models.Webhook) (*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, userID int, admin bool, id int64) (bool, error)
+
+	Enqueue(ctx context.Context, event, eventID, payload string, userID int) (int64, error)
+	ClaimDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.Delivery, error)
+	CompleteDelivery(ctx context.Context, id int64) error
+	RetryDelivery(ctx context.Context, id int64, next time.Time, lastErr string, lastStatus int) error
+	FailDelivery(ctx context.Context, id int64, lastErr string, lastStatus int) error
+
+	ListDeadLetters(ctx context.Context, userID int, admin bool, beforeID int64, limit int) ([]models.DeadLetter, error)
+	Redeliver(ctx context.Context, userID int, admin bool, id int64) (bool, error)
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook адрес, на который отправляются события. Подписка без пользователя - общая,
+// она получает события всех пользователей, включая неавторизованных
+type Webhook struct {
+	// Идентификатор подписки
+	ID int64 `json:"ID" example:"7"`
+
+	// Адрес получателя
+	URL string `json:"URL" example:"https://hooks.example.com/minions"`
+
+	// События, на которые оформлена подписка
+	Events []string `json:"Events" example:"[\"scan.red_verdict\"]"`
+
+	// Общая подписка администратора
+	Global bool `json:"Global" example:"false"`
+
+	// Ключ подписи HMAC-SHA256, возвращается только при создании
+	Secret string `json:"Secret,omitempty" example:"6f1c1f0e1d8f4b3a9b2c7d5e4f3a2b1c6f1c1f0e1d8f4b3a9b2c7d5e4f3a2b1c"`
+
+	// Время создания
+	CreatedAt time.Time `json:"CreatedAt" example:"2024-01-01T10:00:00Z"`
+
+	UserID int `json:"-"`
+}
+
+// WebhookRequest запрос на создание подписки
+type WebhookRequest struct {
+	// Адрес получателя, только http и https
+	URL string `json:"url" example:"https://hooks.example.com/minions"`
+
+	// События: scan.red_verdict, scan.file_completed, watchlist.verdict_changed
+	Events []string `json:"events" example:"[\"scan.red_verdict\"]"`
+
+	// Общая подписка на события всех пользователей, только для администратора
+	Global bool `json:"global,omitempty" example:"false"`
+}
+
+// Payload тело запроса, которое получает подписчик
+type Payload struct {
+	// Идентификатор события, не меняется при повторных попытках
+	ID string `json:"ID" example:"5f0c6a522b394d8e9a610b1f2c3d4e5f"`
+
+	// Тип события
+	Event string `json:"Event" example:"scan.red_verdict"`
+
+	// Время события
+	CreatedAt time.Time `json:"CreatedAt" example:"2024-01-02T15:04:05Z"`
+
+	// Пользователь, с проверкой которого связано событие; пусто для неавторизованного
+	UserID int `json:"UserID,omitempty" example:"3"`
+
+	// Данные события, формат зависит от типа
+	Data json.RawMessage `json:"Data" swaggertype:"object"`
+}
+
+// Delivery доставка события на один адрес
+type Delivery struct {
+	ID        int64
+	WebhookID int64
+	URL       string
+	Secret    string
+	Global    bool
+	EventID   string
+	Event     string
+	Payload   string
+	Attempts  int
+}
+
+// DeadLetter событие, которое не удалось доставить за все попытки
+type DeadLetter struct {
+	// Идентификатор записи
+	ID int64 `json:"ID" example:"15"`
+
+	// Идентификатор подписки
+	WebhookID int64 `json:"WebhookID" example:"7"`
+
+	// Адрес получателя
+	URL string `json:"URL" example:"https://hooks.example.com/minions"`
+
+	// Тип события
+	Event string `json:"Event" example:"scan.red_verdict"`
+
+	// Тело запроса
+	Payload json.RawMessage `json:"Payload" swaggertype:"object"`
+
+	// Количество попыток
+	Attempts int `json:"Attempts" example:"8"`
+
+	// Ошибка последней попытки
+	LastError string `json:"LastError" example:"unexpected status 503"`
+
+	// HTTP-статус последней попытки, 0 - ответа не было
+	LastStatus int `json:"LastStatus" example:"503"`
+
+	// Время события
+	CreatedAt time.Time `json:"CreatedAt" example:"2024-01-02T15:04:05Z"`
+
+	// Время последней попытки
+	FailedAt time.Time `json:"FailedAt" example:"2024-01-02T19:20:00Z"`
+}
+
+// DeadLetterPage страница недоставленных событий
+type DeadLetterPage struct {
+	// Недоставленные события, новые первыми
+	Items []DeadLetter `json:"Items"`
+
+	// Курсор следующей страницы, пустой на последней странице
+	NextCursor string `json:"NextCursor,omitempty" example:"MTU"`
+}
@@ -0,0 +1,300 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/webhook/models"
+)
+
+var (
+	webhookColumns = `id, COALESCE(user_id, 0), url, events, user_id IS NULL, created_at`
+
+	// Администратор видит общие подписки вместе со своими
+	ownedBy = `(user_id = $1 OR ($2 AND user_id IS NULL))`
+
+	ListWebhooks = `
+        SELECT ` + webhookColumns + `
+        FROM webhooks
+        WHERE ` + ownedBy + `
+        ORDER BY id
+    `
+	CountWebhooks = `
+        SELECT COUNT(*) FROM webhooks WHERE user_id = $1
+    `
+	CreateWebhook = `
+        INSERT INTO webhooks (user_id, url, secret, events)
+        VALUES (NULLIF($1, 0), $2, $3, $4)
+        RETURNING ` + webhookColumns + `
+    `
+	DeleteWebhook = `
+        DELETE FROM webhooks WHERE id = $3 AND ` + ownedBy + `
+    `
+	// Событие пользователя получают его подписки и общие, событие без пользователя - только общие
+	Enqueue = `
+        INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload)
+        SELECT id, $2::text, $1::text, $3::text
+        FROM webhooks
+        WHERE $1 = ANY(events)
+          AND (user_id IS NULL OR ($4 <> 0 AND user_id = $4))
+    `
+	// Попытка засчитывается при захвате: если шлюз упадёт во время отправки, доставка
+	// вернётся в очередь после аренды и не будет повторяться бесконечно
+	ClaimDeliveries = `
+        UPDATE webhook_deliveries d
+        SET attempts = d.attempts + 1, next_attempt_at = $2
+        FROM webhooks w
+        WHERE w.id = d.webhook_id
+          AND d.id IN (
+            SELECT id
+            FROM webhook_deliveries
+            WHERE next_attempt_at <= $1
+            ORDER BY next_attempt_at
+            LIMIT $3
+            FOR UPDATE SKIP LOCKED
+          )
+        RETURNING d.id, d.webhook_id, w.url, w.secret, w.user_id IS NULL, d.event_id, d.event, d.payload, d.attempts
+    `
+	CompleteDelivery = `
+        DELETE FROM webhook_deliveries WHERE id = $1
+    `
+	RetryDelivery = `
+        UPDATE webhook_deliveries
+        SET next_attempt_at = $2, last_error = $3, last_status = $4
+        WHERE id = $1
+    `
+	FailDelivery = `
+        WITH failed AS (
+            DELETE FROM webhook_deliveries WHERE id = $1
+            RETURNING webhook_id, event_id, event, payload, attempts, created_at
+        )
+        INSERT INTO webhook_dead_letters (webhook_id, event_id, event, payload, attempts, last_error, last_status, created_at)
+        SELECT webhook_id, event_id, event, payload, attempts, $2::text, $3::int, created_at
+        FROM failed
+    `
+	ListDeadLetters = `
+        SELECT dl.id, dl.webhook_id, w.url, dl.event, dl.payload, dl.attempts, dl.last_error, dl.last_status,
+               dl.created_at, dl.failed_at
+        FROM webhook_dead_letters dl
+        JOIN webhooks w ON w.id = dl.webhook_id
+        WHERE (w.user_id = $1 OR ($2 AND w.user_id IS NULL))
+          AND ($3 = 0 OR dl.id < $3)
+        ORDER BY dl.id DESC
+        LIMIT $4
+    `
+	// Повторная доставка начинается с нуля попыток, идентификатор события сохраняется
+	Redeliver = `
+        WITH revived AS (
+            DELETE FROM webhook_dead_letters dl
+            USING webhooks w
+            WHERE dl.id = $3 AND w.id = dl.webhook_id
+              AND (w.user_id = $1 OR ($2 AND w.user_id IS NULL))
+            RETURNING dl.webhook_id, dl.event_id, dl.event, dl.payload, dl.created_at
+        )
+        INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload, created_at)
+        SELECT webhook_id, event_id, event, payload, created_at
+        FROM revived
+    `
+)
+
+type Postgres struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func New(db *sql.DB, logger *slog.Logger) *Postgres {
+	return &Postgres{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (p *Postgres) ListWebhooks(ctx context.Context, userID int, admin bool) ([]models.Webhook, error) {
+	rows, err := p.db.QueryContext(ctx, ListWebhooks, userID, admin)
+	if err != nil {
+		p.logger.Error("Error executing webhooks query", slog.Any("error", err))
+		return nil, fmt.Errorf("error executing webhooks query: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			p.logger.Error("Error scanning row", slog.Any("error", err))
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		webhooks = append(webhooks, *w)
+	}
+
+	if err := rows.Err(); err != nil {
+		p.logger.Error("Rows iteration error", slog.Any("error", err))
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+func (p *Postgres) CountWebhooks(ctx context.Context, userID int) (int, error) {
+	var count int
+	if err := p.db.QueryRowContext(ctx, CountWebhooks, userID).Scan(&count); err != nil {
+		p.logger.Error("Error counting webhooks", slog.Any("error", err))
+		return 0, fmt.Errorf("error counting webhooks: %w", err)
+	}
+
+	return count, nil
+}
+
+// CreateWebhook сохраняет подписку. Подписка с UserID 0 становится общей
+func (p *Postgres) CreateWebhook(ctx context.Context, w models.Webhook) (*models.Webhook, error) {
+	created, err := scanWebhook(p.db.QueryRowContext(ctx, CreateWebhook, w.UserID, w.URL, w.Secret, pq.Array(w.Events)))
+	if err != nil {
+		p.logger.Error("Error creating webhook", slog.Any("error", err))
+		return nil, fmt.Errorf("error creating webhook: %w", err)
+	}
+
+	return created, nil
+}
+
+func (p *Postgres) DeleteWebhook(ctx context.Context, userID int, admin bool, id int64) (bool, error) {
+	return p.execAffected(ctx, "deleting webhook", DeleteWebhook, userID, admin, id)
+}
+
+// Enqueue ставит событие в очередь всех подходящих подписок и возвращает число доставок
+func (p *Postgres) Enqueue(ctx context.Context, event, eventID, payload string, userID int) (int64, error) {
+	res, err := p.db.ExecContext(ctx, Enqueue, event, eventID, payload, userID)
+	if err != nil {
+		p.logger.Error("Error enqueuing webhook event", slog.Any("error", err))
+		return 0, fmt.Errorf("error enqueuing webhook event: %w", err)
+	}
+
+	return res.RowsAffected()
+}
+
+// ClaimDeliveries захватывает доставки, время которых пришло, до leaseUntil. Несколько копий шлюза
+// не захватят одну доставку дважды
+func (p *Postgres) ClaimDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.Delivery, error) {
+	rows, err := p.db.QueryContext(ctx, ClaimDeliveries, now, leaseUntil, limit)
+	if err != nil {
+		p.logger.Error("Error claiming webhook deliveries", slog.Any("error", err))
+		return nil, fmt.Errorf("error claiming webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []models.Delivery
+	for rows.Next() {
+		var d models.Delivery
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.URL, &d.Secret, &d.Global, &d.EventID, &d.Event, &d.Payload, &d.Attempts)
+		if err != nil {
+			p.logger.Error("Error scanning row", slog.Any("error", err))
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		p.logger.Error("Rows iteration error", slog.Any("error", err))
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func (p *Postgres) CompleteDelivery(ctx context.Context, id int64) error {
+	if _, err := p.db.ExecContext(ctx, CompleteDelivery, id); err != nil {
+		p.logger.Error("Error completing webhook delivery", slog.Any("error", err))
+		return fmt.Errorf("error completing webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) RetryDelivery(ctx context.Context, id int64, next time.Time, lastErr string, lastStatus int) error {
+	if _, err := p.db.ExecContext(ctx, RetryDelivery, id, next, lastErr, lastStatus); err != nil {
+		p.logger.Error("Error rescheduling webhook delivery", slog.Any("error", err))
+		return fmt.Errorf("error rescheduling webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+// FailDelivery переносит доставку в недоставленные одним запросом
+func (p *Postgres) FailDelivery(ctx context.Context, id int64, lastErr string, lastStatus int) error {
+	if _, err := p.db.ExecContext(ctx, FailDelivery, id, lastErr, lastStatus); err != nil {
+		p.logger.Error("Error moving webhook delivery to dead letters", slog.Any("error", err))
+		return fmt.Errorf("error moving webhook delivery to dead letters: %w", err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) ListDeadLetters(ctx context.Context, userID int, admin bool, beforeID int64, limit int) ([]models.DeadLetter, error) {
+	rows, err := p.db.QueryContext(ctx, ListDeadLetters, userID, admin, beforeID, limit)
+	if err != nil {
+		p.logger.Error("Error executing dead letters query", slog.Any("error", err))
+		return nil, fmt.Errorf("error executing dead letters query: %w", err)
+	}
+	defer rows.Close()
+
+	letters := []models.DeadLetter{}
+	for rows.Next() {
+		var (
+			dl      models.DeadLetter
+			payload string
+		)
+		err := rows.Scan(&dl.ID, &dl.WebhookID, &dl.URL, &dl.Event, &payload, &dl.Attempts, &dl.LastError,
+			&dl.LastStatus, &dl.CreatedAt, &dl.FailedAt)
+		if err != nil {
+			p.logger.Error("Error scanning row", slog.Any("error", err))
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		dl.Payload = []byte(payload)
+		letters = append(letters, dl)
+	}
+
+	if err := rows.Err(); err != nil {
+		p.logger.Error("Rows iteration error", slog.Any("error", err))
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return letters, nil
+}
+
+// Redeliver возвращает недоставленное событие в очередь. false - записи нет или она чужая
+func (p *Postgres) Redeliver(ctx context.Context, userID int, admin bool, id int64) (bool, error) {
+	return p.execAffected(ctx, "redelivering webhook event", Redeliver, userID, admin, id)
+}
+
+func (p *Postgres) execAffected(ctx context.Context, action, query string, args ...any) (bool, error) {
+	res, err := p.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		p.logger.Error("Error "+action, slog.Any("error", err))
+		return false, fmt.Errorf("error %s: %w", action, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// scanWebhook читает строку с колонками webhookColumns
+func scanWebhook(row interface{ Scan(...any) error }) (*models.Webhook, error) {
+	var w models.Webhook
+	err := row.Scan(&w.ID, &w.UserID, &w.URL, pq.Array(&w.Events), &w.Global, &w.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if w.Events == nil {
+		w.Events = []string{}
+	}
+
+	return &w, nil
+}
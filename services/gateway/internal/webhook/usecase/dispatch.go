@@ -0,0 +1,232 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/webhook/models"
+)
+
+const (
+	pollInterval     = 5 * time.Second  // Как часто диспетчер ищет доставки, которым пора
+	batchPerWorker   = 10               // Сколько доставок захватывается за раз на одного исполнителя
+	maxResponseBody  = 64 << 10         // Сколько байт ответа читается, чтобы соединение вернулось в пул
+	maxErrorLength   = 512              // Максимальная длина сохраняемой ошибки
+	leaseGracePeriod = 30 * time.Second // Запас аренды сверх таймаутов попыток
+)
+
+// Заголовки запроса к получателю
+const (
+	HeaderEvent     = "X-Minions-Event"
+	HeaderDelivery  = "X-Minions-Delivery"
+	HeaderTimestamp = "X-Minions-Timestamp"
+	HeaderSignature = "X-Minions-Signature"
+)
+
+// Sign вычисляет подпись тела запроса: HMAC-SHA256 от "timestamp.body" с ключом подписки.
+// Метка времени входит в подпись, чтобы перехваченный запрос нельзя было повторить позже
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Name имя проверки для /api/health
+func (uc *Usecase) Name() string {
+	return "webhooks"
+}
+
+// Check возвращает ошибку последнего опроса очереди доставки
+func (uc *Usecase) Check(_ context.Context) error {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	return uc.lastErr
+}
+
+// Run отправляет события из очереди до отмены контекста
+func (uc *Usecase) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			uc.dispatch(ctx)
+		}
+	}
+}
+
+// dispatch захватывает доставки, которым пора, и отправляет их параллельно. Если очередь
+// заполнена, следующая пачка забирается сразу, не дожидаясь тика
+func (uc *Usecase) dispatch(ctx context.Context) {
+	limit := uc.cfg.Workers * batchPerWorker
+	for ctx.Err() == nil {
+		now := time.Now()
+		// Аренда покрывает все попытки пачки, даже если они выполнятся одна за другой
+		lease := now.Add(time.Duration(batchPerWorker)*uc.cfg.Timeout + leaseGracePeriod)
+
+		deliveries, err := uc.repo.ClaimDeliveries(ctx, now, lease, limit)
+		uc.mu.Lock()
+		uc.lastErr = err
+		uc.mu.Unlock()
+		if err != nil {
+			uc.logger.Error("Failed to claim webhook deliveries", slog.Any("error", err))
+			return
+		}
+
+		jobs := make(chan models.Delivery)
+		var wg sync.WaitGroup
+		for range min(uc.cfg.Workers, len(deliveries)) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for d := range jobs {
+					uc.deliver(ctx, d)
+				}
+			}()
+		}
+		for _, d := range deliveries {
+			jobs <- d
+		}
+		close(jobs)
+		wg.Wait()
+
+		if len(deliveries) < limit {
+			return
+		}
+	}
+}
+
+// deliver выполняет одну попытку и записывает её исход: удаляет доставку, откладывает
+// следующую попытку или переносит событие в недоставленные
+func (uc *Usecase) deliver(ctx context.Context, d models.Delivery) {
+	logger := uc.logger.With(
+		slog.Int64("delivery_id", d.ID),
+		slog.Int64("webhook_id", d.WebhookID),
+		slog.String("event", d.Event),
+		slog.Int("attempt", d.Attempts),
+	)
+
+	status, err := uc.send(ctx, d)
+	if err == nil {
+		if err := uc.repo.CompleteDelivery(ctx, d.ID); err != nil {
+			logger.Error("Failed to complete webhook delivery", slog.Any("error", err))
+		}
+		logger.Debug("Webhook delivered", slog.Int("status", status))
+		return
+	}
+
+	lastErr := err.Error()
+	if len(lastErr) > maxErrorLength {
+		lastErr = lastErr[:maxErrorLength]
+	}
+
+	if d.Attempts >= uc.cfg.MaxAttempts {
+		if err := uc.repo.FailDelivery(ctx, d.ID, lastErr, status); err != nil {
+			logger.Error("Failed to move webhook delivery to dead letters", slog.Any("error", err))
+			return
+		}
+		logger.Warn("Webhook delivery failed, moved to dead letters", slog.Int("status", status), slog.String("error", lastErr))
+		return
+	}
+
+	next := time.Now().Add(uc.backoff(d.Attempts))
+	if err := uc.repo.RetryDelivery(ctx, d.ID, next, lastErr, status); err != nil {
+		logger.Error("Failed to reschedule webhook delivery", slog.Any("error", err))
+		return
+	}
+	logger.Info("Webhook delivery failed, will retry",
+		slog.Int("status", status),
+		slog.String("error", lastErr),
+		slog.Time("next_attempt_at", next),
+	)
+}
+
+// send отправляет событие получателю. Успехом считается только ответ 2xx, перенаправления
+// не выполняются. Возвращает HTTP-статус, 0 - если ответа не было
+func (uc *Usecase) send(ctx context.Context, d models.Delivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, uc.cfg.Timeout)
+	defer cancel()
+
+	body := []byte(d.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "minions-webhooks/1.0")
+	req.Header.Set(HeaderEvent, d.Event)
+	req.Header.Set(HeaderDelivery, d.EventID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(d.Secret, timestamp, body))
+
+	client := uc.guardedClient
+	if d.Global {
+		client = uc.globalClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// backoff пауза после attempts неудачных попыток: BaseBackoff, затем вдвое больше, но не больше MaxBackoff
+func (uc *Usecase) backoff(attempts int) time.Duration {
+	delay := uc.cfg.BaseBackoff
+	for i := 1; i < attempts && delay < uc.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, uc.cfg.MaxBackoff)
+}
+
+// newClient создаёт клиент без перенаправлений. control проверяет адрес после DNS, nil - без проверки
+func newClient(timeout time.Duration, control func(network, address string, c syscall.RawConn) error) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: control,
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConnsPerHost:   2,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
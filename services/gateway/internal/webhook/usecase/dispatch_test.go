@@ -0,0 +1,169 @@
+package usecase
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/webhook"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/webhook/models"
+)
+
+func TestSign(t *testing.T) {
+	// Эталон: printf '1700000000.{"event":"red_verdict"}' | openssl dgst -sha256 -hmac whsec_test
+	want := "sha256=15fcbfe9aef3569d341dc6918d817e5355d12e27dbf779e5447fed2c1d94649a"
+	if got := Sign("whsec_test", "1700000000", []byte(`{"event":"red_verdict"}`)); got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+
+	// Метка времени входит в подпись
+	if Sign("whsec_test", "1700000001", []byte(`{"event":"red_verdict"}`)) == want {
+		t.Error("signature does not depend on timestamp")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	uc := &Usecase{cfg: Config{BaseBackoff: time.Minute, MaxBackoff: time.Hour}}
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: time.Minute},
+		{attempts: 2, want: 2 * time.Minute},
+		{attempts: 4, want: 8 * time.Minute},
+		{attempts: 7, want: time.Hour},
+		{attempts: 100, want: time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := uc.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+// deliveryRepo запоминает исход доставки, остальные методы Repo не вызываются
+type deliveryRepo struct {
+	webhook.Repo
+	completed bool
+	retryAt   time.Time
+	failed    bool
+	status    int
+}
+
+func (r *deliveryRepo) CompleteDelivery(context.Context, int64) error {
+	r.completed = true
+	return nil
+}
+
+func (r *deliveryRepo) RetryDelivery(_ context.Context, _ int64, next time.Time, _ string, status int) error {
+	r.retryAt, r.status = next, status
+	return nil
+}
+
+func (r *deliveryRepo) FailDelivery(_ context.Context, _ int64, _ string, status int) error {
+	r.failed, r.status = true, status
+	return nil
+}
+
+func TestDeliver(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		attempts      int
+		wantCompleted bool
+		wantRetry     time.Duration
+		wantFailed    bool
+	}{
+		{name: "delivered", status: http.StatusNoContent, attempts: 1, wantCompleted: true},
+		{name: "retried with backoff", status: http.StatusInternalServerError, attempts: 2, wantRetry: 2 * time.Minute},
+		{name: "redirect is a failure", status: http.StatusFound, attempts: 1, wantRetry: time.Minute},
+		{name: "moved to dead letters", status: http.StatusBadGateway, attempts: 3, wantFailed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var header http.Header
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				header = r.Header.Clone()
+				if Sign("whsec_test", r.Header.Get(HeaderTimestamp), body) != r.Header.Get(HeaderSignature) {
+					t.Error("signature does not match body and timestamp")
+				}
+				w.Header().Set("Location", "http://example.com/")
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			repo := &deliveryRepo{}
+			// Тестовый сервер слушает loopback, поэтому подписка общая и внутренние адреса разрешены
+			uc := New(repo, Config{
+				Timeout:             5 * time.Second,
+				MaxAttempts:         3,
+				BaseBackoff:         time.Minute,
+				MaxBackoff:          time.Hour,
+				AllowPrivateTargets: true,
+			}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			start := time.Now()
+			uc.deliver(context.Background(), models.Delivery{
+				ID:       1,
+				URL:      server.URL,
+				Secret:   "whsec_test",
+				Global:   true,
+				EventID:  "evt_1",
+				Event:    "scan.red_verdict",
+				Payload:  `{"zone":"Red"}`,
+				Attempts: tt.attempts,
+			})
+
+			if header.Get(HeaderEvent) != "scan.red_verdict" || header.Get(HeaderDelivery) != "evt_1" {
+				t.Errorf("headers = %v", header)
+			}
+			if repo.completed != tt.wantCompleted || repo.failed != tt.wantFailed {
+				t.Errorf("completed = %v, failed = %v", repo.completed, repo.failed)
+			}
+			if tt.wantRetry == 0 {
+				if !repo.retryAt.IsZero() {
+					t.Errorf("unexpected retry at %v", repo.retryAt)
+				}
+				return
+			}
+			if delay := repo.retryAt.Sub(start); delay < tt.wantRetry || delay > tt.wantRetry+5*time.Second {
+				t.Errorf("retry after %s, want %s", delay, tt.wantRetry)
+			}
+			if repo.status != tt.status {
+				t.Errorf("status = %d, want %d", repo.status, tt.status)
+			}
+		})
+	}
+}
+
+// Пользовательская подписка не может вести во внутреннюю сеть, даже если общим это разрешено
+func TestDeliverBlocksPrivateTargets(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	repo := &deliveryRepo{}
+	uc := New(repo, Config{
+		Timeout:             5 * time.Second,
+		MaxAttempts:         3,
+		BaseBackoff:         time.Minute,
+		MaxBackoff:          time.Hour,
+		AllowPrivateTargets: true,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	uc.deliver(context.Background(), models.Delivery{ID: 1, URL: server.URL, Secret: "s", Event: "scan.red_verdict", Payload: "{}", Attempts: 1})
+
+	if called || repo.completed || repo.retryAt.IsZero() || repo.status != 0 {
+		t.Errorf("called = %v, completed = %v, retry at %v, status %d", called, repo.completed, repo.retryAt, repo.status)
+	}
+}
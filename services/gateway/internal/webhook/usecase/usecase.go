@@ -0,0 +1,259 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	scanModels "github.com/CodeMaster482/minions-server/services/gateway/internal/scan/models"
+	watchlistModels "github.com/CodeMaster482/minions-server/services/gateway/internal/watchlist/models"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/webhook"
+	"github.com/CodeMaster482/minions-server/services/gateway/internal/webhook/models"
+	"github.com/CodeMaster482/minions-server/services/gateway/pkg/cursor"
+	"github.com/CodeMaster482/minions-server/services/gateway/pkg/netguard"
+)
+
+var (
+	ErrNotFound         = errors.New("webhook not found")
+	ErrInvalidURL       = errors.New("invalid webhook url")
+	ErrInvalidEvents    = errors.New("unknown or empty webhook events")
+	ErrForbidden        = errors.New("global webhooks require admin role")
+	ErrTooManyWebhooks  = errors.New("webhook limit reached")
+	ErrInvalidCursor    = errors.New("invalid dead letters cursor")
+	ErrDeadLetterAbsent = errors.New("dead letter not found")
+)
+
+const maxURLLength = 2048 // Максимальная длина адреса подписки
+
+// Events события, на которые можно подписаться
+var Events = []string{
+	scanModels.EventRedVerdict,
+	scanModels.EventFileScanned,
+	watchlistModels.EventWatchChanged,
+}
+
+// Config параметры доставки
+type Config struct {
+	Timeout             time.Duration // таймаут одной попытки
+	MaxAttempts         int           // попыток до переноса в недоставленные
+	BaseBackoff         time.Duration // пауза перед второй попыткой, дальше удваивается
+	MaxBackoff          time.Duration
+	Workers             int  // одновременных доставок
+	MaxPerUser          int  // подписок на одного пользователя
+	AllowPrivateTargets bool // общие подписки могут вести во внутреннюю сеть
+}
+
+type Usecase struct {
+	repo   webhook.Repo
+	cfg    Config
+	logger *slog.Logger
+
+	// Пользовательские подписки всегда отправляются через клиент с запретом внутренних адресов
+	guardedClient *http.Client
+	globalClient  *http.Client
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+func New(repo webhook.Repo, cfg Config, logger *slog.Logger) *Usecase {
+	guardedClient := newClient(cfg.Timeout, netguard.Control)
+	globalClient := guardedClient
+	if cfg.AllowPrivateTargets {
+		globalClient = newClient(cfg.Timeout, nil)
+	}
+
+	return &Usecase{
+		repo:          repo,
+		cfg:           cfg,
+		logger:        logger,
+		guardedClient: guardedClient,
+		globalClient:  globalClient,
+	}
+}
+
+// List возвращает подписки пользователя, администратору - ещё и общие
+func (uc *Usecase) List(ctx context.Context, userID int, admin bool) ([]models.Webhook, error) {
+	return uc.repo.ListWebhooks(ctx, userID, admin)
+}
+
+// Create создаёт подписку и возвращает её вместе с ключом подписи. Ключ больше нигде не выдаётся
+func (uc *Usecase) Create(ctx context.Context, userID int, admin bool, req models.WebhookRequest) (*models.Webhook, error) {
+	if req.Global && !admin {
+		return nil, ErrForbidden
+	}
+
+	target, err := validateURL(req.URL, req.Global && uc.cfg.AllowPrivateTargets)
+	if err != nil {
+		return nil, err
+	}
+
+	events := slices.Clone(req.Events)
+	slices.Sort(events)
+	events = slices.Compact(events)
+	if len(events) == 0 {
+		return nil, ErrInvalidEvents
+	}
+	for _, event := range events {
+		if !slices.Contains(Events, event) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidEvents, event)
+		}
+	}
+
+	owner := userID
+	if req.Global {
+		owner = 0
+	} else {
+		count, err := uc.repo.CountWebhooks(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if count >= uc.cfg.MaxPerUser {
+			return nil, ErrTooManyWebhooks
+		}
+	}
+
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := uc.repo.CreateWebhook(ctx, models.Webhook{
+		UserID: owner,
+		URL:    target,
+		Secret: secret,
+		Events: events,
+	})
+	if err != nil {
+		return nil, err
+	}
+	created.Secret = secret
+
+	return created, nil
+}
+
+// Delete удаляет подписку вместе с очередью и недоставленными событиями
+func (uc *Usecase) Delete(ctx context.Context, userID int, admin bool, id int64) error {
+	deleted, err := uc.repo.DeleteWebhook(ctx, userID, admin, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// DeadLetters возвращает страницу недоставленных событий, новые первыми
+func (uc *Usecase) DeadLetters(ctx context.Context, userID int, admin bool, pageCursor string, limit int) (*models.DeadLetterPage, error) {
+	beforeID, err := cursor.Decode(pageCursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	// Одна лишняя запись показывает, есть ли следующая страница
+	letters, err := uc.repo.ListDeadLetters(ctx, userID, admin, beforeID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.DeadLetterPage{Items: letters}
+	if len(letters) > limit {
+		page.Items = letters[:limit]
+		page.NextCursor = cursor.Encode(page.Items[limit-1].ID)
+	}
+
+	return page, nil
+}
+
+// Redeliver возвращает недоставленное событие в очередь с полным набором попыток
+func (uc *Usecase) Redeliver(ctx context.Context, userID int, admin bool, id int64) error {
+	ok, err := uc.repo.Redeliver(ctx, userID, admin, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrDeadLetterAbsent
+	}
+
+	return nil
+}
+
+// Notify ставит событие в очередь подписок пользователя и общих подписок. Отправка выполняется
+// в Run, поэтому медленный получатель не задерживает проверку
+func (uc *Usecase) Notify(ctx context.Context, event string, userID int, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	eventID, err := randomHex(16)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(models.Payload{
+		ID:        eventID,
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		UserID:    userID,
+		Data:      raw,
+	})
+	if err != nil {
+		return err
+	}
+
+	queued, err := uc.repo.Enqueue(ctx, event, eventID, string(payload), userID)
+	if err != nil {
+		return err
+	}
+	if queued > 0 {
+		uc.logger.Debug("Webhook event enqueued",
+			slog.String("event", event),
+			slog.String("event_id", eventID),
+			slog.Int64("deliveries", queued),
+		)
+	}
+
+	return nil
+}
+
+// validateURL проверяет адрес подписки. Адреса-имена проверяются при каждом соединении, здесь
+// сразу отклоняются только IP-адреса внутренней сети
+func validateURL(raw string, allowPrivate bool) (string, error) {
+	if len(raw) > maxURLLength {
+		return "", ErrInvalidURL
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || u.User != nil {
+		return "", ErrInvalidURL
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !allowPrivate && !netguard.IsPublicIP(ip) {
+		return "", fmt.Errorf("%w: %w", ErrInvalidURL, netguard.ErrForbiddenAddress)
+	}
+	u.Fragment = ""
+
+	return u.String(), nil
+}
+
+// randomHex возвращает n случайных байт в hex
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
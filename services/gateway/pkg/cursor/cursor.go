@@ -0,0 +1,33 @@
+// Package cursor кодирует курсоры постраничной выдачи: id последней записи страницы в base64url
+package cursor
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+)
+
+var ErrInvalid = errors.New("invalid cursor")
+
+// Encode возвращает курсор следующей страницы, которая начинается после записи id
+func Encode(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+// Decode возвращает id, с которого начинается страница, 0 для первой страницы
+func Decode(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalid
+	}
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, ErrInvalid
+	}
+
+	return id, nil
+}
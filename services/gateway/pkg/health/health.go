@@ -0,0 +1,129 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/CodeMaster482/minions-server/common"
+)
+
+const checkTimeout = 3 * time.Second
+
+// Checker проверяет состояние одного компонента сервиса
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+type checkerFunc struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+func (c checkerFunc) Name() string                    { return c.name }
+func (c checkerFunc) Check(ctx context.Context) error { return c.check(ctx) }
+
+// CheckerFunc оборачивает функцию в Checker с заданным именем
+func CheckerFunc(name string, check func(ctx context.Context) error) Checker {
+	return checkerFunc{name: name, check: check}
+}
+
+// warning ошибка компонента, без которого сервис продолжает обслуживать запросы
+type warning struct {
+	err error
+}
+
+func (w warning) Error() string { return w.err.Error() }
+func (w warning) Unwrap() error { return w.err }
+
+// Warning помечает ошибку компонента как warning. Нужна компонентам, которые сами отличают
+// некритичный сбой от критичного, остальным достаточно NonCritical
+func Warning(err error) error {
+	return warning{err: err}
+}
+
+// IsWarning сообщает, что ошибка компонента помечена как warning
+func IsWarning(err error) bool {
+	return errors.As(err, &warning{})
+}
+
+// NonCritical оборачивает Checker так, что его ошибка попадает в ответ со статусом warning,
+// но не переводит весь сервис в degraded и не снимает экземпляр с балансировки
+func NonCritical(checker Checker) Checker {
+	return CheckerFunc(checker.Name(), func(ctx context.Context) error {
+		if err := checker.Check(ctx); err != nil {
+			return Warning(err)
+		}
+		return nil
+	})
+}
+
+// ComponentStatus состояние компонента
+type ComponentStatus struct {
+	Status string `json:"status" example:"ok"` // ok, warning или error
+	Error  string `json:"error,omitempty" example:"IAM token renewal failed"`
+}
+
+// Response ответ проверки здоровья
+type Response struct {
+	Status     string                     `json:"status" example:"ok"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+type Handler struct {
+	checkers []Checker
+	logger   *slog.Logger
+}
+
+func New(logger *slog.Logger, checkers ...Checker) *Handler {
+	return &Handler{
+		checkers: checkers,
+		logger:   logger,
+	}
+}
+
+// Health
+// @Summary Проверка состояния сервиса
+// @Description Проверяет зависимости шлюза (PostgreSQL, Redis, обновление IAM-токена Yandex) и возвращает их состояние.
+// @Description Компоненты со статусом warning (например, ошибка перезагрузки локальных правил) на общий статус не влияют.
+// @ID health
+// @Tags Health
+// @Produce json
+// @Success 200 {object} health.Response "Все компоненты работают"
+// @Failure 503 {object} health.Response "Хотя бы один компонент неисправен"
+// @Router /api/health [get]
+func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+
+	response := Response{
+		Status:     "ok",
+		Components: make(map[string]ComponentStatus, len(h.checkers)),
+	}
+
+	for _, checker := range h.checkers {
+		err := checker.Check(ctx)
+		if IsWarning(err) {
+			response.Components[checker.Name()] = ComponentStatus{Status: "warning", Error: err.Error()}
+			h.logger.Warn("Health check warning", slog.String("component", checker.Name()), slog.Any("error", err))
+			continue
+		}
+		if err != nil {
+			response.Status = "degraded"
+			response.Components[checker.Name()] = ComponentStatus{Status: "error", Error: err.Error()}
+			h.logger.Warn("Health check failed", slog.String("component", checker.Name()), slog.Any("error", err))
+			continue
+		}
+		response.Components[checker.Name()] = ComponentStatus{Status: "ok"}
+	}
+
+	statusCode := http.StatusOK
+	if response.Status != "ok" {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	common.RespondWithJSON(w, statusCode, response)
+}
@@ -52,9 +52,11 @@ func (m *Middleware) Logging(logger *slog.Logger) mux.MiddlewareFunc {
 func (m *Middleware) Cors(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
+		// Заголовки протокола tus должны быть видны клиенту в браузере
+		w.Header().Set("Access-Control-Expose-Headers", "Location, Upload-Offset, Upload-Length, Upload-Expires, Tus-Resumable, Tus-Version, Tus-Extension, Tus-Max-Size, Tus-Checksum-Algorithm")
 		if r.Method == http.MethodOptions {
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, x-api-key")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, HEAD, PATCH, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, x-api-key, Upload-Length, Upload-Offset, Upload-Metadata, Upload-Checksum, Tus-Resumable")
 			w.WriteHeader(http.StatusOK)
 			return
 		}
@@ -94,6 +96,24 @@ func (m *Middleware) RequireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// RequireRole пропускает только пользователей с указанной ролью. Роль записывается в сессию при входе,
+// поэтому после назначения роли пользователю нужно войти заново
+func (m *Middleware) RequireRole(role string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if m.SessionManager.GetInt(r.Context(), "user_id") == 0 {
+				common.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+			if m.SessionManager.GetString(r.Context(), "role") != role {
+				common.RespondWithError(w, http.StatusForbidden, "Forbidden")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // Пока не используем
 //func (m *Middleware) CSRFTokenMiddleware(next http.Handler) http.Handler {
 //	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
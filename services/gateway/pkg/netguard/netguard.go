@@ -0,0 +1,64 @@
+// Package netguard запрещает исходящие соединения с частными, локальными и служебными адресами,
+// чтобы адреса, присланные пользователями, нельзя было использовать для доступа во внутреннюю сеть
+package netguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+)
+
+var ErrForbiddenAddress = errors.New("remote address is private, loopback or reserved")
+
+// reservedPrefixes служебные диапазоны, которые не покрываются методами net.IP
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // "этот" узел
+	netip.MustParsePrefix("100.64.0.0/10"),  // CGNAT
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF Protocol Assignments
+	netip.MustParsePrefix("198.18.0.0/15"),  // тестирование производительности
+	netip.MustParsePrefix("240.0.0.0/4"),    // зарезервировано
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64, внутри может быть частный IPv4
+	netip.MustParsePrefix("64:ff9b:1::/48"), // локальный NAT64
+	netip.MustParsePrefix("2001:db8::/32"),  // документация
+	netip.MustParsePrefix("fec0::/10"),      // устаревшие site-local
+	netip.MustParsePrefix("100::/64"),       // discard
+	netip.MustParsePrefix("2002::/16"),      // 6to4, внутри может быть частный IPv4
+	netip.MustParsePrefix("255.255.255.255/32"),
+}
+
+// IsPublicIP проверяет, что адрес маршрутизируется в интернете: не частный, не локальный и не служебный
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Control проверяет адрес в net.Dialer уже после DNS, поэтому защита действует и для имён,
+// которые разрешаются во внутренние адреса, и после перенаправлений
+func Control(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package netguard
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "8.8.8.8", want: true},
+		{ip: "93.184.216.34", want: true},
+		{ip: "2606:4700:4700::1111", want: true},
+
+		{ip: "10.1.2.3"},
+		{ip: "172.16.0.1"},
+		{ip: "192.168.1.1"},
+		{ip: "127.0.0.1"},
+		{ip: "169.254.169.254"},
+		{ip: "0.0.0.0"},
+		{ip: "0.1.2.3"},
+		{ip: "100.64.0.1"},
+		{ip: "192.0.0.8"},
+		{ip: "198.18.0.1"},
+		{ip: "240.0.0.1"},
+		{ip: "255.255.255.255"},
+		{ip: "224.0.0.1"},
+		{ip: "::"},
+		{ip: "::1"},
+		{ip: "fe80::1"},
+		{ip: "fc00::1"},
+		{ip: "fec0::1"},
+		{ip: "ff02::1"},
+		{ip: "2001:db8::1"},
+		{ip: "100::1"},
+		// IPv4 внутри IPv6 проверяется как IPv4
+		{ip: "::ffff:127.0.0.1"},
+		{ip: "::ffff:10.0.0.1"},
+		{ip: "64:ff9b::a00:1"},
+		{ip: "2002:a00:1::1"},
+	}
+
+	for _, tt := range tests {
+		if got := IsPublicIP(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("IsPublicIP(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestControl(t *testing.T) {
+	tests := []struct {
+		address string
+		wantErr error
+	}{
+		{address: "8.8.8.8:443"},
+		{address: "[2606:4700:4700::1111]:80"},
+		{address: "127.0.0.1:80", wantErr: ErrForbiddenAddress},
+		{address: "[::1]:8080", wantErr: ErrForbiddenAddress},
+		{address: "169.254.169.254:80", wantErr: ErrForbiddenAddress},
+		// До Control доходит уже разрешённый адрес, имя означает, что проверка обойдена
+		{address: "localhost:80", wantErr: ErrForbiddenAddress},
+	}
+
+	for _, tt := range tests {
+		if err := Control("tcp", tt.address, nil); !errors.Is(err, tt.wantErr) {
+			t.Errorf("Control(%s) = %v, want %v", tt.address, err, tt.wantErr)
+		}
+	}
+
+	if err := Control("tcp", "8.8.8.8", nil); err == nil {
+		t.Error("Control without port: expected error")
+	}
+}
@@ -0,0 +1,196 @@
+// Package zipcrypto реализует традиционное шифрование PKWARE (ZipCrypto, APPNOTE.TXT, раздел 6.1).
+// Шифр слабый, но его открывает любой архиватор, поэтому он нужен для обмена образцами,
+// а не для секретности
+package zipcrypto
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+// ErrPassword пароль не подошёл к файлу, зашифрованному ZipCrypto
+var ErrPassword = errors.New("zipcrypto: wrong password")
+
+// headerSize размер заголовка шифрования перед данными файла
+const headerSize = 12
+
+// flagEncrypted бит общего назначения: файл зашифрован
+const flagEncrypted = 0x1
+
+// Encrypt упаковывает один файл в ZIP, зашифрованный ZipCrypto
+func Encrypt(name string, modified time.Time, content []byte, password string) ([]byte, error) {
+	var compressed bytes.Buffer
+	fw, err := flate.NewWriter(&compressed, flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fw.Write(content); err != nil {
+		return nil, err
+	}
+	if err := fw.Close(); err != nil {
+		return nil, err
+	}
+
+	crc := crc32.ChecksumIEEE(content)
+
+	// Заголовок шифрования: 11 случайных байт и старший байт CRC32 для проверки пароля
+	header := make([]byte, headerSize)
+	if _, err := rand.Read(header[:headerSize-1]); err != nil {
+		return nil, err
+	}
+	header[headerSize-1] = byte(crc >> 24)
+
+	k := newKeys(password)
+	encrypted := make([]byte, 0, headerSize+compressed.Len())
+	for _, b := range header {
+		encrypted = append(encrypted, k.encrypt(b))
+	}
+	for _, b := range compressed.Bytes() {
+		encrypted = append(encrypted, k.encrypt(b))
+	}
+
+	var out bytes.Buffer
+	zw := zip.NewWriter(&out)
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               name,
+		Method:             zip.Deflate,
+		Flags:              flagEncrypted,
+		Modified:           modified,
+		CRC32:              crc,
+		CompressedSize64:   uint64(len(encrypted)),
+		UncompressedSize64: uint64(len(content)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(encrypted); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+// Decrypt возвращает расшифрованное и распакованное содержимое файла, raw - результат f.OpenRaw.
+// Проверочный байт заголовка отсекает почти все неверные пароли сразу, остальные отсекает CRC32 в конце
+func Decrypt(f *zip.File, raw io.Reader, password string) (io.Reader, error) {
+	if f.Method != zip.Store && f.Method != zip.Deflate {
+		// AES (метод 99) и прочие методы не поддерживаем
+		return nil, fmt.Errorf("zipcrypto: unsupported compression method %d", f.Method)
+	}
+
+	var header [headerSize]byte
+	if _, err := io.ReadFull(raw, header[:]); err != nil {
+		return nil, err
+	}
+
+	k := newKeys(password)
+	for i := range header {
+		header[i] = k.decrypt(header[i])
+	}
+
+	// С флагом 0x8 CRC ещё не известен при записи заголовка, проверяется старший байт времени изменения
+	check := byte(f.CRC32 >> 24)
+	if f.Flags&0x8 != 0 {
+		check = byte(f.ModifiedTime >> 8)
+	}
+	if header[headerSize-1] != check {
+		return nil, ErrPassword
+	}
+
+	var r io.Reader = &decrypter{r: raw, keys: k}
+	if f.Method == zip.Deflate {
+		r = flate.NewReader(r)
+	}
+
+	return &crcCheckReader{r: r, hash: crc32.NewIEEE(), want: f.CRC32}, nil
+}
+
+// keys состояние шифра
+type keys [3]uint32
+
+func newKeys(password string) *keys {
+	k := &keys{0x12345678, 0x23456789, 0x34567890}
+	for i := 0; i < len(password); i++ {
+		k.update(password[i])
+	}
+
+	return k
+}
+
+func (k *keys) update(b byte) {
+	k[0] = crc32Update(k[0], b)
+	k[1] = (k[1]+(k[0]&0xFF))*134775813 + 1
+	k[2] = crc32Update(k[2], byte(k[1]>>24))
+}
+
+// stream очередной байт ключевого потока
+func (k *keys) stream() byte {
+	t := uint16(k[2] | 2)
+
+	return byte((uint32(t) * uint32(t^1)) >> 8)
+}
+
+func (k *keys) encrypt(b byte) byte {
+	cipher := b ^ k.stream()
+	k.update(b)
+
+	return cipher
+}
+
+func (k *keys) decrypt(b byte) byte {
+	plain := b ^ k.stream()
+	k.update(plain)
+
+	return plain
+}
+
+func crc32Update(crc uint32, b byte) uint32 {
+	return crc32.IEEETable[(crc^uint32(b))&0xFF] ^ (crc >> 8)
+}
+
+// decrypter расшифровывает поток данных после заголовка
+type decrypter struct {
+	r    io.Reader
+	keys *keys
+}
+
+func (d *decrypter) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	for i := 0; i < n; i++ {
+		p[i] = d.keys.decrypt(p[i])
+	}
+
+	return n, err
+}
+
+// crcCheckReader сверяет CRC32 распакованных данных в конце потока
+type crcCheckReader struct {
+	r    io.Reader
+	hash hash.Hash32
+	want uint32
+}
+
+func (c *crcCheckReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.hash.Write(p[:n])
+	if err == io.EOF && c.hash.Sum32() != c.want {
+		return n, ErrPassword
+	}
+	if err != nil && err != io.EOF {
+		// Неверный пароль обычно даёт мусор, который flate не может распаковать
+		return n, errors.Join(ErrPassword, err)
+	}
+
+	return n, err
+}